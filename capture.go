@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/korya/http-assert/httpassert"
+	"github.com/spf13/pflag"
+)
+
+// captureNameRE is a shell variable's name. --capture prints the value as an
+// assignment to one, and a placeholder needs a name that cannot run into the
+// text around it; the shell's rule serves both and is one a reader knows.
+var captureNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`) //nolint:forbidigo // a constant, not user input
+
+// parseCaptureSpec splits NAME=SOURCE. The first = separates them, since a jq
+// expression or a regexp is free to contain one.
+func parseCaptureSpec(v string) (name, source string, err error) {
+	name, source, found := strings.Cut(v, "=")
+	name = strings.TrimSpace(name)
+	if !found || source == "" {
+		return "", "", fmt.Errorf("expected NAME=..., got %q", v)
+	}
+	if !captureNameRE.MatchString(name) {
+		return "", "", fmt.Errorf("%q is not a valid name; use letters, digits and _, "+
+			"not starting with a digit", name)
+	}
+
+	return name, source, nil
+}
+
+func registerCaptureFlags(fs *pflag.FlagSet) {
+	fs.StringArray("capture", nil,
+		"Capture the value a jq expression yields, as NAME=EXPR; repeat to capture several")
+	fs.StringArray("capture-header", nil,
+		"Capture the value of a response header, as NAME=HEADER; repeat to capture several")
+	fs.StringArray("capture-body", nil,
+		"Capture what a regexp matches in the body (its first group, if any), as NAME=REGEXP")
+}
+
+// parseCaptureFlags builds the captures the flags ask for, in the order of the
+// flags and then of their values. A name may be captured once per request.
+func parseCaptureFlags(fs *pflag.FlagSet) ([]*httpassert.Capture, error) {
+	var res []*httpassert.Capture
+	seen := map[string]bool{}
+
+	for _, flag := range []string{"capture", "capture-header", "capture-body"} {
+		if !fs.Changed(flag) {
+			continue
+		}
+		vs, _ := fs.GetStringArray(flag)
+		for _, v := range vs {
+			name, source, err := parseCaptureSpec(v)
+			if err != nil {
+				return nil, invalidf("Invalid value for --%s flag: %s", flag, err)
+			}
+			if seen[name] {
+				return nil, invalidf("Invalid value for --%s flag: %s is already captured", flag, name)
+			}
+			seen[name] = true
+
+			var c *httpassert.Capture
+			switch flag {
+			case "capture":
+				c, err = httpassert.CaptureJQ(name, source)
+			case "capture-header":
+				c = httpassert.CaptureHeader(name, strings.TrimSpace(source))
+			case "capture-body":
+				c, err = httpassert.CaptureBody(name, source)
+			}
+			if err != nil {
+				return nil, invalidf("Invalid value for --%s flag: %s", flag, err)
+			}
+			res = append(res, c)
+		}
+	}
+
+	return res, nil
+}
+
+// captureFormats are the forms --capture-format prints the values in.
+var captureFormats = []string{"shell", "github"}
+
+// writeCaptures prints a NAME=value line for each capture, once the run has
+// passed. It is the only thing besides a report that reaches stdout (#34), so
+// that `eval "$(http-assert --capture ...)"` reads nothing else.
+//
+// "shell" single-quotes every value, which is the one quoting with no special
+// characters inside it: eval cannot be made to run anything, and a newline is
+// just a newline. "github" is the file $GITHUB_ENV reads, which takes no
+// quoting at all and has a heredoc form for multi-line values instead.
+func writeCaptures(w io.Writer, format string, captures []*httpassert.Capture) error {
+	var b strings.Builder
+	for _, c := range captures {
+		v, _ := c.Value()
+		switch format {
+		case "shell":
+			fmt.Fprintf(&b, "%s=%s\n", c.Name(), shellQuote(v))
+		case "github":
+			writeGitHubEnv(&b, c.Name(), v)
+		default:
+			return fmt.Errorf("unknown capture format %q", format)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// shellQuote quotes v for a POSIX shell. A single quote cannot appear inside
+// single quotes, so each one closes the string, is escaped, and reopens it.
+func shellQuote(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
+// writeGitHubEnv writes one variable in the format of $GITHUB_ENV.
+//
+// A value on one line is written as it is. Anything else uses the heredoc
+// form, and its delimiter is the one thing that must not be guessable: the
+// value comes from a response, and a response that could write the delimiter
+// followed by a line of its own would set a variable of its choosing -- PATH,
+// say -- for the rest of the job. Derived from the value's own hash, the
+// delimiter cannot occur in it, and a run prints the same bytes every time.
+func writeGitHubEnv(b *strings.Builder, name, v string) {
+	if !strings.ContainsAny(v, "\r\n") {
+		fmt.Fprintf(b, "%s=%s\n", name, v)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(v))
+	delim := "ghadelimiter_" + hex.EncodeToString(sum[:])
+	fmt.Fprintf(b, "%s<<%s\n%s\n%s\n", name, delim, v, delim)
+}
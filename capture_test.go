@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/korya/http-assert/httpassert"
+)
+
+func Test_parseCaptureSpec(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Spec   string
+		Name   string
+		Source string
+		Err    bool
+	}{
+		{Spec: "TOKEN=.access_token", Name: "TOKEN", Source: ".access_token"},
+		// The first = separates; the expression keeps the rest.
+		{Spec: `OK=.status == "ok"`, Name: "OK", Source: `.status == "ok"`},
+		{Spec: " id_2 =Location", Name: "id_2", Source: "Location"},
+		{Spec: "TOKEN", Err: true},
+		{Spec: "TOKEN=", Err: true},
+		{Spec: "=.token", Err: true},
+		{Spec: "2FA=.code", Err: true},
+		{Spec: "A-B=.x", Err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Spec, func(t *testing.T) {
+			name, source, err := parseCaptureSpec(tc.Spec)
+			if (err != nil) != tc.Err {
+				t.Fatalf("err = %v, want error: %t", err, tc.Err)
+			}
+			if name != tc.Name || source != tc.Source {
+				t.Errorf("got %q, %q; want %q, %q", name, source, tc.Name, tc.Source)
+			}
+		})
+	}
+}
+
+func Test_writeCaptures(t *testing.T) {
+	t.Parallel()
+
+	captured := func(name, value string) *httpassert.Capture {
+		c := httpassert.CaptureHeader(name, "X-Value")
+		res := &httpassert.Response{Response: &http.Response{Header: http.Header{"X-Value": {value}}}}
+		if f, err := c.Check(res); f != nil || err != nil {
+			t.Fatalf("cannot capture %s: %v, %v", name, f, err)
+		}
+		return c
+	}
+	captures := []*httpassert.Capture{
+		captured("VERSION", "v1.2.3"),
+		captured("QUOTE", "it's"),
+		captured("LINES", "one\ntwo"),
+	}
+
+	tests := []struct {
+		Format string
+		Want   string
+	}{
+		{"shell", "VERSION='v1.2.3'\nQUOTE='it'\\''s'\nLINES='one\ntwo'\n"},
+		{"github", "VERSION=v1.2.3\nQUOTE=it's\n" +
+			"LINES<<ghadelimiter_21066d108d5319ecb5a1fc4454f42ef22fc5f1c7df49c31d90294950e0ea8b2c\n" +
+			"one\ntwo\n" +
+			"ghadelimiter_21066d108d5319ecb5a1fc4454f42ef22fc5f1c7df49c31d90294950e0ea8b2c\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Format, func(t *testing.T) {
+			var b strings.Builder
+			if err := writeCaptures(&b, tc.Format, captures); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tc.Want {
+				t.Errorf("got\n%s\nwant\n%s", b.String(), tc.Want)
+			}
+		})
+	}
+}
@@ -232,6 +232,51 @@ func TestE2EHostMapping(t *testing.T) {
 		assertExit(t, run(t, nil, "--maphost", "*:80="+hostPort(), "--assert-ok", target), exitOK)
 	})
 
+	// Once parsed and matched apart, these were rejected by one and
+	// supported by the other.
+	for _, src := range []string{"*", "*:*", "mapped.invalid:*"} {
+		t.Run("wildcard "+src, func(t *testing.T) {
+			assertExit(t, run(t, nil, "--maphost", src+"="+hostPort(), "--assert-ok", target), exitOK)
+		})
+	}
+
+	t.Run("glob hostname", func(t *testing.T) {
+		assertExit(t, run(t, nil, "--maphost", "*.invalid:80="+hostPort(), "--assert-ok", target), exitOK)
+		assertExit(t, run(t, nil, "--maphost", "*.example:80="+hostPort(), "--assert-ok", target), exitTransportFail)
+	})
+
+	t.Run("CIDR block", func(t *testing.T) {
+		assertExit(t, run(t, nil, "--maphost", "192.0.2.0/24:80="+hostPort(),
+			"--assert-ok", "http://192.0.2.7/ok"), exitOK)
+	})
+
+	// Nothing listens on 127.0.0.1:9; the retry goes to the next destination.
+	t.Run("destinations rotate per attempt", func(t *testing.T) {
+		r := run(t, nil, "-v", "--retry", "1", "--retry-delay", "10ms",
+			"--maphost", "mapped.invalid:80=127.0.0.1:9,"+hostPort(), "--assert-ok", target)
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "(from "+hostPort()+")")
+
+		r = run(t, nil, "--maphost", "mapped.invalid:80=127.0.0.1:9,"+hostPort(), "--assert-ok", target)
+		assertExit(t, r, exitTransportFail)
+	})
+
+	t.Run("and through the environment", func(t *testing.T) {
+		r := run(t, map[string]string{"HTTP_ASSERT_MAPHOST": "decoy.invalid:80=127.0.0.1:9 *.invalid:80=127.0.0.1:9," + hostPort()},
+			"--retry", "1", "--retry-delay", "10ms", "--assert-ok", target)
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("a parse error says what is wrong", func(t *testing.T) {
+		r := run(t, nil, "--maphost", "garbage", "--assert-ok", target)
+		assertExit(t, r, exitBadInvocation)
+		assertContains(t, r, `Invalid value for --maphost flag: value "garbage" has no separator, =`)
+
+		r = run(t, nil, "--maphost", "a:80=b:1,c:80=d", "--assert-ok", target)
+		assertExit(t, r, exitBadInvocation)
+		assertContains(t, r, "holds more than one mapping")
+	})
+
 	t.Run("repeated mappings accumulate", func(t *testing.T) {
 		assertExit(t, run(t, nil,
 			"--maphost", "decoy.invalid:80=127.0.0.1:9",
@@ -0,0 +1,244 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestE2EAuth(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	netrc := filepath.Join(dir, "netrc")
+	if err := os.WriteFile(tokenFile, []byte("t0ken\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(netrc, []byte("machine 127.0.0.1 login alice password s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Env  map[string]string
+		Args []string
+	}{
+		{"-u", nil, []string{"-u", "alice:s3cret"}},
+		{"--oauth2-bearer", nil, []string{"--oauth2-bearer", "t0ken"}},
+		{"--oauth2-bearer @file", nil, []string{"--oauth2-bearer", "@" + tokenFile}},
+		{"--oauth2-bearer-env", map[string]string{"CI_TOKEN": "t0ken"}, []string{"--oauth2-bearer-env", "CI_TOKEN"}},
+		{"--netrc-file", nil, []string{"--netrc-file", netrc}},
+		{"--netrc", map[string]string{"NETRC": netrc}, []string{"--netrc"}},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, tc.Env, append(tc.Args, "--assert-ok", url("/auth"))...)
+			assertExit(t, r, exitOK)
+		})
+	}
+
+	t.Run("no credentials", func(t *testing.T) {
+		assertExit(t, run(t, nil, "--assert-ok", url("/auth")), exitAssertFail)
+	})
+
+	// The dump shows that credentials went, and not what they were.
+	t.Run("redacted in the dump", func(t *testing.T) {
+		r := run(t, nil, "-u", "alice:wrong", "--assert-ok", url("/auth"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "Authorization: Basic alice:xxxxx")
+		assertNotContains(t, r, "wrong")
+
+		r = run(t, nil, "--oauth2-bearer", "@"+tokenFile, "--assert-status", "201", url("/auth"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "Authorization: Bearer xxxxx")
+		assertNotContains(t, r, "t0ken")
+	})
+
+	t.Run("and in the log", func(t *testing.T) {
+		r := run(t, nil, "-v", "--assert-ok", strings.Replace(url("/auth"), "://", "://alice:s3cret@", 1))
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "alice:xxxxx@")
+		assertNotContains(t, r, "s3cret")
+	})
+
+	// With credentials given, the dump redacts whichever header was sent.
+	t.Run("-H wins, and is redacted too", func(t *testing.T) {
+		r := run(t, nil, "-u", "alice:s3cret", "-H", "Authorization: Bearer nope", "--assert-ok", url("/auth"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "Authorization: Bearer xxxxx")
+		assertNotContains(t, r, "nope")
+	})
+
+	t.Run("-H alone is redacted", func(t *testing.T) {
+		r := run(t, nil, "-H", "Authorization: Bearer nope", "-H", "Proxy-Authorization: Basic YWxpY2U6czNjcmV0",
+			"--assert-ok", url("/auth"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "Authorization: Bearer xxxxx")
+		assertContains(t, r, "Proxy-Authorization: Basic alice:xxxxx")
+		assertNotContains(t, r, "nope")
+	})
+
+	t.Run("in a suite", func(t *testing.T) {
+		suite := filepath.Join(t.TempDir(), "suite.yaml")
+		body := "requests:\n" +
+			"  - name: inherited\n    url: " + url("/auth") + "\n    assert-ok: true\n" +
+			"  - name: overridden\n    url: " + url("/auth") + "\n    user: alice:s3cret\n    assert-ok: true\n"
+		if err := os.WriteFile(suite, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		r := run(t, nil, "run", "--netrc-file", netrc, suite)
+		assertExit(t, r, exitOK)
+	})
+}
+
+// tokensIssued is how many tokens /token gave out under id.
+func tokensIssued(id string) int64 {
+	v, ok := flakyHits.Load("token-" + id)
+	if !ok {
+		return 0
+	}
+	return v.(*atomic.Int64).Load()
+}
+
+func TestE2EOAuth2(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	flags := func(query string) []string {
+		return []string{"--oauth2-token-url", url("/token?" + query),
+			"--oauth2-client-id", "client", "--oauth2-client-secret-file", secret}
+	}
+
+	t.Run("gets the token", func(t *testing.T) {
+		r := run(t, nil, append(flags("id=e2e-once"), "-v", "--oauth2-scope", "read write",
+			"--assert-ok", url("/auth"))...)
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "[.] OAuth 2.0 token from ")
+		if n := tokensIssued("e2e-once"); n != 1 {
+			t.Errorf("%d tokens issued, want 1", n)
+		}
+	})
+
+	t.Run("once for the run", func(t *testing.T) {
+		r := run(t, nil, append(flags("id=e2e-cached"), "--retry", "2", "--retry-delay", "0s",
+			"--assert-ok", url("/flaky?id=e2e-oauth2-cached&fail=2"))...)
+		assertExit(t, r, exitOK)
+
+		suite := filepath.Join(t.TempDir(), "suite.yaml")
+		body := "requests:\n" +
+			"  - name: a\n    url: " + url("/auth") + "\n    assert-ok: true\n" +
+			"  - name: b\n    url: " + url("/auth") + "\n    assert-ok: true\n"
+		if err := os.WriteFile(suite, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		assertExit(t, run(t, nil, append([]string{"run"}, append(flags("id=e2e-suite"), suite)...)...), exitOK)
+
+		if a, b := tokensIssued("e2e-cached"), tokensIssued("e2e-suite"); a != 1 || b != 1 {
+			t.Errorf("%d tokens issued for three attempts and %d for a suite of two, want 1 each", a, b)
+		}
+	})
+
+	// Eleven seconds is within the refresh margin by the second attempt.
+	t.Run("again when it expires", func(t *testing.T) {
+		r := run(t, nil, append(flags("id=e2e-expiry&expires=11"), "--retry", "1", "--retry-delay", "1500ms",
+			"--assert-ok", url("/flaky?id=e2e-oauth2-expiry&fail=1"))...)
+		assertExit(t, r, exitOK)
+		if n := tokensIssued("e2e-expiry"); n != 2 {
+			t.Errorf("%d tokens issued, want 2", n)
+		}
+	})
+
+	t.Run("a refusal is its own failure", func(t *testing.T) {
+		wrong := filepath.Join(t.TempDir(), "wrong")
+		if err := os.WriteFile(wrong, []byte("wrong"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		r := run(t, nil, "--oauth2-token-url", url("/token"), "--oauth2-client-id", "client",
+			"--oauth2-client-secret-file", wrong, "--assert-ok", url("/auth"))
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "failed to get an OAuth 2.0 token, so the request was not sent:\n"+
+			"- token endpoint "+url("/token")+": 401 Unauthorized: invalid_client")
+		assertNotContains(t, r, "wrong")
+	})
+}
+
+func TestE2EDigest(t *testing.T) {
+	t.Run("answers the challenge", func(t *testing.T) {
+		r := run(t, nil, "-v", "--digest", "-u", "alice:s3cret", "--assert-ok", url("/digest"))
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "401 Unauthorized (digest challenge)")
+	})
+
+	t.Run("from a .netrc", func(t *testing.T) {
+		netrc := filepath.Join(t.TempDir(), "netrc")
+		if err := os.WriteFile(netrc, []byte("machine 127.0.0.1 login alice password s3cret\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		assertExit(t, run(t, nil, "--digest", "--netrc-file", netrc, "--assert-ok", url("/digest")), exitOK)
+	})
+
+	// Basic credentials are not what the server asked for.
+	t.Run("without --digest", func(t *testing.T) {
+		assertExit(t, run(t, nil, "-u", "alice:s3cret", "--assert-ok", url("/digest")), exitAssertFail)
+	})
+
+	// The assertions see the response to the answer, and the dump says there
+	// was a question.
+	t.Run("a wrong password", func(t *testing.T) {
+		r := run(t, nil, "--digest", "-u", "alice:wrong", "--retry", "1", "--retry-delay", "0s",
+			"--assert-ok", url("/digest"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "gave up after 2 attempts")
+		assertContains(t, r, `Challenged: 401 Unauthorized, Digest realm="e2e"`)
+		assertContains(t, r, "-- answered as alice")
+		assertNotContains(t, r, "wrong")
+	})
+}
+
+func TestE2EAuthRejected(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Diag string
+	}{
+		{"-u without a password", []string{"-u", "alice"},
+			"Invalid value for --user flag: write user:password"},
+		{"-u and a token", []string{"-u", "alice:s3cret", "--oauth2-bearer", "t0ken"},
+			"Flags --user and --oauth2-bearer cannot be used together"},
+		{"two tokens", []string{"--oauth2-bearer", "t0ken", "--oauth2-bearer-env", "CI_TOKEN"},
+			"Flags --oauth2-bearer and --oauth2-bearer-env cannot be used together"},
+		{"a token file that is missing", []string{"--oauth2-bearer", "@" + missing},
+			"Invalid value for --oauth2-bearer flag: open " + missing},
+		{"an unset variable", []string{"--oauth2-bearer-env", "HTTP_ASSERT_E2E_UNSET"},
+			"Invalid value for --oauth2-bearer-env flag: $HTTP_ASSERT_E2E_UNSET is not set"},
+		{"a .netrc that is missing", []string{"--netrc-file", missing},
+			"Cannot read the .netrc file: open " + missing},
+		{"--digest without credentials", []string{"--digest"},
+			"Flag --digest needs credentials"},
+		{"--digest and a token", []string{"--digest", "--oauth2-bearer", "t0ken"},
+			"Flags --digest and --oauth2-bearer cannot be used together"},
+		{"--oauth2-token-url without a client", []string{"--oauth2-token-url", url("/token")},
+			"Flag --oauth2-token-url needs --oauth2-client-id"},
+		{"--oauth2-token-url without a secret", []string{"--oauth2-token-url", url("/token"), "--oauth2-client-id", "client"},
+			"Flag --oauth2-token-url needs --oauth2-client-secret-file"},
+		{"a client without --oauth2-token-url", []string{"--oauth2-client-id", "client"},
+			"need --oauth2-token-url"},
+		{"--oauth2-token-url and -u", []string{"--oauth2-token-url", url("/token"), "-u", "alice:s3cret"},
+			"Flag --oauth2-token-url cannot be used with --user, --oauth2-bearer or --digest"},
+		{"a token URL that is not one", []string{"--oauth2-token-url", "auth.example.com/token",
+			"--oauth2-client-id", "client", "--oauth2-client-secret-file", missing},
+			`Invalid value for --oauth2-token-url flag: "auth.example.com/token" is not an http:// or https:// URL`},
+		{"a secret file that is missing", []string{"--oauth2-token-url", url("/token"),
+			"--oauth2-client-id", "client", "--oauth2-client-secret-file", missing},
+			"Invalid value for --oauth2-client-secret-file flag: open " + missing},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, nil, append(tc.Args, "--assert-ok", url("/auth"))...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --capture hands a value from a passing check to the script that ran it. These
+// tests hand the output to a real shell, because "safe for eval" is a claim
+// about what a shell does with it, and nothing else can check that claim.
+
+// evalCaptured evals the output in sh and prints the named variable.
+func evalCaptured(t *testing.T, output, name string) string {
+	t.Helper()
+
+	out, err := exec.Command("sh", "-c", `eval "$1" && printf %s "$`+name+`"`, "sh", output).Output()
+	if err != nil {
+		t.Fatalf("sh cannot eval the output: %s\n%s", err, output)
+	}
+
+	return string(out)
+}
+
+func TestE2ECapture(t *testing.T) {
+	t.Run("a passing run prints the values", func(t *testing.T) {
+		r := run(t, nil, "--assert-ok", "--capture", "VERSION=.meta.version",
+			"--capture", "COUNT=.count", "--capture-header", "TYPE=Content-Type", url("/json"))
+		assertExit(t, r, exitOK)
+		if want := "VERSION='v1'\nCOUNT='2'\nTYPE='application/json'\n"; r.Stdout != want {
+			t.Errorf("stdout = %q, want %q", r.Stdout, want)
+		}
+	})
+
+	// The value comes from the server, so it is as hostile as a server can
+	// make it: quotes, a command substitution, and more than one line.
+	t.Run("eval reads the value back exactly", func(t *testing.T) {
+		hostile := "it's \"quoted\"\n$(echo pwned) `echo pwned`\n\\n; exit 1"
+		r := run(t, nil, "-d", hostile, "--capture", "BODY=.body", url("/echo"))
+		assertExit(t, r, exitOK)
+
+		if got := evalCaptured(t, r.Stdout, "BODY"); got != hostile {
+			t.Errorf("sh read back %q, want %q", got, hostile)
+		}
+	})
+
+	t.Run("--capture alone is a check", func(t *testing.T) {
+		r := run(t, nil, "--capture", "STATUS=.status", url("/ok"))
+		assertExit(t, r, exitOK)
+		assertNotContains(t, r, "No assertions specified")
+	})
+
+	t.Run("a failed run prints nothing", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"--assert-status", "201", "--capture", "STATUS=.status", url("/ok")},
+			{"--capture", "TOKEN=.token", url("/ok")},
+		} {
+			r := run(t, nil, args...)
+			assertExit(t, r, exitAssertFail)
+			if r.Stdout != "" {
+				t.Errorf("%v: stdout = %q, want nothing for a script to eval", args, r.Stdout)
+			}
+		}
+
+		r := run(t, nil, "--capture", "TOKEN=.token", url("/ok"))
+		assertContains(t, r, "capture[TOKEN]: jq[.token] yielded null")
+	})
+
+	t.Run("github writes what $GITHUB_ENV reads", func(t *testing.T) {
+		r := run(t, nil, "--capture-format", "github", "--capture-header", "TYPE=Content-Type",
+			"-d", "one\ntwo", "--capture", "BODY=.body", url("/echo"))
+		assertExit(t, r, exitOK)
+
+		// --capture before --capture-header, whatever the command line's
+		// order, as the assertions are.
+		lines := strings.Split(r.Stdout, "\n")
+		if len(lines) != 6 || !strings.HasPrefix(lines[0], "BODY<<ghadelimiter_") ||
+			lines[1] != "one" || lines[2] != "two" || lines[3] != strings.TrimPrefix(lines[0], "BODY<<") ||
+			lines[4] != "TYPE=application/json" {
+			t.Errorf("stdout is not in the $GITHUB_ENV format:\n%s", r.Stdout)
+		}
+	})
+
+	t.Run("a report file leaves stdout to the values", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.json")
+		r := run(t, nil, "--report", "json", "--report-file", path,
+			"--capture", "STATUS=.status", url("/ok"))
+		assertExit(t, r, exitOK)
+		if r.Stdout != "STATUS='success'\n" {
+			t.Errorf("stdout = %q", r.Stdout)
+		}
+	})
+
+	// #34: without --capture or --report, stdout stays empty, so an existing
+	// `> /dev/null` or `$(...)` around the binary sees what it always saw.
+	t.Run("nothing asked for, nothing printed", func(t *testing.T) {
+		r := run(t, nil, "--assert-ok", url("/ok"))
+		assertExit(t, r, exitOK)
+		if r.Stdout != "" {
+			t.Errorf("stdout = %q, want it empty", r.Stdout)
+		}
+	})
+}
+
+func TestE2ECaptureRejected(t *testing.T) {
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Diag string
+	}{
+		{"no name", []string{"--capture", ".version"}, "Invalid value for --capture flag"},
+		{"a name a shell would refuse", []string{"--capture-header", "X-ID=X-Id"}, `"X-ID" is not a valid name`},
+		{"a name twice", []string{"--capture", "V=.a", "--capture", "V=.b"}, "V is already captured"},
+		{"bad jq", []string{"--capture", "V=.["}, "Invalid value for --capture flag"},
+		{"bad regexp", []string{"--capture-body", "V=("}, "Invalid value for --capture-body flag"},
+		{"unknown format", []string{"--capture", "V=.a", "--capture-format", "fish"}, "possible values: shell, github"},
+		{"a format with nothing to print", []string{"--assert-ok", "--capture-format", "github"}, "pass --capture"},
+		{"a report on the same stdout", []string{"--capture", "V=.a", "--report", "json"}, "both write to stdout"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, nil, append(tc.Args, url("/ok"))...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+			assertNotContains(t, r, "[.]")
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package main_test
 
 import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -15,7 +21,7 @@ import (
 // the two sources is covered separately by TestE2EConfigPrecedence.
 //
 // EnvSupported records what the tool does *today*, not what it should do. Only
-// 6 of the 25 options honour the environment; the other 19 silently ignore it
+// 6 of the options honour the environment; every other one silently ignores it
 // (#54 proposes making this uniform). When that lands, flip those booleans --
 // the diff is the proof the change did what it claimed.
 
@@ -48,7 +54,30 @@ func configCases(t *testing.T) []configCase {
 	t.Helper()
 
 	okURL := url("/ok")
+	junitPath := filepath.Join(t.TempDir(), "junit.xml")
+	savedPath := filepath.Join(t.TempDir(), "response.http")
+	if err := os.WriteFile(savedPath, []byte("HTTP/1.1 200 OK\r\n\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
 	mapping := "mapped.invalid:80=" + hostPort()
+	_, port, _ := net.SplitHostPort(hostPort())
+	// The TLS server's own certificate is the CA that verifies it.
+	caPath := t.TempDir()
+	caFile := filepath.Join(caPath, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsSrv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h2URL, h2cURL := startHTTP2(t)
+	h3URL, _ := startHTTP3(t)
+	sock := startUnix(t)
+	proxy, authProxy := startProxy(t, false), startProxy(t, true)
+	netrcPath := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(netrcPath, []byte("default login alice password s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jarPath := filepath.Join(t.TempDir(), "cookies")
+	otherPin := "sha256//" + base64.StdEncoding.EncodeToString(make([]byte, 32))
 
 	// An assertion option is "applied" when the CLI stops complaining that it
 	// has nothing to check. Every assertion flag shares this shape.
@@ -60,6 +89,18 @@ func configCases(t *testing.T) []configCase {
 		}
 	}
 
+	// The parts of --oauth2-token-url are refused without it, which is how
+	// each shows it was read.
+	oauth2Part := func(flag, value, target string) configCase {
+		return configCase{
+			Flag: flag, CLI: []string{"--" + flag, value},
+			EnvKey: "HTTP_ASSERT_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_")), EnvVal: value,
+			EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", target},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "need --oauth2-token-url") },
+		}
+	}
+
 	return []configCase{
 		// ---- options wired through viper: the environment works ----
 		{
@@ -123,6 +164,24 @@ func configCases(t *testing.T) []configCase {
 			Base:    []string{"--assert-body-eq", "never-matches", url("/echo")},
 			Applied: func(r result) bool { return strings.Contains(r.Output(), "probe-payload") },
 		},
+		{
+			Flag: "data-binary", CLI: []string{"--data-binary", "probe-payload"},
+			EnvKey: "HTTP_ASSERT_DATA_BINARY", EnvVal: "probe-payload", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-body-eq", "never-matches", url("/echo")},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "probe-payload") },
+		},
+		{
+			Flag: "data-urlencode", CLI: []string{"--data-urlencode", "probe=a b"},
+			EnvKey: "HTTP_ASSERT_DATA_URLENCODE", EnvVal: "probe=a b", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-body-eq", "never-matches", url("/echo")},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "probe=a%20b") },
+		},
+		{
+			Flag: "data-file", CLI: []string{"--data-file", netrcPath},
+			EnvKey: "HTTP_ASSERT_DATA_FILE", EnvVal: netrcPath, EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-body-eq", "never-matches", url("/echo")},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "password s3cret") },
+		},
 		{
 			Flag: "location", CLI: []string{"-L"},
 			EnvKey: "HTTP_ASSERT_LOCATION", EnvVal: "true", EnvSupported: false, Issue: 54,
@@ -169,6 +228,293 @@ func configCases(t *testing.T) []configCase {
 			},
 		},
 
+		{
+			Flag: "report", CLI: []string{"--report", "json"},
+			EnvKey: "HTTP_ASSERT_REPORT", EnvVal: "json", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", okURL},
+			Applied: func(r result) bool { return strings.Contains(r.Stdout, `"exit_code"`) },
+		},
+		{
+			Flag: "report-file", CLI: []string{"--report-file", filepath.Join(t.TempDir(), "report.json")},
+			EnvKey: "HTTP_ASSERT_REPORT_FILE", EnvVal: "report.json", EnvSupported: false, Issue: 54,
+			Base: []string{"--report", "json", "--assert-ok", okURL},
+			// The report goes to stdout unless a file takes it.
+			Applied: func(r result) bool { return r.Stdout == "" },
+		},
+		{
+			Flag: "junit", CLI: []string{"--junit", junitPath},
+			EnvKey: "HTTP_ASSERT_JUNIT", EnvVal: junitPath, EnvSupported: false, Issue: 54,
+			Base: []string{"--assert-ok", okURL},
+			// Each sub-run starts from a missing file, so only the run that
+			// honoured the option leaves one behind.
+			Applied: func(result) bool {
+				_, err := os.Stat(junitPath)
+				_ = os.Remove(junitPath)
+				return err == nil
+			},
+		},
+
+		{
+			Flag: "from-response", CLI: []string{"--from-response", savedPath},
+			EnvKey: "HTTP_ASSERT_FROM_RESPONSE", EnvVal: savedPath, EnvSupported: false, Issue: 54,
+			// No URL: only the run that reads the file has a response at all.
+			Base:    []string{"--assert-ok"},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "(from "+savedPath+")") },
+		},
+		{
+			Flag: "capture", CLI: []string{"--capture", "STATUS=.status"},
+			EnvKey: "HTTP_ASSERT_CAPTURE", EnvVal: "STATUS=.status", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", okURL},
+			Applied: func(r result) bool { return r.Stdout == "STATUS='success'\n" },
+		},
+		{
+			Flag: "capture-header", CLI: []string{"--capture-header", "V=X-Api-Version"},
+			EnvKey: "HTTP_ASSERT_CAPTURE_HEADER", EnvVal: "V=X-Api-Version", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", okURL},
+			Applied: func(r result) bool { return r.Stdout == "V='v1'\n" },
+		},
+		{
+			Flag: "capture-body", CLI: []string{"--capture-body", `S="status":"(\w+)"`},
+			EnvKey: "HTTP_ASSERT_CAPTURE_BODY", EnvVal: `S="status":"(\w+)"`, EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", okURL},
+			Applied: func(r result) bool { return r.Stdout == "S='success'\n" },
+		},
+		{
+			Flag: "capture-format", CLI: []string{"--capture-format", "github"},
+			EnvKey: "HTTP_ASSERT_CAPTURE_FORMAT", EnvVal: "github", EnvSupported: false, Issue: 54,
+			Base:    []string{"--capture", "STATUS=.status", okURL},
+			Applied: func(r result) bool { return r.Stdout == "STATUS=success\n" },
+		},
+
+		{
+			Flag: "cert", CLI: []string{"--cert", savedPath},
+			EnvKey: "HTTP_ASSERT_CERT", EnvVal: savedPath, EnvSupported: false, Issue: 54,
+			Base: []string{"--assert-ok", tlsSrv.URL},
+			// Not a certificate, so applying it is refusing it.
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "Cannot load client certificate") },
+		},
+		{
+			Flag: "key", CLI: []string{"--key", savedPath},
+			EnvKey: "HTTP_ASSERT_KEY", EnvVal: savedPath, EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", tlsSrv.URL},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "Flag --key needs --cert") },
+		},
+		{
+			Flag: "cacert", CLI: []string{"--cacert", caFile},
+			EnvKey: "HTTP_ASSERT_CACERT", EnvVal: caFile, EnvSupported: false, Issue: 54,
+			Base: []string{"--assert-ok", tlsSrv.URL},
+			// Trusting the self-signed certificate is what lets the run pass.
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "capath", CLI: []string{"--capath", caPath},
+			EnvKey: "HTTP_ASSERT_CAPATH", EnvVal: caPath, EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", tlsSrv.URL},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "pinnedpubkey", CLI: []string{"--pinnedpubkey", otherPin},
+			EnvKey: "HTTP_ASSERT_PINNEDPUBKEY", EnvVal: otherPin, EnvSupported: false, Issue: 54,
+			Base:    []string{"-k", "--assert-ok", tlsSrv.URL},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "is not pinned") },
+		},
+		{
+			Flag: "tls-min", CLI: []string{"--tls-min", "1.3"},
+			EnvKey: "HTTP_ASSERT_TLS_MIN", EnvVal: "1.3", EnvSupported: false, Issue: 54,
+			Base:    []string{"-k", "--tls-max", "1.2", "--assert-ok", tlsSrv.URL},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "leave no version to negotiate") },
+		},
+		{
+			Flag: "tls-max", CLI: []string{"--tls-max", "1.2"},
+			EnvKey: "HTTP_ASSERT_TLS_MAX", EnvVal: "1.2", EnvSupported: false, Issue: 54,
+			Base:    []string{"-k", "--assert-tls-version", "1.2", tlsSrv.URL},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "ciphers", CLI: []string{"--ciphers", "TLS_RSA_WITH_AES_128_CBC_SHA"},
+			EnvKey: "HTTP_ASSERT_CIPHERS", EnvVal: "TLS_RSA_WITH_AES_128_CBC_SHA", EnvSupported: false, Issue: 54,
+			Base: []string{"-k", "--tls-max", "1.2", "--assert-ok", tlsSrv.URL},
+			// The server does not offer RSA key exchange, so the handshake fails.
+			Applied: func(r result) bool { return r.ExitCode == exitTransportFail },
+		},
+		{
+			Flag: "http2", CLI: []string{"--http2"},
+			EnvKey: "HTTP_ASSERT_HTTP2", EnvVal: "true", EnvSupported: false, Issue: 54,
+			Base:    []string{"-k", "--assert-proto", "HTTP/2.0", h2URL},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "http2-prior-knowledge", CLI: []string{"--http2-prior-knowledge"},
+			EnvKey: "HTTP_ASSERT_HTTP2_PRIOR_KNOWLEDGE", EnvVal: "true", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-proto", "HTTP/2.0", h2cURL},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "http3", CLI: []string{"--http3"},
+			EnvKey: "HTTP_ASSERT_HTTP3", EnvVal: "true", EnvSupported: false, Issue: 54,
+			Base:    []string{"-k", "--assert-proto", "HTTP/3.0", h3URL},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "unix-socket", CLI: []string{"--unix-socket", sock},
+			EnvKey: "HTTP_ASSERT_UNIX_SOCKET", EnvVal: sock, EnvSupported: false, Issue: 54,
+			Base: []string{"--assert-ok", "http://socket.invalid/"},
+			// Without the socket the host does not resolve at all.
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "resolve", CLI: []string{"--resolve", "resolved.invalid:" + port + ":127.0.0.1"},
+			EnvKey: "HTTP_ASSERT_RESOLVE", EnvVal: "resolved.invalid:" + port + ":127.0.0.1", EnvSupported: false, Issue: 54,
+			Base: []string{"--assert-ok", "http://resolved.invalid:" + port + "/ok"},
+			// Without the entry the host does not resolve at all.
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "dns-servers", CLI: []string{"--dns-servers", "127.0.0.1:1"},
+			EnvKey: "HTTP_ASSERT_DNS_SERVERS", EnvVal: "127.0.0.1:1", EnvSupported: false, Issue: 54,
+			Base: []string{"--assert-ok", "http://resolved.invalid/"},
+			// The lookup fails either way; the error names the server asked.
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "on 127.0.0.1:1") },
+		},
+		{
+			Flag: "ipv4", CLI: []string{"-4"},
+			EnvKey: "HTTP_ASSERT_IPV4", EnvVal: "true", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", "http://[::1]:1/"},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "no suitable address") },
+		},
+		{
+			Flag: "ipv6", CLI: []string{"-6"},
+			EnvKey: "HTTP_ASSERT_IPV6", EnvVal: "true", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", okURL},
+			Applied: func(r result) bool { return r.ExitCode == exitTransportFail },
+		},
+		{
+			Flag: "all-addresses", CLI: []string{"--all-addresses"},
+			EnvKey: "HTTP_ASSERT_ALL_ADDRESSES", EnvVal: "true", EnvSupported: false, Issue: 54,
+			Base: []string{"--resolve", "resolved.invalid:" + port + ":127.0.0.2,127.0.0.1",
+				"--assert-ok", "http://resolved.invalid:" + port + "/ok"},
+			// Nothing listens on the first address, which only a run against
+			// each of them notices.
+			Applied: func(r result) bool { return r.ExitCode == exitTransportFail },
+		},
+		{
+			Flag: "proxy", CLI: []string{"--proxy", proxy},
+			EnvKey: "HTTP_ASSERT_PROXY", EnvVal: proxy, EnvSupported: false, Issue: 54,
+			Base: []string{"--assert-ok", "http://proxied.invalid/"},
+			// Only the proxy answers for a host that does not resolve.
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "proxy-user", CLI: []string{"--proxy-user", "alice:s3cret"},
+			EnvKey: "HTTP_ASSERT_PROXY_USER", EnvVal: "alice:s3cret", EnvSupported: false, Issue: 54,
+			Base:    []string{"--proxy", authProxy, "--assert-ok", "http://proxied.invalid/"},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "noproxy", CLI: []string{"--noproxy", "127.0.0.1"},
+			EnvKey: "HTTP_ASSERT_NOPROXY", EnvVal: "127.0.0.1", EnvSupported: false, Issue: 54,
+			Base:    []string{"--proxy", "http://127.0.0.1:1", "--assert-ok", okURL},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "no-proxy-env", CLI: []string{"--no-proxy-env"},
+			EnvKey: "HTTP_ASSERT_NO_PROXY_ENV", EnvVal: "true", EnvSupported: false, Issue: 54,
+			Base: []string{"--from-response", savedPath, "--assert-ok"},
+			// With no proxy in the environment there is nothing for it to
+			// change; --from-response refusing it shows it was read.
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "--no-proxy-env") },
+		},
+		{
+			Flag: "user", CLI: []string{"-u", "alice:s3cret"},
+			EnvKey: "HTTP_ASSERT_USER", EnvVal: "alice:s3cret", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", url("/auth")},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "oauth2-bearer", CLI: []string{"--oauth2-bearer", "t0ken"},
+			EnvKey: "HTTP_ASSERT_OAUTH2_BEARER", EnvVal: "t0ken", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", url("/auth")},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "oauth2-bearer-env", CLI: []string{"--oauth2-bearer-env", "HTTP_ASSERT_E2E_UNSET"},
+			EnvKey: "HTTP_ASSERT_OAUTH2_BEARER_ENV", EnvVal: "HTTP_ASSERT_E2E_UNSET", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", url("/auth")},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "$HTTP_ASSERT_E2E_UNSET is not set") },
+		},
+		{
+			Flag: "netrc", CLI: []string{"--netrc"},
+			EnvKey: "HTTP_ASSERT_NETRC", EnvVal: "true", EnvSupported: false, Issue: 54,
+			Base: []string{"--from-response", savedPath, "--assert-ok"},
+			// Whether the run finds a ~/.netrc is the machine's business;
+			// --from-response refusing the flag shows it was read.
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "--netrc") },
+		},
+		{
+			Flag: "netrc-file", CLI: []string{"--netrc-file", netrcPath},
+			EnvKey: "HTTP_ASSERT_NETRC_FILE", EnvVal: netrcPath, EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", url("/auth")},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "digest", CLI: []string{"--digest"},
+			EnvKey: "HTTP_ASSERT_DIGEST", EnvVal: "true", EnvSupported: false, Issue: 54,
+			Base:    []string{"-u", "alice:s3cret", "--assert-ok", url("/digest")},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "oauth2-token-url", CLI: []string{"--oauth2-token-url", url("/token")},
+			EnvKey: "HTTP_ASSERT_OAUTH2_TOKEN_URL", EnvVal: url("/token"), EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", okURL},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "needs --oauth2-client-id") },
+		},
+		oauth2Part("oauth2-client-id", "client", okURL),
+		oauth2Part("oauth2-client-secret-file", netrcPath, okURL),
+		oauth2Part("oauth2-scope", "read", okURL),
+		{
+			Flag: "aws-sigv4", CLI: []string{"--aws-sigv4", "aws:us-east-1:s3"},
+			EnvKey: "HTTP_ASSERT_AWS_SIGV4", EnvVal: "aws:us-east-1:s3", EnvSupported: false, Issue: 54,
+			Base: []string{"--assert-ok", okURL},
+			// The run has no AWS credentials; being asked for them shows the
+			// flag was read.
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "Flag --aws-sigv4 needs") },
+		},
+		{
+			Flag: "hmac", CLI: []string{"--hmac", "sha256:X-Signature:X-Timestamp"},
+			EnvKey: "HTTP_ASSERT_HMAC", EnvVal: "sha256:X-Signature:X-Timestamp", EnvSupported: false, Issue: 54,
+			Base:    []string{"--hmac-key", "k3y", "--assert-ok", url("/hmac")},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "hmac-key", CLI: []string{"--hmac-key", "k3y"},
+			EnvKey: "HTTP_ASSERT_HMAC_KEY", EnvVal: "k3y", EnvSupported: false, Issue: 54,
+			Base:    []string{"--hmac", "sha256:X-Signature:X-Timestamp", "--assert-ok", url("/hmac")},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "hmac-key-env", CLI: []string{"--hmac-key-env", "HTTP_ASSERT_E2E_UNSET"},
+			EnvKey: "HTTP_ASSERT_HMAC_KEY_ENV", EnvVal: "HTTP_ASSERT_E2E_UNSET", EnvSupported: false, Issue: 54,
+			Base:    []string{"--hmac", "sha256:X-Signature", "--assert-ok", okURL},
+			Applied: func(r result) bool { return strings.Contains(r.Output(), "$HTTP_ASSERT_E2E_UNSET is not set") },
+		},
+		{
+			Flag: "cookie", CLI: []string{"-b", "session=s3ss10n"},
+			EnvKey: "HTTP_ASSERT_COOKIE", EnvVal: "session=s3ss10n", EnvSupported: false, Issue: 54,
+			Base:    []string{"--assert-ok", url("/dashboard")},
+			Applied: func(r result) bool { return r.ExitCode == exitOK },
+		},
+		{
+			Flag: "cookie-jar", CLI: []string{"-c", jarPath},
+			EnvKey: "HTTP_ASSERT_COOKIE_JAR", EnvVal: jarPath, EnvSupported: false, Issue: 54,
+			Base: []string{"--assert-ok", url("/login")},
+			// Taken away once read, so that each run has to write its own.
+			Applied: func(r result) bool {
+				data, err := os.ReadFile(jarPath)
+				_ = os.Remove(jarPath)
+				return err == nil && strings.Contains(string(data), "s3ss10n")
+			},
+		},
+
 		// ---- assertion options: all cobra-only ----
 		assertion("assert-ok", []string{"--assert-ok"}, "HTTP_ASSERT_ASSERT_OK", okURL),
 		assertion("assert-status", []string{"--assert-status", "200"}, "HTTP_ASSERT_ASSERT_STATUS", okURL),
@@ -181,6 +527,15 @@ func configCases(t *testing.T) []configCase {
 		assertion("assert-redirect", []string{"--assert-redirect", `https://.*\.com/.*`}, "HTTP_ASSERT_ASSERT_REDIRECT", url("/redirect")),
 		assertion("assert-redirect-eq", []string{"--assert-redirect-eq", "https://new-domain.com/path"}, "HTTP_ASSERT_ASSERT_REDIRECT_EQ", url("/redirect")),
 		assertion("assert-jq", []string{"--assert-jq", `.status == "success"`}, "HTTP_ASSERT_ASSERT_JQ", url("/json")),
+		assertion("assert-time", []string{"--assert-time", "total<10s"}, "HTTP_ASSERT_ASSERT_TIME", okURL),
+		assertion("assert-cert-expires-after", []string{"--assert-cert-expires-after", "14d"}, "HTTP_ASSERT_ASSERT_CERT_EXPIRES_AFTER", okURL),
+		assertion("assert-cert-san", []string{"--assert-cert-san", "example.com"}, "HTTP_ASSERT_ASSERT_CERT_SAN", okURL),
+		assertion("assert-cert-issuer", []string{"--assert-cert-issuer", "Acme"}, "HTTP_ASSERT_ASSERT_CERT_ISSUER", okURL),
+		assertion("assert-tls-version", []string{"--assert-tls-version", ">=1.2"}, "HTTP_ASSERT_ASSERT_TLS_VERSION", okURL),
+		assertion("assert-alpn", []string{"--assert-alpn", "http/1.1"}, "HTTP_ASSERT_ASSERT_ALPN", okURL),
+		assertion("assert-proto", []string{"--assert-proto", "HTTP/1.1"}, "HTTP_ASSERT_ASSERT_PROTO", okURL),
+		assertion("assert-alt-svc", []string{"--assert-alt-svc", "h3"}, "HTTP_ASSERT_ASSERT_ALT_SVC", okURL),
+		assertion("assert-cookie", []string{"--assert-cookie", "session=s3ss10n"}, "HTTP_ASSERT_ASSERT_COOKIE", url("/login")),
 	}
 }
 
@@ -188,7 +543,7 @@ func TestE2EConfigContract(t *testing.T) {
 	cases := configCases(t)
 
 	// Guards against an option being added to the CLI and quietly skipped here.
-	if got, want := len(cases), 25; got != want {
+	if got, want := len(cases), 82; got != want {
 		t.Fatalf("config matrix covers %d options, want %d -- add the new flag to configCases", got, want)
 	}
 
@@ -211,7 +566,8 @@ func TestE2EConfigContract(t *testing.T) {
 			t.Run("env", func(t *testing.T) {
 				if !tc.EnvSupported {
 					characterizes(t, tc.Issue,
-						tc.EnvKey+" is ignored; only 6 of 25 options read the environment")
+						fmt.Sprintf("%s is ignored; only 6 of %d options read the environment",
+							tc.EnvKey, len(cases)))
 				}
 				r := run(t, map[string]string{tc.EnvKey: tc.EnvVal}, tc.Base...)
 				if got := tc.Applied(r); got != tc.EnvSupported {
@@ -0,0 +1,109 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestE2ECookies(t *testing.T) {
+	// The session cookie /login sets is what /dashboard, two hops on, asks
+	// for; no flag is needed for one chain to carry its own cookies.
+	t.Run("through a redirect chain", func(t *testing.T) {
+		r := run(t, nil, "-L", "--assert-status", "200", "--assert-body-eq", "welcome",
+			"--assert-cookie", "session=^s3ss10n$", url("/login"))
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("-b name=value", func(t *testing.T) {
+		r := run(t, nil, "-b", "session=s3ss10n; theme=dark", "--assert-jq",
+			`.headers.Cookie == ["session=s3ss10n; theme=dark"]`, url("/echo"))
+		assertExit(t, r, exitOK)
+
+		r = run(t, nil, "-b", "session=s3ss10n", "--assert-ok", url("/dashboard"))
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("-c then -b", func(t *testing.T) {
+		jar := filepath.Join(t.TempDir(), "cookies.txt")
+		r := run(t, nil, "-b", jar, "-c", jar, "--assert-status", "302", url("/login"))
+		assertExit(t, r, exitOK)
+		data, err := os.ReadFile(jar)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "#HttpOnly_127.0.0.1\tFALSE\t/\tFALSE\t0\tsession\ts3ss10n") {
+			t.Fatalf("the jar holds:\n%s", data)
+		}
+
+		r = run(t, nil, "-b", jar, "--assert-ok", url("/dashboard"))
+		assertExit(t, r, exitOK)
+	})
+
+	// The cookies a failed run was left with are written all the same.
+	t.Run("-c on failure", func(t *testing.T) {
+		jar := filepath.Join(t.TempDir(), "cookies.txt")
+		r := run(t, nil, "-c", jar, "-L", "--assert-status", "201", url("/login"))
+		assertExit(t, r, exitAssertFail)
+		if data, err := os.ReadFile(jar); err != nil || !strings.Contains(string(data), "s3ss10n") {
+			t.Fatalf("the jar was not written: %v\n%s", err, data)
+		}
+	})
+
+	// One jar for the whole suite, so a login request signs in the rest.
+	t.Run("across a suite", func(t *testing.T) {
+		suite := writeSuite(t, `
+requests:
+  - name: login
+    url: `+url("/login")+`
+    assert-status: 302
+  - name: dashboard
+    url: `+url("/dashboard")+`
+    assert-status: 200
+`)
+		r := run(t, nil, "run", "-c", filepath.Join(t.TempDir(), "cookies.txt"), suite)
+		assertExit(t, r, exitOK)
+
+		r = run(t, nil, "run", suite)
+		assertExit(t, r, exitAssertFail)
+
+		// The login's session replaces the one -b gave, rather than the
+		// dashboard being sent both.
+		r = run(t, nil, "run", "-b", "session=stale", "-c", filepath.Join(t.TempDir(), "cookies.txt"), suite)
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("--assert-cookie fails", func(t *testing.T) {
+		r := run(t, nil, "--assert-cookie", "session=^other$", "--assert-cookie", "lang", url("/login"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, `cookie[session]: expected to match "^other$", got "s3ss10n"`)
+		assertContains(t, r, "cookie[lang]: expected to be set, got session")
+	})
+
+	t.Run("--assert-cookie asks what a response set", func(t *testing.T) {
+		// -b sends the cookie; the server never set it.
+		r := run(t, nil, "-b", "session=s3ss10n", "--assert-cookie", "session", url("/echo"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "cookie[session]: expected to be set, got none")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		bad := filepath.Join(t.TempDir(), "bad.txt")
+		if err := os.WriteFile(bad, []byte("example.com\tFALSE\t/\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range []struct {
+			args []string
+			want string
+		}{
+			{[]string{"-b", bad}, "want 7 tab-separated fields, got 3"},
+			{[]string{"--assert-cookie", "=x"}, "Invalid value for --assert-cookie flag"},
+			{[]string{"--assert-cookie", "session=("}, "Invalid value for --assert-cookie flag"},
+		} {
+			r := run(t, nil, append(tt.args, "--assert-ok", url("/ok"))...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tt.want)
+		}
+	})
+}
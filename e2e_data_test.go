@@ -0,0 +1,97 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestE2EData(t *testing.T) {
+	dir := t.TempDir()
+	// Big enough that the dump has to crop it.
+	fixture := `{"items":[` + strings.Repeat(`{"name":"item","tags":["a","b"]},`, 2000) + `{}]}`
+	fixturePath := filepath.Join(dir, "fixture.json")
+	if err := os.WriteFile(fixturePath, []byte(fixture), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	notePath := filepath.Join(dir, "note.txt")
+	if err := os.WriteFile(notePath, []byte("hello & goodbye\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The whole file goes with every attempt, the retried one included.
+	t.Run("--data-binary @file", func(t *testing.T) {
+		r := run(t, nil, "--data-binary", "@"+fixturePath, "-H", "Content-Type: application/json",
+			"--retry", "1", "--retry-delay", "0s",
+			"--assert-jq", `.method == "POST"`,
+			"--assert-jq", `.headers["Content-Type"] == ["application/json"]`,
+			"--assert-jq", `(.body | fromjson | .items | length) == 2001`,
+			url("/flaky-echo?id=data-binary&fail=1"))
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "retry 1/1")
+	})
+
+	t.Run("from stdin", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"--data-binary", "@-"},
+			{"--data-file", "-"},
+		} {
+			r := runWithStdin(t, "line one\nline two\n", nil, append(args,
+				"--assert-jq", `.body == "line one\nline two\n"`, url("/echo"))...)
+			assertExit(t, r, exitOK)
+		}
+	})
+
+	t.Run("--data-file", func(t *testing.T) {
+		r := run(t, nil, "--data-file", notePath, "-X", "PUT",
+			"--assert-jq", `.method == "PUT" and .body == "hello & goodbye\n"`, url("/echo"))
+		assertExit(t, r, exitOK)
+	})
+
+	// Without an @, --data-binary is the data itself, and -d never reads a
+	// file.
+	t.Run("literal", func(t *testing.T) {
+		r := run(t, nil, "--data-binary", "raw=1", "--assert-jq", `.body == "raw=1"`, url("/echo"))
+		assertExit(t, r, exitOK)
+
+		r = run(t, nil, "-d", "@"+notePath, "--assert-jq", `.body == "@`+notePath+`"`, url("/echo"))
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("--data-urlencode", func(t *testing.T) {
+		r := run(t, nil,
+			"--data-urlencode", "q=a b&c",
+			"--data-urlencode", "=x=y",
+			"--data-urlencode", "note@"+notePath,
+			"--data-urlencode", "plain text",
+			"--assert-jq", `.body == "q=a%20b%26c&x%3Dy&note=hello%20%26%20goodbye%0A&plain%20text"`,
+			"--assert-jq", `.headers["Content-Type"] == ["application/x-www-form-urlencoded"]`,
+			url("/echo"))
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("cropped in the dump", func(t *testing.T) {
+		r := run(t, nil, "--data-binary", "@"+fixturePath, "--assert-status", "201", url("/echo"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, `{"items":[{"name":"item"`)
+		assertContains(t, r, "<< Payload is cropped:")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, tt := range []struct {
+			args []string
+			want string
+		}{
+			{[]string{"-d", "a=1", "--data-binary", "b=2"}, "Flags --data and --data-binary cannot be used together"},
+			{[]string{"--data-urlencode", "a=1", "--data-file", notePath}, "Flags --data-file and --data-urlencode cannot be used together"},
+			{[]string{"--data-file", filepath.Join(dir, "missing.json")}, "Invalid value for --data-file flag"},
+			{[]string{"--data-binary", "@" + filepath.Join(dir, "missing.json")}, "Invalid value for --data-binary flag"},
+			{[]string{"--data-urlencode", "a@" + filepath.Join(dir, "missing.txt")}, "Invalid value for --data-urlencode flag"},
+		} {
+			r := run(t, nil, append(tt.args, "--assert-ok", url("/echo"))...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tt.want)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package main_test
+
+import (
+	"net"
+	"testing"
+)
+
+func TestE2EAllAddresses(t *testing.T) {
+	_, port, _ := net.SplitHostPort(hostPort())
+	backend := "http://backend.invalid:" + port
+
+	t.Run("every address passes", func(t *testing.T) {
+		r := run(t, nil, "--all-addresses", "--resolve", "backend.invalid:"+port+":127.0.0.1",
+			"--assert-ok", backend+"/ok")
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "[#] 127.0.0.1:"+port+" (1/1)")
+		assertContains(t, r, "[+] PASSED all 1 addresses")
+	})
+
+	// Nothing listens on 127.0.0.2: a plain run goes on to the next address
+	// and passes, and this one names the address that broke.
+	t.Run("one address fails", func(t *testing.T) {
+		r := run(t, nil, "--all-addresses", "--resolve", "backend.invalid:"+port+":127.0.0.2,127.0.0.1",
+			"--assert-ok", backend+"/ok")
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "1 of 2 addresses failed: 127.0.0.2:"+port)
+		assertNotContains(t, r, "PASSED all")
+	})
+
+	t.Run("an assertion fails everywhere", func(t *testing.T) {
+		r := run(t, nil, "--all-addresses", "--assert-body-eq", "nope", url("/ok"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "1 of 1 addresses failed: "+hostPort())
+		assertContains(t, r, "Connected to: "+hostPort())
+	})
+
+	t.Run("a host that does not resolve", func(t *testing.T) {
+		r := run(t, nil, "--all-addresses", "--assert-ok", "http://nowhere.invalid/")
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "cannot resolve nowhere.invalid:80")
+	})
+
+	t.Run("follows --maphost", func(t *testing.T) {
+		r := run(t, nil, "--all-addresses", "--maphost", "api.invalid:80="+hostPort(),
+			"--assert-ok", "http://api.invalid/ok")
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("rejected with", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"--capture", "S=.status", url("/json")},
+			{"--unix-socket", "/nonexistent.sock", "--assert-ok", url("/ok")},
+			{"--proxy", "http://127.0.0.1:1", "--assert-ok", url("/ok")},
+		} {
+			r := run(t, nil, append([]string{"--all-addresses"}, args...)...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, "Flags --all-addresses and "+args[0]+" cannot be used together")
+		}
+	})
+}
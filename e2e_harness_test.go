@@ -105,8 +105,16 @@ func (r result) Output() string { return r.Stdout + r.Stderr }
 func run(t *testing.T, env map[string]string, args ...string) result {
 	t.Helper()
 
+	return runWithStdin(t, "", env, args...)
+}
+
+// runWithStdin is run with stdin to read, for the options that take "-".
+func runWithStdin(t *testing.T, stdin string, env map[string]string, args ...string) result {
+	t.Helper()
+
 	cmd := exec.Command(binary(t), args...)
 	cmd.Env = testEnv(env)
+	cmd.Stdin = strings.NewReader(stdin)
 
 	var stdout, stderr strings.Builder
 	cmd.Stdout = &stdout
@@ -47,8 +47,8 @@ func TestE2EHelpDocumentsTheContract(t *testing.T) {
 		}
 	})
 
-	// Proxying works through the transport's environment defaults and has no
-	// flag, so --help is the only place a user could learn it exists (#46).
+	// Proxying through the environment needs no flag, so --help is the only
+	// place a user could learn it happens (#46).
 	t.Run("proxy support is discoverable", func(t *testing.T) {
 		for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"} {
 			if !strings.Contains(r.Output(), name) {
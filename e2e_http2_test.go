@@ -0,0 +1,125 @@
+package main_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// startHTTP2 starts two servers that speak HTTP/2 as well as HTTP/1.1: one
+// over TLS, negotiating h2 by ALPN, and one in the clear, taking h2c from a
+// client that starts with it.
+func startHTTP2(t *testing.T) (tlsURL, clearURL string) {
+	t.Helper()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write(w, http.StatusOK, []byte(r.Proto), nil)
+	})
+
+	h2 := httptest.NewUnstartedServer(handler)
+	h2.EnableHTTP2 = true
+	h2.Config.ErrorLog = log.New(io.Discard, "", 0)
+	h2.StartTLS()
+	t.Cleanup(h2.Close)
+
+	var p http.Protocols
+	p.SetHTTP1(true)
+	p.SetUnencryptedHTTP2(true)
+	h2c := httptest.NewUnstartedServer(handler)
+	h2c.Config.Protocols = &p
+	h2c.Start()
+	t.Cleanup(h2c.Close)
+
+	return h2.URL, h2c.URL
+}
+
+func TestE2EHTTP2(t *testing.T) {
+	h2, h2c := startHTTP2(t)
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Log  string
+	}{
+		// Opt-in: a server offering h2 is still spoken to in HTTP/1.1.
+		{"HTTP/1.1 by default", []string{"-k", "--assert-proto", "HTTP/1.1", "--assert-body-eq", "HTTP/1.1", h2},
+			"[:] HTTP/1.1 200 OK"},
+		{"--http2 negotiates h2", []string{"-k", "--http2", "--assert-proto", "HTTP/2", "--assert-alpn", "h2",
+			"--assert-body-eq", "HTTP/2.0", h2}, "[:] HTTP/2.0 200 OK"},
+		{"--http2 falls back to HTTP/1.1", []string{"-k", "--http2", "--assert-proto", "HTTP/1.1", tlsSrv.URL},
+			"[:] HTTP/1.1 200 OK"},
+		{"--http2 leaves http:// alone", []string{"--http2", "--assert-proto", "HTTP/1.1", h2c},
+			"[:] HTTP/1.1 200 OK"},
+		{"--http2-prior-knowledge speaks h2c", []string{"--http2-prior-knowledge", "--assert-proto", "HTTP/2.0", h2c},
+			"[:] HTTP/2.0 200 OK"},
+		{"--http2-prior-knowledge over TLS", []string{"-k", "--http2-prior-knowledge", "--assert-proto", "HTTP/2.0", h2},
+			"[:] HTTP/2.0 200 OK"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, nil, tc.Args...)
+			assertExit(t, r, exitOK)
+			assertContains(t, r, tc.Log)
+		})
+	}
+
+	t.Run("the wrong protocol fails the assertion", func(t *testing.T) {
+		r := run(t, nil, "-k", "--assert-proto", "HTTP/2.0", h2)
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "proto: expected HTTP/2.0, got HTTP/1.1")
+	})
+
+	// The server was promised HTTP/2 and cannot speak it: no exchange
+	// happened, which is the network's verdict rather than the response's.
+	t.Run("--http2-prior-knowledge to an HTTP/1.1 server", func(t *testing.T) {
+		r := run(t, nil, "-k", "--http2-prior-knowledge", "--assert-ok", tlsSrv.URL)
+		assertExit(t, r, exitTransportFail)
+	})
+
+	t.Run("a saved HTTP/2 response", func(t *testing.T) {
+		saved := filepath.Join(t.TempDir(), "response.http")
+		if err := os.WriteFile(saved, []byte("HTTP/2 200\r\ncontent-type: text/plain\r\n\r\nok"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		r := run(t, nil, "--from-response", saved, "--assert-proto", "HTTP/2")
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("in a suite", func(t *testing.T) {
+		suite := filepath.Join(t.TempDir(), "suite.yaml")
+		body := "requests:\n" +
+			"  - name: inherited\n    url: " + h2 + "\n    assert-proto: HTTP/2.0\n" +
+			"  - name: overridden\n    url: " + h2 + "\n    http2: false\n    assert-proto: HTTP/1.1\n"
+		if err := os.WriteFile(suite, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		r := run(t, nil, "run", "-k", "--http2", suite)
+		assertExit(t, r, exitOK)
+	})
+}
+
+func TestE2EHTTP2Rejected(t *testing.T) {
+	saved, _ := saveResponse(t, "/ok")
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Diag string
+	}{
+		{"both switches", []string{"--http2", "--http2-prior-knowledge", "--assert-ok", url("/ok")},
+			"Flags --http2 and --http2-prior-knowledge cannot be used together"},
+		{"an ALPN name for a version", []string{"--assert-proto", "h2", url("/ok")},
+			`Invalid value for --assert-proto flag: "h2" is not an HTTP version`},
+		{"with --from-response", []string{"--http2", "--from-response", saved, "--assert-ok"},
+			"Flags --from-response and --http2 cannot be used together"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, nil, tc.Args...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+}
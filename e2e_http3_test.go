@@ -0,0 +1,129 @@
+package main_test
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// startHTTP3 starts a server the way a CDN edge runs one: HTTP/1.1 over TLS on
+// a TCP port, advertising HTTP/3 on the same UDP port with Alt-Svc, and
+// answering there over QUIC. Both present httptest's certificate, so runs need
+// -k. The body names the protocol the request came over.
+func startHTTP3(t *testing.T) (url string, port int) {
+	t.Helper()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%d"; ma=86400`, port))
+		status := http.StatusOK
+		if strings.HasSuffix(r.URL.Path, "/500") {
+			status = http.StatusInternalServerError
+		}
+		write(w, status, []byte(r.Proto), nil)
+	})
+
+	tcp := httptest.NewUnstartedServer(handler)
+	tcp.Config.ErrorLog = log.New(io.Discard, "", 0)
+	tcp.StartTLS()
+	t.Cleanup(tcp.Close)
+	port = tcp.Listener.Addr().(*net.TCPAddr).Port
+
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		t.Fatalf("cannot take UDP port %d alongside TCP: %s", port, err)
+	}
+	h3 := &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(tcp.TLS.Clone()),
+	}
+	go func() { _ = h3.Serve(udp) }()
+	t.Cleanup(func() { _ = h3.Close(); _ = udp.Close() })
+
+	return tcp.URL, port
+}
+
+func TestE2EHTTP3(t *testing.T) {
+	h3URL, port := startHTTP3(t)
+
+	t.Run("Alt-Svc over TCP", func(t *testing.T) {
+		r := run(t, nil, "-k", "--assert-alt-svc", "h3", "--assert-alt-svc", fmt.Sprintf("h3=:%d", port),
+			"--assert-proto", "HTTP/1.1", h3URL)
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("the same URL over QUIC", func(t *testing.T) {
+		r := run(t, nil, "-k", "-v", "--http3", "--assert-proto", "HTTP/3.0", "--assert-body-eq", "HTTP/3.0",
+			"--assert-alpn", "h3", "--assert-tls-version", "1.3", h3URL)
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "[:] HTTP/3.0 200 OK")
+		assertContains(t, r, "[:] timing: ")
+	})
+
+	// The failure dump is the one every other failure gets.
+	t.Run("a failed assertion over QUIC", func(t *testing.T) {
+		r := run(t, nil, "-k", "--http3", "--assert-ok", h3URL+"/500")
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "ok: expected OK, got 500")
+		assertContains(t, r, "FAILED: GET "+h3URL+"/500")
+		assertContains(t, r, "HTTP/3.0 500 Internal Server Error")
+	})
+
+	t.Run("no Alt-Svc", func(t *testing.T) {
+		r := run(t, nil, "--assert-alt-svc", "h3", url("/ok"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "alt-svc[h3]: expected to be advertised, no Alt-Svc header")
+	})
+	t.Run("another port advertised", func(t *testing.T) {
+		r := run(t, nil, "-k", "--assert-alt-svc", "h3=:1", h3URL)
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, fmt.Sprintf(`alt-svc[h3=:1]: expected to be advertised, got "h3=\":%d\"; ma=86400"`, port))
+	})
+
+	// No QUIC on the other end is the outage --http3 exists to catch: it
+	// fails rather than falling back to the TCP port that still answers.
+	t.Run("a server with no QUIC", func(t *testing.T) {
+		r := run(t, nil, "-k", "-m", "2", "--http3", "--assert-ok", tlsSrv.URL)
+		assertExit(t, r, exitTransportFail)
+	})
+	t.Run("plain http://", func(t *testing.T) {
+		r := run(t, nil, "--http3", "--assert-ok", url("/ok"))
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "unsupported protocol scheme: http")
+	})
+}
+
+func TestE2EHTTP3Rejected(t *testing.T) {
+	saved, _ := saveResponse(t, "/ok")
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Diag string
+	}{
+		{"with --http2", []string{"--http3", "--http2"}, "Flags --http2 and --http3 cannot be used together"},
+		{"with --tls-max 1.2", []string{"--http3", "--tls-max", "1.2"},
+			"Flags --http3 and --tls-max 1.2 cannot be used together: QUIC runs over TLS 1.3 only"},
+		{"with --ciphers", []string{"--http3", "--ciphers", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			"Flags --http3 and --ciphers cannot be used together"},
+		{"an empty Alt-Svc", []string{"--assert-alt-svc", "=:443"}, "Invalid value for --assert-alt-svc flag"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, nil, append(tc.Args, "--assert-ok", tlsSrv.URL)...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+
+	t.Run("with --from-response", func(t *testing.T) {
+		r := run(t, nil, "--http3", "--from-response", saved, "--assert-ok")
+		assertExit(t, r, exitBadInvocation)
+		assertContains(t, r, "Flags --from-response and --http3 cannot be used together")
+	})
+}
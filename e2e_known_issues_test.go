@@ -22,31 +22,6 @@ func TestKnownIssue20AssertOkAcceptsRedirects(t *testing.T) {
 	}
 }
 
-// TestKnownIssue23WildcardMaphostUnreachable: hostMapping.Matches handles "*"
-// and "*:*", but the parser rejects both, so the branches are dead code.
-func TestKnownIssue23WildcardMaphostUnreachable(t *testing.T) {
-	for _, src := range []string{"*", "*:*"} {
-		t.Run(src, func(t *testing.T) {
-			characterizes(t, 23, "the parser rejects a wildcard the matcher supports")
-
-			r := run(t, nil, "--maphost", src+"="+hostPort(), "--assert-ok", "http://mapped.invalid/ok")
-			assertExit(t, r, exitBadInvocation)
-			assertContains(t, r, "Invalid value for --maphost flag")
-		})
-	}
-}
-
-// TestKnownIssue26MaphostErrorDiscarded: parseHostMappings produces a precise
-// message that mustParseHostMappings throws away.
-func TestKnownIssue26MaphostErrorDiscarded(t *testing.T) {
-	characterizes(t, 26, "the specific parse error is replaced by the raw flag slice")
-
-	r := run(t, nil, "--maphost", "garbage", "--assert-ok", url("/ok"))
-	assertExit(t, r, exitBadInvocation)
-	assertContains(t, r, "Invalid value for --maphost flag: [garbage]")
-	assertNotContains(t, r, "has no separator")
-}
-
 // TestKnownIssue31MaxTimeAcceptsNonPositive: values <= 0 reach http.Client.Timeout,
 // where they mean "no timeout" rather than being rejected.
 func TestKnownIssue31MaxTimeAcceptsNonPositive(t *testing.T) {
@@ -58,30 +33,6 @@ func TestKnownIssue31MaxTimeAcceptsNonPositive(t *testing.T) {
 	}
 }
 
-// TestKnownIssue34StdoutAlwaysEmpty: every byte goes to stderr, so redirecting
-// stdout captures nothing.
-func TestKnownIssue34StdoutAlwaysEmpty(t *testing.T) {
-	characterizes(t, 34, "results are on stderr; stdout is never written to")
-
-	for _, tc := range []struct {
-		name string
-		args []string
-	}{
-		{"on success", []string{"--assert-ok", url("/ok")}},
-		{"on failure", []string{"--assert-ok", url("/500")}},
-	} {
-		t.Run(tc.name, func(t *testing.T) {
-			r := run(t, nil, tc.args...)
-			if r.Stdout != "" {
-				t.Fatalf("stdout is no longer empty (%q); #34 is fixed -- update this test", r.Stdout)
-			}
-			if r.Stderr == "" {
-				t.Fatal("stderr unexpectedly empty")
-			}
-		})
-	}
-}
-
 // TestKnownIssueWarnLevelIsDead: nothing logs at warn, so --log-level warn is
 // byte-identical to --log-level error. The unused logWarn/logError helpers have
 // been removed; the LWarn level itself stays, because --log-level warn is part
@@ -0,0 +1,191 @@
+package main_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// mtlsFiles are the files a run against the mutual-TLS server is given, all
+// issued by one private CA that no system trusts.
+type mtlsFiles struct {
+	URL    string
+	CACert string // the CA, PEM
+	CAPath string // a directory holding the CA
+	Cert   string // the client's certificate, PEM
+	Key    string // its key, PEM
+	P12    string // both, PKCS#12 with the password "s3cret"
+}
+
+// startMutualTLS starts a server that only answers a client presenting a
+// certificate its CA signed, and writes out everything a client needs to.
+func startMutualTLS(t *testing.T) mtlsFiles {
+	t.Helper()
+
+	issue := func(tmpl, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		tmpl.NotBefore, tmpl.NotAfter = time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+	ca, caKey := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "Private CA"},
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}, nil, nil)
+	srvCert, srvKey := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(2), IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	client, clientKey := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "ci"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write(w, http.StatusOK, []byte("hello "+r.TLS.PeerCertificates[0].Subject.CommonName), nil)
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{srvCert.Raw}, PrivateKey: srvKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	save := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p12, err := pkcs12.Modern.Encode(clientKey, client, nil, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	if err := os.Mkdir(filepath.Join(dir, "cas"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	save(filepath.Join("cas", "private.pem"), caPEM)
+
+	return mtlsFiles{
+		URL:    srv.URL,
+		CACert: save("ca.pem", caPEM),
+		CAPath: filepath.Join(dir, "cas"),
+		Cert:   save("client.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: client.Raw})),
+		Key:    save("client.key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+		P12:    save("client.p12", p12),
+	}
+}
+
+func TestE2EMutualTLS(t *testing.T) {
+	f := startMutualTLS(t)
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+	}{
+		{"PEM certificate and key", []string{"--cert", f.Cert, "--key", f.Key, "--cacert", f.CACert}},
+		{"PKCS#12 with its password", []string{"--cert", f.P12 + ":s3cret", "--cacert", f.CACert}},
+		{"a CA directory", []string{"--cert", f.Cert, "--key", f.Key, "--capath", f.CAPath}},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, nil, append(tc.Args, "--assert-body-eq", "hello ci", f.URL)...)
+			assertExit(t, r, exitOK)
+		})
+	}
+
+	// Both halves are needed, and a missing one is the server's or the
+	// network's verdict, reached with a request: exit 92, not 71.
+	t.Run("without the certificate", func(t *testing.T) {
+		r := run(t, nil, "--cacert", f.CACert, "--assert-ok", f.URL)
+		assertExit(t, r, exitTransportFail)
+	})
+	t.Run("without the CA", func(t *testing.T) {
+		r := run(t, nil, "--cert", f.Cert, "--key", f.Key, "--assert-ok", f.URL)
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "certificate signed by unknown authority")
+	})
+
+	t.Run("in a suite", func(t *testing.T) {
+		suite := filepath.Join(t.TempDir(), "suite.yaml")
+		body := "requests:\n  - name: mtls\n    url: " + f.URL + "\n    cert: " + f.Cert +
+			"\n    key: " + f.Key + "\n    assert-body-eq: hello ci\n"
+		if err := os.WriteFile(suite, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		r := run(t, nil, "run", "--cacert", f.CACert, suite)
+		assertExit(t, r, exitOK)
+	})
+}
+
+// A file that cannot be loaded is a mistake in the invocation, found before
+// any request is made.
+func TestE2EMutualTLSRejected(t *testing.T) {
+	f := startMutualTLS(t)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Diag string
+	}{
+		{"missing certificate", []string{"--cert", missing}, "Cannot load client certificate: open " + missing},
+		{"key without certificate", []string{"--key", f.Key}, "Flag --key needs --cert"},
+		{"certificate without key", []string{"--cert", f.Cert}, "give the key file too"},
+		{"wrong password", []string{"--cert", f.P12 + ":guess"}, "decryption password incorrect"},
+		{"CA file with no certificates", []string{"--cacert", f.Key}, "Cannot load CA certificates: " + f.Key + ": no certificates in it"},
+		{"empty CA directory", []string{"--capath", t.TempDir()}, "no PEM certificates in it"},
+		{"with --from-response", []string{"--cacert", f.CACert, "--from-response", f.Key},
+			"Flags --from-response and --cacert cannot be used together"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			args := append(tc.Args, "--assert-ok")
+			if tc.Args[len(tc.Args)-2] != "--from-response" {
+				args = append(args, f.URL)
+			}
+			r := run(t, nil, args...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+			assertNotContains(t, r, "[.]")
+		})
+	}
+}
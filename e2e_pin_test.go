@@ -0,0 +1,95 @@
+package main_test
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tlsSrvPin is --pinnedpubkey's spelling of the TLS test server's key.
+func tlsSrvPin() string {
+	sum := sha256.Sum256(tlsSrv.Certificate().RawSubjectPublicKeyInfo)
+	return "sha256//" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestE2EPinnedPubKey(t *testing.T) {
+	wrong := "sha256//" + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	t.Run("the server's key", func(t *testing.T) {
+		r := run(t, nil, "-k", "--pinnedpubkey", tlsSrvPin(), "--assert-ok", tlsSrv.URL)
+		assertExit(t, r, exitOK)
+	})
+	t.Run("one of several", func(t *testing.T) {
+		r := run(t, nil, "-k", "--pinnedpubkey", wrong+";"+tlsSrvPin(), "--assert-ok", tlsSrv.URL)
+		assertExit(t, r, exitOK)
+	})
+	t.Run("a public key file", func(t *testing.T) {
+		der, err := x509.MarshalPKIXPublicKey(tlsSrv.Certificate().PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(t.TempDir(), "server.pub")
+		if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		r := run(t, nil, "-k", "--pinnedpubkey", file, "--assert-ok", tlsSrv.URL)
+		assertExit(t, r, exitOK)
+	})
+
+	// A key that is not pinned is the network's verdict, like a failed
+	// handshake, and the report names the key that was seen.
+	t.Run("another key", func(t *testing.T) {
+		r := run(t, nil, "-k", "--pinnedpubkey", wrong, "--assert-ok", tlsSrv.URL)
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "the server's public key is not pinned")
+		assertContains(t, r, "- observed "+tlsSrvPin())
+		assertContains(t, r, "- pinned   "+wrong)
+	})
+}
+
+func TestE2ETLSPolicy(t *testing.T) {
+	t.Run("tls-max caps the version", func(t *testing.T) {
+		r := run(t, nil, "-k", "--tls-max", "1.2", "--assert-tls-version", "1.2", tlsSrv.URL)
+		assertExit(t, r, exitOK)
+	})
+	t.Run("tls-min", func(t *testing.T) {
+		r := run(t, nil, "-k", "--tls-min", "1.3", "--assert-tls-version", "1.3", tlsSrv.URL)
+		assertExit(t, r, exitOK)
+	})
+	t.Run("a suite the server offers", func(t *testing.T) {
+		r := run(t, nil, "-k", "--tls-max", "1.2",
+			"--ciphers", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", "--assert-ok", tlsSrv.URL)
+		assertExit(t, r, exitOK)
+	})
+	t.Run("a suite the server refuses", func(t *testing.T) {
+		r := run(t, nil, "-k", "--tls-max", "1.2",
+			"--ciphers", "TLS_RSA_WITH_AES_128_CBC_SHA", "--assert-ok", tlsSrv.URL)
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "handshake failure")
+	})
+}
+
+func TestE2ETLSPolicyRejected(t *testing.T) {
+	for _, tc := range []struct {
+		Args []string
+		Diag string
+	}{
+		{[]string{"--pinnedpubkey", "sha256//nope"}, "Invalid value for --pinnedpubkey flag"},
+		{[]string{"--pinnedpubkey", filepath.Join(t.TempDir(), "missing.pub")}, "a pin is sha256//<base64> or the name of a public key file"},
+		{[]string{"--tls-min", "1.4"}, "Invalid value for --tls-min flag"},
+		{[]string{"--tls-max", "ssl3"}, "Invalid value for --tls-max flag"},
+		{[]string{"--tls-min", "1.3", "--tls-max", "1.2"}, "Flags --tls-min 1.3 and --tls-max 1.2 leave no version to negotiate"},
+		{[]string{"--ciphers", "ECDHE-RSA-AES128-GCM-SHA256"}, "write its IANA name"},
+		{[]string{"--ciphers", "TLS_AES_128_GCM_SHA256"}, "is a TLS 1.3 suite"},
+	} {
+		t.Run(tc.Args[len(tc.Args)-1], func(t *testing.T) {
+			r := run(t, nil, append(tc.Args, "-k", "--assert-ok", tlsSrv.URL)...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// startProxy starts a forward proxy that answers every request itself, with
+// the absolute URL it was asked for, so a body says whether the request went
+// through it. It wants alice's credentials when asked to.
+func startProxy(t *testing.T, auth bool) string {
+	t.Helper()
+
+	p := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth && r.Header.Get("Proxy-Authorization") != "Basic YWxpY2U6czNjcmV0" {
+			write(w, http.StatusProxyAuthRequired, nil, http.Header{"Proxy-Authenticate": {"Basic"}})
+			return
+		}
+		write(w, http.StatusOK, []byte("via-proxy "+r.URL.String()), nil)
+	}))
+	t.Cleanup(p.Close)
+
+	return p.URL
+}
+
+func TestE2EProxy(t *testing.T) {
+	proxy := startProxy(t, false)
+	authProxy := startProxy(t, true)
+
+	t.Run("--proxy carries the request", func(t *testing.T) {
+		r := run(t, nil, "-v", "--proxy", proxy, "--assert-body-eq", "via-proxy http://origin.invalid/ok",
+			"http://origin.invalid/ok")
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "(via proxy "+proxy+")")
+	})
+
+	t.Run("--proxy-user authenticates, and is redacted", func(t *testing.T) {
+		r := run(t, nil, "-v", "--proxy", authProxy, "--proxy-user", "alice:s3cret", "--assert-ok",
+			"http://origin.invalid/ok")
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "alice:xxxxx@")
+		assertNotContains(t, r, "s3cret")
+
+		r = run(t, nil, "--proxy", authProxy, "--assert-ok", "http://origin.invalid/ok")
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "407 Proxy Authentication Required")
+	})
+
+	t.Run("--noproxy goes direct", func(t *testing.T) {
+		r := run(t, nil, "-v", "--proxy", proxy, "--noproxy", "example.com, 127.0.0.0/8",
+			"--assert-body-eq", `{"status":"success","users":[]}`, url("/ok"))
+		assertExit(t, r, exitOK)
+		assertNotContains(t, r, "via proxy")
+	})
+
+	t.Run("in a suite", func(t *testing.T) {
+		suite := filepath.Join(t.TempDir(), "suite.yaml")
+		body := "requests:\n" +
+			"  - name: inherited\n    url: http://origin.invalid/a\n" +
+			"    assert-body-eq: via-proxy http://origin.invalid/a\n" +
+			"  - name: overridden\n    url: " + url("/ok") + "\n    noproxy: \"*\"\n    assert-ok: true\n"
+		if err := os.WriteFile(suite, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		r := run(t, nil, "run", "--proxy", proxy, suite)
+		assertExit(t, r, exitOK)
+	})
+}
+
+// A stale proxy variable on a build agent is the case the flags exist for.
+// The environment's proxy is never used for a loopback host, so the request
+// names one that --maphost sends to the test server.
+func TestE2EProxyEnv(t *testing.T) {
+	env := map[string]string{"HTTP_PROXY": "http://127.0.0.1:1"}
+	base := []string{"--maphost", "stale.invalid:80=" + hostPort(), "--assert-ok", "http://stale.invalid/ok"}
+
+	t.Run("is honoured by default", func(t *testing.T) {
+		r := run(t, env, base...)
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "Proxy: http://127.0.0.1:1")
+	})
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+	}{
+		{"--no-proxy-env", []string{"--no-proxy-env"}},
+		{"an empty --proxy", []string{"--proxy", ""}},
+		{"--noproxy", []string{"--noproxy", "stale.invalid"}},
+	} {
+		t.Run("is ignored with "+tc.Name, func(t *testing.T) {
+			r := run(t, env, append(tc.Args, base...)...)
+			assertExit(t, r, exitOK)
+		})
+	}
+
+	t.Run("gives way to --proxy", func(t *testing.T) {
+		r := run(t, env, "--proxy", startProxy(t, false), "--assert-body-eq",
+			"via-proxy http://stale.invalid/ok", "http://stale.invalid/ok")
+		assertExit(t, r, exitOK)
+	})
+}
+
+func TestE2EProxyRejected(t *testing.T) {
+	saved, _ := saveResponse(t, "/ok")
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Diag string
+	}{
+		{"an unknown scheme", []string{"--proxy", "socks4://proxy.corp", "--assert-ok", url("/ok")},
+			`Invalid value for --proxy flag: unsupported proxy scheme "socks4"`},
+		{"--proxy-user alone", []string{"--proxy-user", "alice:s3cret", "--assert-ok", url("/ok")},
+			"Flag --proxy-user needs --proxy"},
+		{"--proxy-user without a password", []string{"--proxy", "proxy.corp", "--proxy-user", "alice",
+			"--assert-ok", url("/ok")}, "Invalid value for --proxy-user flag: write user:password"},
+		{"with --unix-socket", []string{"--proxy", "proxy.corp", "--unix-socket", "/tmp/d.sock",
+			"--assert-ok", url("/ok")}, "Flags --proxy and --unix-socket cannot be used together"},
+		{"with --http3", []string{"--proxy", "proxy.corp", "--http3", "--assert-ok", "https://localhost/"},
+			"Flags --proxy and --http3 cannot be used together"},
+		{"with --from-response", []string{"--no-proxy-env", "--from-response", saved, "--assert-ok"},
+			"Flags --from-response and --no-proxy-env cannot be used together"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, nil, tc.Args...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package main_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --report json is the contract a dashboard reads instead of the dump. These
+// tests read it the way such a consumer would -- as JSON off stdout or out of a
+// file -- and never look at the prose on stderr.
+
+// jsonReport decodes the parts of the report a consumer branches on.
+type jsonReport struct {
+	Verdict      string `json:"verdict"`
+	ExitCode     int    `json:"exit_code"`
+	ExitCategory string `json:"exit_category"`
+	Assertions   []struct {
+		Kind    string `json:"kind"`
+		Target  string `json:"target"`
+		Verdict string `json:"verdict"`
+		Error   string `json:"error"`
+		Failure *struct {
+			Expected any `json:"expected"`
+			Actual   any `json:"actual"`
+		} `json:"failure"`
+	} `json:"assertions"`
+	Attempts []struct {
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	} `json:"attempts"`
+}
+
+func decodeJSONReport(t *testing.T, raw string) jsonReport {
+	t.Helper()
+
+	var rep jsonReport
+	if err := json.Unmarshal([]byte(raw), &rep); err != nil {
+		t.Fatalf("the report is not JSON: %s\n%s", err, raw)
+	}
+
+	return rep
+}
+
+func TestE2EReportJSON(t *testing.T) {
+	t.Run("a pass is reported on stdout", func(t *testing.T) {
+		r := run(t, nil, "--report", "json", "--assert-ok", "--assert-header-eq", "X-Api-Version: v1", url("/ok"))
+		assertExit(t, r, exitOK)
+
+		rep := decodeJSONReport(t, r.Stdout)
+		if rep.Verdict != "passed" || rep.ExitCode != exitOK || rep.ExitCategory != "ok" {
+			t.Fatalf("verdict/exit = %s/%d/%s, want passed/0/ok", rep.Verdict, rep.ExitCode, rep.ExitCategory)
+		}
+		if len(rep.Assertions) != 2 || rep.Assertions[1].Target != "X-Api-Version" {
+			t.Fatalf("assertions = %+v, want ok then header[X-Api-Version]", rep.Assertions)
+		}
+	})
+
+	t.Run("the exit code in the report is the process exit code", func(t *testing.T) {
+		for _, tc := range []struct {
+			Name     string
+			Args     []string
+			Code     int
+			Category string
+		}{
+			{"assertion", []string{"--assert-ok", url("/500")}, exitAssertFail, "assertion"},
+			{"transport", []string{"--assert-ok", "http://127.0.0.1:9/"}, exitTransportFail, "transport"},
+		} {
+			t.Run(tc.Name, func(t *testing.T) {
+				r := run(t, nil, append([]string{"--report", "json"}, tc.Args...)...)
+				assertExit(t, r, tc.Code)
+
+				rep := decodeJSONReport(t, r.Stdout)
+				if rep.ExitCode != tc.Code || rep.ExitCategory != tc.Category || rep.Verdict != "failed" {
+					t.Fatalf("report says %d/%s/%s, want %d/%s/failed",
+						rep.ExitCode, rep.ExitCategory, rep.Verdict, tc.Code, tc.Category)
+				}
+			})
+		}
+	})
+
+	// The distinction the report exists to carry: /ok is not the JSON shape
+	// the query expects, which is a failure; /500 is not JSON at all, which
+	// means the query never ran.
+	t.Run("failures and evaluation errors are told apart", func(t *testing.T) {
+		for _, tc := range []struct {
+			URL     string
+			Verdict string
+		}{
+			{url("/ok"), "failed"},
+			{url("/500"), "error"},
+		} {
+			r := run(t, nil, "--report", "json", "--assert-jq", ".count == 2", tc.URL)
+			assertExit(t, r, exitAssertFail)
+
+			rep := decodeJSONReport(t, r.Stdout)
+			a := rep.Assertions[0]
+			if a.Verdict != tc.Verdict {
+				t.Errorf("%s: verdict = %q, want %q", tc.URL, a.Verdict, tc.Verdict)
+			}
+			if (a.Failure != nil) == (a.Error != "") {
+				t.Errorf("%s: want exactly one of failure and error, got %+v", tc.URL, a)
+			}
+		}
+	})
+
+	t.Run("every retry attempt is listed", func(t *testing.T) {
+		r := run(t, nil, "--report", "json", "--retry", "3", "--retry-delay", "10ms",
+			"--assert-ok", url("/flaky?id=report-json&fail=2"))
+		assertExit(t, r, exitOK)
+
+		rep := decodeJSONReport(t, r.Stdout)
+		if len(rep.Attempts) != 3 {
+			t.Fatalf("got %d attempts, want 3", len(rep.Attempts))
+		}
+		for i, want := range []int{503, 503, 200} {
+			if got := rep.Attempts[i].Status; got != want {
+				t.Errorf("attempt %d: status = %d, want %d", i+1, got, want)
+			}
+		}
+	})
+
+	t.Run("--report-file writes the file and leaves stdout alone", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.json")
+		r := run(t, nil, "--report", "json", "--report-file", path, "--assert-ok", url("/ok"))
+		assertExit(t, r, exitOK)
+
+		if r.Stdout != "" {
+			t.Errorf("stdout = %q, want nothing", r.Stdout)
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("the report file was not written: %s", err)
+		}
+		if rep := decodeJSONReport(t, string(raw)); rep.Verdict != "passed" {
+			t.Errorf("verdict = %q, want passed", rep.Verdict)
+		}
+	})
+}
+
+// junitSuite decodes the testsuite a CI system renders; the e2e tests only
+// look at what ends up on the pipeline page.
+type junitSuite struct {
+	Tests      int `xml:"tests,attr"`
+	Failures   int `xml:"failures,attr"`
+	Errors     int `xml:"errors,attr"`
+	Skipped    int `xml:"skipped,attr"`
+	Properties []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"properties>property"`
+	Cases []struct {
+		Name    string    `xml:"name,attr"`
+		Failure *struct{} `xml:"failure"`
+		Error   *struct{} `xml:"error"`
+	} `xml:"testcase"`
+	SystemOut string `xml:"system-out"`
+}
+
+func readJUnit(t *testing.T, path string) junitSuite {
+	t.Helper()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("the JUnit file was not written: %s", err)
+	}
+
+	var doc struct {
+		Suites []junitSuite `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("the JUnit file is not XML: %s\n%s", err, raw)
+	}
+	if len(doc.Suites) != 1 {
+		t.Fatalf("got %d test suites, want 1", len(doc.Suites))
+	}
+
+	return doc.Suites[0]
+}
+
+func TestE2EReportJUnit(t *testing.T) {
+	t.Run("a failure is a failed test case with the dump attached", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "junit.xml")
+		r := run(t, nil, "--junit", path, "--assert-ok", "--assert-header-missing", "X-Api-Version", url("/500"))
+		assertExit(t, r, exitAssertFail)
+
+		// The prose on stderr is unchanged; the file is in addition to it.
+		assertContains(t, r, "FAILED:")
+		if r.Stdout != "" {
+			t.Errorf("stdout = %q, want nothing", r.Stdout)
+		}
+
+		s := readJUnit(t, path)
+		if s.Tests != 3 || s.Failures != 1 || s.Errors != 0 {
+			t.Errorf("tests/failures/errors = %d/%d/%d, want 3/1/0", s.Tests, s.Failures, s.Errors)
+		}
+		if s.Cases[1].Name != "ok" || s.Cases[1].Failure == nil {
+			t.Errorf("case 2 = %+v, want a failed ok", s.Cases[1])
+		}
+		if !strings.Contains(s.SystemOut, "500 Internal Server Error") {
+			t.Errorf("system-out = %q, want the response dump", s.SystemOut)
+		}
+	})
+
+	t.Run("retries are counted", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "junit.xml")
+		r := run(t, nil, "--junit", path, "--retry", "3", "--retry-delay", "10ms",
+			"--assert-ok", url("/flaky?id=report-junit&fail=2"))
+		assertExit(t, r, exitOK)
+
+		s := readJUnit(t, path)
+		if s.Failures != 0 || s.Errors != 0 {
+			t.Errorf("failures/errors = %d/%d, want a clean suite", s.Failures, s.Errors)
+		}
+		for _, p := range s.Properties {
+			if p.Name == "attempts" && p.Value != "3" {
+				t.Errorf("attempts = %s, want 3", p.Value)
+			}
+		}
+	})
+
+	t.Run("a transport failure errors the request case", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "junit.xml")
+		r := run(t, nil, "--junit", path, "--assert-ok", "http://127.0.0.1:9/")
+		assertExit(t, r, exitTransportFail)
+
+		s := readJUnit(t, path)
+		if s.Errors != 1 || s.Skipped != 1 || s.Cases[0].Error == nil {
+			t.Errorf("errors/skipped = %d/%d, want the request case errored and ok skipped", s.Errors, s.Skipped)
+		}
+	})
+
+	t.Run("composes with --report json", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "junit.xml")
+		r := run(t, nil, "--junit", path, "--report", "json", "--assert-ok", url("/ok"))
+		assertExit(t, r, exitOK)
+
+		if rep := decodeJSONReport(t, r.Stdout); rep.Verdict != "passed" {
+			t.Errorf("verdict = %q, want passed", rep.Verdict)
+		}
+		readJUnit(t, path)
+	})
+}
+
+// TAP is read line by line by harnesses that know nothing else about the run,
+// so these tests look only at the lines such a harness acts on.
+func TestE2EReportTAP(t *testing.T) {
+	t.Run("a test point per assertion, in the order they are built", func(t *testing.T) {
+		r := run(t, nil, "--report", "tap", "--assert-ok", "--assert-header-eq", "X-Api-Version: v2",
+			"--assert-jq", ".count == 2", url("/500"))
+		assertExit(t, r, exitAssertFail)
+
+		for _, want := range []string{
+			"TAP version 14\n",
+			"1..3\n",
+			"not ok 1 - ok\n",
+			"not ok 2 - header[X-Api-Version]\n",
+			"not ok 3 - jq[.count == 2] (evaluation error)\n",
+			`  severity: "fail"`,
+			`  severity: "error"`,
+			`  expected: "v2"`,
+		} {
+			if !strings.Contains(r.Stdout, want) {
+				t.Errorf("stdout lacks %q\n%s", want, r.Stdout)
+			}
+		}
+	})
+
+	t.Run("a pass is all ok", func(t *testing.T) {
+		r := run(t, nil, "--report", "tap", "--assert-ok", "--assert-jq", `.status == "success"`, url("/json"))
+		assertExit(t, r, exitOK)
+
+		if strings.Contains(r.Stdout, "not ok") || !strings.Contains(r.Stdout, "ok 2 - jq[") {
+			t.Errorf("stdout = %q, want two ok test points", r.Stdout)
+		}
+	})
+
+	t.Run("a transport failure bails out", func(t *testing.T) {
+		r := run(t, nil, "--report", "tap", "--assert-ok", "http://127.0.0.1:9/")
+		assertExit(t, r, exitTransportFail)
+
+		if !strings.Contains(r.Stdout, "1..1\nBail out! ") {
+			t.Errorf("stdout = %q, want a plan and a bail out", r.Stdout)
+		}
+	})
+}
+
+func TestE2EReportRejectedCombinations(t *testing.T) {
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Diag string
+	}{
+		{"unknown format", []string{"--report", "xml"}, "Invalid value for --report flag"},
+		{"file without a report", []string{"--report-file", "x.json"}, "pass --report"},
+		{"unwritable file", []string{"--report", "json", "--report-file", filepath.Join(t.TempDir(), "no", "such", "dir")}, "Cannot open --report-file"},
+		{"unwritable junit file", []string{"--junit", filepath.Join(t.TempDir(), "no", "such", "dir")}, "Cannot open --junit file"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, nil, append(tc.Args, "--assert-ok", url("/ok"))...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestE2EResolve(t *testing.T) {
+	_, port, _ := net.SplitHostPort(hostPort())
+	// Nothing listens on 127.0.0.2, so the second address answers.
+	resolve := "backend.invalid:" + port + ":127.0.0.2,127.0.0.1"
+	backend := "http://backend.invalid:" + port
+
+	t.Run("--resolve gives the addresses, and -v names the one that answered", func(t *testing.T) {
+		r := run(t, nil, "-v", "--resolve", resolve, "--assert-ok", backend+"/ok")
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "[:] HTTP/1.1 200 OK (from 127.0.0.1:"+port+")")
+	})
+
+	t.Run("the dump names it too", func(t *testing.T) {
+		r := run(t, nil, "--resolve", resolve, "--assert-body-eq", "nope", backend+"/ok")
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "Connected to: 127.0.0.1:"+port)
+	})
+
+	t.Run("applies to where --maphost points", func(t *testing.T) {
+		r := run(t, nil, "--maphost", "api.invalid:80=backend.invalid:"+port, "--resolve", resolve,
+			"--assert-ok", "http://api.invalid/ok")
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("-6 with IPv4 addresses only", func(t *testing.T) {
+		r := run(t, nil, "-6", "--resolve", resolve, "--assert-ok", backend+"/ok")
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "no IPv6 address for backend.invalid:"+port)
+	})
+
+	t.Run("-4 with an IPv6 host", func(t *testing.T) {
+		r := run(t, nil, "-4", "--assert-ok", "http://[::1]:"+port+"/ok")
+		assertExit(t, r, exitTransportFail)
+	})
+
+	// The lookup fails at the server named, and the error says which.
+	t.Run("--dns-servers", func(t *testing.T) {
+		r := run(t, nil, "--dns-servers", "127.0.0.1:1", "--assert-ok", "http://dns.invalid/")
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "lookup dns.invalid on 127.0.0.1:1")
+
+		r = run(t, nil, "--dns-servers", "127.0.0.1:1", "--assert-ok", url("/ok"))
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("in a suite", func(t *testing.T) {
+		suite := filepath.Join(t.TempDir(), "suite.yaml")
+		body := "requests:\n" +
+			"  - name: inherited\n    url: " + backend + "/ok\n    assert-ok: true\n" +
+			"  - name: overridden\n    url: " + backend + "/ok\n    ipv6: true\n" +
+			"    resolve: backend.invalid:" + port + ":::1\n    assert-ok: true\n"
+		if err := os.WriteFile(suite, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		r := run(t, nil, "run", "--resolve", resolve, suite)
+		// The second request goes to ::1, where nothing listens.
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "[::1]:"+port)
+	})
+}
+
+func TestE2EResolveRejected(t *testing.T) {
+	saved, _ := saveResponse(t, "/ok")
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Diag string
+	}{
+		{"a hostname for an address", []string{"--resolve", "a.invalid:80:backend", "--assert-ok", url("/ok")},
+			`Invalid value for --resolve flag: "backend" is not an IP address`},
+		{"a name for a DNS server", []string{"--dns-servers", "ns1.example.com", "--assert-ok", url("/ok")},
+			`Invalid value for --dns-servers flag: "ns1.example.com" is not an IP address`},
+		{"-4 and -6", []string{"-4", "-6", "--assert-ok", url("/ok")},
+			"Flags --ipv4 and --ipv6 cannot be used together"},
+		{"with --unix-socket", []string{"--unix-socket", "/tmp/d.sock", "--resolve", "a.invalid:80:127.0.0.1",
+			"--assert-ok", url("/ok")}, "Flags --unix-socket and --resolve cannot be used together"},
+		{"with --from-response", []string{"-4", "--from-response", saved, "--assert-ok"},
+			"Flags --from-response and --ipv4 cannot be used together"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, nil, tc.Args...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+}
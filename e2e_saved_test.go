@@ -0,0 +1,124 @@
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// --from-response asserts on a response that was received elsewhere. The
+// fixtures here are what the test server really sends, saved the way curl -i
+// would save them, so a pass means the same thing it means against the server.
+
+// saveResponse fetches path from the test server and writes the raw response
+// to a file, returning its name and its bytes.
+func saveResponse(t *testing.T, path string) (string, string) {
+	t.Helper()
+
+	// Without compression, so a compressed body is saved as it was sent.
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	res, err := client.Get(url(path))
+	if err != nil {
+		t.Fatalf("cannot fetch %s: %s", path, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	raw, err := httputil.DumpResponse(res, true)
+	if err != nil {
+		t.Fatalf("cannot dump %s: %s", path, err)
+	}
+
+	file := filepath.Join(t.TempDir(), "response.http")
+	if err := os.WriteFile(file, raw, 0o600); err != nil {
+		t.Fatalf("cannot save %s: %s", path, err)
+	}
+
+	return file, string(raw)
+}
+
+func TestE2EFromResponse(t *testing.T) {
+	okFile, okRaw := saveResponse(t, "/ok")
+
+	t.Run("the assertions run against the file", func(t *testing.T) {
+		r := run(t, nil, "--from-response", okFile, "--assert-ok",
+			"--assert-header-eq", "X-Api-Version: v1", "--assert-jq", `.status == "success"`)
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "[:] HTTP/1.1 200 OK (from "+okFile+")")
+		assertNotContains(t, r, "[.]")
+	})
+
+	t.Run("stdin is -", func(t *testing.T) {
+		r := runWithStdin(t, okRaw, nil, "--from-response", "-", "--assert-status", "200")
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "(from stdin)")
+	})
+
+	t.Run("a failure is dumped and exits 93", func(t *testing.T) {
+		r := run(t, nil, "--from-response", okFile, "--assert-header-eq", "X-Api-Version: v2")
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, `header[X-Api-Version]: expected "v2", got "v1"`)
+		assertContains(t, r, "FAILED: the response read from "+okFile)
+		assertContains(t, r, `{"status":"success","users":[]}`)
+	})
+
+	t.Run("a compressed body is decoded first", func(t *testing.T) {
+		file, _ := saveResponse(t, "/gzip-always")
+		r := run(t, nil, "--from-response", file, "--assert-jq", `.status == "success"`, "--assert-header", "Content-Encoding: gzip")
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("reports and captures work as they do live", func(t *testing.T) {
+		report := filepath.Join(t.TempDir(), "report.json")
+		r := run(t, nil, "--from-response", okFile, "--report", "json", "--report-file", report,
+			"--capture", "STATUS=.status")
+		assertExit(t, r, exitOK)
+		if r.Stdout != "STATUS='success'\n" {
+			t.Errorf("stdout = %q", r.Stdout)
+		}
+
+		raw, err := os.ReadFile(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rep struct {
+			Verdict  string `json:"verdict"`
+			Attempts []struct {
+				Status int `json:"status"`
+			} `json:"attempts"`
+		}
+		if err := json.Unmarshal(raw, &rep); err != nil {
+			t.Fatalf("the report is not JSON: %s\n%s", err, raw)
+		}
+		if rep.Verdict != "passed" || len(rep.Attempts) != 1 || rep.Attempts[0].Status != 200 {
+			t.Errorf("report = %+v, want one passing attempt with status 200", rep)
+		}
+	})
+}
+
+func TestE2EFromResponseRejected(t *testing.T) {
+	okFile, _ := saveResponse(t, "/ok")
+	garbage := filepath.Join(t.TempDir(), "garbage")
+	if err := os.WriteFile(garbage, []byte(`{"status":"success"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Diag string
+	}{
+		{"a URL as well", []string{"--from-response", okFile, "--assert-ok", url("/ok")}, "drop one of them"},
+		{"a request flag", []string{"--from-response", okFile, "--assert-ok", "--retry", "3"}, "--from-response and --retry cannot be used together"},
+		{"a missing file", []string{"--from-response", filepath.Join(t.TempDir(), "none"), "--assert-ok"}, "Cannot read --from-response file"},
+		{"not a response", []string{"--from-response", garbage, "--assert-ok"}, "not an HTTP response"},
+		{"no assertions", []string{"--from-response", okFile}, "No assertions specified"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, nil, tc.Args...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+}
@@ -5,6 +5,10 @@ import (
 	"compress/flate"
 	"compress/gzip"
 	"compress/zlib"
+	"crypto/hmac"
+	"crypto/md5" // #nosec G501 - the algorithm under test
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,6 +18,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -91,6 +96,12 @@ func testHandler() http.Handler {
 		write(w, http.StatusMovedPermanently, nil, http.Header{"Location": {"/target"}})
 	})
 
+	// Answers as a create endpoint does: 201, with the new resource's absolute
+	// URL in Location, for a suite to capture and request next.
+	mux.HandleFunc("/create", func(w http.ResponseWriter, r *http.Request) {
+		write(w, http.StatusCreated, nil, http.Header{"Location": {"http://" + r.Host + "/echo?id=42"}})
+	})
+
 	// The endpoints below exist for --location. Note that /redirect above
 	// points at a real external host, so it must never be used with -L: the
 	// suite would leave the loopback interface and reach the internet.
@@ -321,12 +332,103 @@ func testHandler() http.Handler {
 		w.WriteHeader(http.StatusNoContent)
 	})
 
+	// Admits alice:s3cret and the bearer token t0ken, and nobody else.
+	mux.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, basic := r.BasicAuth()
+		if (basic && user == "alice" && pass == "s3cret") || r.Header.Get("Authorization") == "Bearer t0ken" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="e2e"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	// Digest for alice:s3cret, MD5 and qop=auth, with one fixed nonce.
+	mux.HandleFunc("/digest", func(w http.ResponseWriter, r *http.Request) {
+		if digestValid(r, "alice", "s3cret") {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Digest realm="e2e", nonce="n0nce", qop="auth", algorithm=MD5`)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	// /token?id=X&expires=N is an OAuth 2.0 token endpoint issuing /auth's
+	// token to client:s3cret, valid for N seconds, and counting the tokens
+	// under "token-X".
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || r.PostFormValue("grant_type") != "client_credentials" ||
+			id != "client" || secret != "s3cret" {
+			write(w, http.StatusUnauthorized, []byte(`{"error":"invalid_client"}`),
+				http.Header{"Content-Type": {"application/json"}})
+			return
+		}
+		flakyHits.LoadOrStore("token-"+r.URL.Query().Get("id"), &atomic.Int64{})
+		v, _ := flakyHits.Load("token-" + r.URL.Query().Get("id"))
+		v.(*atomic.Int64).Add(1)
+		expires := r.URL.Query().Get("expires")
+		if expires == "" {
+			expires = "3600"
+		}
+		write(w, http.StatusOK, []byte(`{"access_token":"t0ken","token_type":"Bearer","expires_in":`+expires+`}`),
+			http.Header{"Content-Type": {"application/json"}})
+	})
+
+	// HMAC-SHA256 with the key k3y, of X-Timestamp, a dot and the body.
+	mux.HandleFunc("/hmac", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		m := hmac.New(sha256.New, []byte("k3y"))
+		_, _ = m.Write([]byte(r.Header.Get("X-Timestamp") + "." + string(body)))
+		if hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte(hex.EncodeToString(m.Sum(nil)))) {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	// /login sets a session cookie and sends the browser on to /dashboard,
+	// which admits nobody without it.
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3ss10n", Path: "/", HttpOnly: true})
+		http.Redirect(w, r, "/dashboard", http.StatusFound)
+	})
+	mux.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		if c := r.CookiesNamed("session"); len(c) == 1 && c[0].Value == "s3ss10n" {
+			write(w, http.StatusOK, []byte("welcome"), nil)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
 	// Reflects the request so tests can observe -X, -H and -d taking effect.
 	mux.HandleFunc("/echo", echo)
 
 	return mux
 }
 
+// digestValid checks a Digest answer to the /digest challenge.
+func digestValid(r *http.Request, user, password string) bool {
+	rest, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Digest ")
+	if !ok {
+		return false
+	}
+	p := map[string]string{}
+	for kv := range strings.SplitSeq(rest, ", ") {
+		k, v, _ := strings.Cut(kv, "=")
+		p[k] = strings.Trim(v, `"`)
+	}
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s)) // #nosec G401 - the algorithm under test
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := h(user + ":e2e:" + password)
+	ha2 := h(r.Method + ":" + r.URL.RequestURI())
+
+	return p["username"] == user && p["uri"] == r.URL.RequestURI() &&
+		p["response"] == h(ha1+":n0nce:"+p["nc"]+":"+p["cnonce"]+":auth:"+ha2)
+}
+
 func echo(w http.ResponseWriter, r *http.Request) {
 	body := make([]byte, 0, 512)
 	if r.Body != nil {
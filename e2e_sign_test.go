@@ -0,0 +1,97 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestE2ESign(t *testing.T) {
+	aws := map[string]string{
+		"AWS_ACCESS_KEY_ID":     "AKIDEXAMPLE",
+		"AWS_SECRET_ACCESS_KEY": "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		"AWS_SESSION_TOKEN":     "s3ssion",
+	}
+
+	t.Run("--aws-sigv4", func(t *testing.T) {
+		r := run(t, aws, "--aws-sigv4", "aws:eu-west-1:execute-api",
+			"--assert-jq", `.headers.Authorization[0] | startswith("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/")`,
+			"--assert-jq", `.headers.Authorization[0] | contains("/eu-west-1/execute-api/aws4_request, ")`,
+			"--assert-jq", `.headers["X-Amz-Date"] | length == 1`,
+			"--assert-jq", `.headers["X-Amz-Security-Token"] == ["s3ssion"]`,
+			url("/echo"))
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("--hmac", func(t *testing.T) {
+		dir := t.TempDir()
+		keyFile := filepath.Join(dir, "key")
+		if err := os.WriteFile(keyFile, []byte("k3y\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range [][]string{
+			{"--hmac-key", "k3y"},
+			{"--hmac-key", "@" + keyFile},
+			{"--hmac-key-env", "HOOK_KEY"},
+		} {
+			args := append([]string{"--hmac", "sha256:X-Signature:X-Timestamp", "-X", "POST", "-d", "payload"}, key...)
+			r := run(t, map[string]string{"HOOK_KEY": "k3y"}, append(args, "--assert-ok", url("/hmac"))...)
+			assertExit(t, r, exitOK)
+		}
+	})
+
+	// Each attempt is signed over the body it sends, retries included.
+	t.Run("every attempt", func(t *testing.T) {
+		r := run(t, nil, "--hmac", "sha256:X-Signature:X-Timestamp", "--hmac-key", "k3y",
+			"-X", "POST", "-d", "payload", "--retry", "1", "--retry-delay", "0s",
+			"--assert-header", "X-Absent: .", url("/hmac"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "gave up after 2 attempts")
+		assertContains(t, r, "HTTP/1.1 200 OK")
+	})
+
+	t.Run("redacted in the dump", func(t *testing.T) {
+		r := run(t, aws, "--aws-sigv4", "aws:eu-west-1:execute-api", "--assert-status", "201", url("/ok"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "Credential=AKIDEXAMPLE/")
+		assertContains(t, r, "Signature=xxxxx")
+		assertContains(t, r, "X-Amz-Security-Token: xxxxx")
+		assertNotContains(t, r, "s3ssion")
+
+		r = run(t, nil, "--hmac", "sha256:X-Signature", "--hmac-key", "wrong", "--assert-ok", url("/hmac"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "X-Signature: xxxxx")
+	})
+}
+
+func TestE2ESignRejected(t *testing.T) {
+	for _, tc := range []struct {
+		Name string
+		Env  map[string]string
+		Args []string
+		Diag string
+	}{
+		{"no AWS credentials", map[string]string{"AWS_ACCESS_KEY_ID": "", "AWS_SECRET_ACCESS_KEY": ""},
+			[]string{"--aws-sigv4", "aws:us-east-1:s3"},
+			"Flag --aws-sigv4 needs $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY"},
+		{"curl's four parts", map[string]string{"AWS_ACCESS_KEY_ID": "a", "AWS_SECRET_ACCESS_KEY": "b"},
+			[]string{"--aws-sigv4", "aws:amz:us-east-1:s3"},
+			`Invalid value for --aws-sigv4 flag: "aws:amz:us-east-1:s3" is not provider:region:service`},
+		{"--aws-sigv4 and -u", nil, []string{"--aws-sigv4", "aws:us-east-1:s3", "-u", "alice:s3cret"},
+			"Flag --aws-sigv4 cannot be used with --user, --oauth2-bearer, --oauth2-token-url or --digest"},
+		{"two signers", nil, []string{"--aws-sigv4", "aws:us-east-1:s3", "--hmac", "sha256:X-Sig"},
+			"Flags --aws-sigv4 and --hmac cannot be used together"},
+		{"--hmac without a key", nil, []string{"--hmac", "sha256:X-Sig"},
+			"Flag --hmac needs a key"},
+		{"a key without --hmac", nil, []string{"--hmac-key", "k3y"},
+			"Flags --hmac-key and --hmac-key-env need --hmac"},
+		{"an unknown algorithm", nil, []string{"--hmac", "md5:X-Sig", "--hmac-key", "k3y"},
+			`Invalid value for --hmac flag: unknown algorithm "md5"; use sha1, sha256 or sha512`},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, tc.Env, append(tc.Args, "--assert-ok", url("/ok"))...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package main_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// `http-assert run` replaces the shell loop around the binary. These tests
+// write the suite file a caller would write and check what the loop used to be
+// responsible for: every request runs, and one exit code says how it went.
+
+// writeSuite writes a suite file into a fresh directory and returns its path.
+func writeSuite(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "suite.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("cannot write the suite: %s", err)
+	}
+
+	return path
+}
+
+func TestE2ESuite(t *testing.T) {
+	t.Run("every request passes", func(t *testing.T) {
+		suite := writeSuite(t, `
+requests:
+  - name: health
+    url: `+url("/ok")+`
+    assert-ok: true
+    assert-header-eq: ["X-Api-Version: v1"]
+  - name: post
+    url: `+url("/echo")+`
+    data: '{"name": "x"}'
+    header: ["Content-Type: application/json", "X-Trace: 1"]
+    assert-status: 200
+    assert-jq: ['.method == "POST"', '.body == "{\"name\": \"x\"}"', '.headers["X-Trace"] == ["1"]']
+`)
+		r := run(t, nil, "run", suite)
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "[#] health (1/2)")
+		assertContains(t, r, "[#] post (2/2)")
+		assertContains(t, r, "PASSED all 2 requests")
+	})
+
+	t.Run("a failure does not stop the rest", func(t *testing.T) {
+		suite := writeSuite(t, `
+requests:
+  - name: broken
+    url: `+url("/500")+`
+    assert-ok: true
+  - name: fine
+    url: `+url("/ok")+`
+    assert-ok: true
+`)
+		r := run(t, nil, "run", suite)
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, `Request "broken": 1 assertions failed`)
+		assertContains(t, r, "[#] fine (2/2)")
+		assertContains(t, r, `1 of 2 requests failed: "broken"`)
+	})
+
+	// An assertion failure elsewhere in the suite must not hide that one
+	// request never got an answer.
+	t.Run("a transport failure decides the exit code", func(t *testing.T) {
+		suite := writeSuite(t, `
+requests:
+  - name: wrong
+    url: `+url("/500")+`
+    assert-ok: true
+  - name: down
+    url: http://127.0.0.1:9/
+    assert-ok: true
+`)
+		r := run(t, nil, "run", suite)
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, `2 of 2 requests failed: "wrong", "down"`)
+	})
+
+	t.Run("retries are per request", func(t *testing.T) {
+		suite := writeSuite(t, `
+requests:
+  - name: warming-up
+    url: `+url("/flaky?id=suite-retry&fail=2")+`
+    retry: 3
+    retry-delay: 10ms
+    assert-ok: true
+`)
+		r := run(t, nil, "run", suite)
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "[~] retry 2/3")
+	})
+
+	t.Run("the command line supplies what a request does not set", func(t *testing.T) {
+		suite := writeSuite(t, `
+requests:
+  - name: mapped
+    url: http://mapped.invalid/ok
+    assert-ok: true
+  - name: own-mapping
+    url: http://other.invalid/ok
+    maphost: ["other.invalid:80=`+hostPort()+`"]
+    assert-ok: true
+`)
+		r := run(t, nil, "run", "--maphost", "mapped.invalid:80="+hostPort(), suite)
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("JSON is a suite file too", func(t *testing.T) {
+		suite := writeSuite(t, `{"requests": [{"name": "json", "url": "`+url("/ok")+`", "assert-status": 200}]}`)
+		r := run(t, nil, "run", suite)
+		assertExit(t, r, exitOK)
+	})
+}
+
+// A flow: what one request answers is what the next one sends.
+func TestE2ESuiteCaptures(t *testing.T) {
+	t.Run("a captured value reaches the url, headers, body and assertions", func(t *testing.T) {
+		suite := writeSuite(t, `
+requests:
+  - name: read
+    url: `+url("/json")+`
+    capture: ["VERSION=.meta.version", "BOB=.users[] | select(.name == \"bob\")"]
+    capture-header: ["TYPE=Content-Type"]
+    capture-body: ['NAME="name":"(\w+)"']
+  - name: use
+    url: `+url("/echo?v={{VERSION}}")+`
+    header: ["X-Version: {{VERSION}}", "X-Type: {{TYPE}}", "X-Name: {{NAME}}"]
+    data: '{{BOB}}'
+    assert-jq:
+      - '.headers["X-Version"] == ["{{VERSION}}"]'
+      - '.headers["X-Type"] == ["application/json"]'
+      - '.headers["X-Name"] == ["alice"]'
+      - '.body | fromjson | .id == 2'
+`)
+		r := run(t, nil, "run", suite)
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "PASSED all 2 requests")
+		assertContains(t, r, "/echo?v=v1")
+	})
+
+	// A Location is the url of the next request, not a value within one.
+	t.Run("a captured Location is requested as it is", func(t *testing.T) {
+		suite := writeSuite(t, `
+requests:
+  - name: create
+    url: `+url("/create")+`
+    request: POST
+    assert-status: 201
+    capture-header: ["LOC=Location"]
+  - name: follow
+    url: "{{LOC}}"
+    assert-jq: ['.method == "GET"']
+  - name: relative
+    url: `+url("/redirect-local")+`
+    capture-header: ["NEXT=Location"]
+    assert-status: 302
+  - name: follow-relative
+    url: "http://`+hostPort()+`{{NEXT}}"
+    assert-ok: true
+`)
+		r := run(t, nil, "run", suite)
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "/echo?id=42")
+	})
+
+	t.Run("a failed capture fails its request like an assertion", func(t *testing.T) {
+		suite := writeSuite(t, `
+requests:
+  - name: login
+    url: `+url("/ok")+`
+    capture: ["TOKEN=.token"]
+  - name: me
+    url: `+url("/echo")+`
+    header: ["Authorization: Bearer {{TOKEN}}"]
+    assert-ok: true
+  - name: unrelated
+    url: `+url("/ok")+`
+    assert-ok: true
+`)
+		r := run(t, nil, "run", "--report", "json", suite)
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, `Request "login": 1 assertions failed`)
+		assertContains(t, r, "capture[TOKEN]: jq[.token] yielded null")
+		assertContains(t, r, `Request "me": not sent: it needs {{TOKEN}}, which "login" did not capture`)
+		assertContains(t, r, `2 of 3 requests failed: "login", "me"`)
+		if n := strings.Count(r.Stderr, "[.]"); n != 2 {
+			t.Errorf("sent %d requests, want 2: the one that needed the token stays unsent", n)
+		}
+
+		var rep struct {
+			Requests []struct {
+				Name       string `json:"name"`
+				ExitCode   int    `json:"exit_code"`
+				Error      string `json:"error"`
+				Assertions []struct {
+					Kind    string `json:"kind"`
+					Target  string `json:"target"`
+					Verdict string `json:"verdict"`
+				} `json:"assertions"`
+			} `json:"requests"`
+		}
+		if err := json.Unmarshal([]byte(r.Stdout), &rep); err != nil {
+			t.Fatalf("the report is not JSON: %s\n%s", err, r.Stdout)
+		}
+		login, me := rep.Requests[0], rep.Requests[1]
+		if len(login.Assertions) != 1 || login.Assertions[0].Kind != "capture" ||
+			login.Assertions[0].Target != "TOKEN" || login.Assertions[0].Verdict != "failed" {
+			t.Errorf("login assertions = %+v, want a failed capture of TOKEN", login.Assertions)
+		}
+		if me.ExitCode != exitAssertFail || !strings.Contains(me.Error, "not sent") {
+			t.Errorf("me = %+v, want 93 and why it was not sent", me)
+		}
+	})
+
+	t.Run("a value that was never captured is a mistake in the file", func(t *testing.T) {
+		suite := writeSuite(t, `
+requests:
+  - name: me
+    url: `+url("/echo")+`
+    header: ["Authorization: Bearer {{TOKEN}}"]
+    assert-ok: true
+  - name: login
+    url: `+url("/ok")+`
+    capture: ["TOKEN=.status"]
+`)
+		r := run(t, nil, "run", suite)
+		assertExit(t, r, exitBadInvocation)
+		assertContains(t, r, `"me": {{TOKEN}} is not captured by an earlier request`)
+		assertNotContains(t, r, "[.]")
+	})
+}
+
+func TestE2ESuiteReports(t *testing.T) {
+	suite := writeSuite(t, `
+requests:
+  - name: health
+    url: `+url("/ok")+`
+    assert-ok: true
+  - name: broken
+    url: `+url("/500")+`
+    assert-ok: true
+`)
+
+	t.Run("json lists every request under its name", func(t *testing.T) {
+		r := run(t, nil, "run", "--report", "json", suite)
+		assertExit(t, r, exitAssertFail)
+
+		var rep struct {
+			ExitCode int `json:"exit_code"`
+			Requests []struct {
+				Name    string `json:"name"`
+				Verdict string `json:"verdict"`
+			} `json:"requests"`
+		}
+		if err := json.Unmarshal([]byte(r.Stdout), &rep); err != nil {
+			t.Fatalf("the report is not JSON: %s\n%s", err, r.Stdout)
+		}
+		if rep.ExitCode != exitAssertFail || len(rep.Requests) != 2 {
+			t.Fatalf("report = %+v, want exit 93 and two requests", rep)
+		}
+		if rep.Requests[0].Name != "health" || rep.Requests[0].Verdict != "passed" ||
+			rep.Requests[1].Name != "broken" || rep.Requests[1].Verdict != "failed" {
+			t.Errorf("requests = %+v", rep.Requests)
+		}
+	})
+
+	t.Run("tap has a subtest per request", func(t *testing.T) {
+		r := run(t, nil, "run", "--report", "tap", suite)
+		assertExit(t, r, exitAssertFail)
+
+		for _, want := range []string{"1..2\n", "# Subtest: health\n", "ok 1 - health\n", "not ok 2 - broken\n"} {
+			if !strings.Contains(r.Stdout, want) {
+				t.Errorf("stdout lacks %q\n%s", want, r.Stdout)
+			}
+		}
+	})
+
+	t.Run("junit has a testsuite per request", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "junit.xml")
+		r := run(t, nil, "run", "--junit", path, suite)
+		assertExit(t, r, exitAssertFail)
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("the JUnit file was not written: %s", err)
+		}
+		if n := strings.Count(string(raw), "<testsuite "); n != 2 {
+			t.Errorf("got %d testsuites, want 2\n%s", n, raw)
+		}
+	})
+}
+
+// A mistake anywhere in the file is found before the first request is sent, so
+// a typo in the last request cannot leave the first one's side effects behind.
+func TestE2ESuiteRejected(t *testing.T) {
+	for _, tc := range []struct {
+		Name  string
+		Suite string
+		Diag  string
+	}{
+		{"unknown key", "  - {name: a, url: U, assert-ok: true, asert-status: 200}", `request 2: "a": unknown key "asert-status"`},
+		{"list for a single value", "  - {name: a, url: U, assert-status: [200, 201]}", "assert-status takes a single value"},
+		{"bad assertion", "  - {name: a, url: U, assert-status: 2zz}", "Invalid value for --assert-status flag"},
+		{"bad jq", "  - {name: a, url: U, assert-jq: ['.[']}", "Invalid value for --assert-jq flag"},
+		{"bad header", "  - {name: a, url: U, assert-ok: true, header: [X-Name]}", "Invalid value for --header flag"},
+		{"no assertions", "  - {name: a, url: U}", `"a": no assertions`},
+		{"no name", "  - {url: U, assert-ok: true}", "name is missing"},
+		{"duplicate name", "  - {name: first, url: U, assert-ok: true}", `name "first" is already taken`},
+		{"redirect contradiction", "  - {name: a, url: U, location: true, assert-redirect: x}", "cannot be used together"},
+		{"retry setting without retry", "  - {name: a, url: U, assert-ok: true, retry-delay: 1s}", "pass --retry"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			first := "  - {name: first, url: " + url("/ok") + ", assert-ok: true}\n"
+			suite := writeSuite(t, "requests:\n"+first+strings.ReplaceAll(tc.Suite, "U", url("/ok"))+"\n")
+
+			r := run(t, nil, "run", suite)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+			assertNotContains(t, r, "[.]")
+		})
+	}
+
+	t.Run("unreadable file", func(t *testing.T) {
+		r := run(t, nil, "run", filepath.Join(t.TempDir(), "missing.yaml"))
+		assertExit(t, r, exitBadInvocation)
+		assertContains(t, r, "Cannot read suite file")
+	})
+
+	t.Run("not a suite", func(t *testing.T) {
+		r := run(t, nil, "run", writeSuite(t, "cases: []\n"))
+		assertExit(t, r, exitBadInvocation)
+		assertContains(t, r, "Invalid suite file")
+	})
+
+	t.Run("assertion flags belong in the file", func(t *testing.T) {
+		r := run(t, nil, "run", "--assert-ok", writeSuite(t, "requests: []\n"))
+		assertExit(t, r, exitBadInvocation)
+		assertContains(t, r, "unknown flag: --assert-ok")
+	})
+}
@@ -0,0 +1,68 @@
+package main_test
+
+import "testing"
+
+// --assert-time is for the backend that answers correctly and too late: a
+// deploy gate that only reads the status waves it through. The bounds here are
+// generous either way, so a loaded CI machine cannot flip a verdict.
+
+func TestE2EAssertTime(t *testing.T) {
+	t.Run("a bound that holds", func(t *testing.T) {
+		r := run(t, nil, "--assert-time", "total<10s", "--assert-time", "ttfb <= 10s", url("/ok"))
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("a slow response fails and shows the breakdown", func(t *testing.T) {
+		r := run(t, nil, "--assert-ok", "--assert-time", "ttfb<100ms", url("/slow?ms=300"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "time[ttfb]: expected < 100ms, got ")
+		assertContains(t, r, "Timing: dns ")
+		assertContains(t, r, ", total ")
+	})
+
+	// The body arrives after the first byte, so a slow handler is the server's
+	// time and not the transfer's.
+	t.Run("the phases are told apart", func(t *testing.T) {
+		r := run(t, nil, "--assert-time", "ttfb<100ms", "--assert-time", "transfer<1s", url("/slow?ms=300"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "time[ttfb]")
+		assertNotContains(t, r, "time[transfer]")
+	})
+
+	t.Run("-v logs the breakdown", func(t *testing.T) {
+		r := run(t, nil, "-v", "--assert-ok", url("/ok"))
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "[:] timing: dns ")
+	})
+
+	t.Run("not at the default level", func(t *testing.T) {
+		r := run(t, nil, "--assert-ok", url("/ok"))
+		assertNotContains(t, r, "timing:")
+	})
+
+	t.Run("a saved response has no timing", func(t *testing.T) {
+		file, _ := saveResponse(t, "/ok")
+		r := run(t, nil, "--from-response", file, "--assert-time", "total<1s")
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "time[total]: the response has no timing")
+	})
+}
+
+func TestE2EAssertTimeRejected(t *testing.T) {
+	for _, tc := range []struct {
+		Spec string
+		Diag string
+	}{
+		{"total300ms", "has no <"},
+		{"latency<300ms", `unknown phase "latency"; possible values: dns, connect, tls, ttfb, transfer, total`},
+		{"total<300", `"300" is not a duration`},
+		{"total<0s", "can never hold"},
+	} {
+		t.Run(tc.Spec, func(t *testing.T) {
+			r := run(t, nil, "--assert-time", tc.Spec, url("/ok"))
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, "Invalid value for --assert-time flag")
+			assertContains(t, r, tc.Diag)
+		})
+	}
+}
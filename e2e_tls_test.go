@@ -0,0 +1,66 @@
+package main_test
+
+import "testing"
+
+// The TLS test server presents httptest's built-in certificate: issued by and
+// for "Acme Co", covering example.com, 127.0.0.1 and ::1, and valid until 2084.
+// It is self-signed, so every run here needs -k -- which is also the point:
+// an unverified chain is still one whose expiry can be checked.
+
+func TestE2ECertAssertions(t *testing.T) {
+	t.Run("all hold", func(t *testing.T) {
+		r := run(t, nil, "-k",
+			"--assert-cert-expires-after", "14d",
+			"--assert-cert-san", "example.com", "--assert-cert-san", "127.0.0.1",
+			"--assert-cert-issuer", "O=Acme Co",
+			"--assert-tls-version", ">=1.2",
+			"--assert-alpn", "http/1.1",
+			tlsSrv.URL)
+		assertExit(t, r, exitOK)
+	})
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Diag string
+	}{
+		{"expiry", []string{"--assert-cert-expires-after", "36500d"},
+			"cert[expiry]: expected O=Acme Co to be valid for 36500d more, it expires 2084-01-29T16:00:00Z"},
+		{"san", []string{"--assert-cert-san", "example.org"},
+			"cert[example.org]: expected O=Acme Co to cover it, got DNS:example.com, DNS:*.example.com, IP:127.0.0.1, IP:::1"},
+		{"issuer", []string{"--assert-cert-issuer", "Let's Encrypt"},
+			`cert[issuer]: expected to match "Let's Encrypt", got "O=Acme Co"`},
+		{"version", []string{"--assert-tls-version", "1.2"}, "tls[version]: expected 1.2, got 1.3"},
+		{"alpn", []string{"--assert-alpn", "h2"}, `tls[alpn]: expected "h2", got "http/1.1"`},
+	} {
+		t.Run(tc.Name+" fails with the certificate's values", func(t *testing.T) {
+			r := run(t, nil, append(append([]string{"-k"}, tc.Args...), tlsSrv.URL)...)
+			assertExit(t, r, exitAssertFail)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+
+	t.Run("plain HTTP has no certificate", func(t *testing.T) {
+		r := run(t, nil, "--assert-cert-expires-after", "14d", url("/ok"))
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "cert[expiry]: the response did not come over TLS")
+	})
+}
+
+func TestE2ECertAssertionsRejected(t *testing.T) {
+	for _, tc := range []struct {
+		Args []string
+		Diag string
+	}{
+		{[]string{"--assert-cert-expires-after", "two weeks"}, "Invalid value for --assert-cert-expires-after flag"},
+		{[]string{"--assert-cert-issuer", "("}, "Invalid value for --assert-cert-issuer flag"},
+		{[]string{"--assert-tls-version", ">=1.4"}, `"1.4" is not a TLS version`},
+		{[]string{"--assert-tls-version", "1.2", "--assert-tls-version", "1.3"}, "accepts a single value"},
+	} {
+		t.Run(tc.Args[1], func(t *testing.T) {
+			r := run(t, nil, append(tc.Args, "-k", tlsSrv.URL)...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+}
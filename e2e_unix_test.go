@@ -0,0 +1,120 @@
+package main_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// startUnix serves on a Unix domain socket and returns its path. The handler
+// echoes the Host header and path, which is what the URL still provides, and
+// /flaky fails its first request.
+func startUnix(t *testing.T) string {
+	t.Helper()
+
+	// A socket path is limited to about a hundred bytes, which t.TempDir,
+	// named after the test, can exceed.
+	dir, err := os.MkdirTemp("", "ha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "d.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var flaky atomic.Int32
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" && flaky.Add(1) == 1 {
+			write(w, http.StatusServiceUnavailable, nil, nil)
+			return
+		}
+		write(w, http.StatusOK, []byte(r.Host+r.URL.Path), nil)
+	}))
+	s.Listener = l
+	s.Start()
+	t.Cleanup(s.Close)
+
+	return path
+}
+
+func TestE2EUnixSocket(t *testing.T) {
+	sock := startUnix(t)
+
+	t.Run("the URL gives Host and path", func(t *testing.T) {
+		r := run(t, nil, "--unix-socket", sock, "--assert-ok",
+			"--assert-body-eq", "docker/v1.45/_ping", "http://docker/v1.45/_ping")
+		assertExit(t, r, exitOK)
+	})
+
+	t.Run("-v names the socket", func(t *testing.T) {
+		r := run(t, nil, "-v", "--unix-socket", sock, "--assert-ok", "http://localhost/")
+		assertExit(t, r, exitOK)
+		assertContains(t, r, "(via "+sock+")")
+	})
+
+	t.Run("composes with --retry", func(t *testing.T) {
+		r := run(t, nil, "--unix-socket", sock, "--retry", "2", "--retry-delay", "50ms",
+			"--assert-ok", "http://localhost/flaky")
+		assertExit(t, r, exitOK)
+		if n := retries(r); n != 1 {
+			t.Errorf("retried %d times, want 1\n%s", n, r.Output())
+		}
+	})
+
+	t.Run("a failed assertion", func(t *testing.T) {
+		r := run(t, nil, "--unix-socket", sock, "--assert-body-eq", "nope", "http://localhost/")
+		assertExit(t, r, exitAssertFail)
+		assertContains(t, r, "Unix socket: "+sock)
+	})
+
+	// The daemon being down is the network's verdict, and the dump says
+	// which socket was tried, since the URL cannot.
+	t.Run("a missing socket", func(t *testing.T) {
+		gone := filepath.Join(filepath.Dir(sock), "gone.sock")
+		r := run(t, nil, "--unix-socket", gone, "--assert-ok", "http://localhost/")
+		assertExit(t, r, exitTransportFail)
+		assertContains(t, r, "Unix socket: "+gone)
+	})
+
+	t.Run("in a suite", func(t *testing.T) {
+		suite := filepath.Join(t.TempDir(), "suite.yaml")
+		body := "requests:\n" +
+			"  - name: inherited\n    url: http://docker/_ping\n    assert-body-eq: docker/_ping\n" +
+			"  - name: overridden\n    url: " + url("/ok") + "\n    unix-socket: \"\"\n    assert-ok: true\n"
+		if err := os.WriteFile(suite, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		r := run(t, nil, "run", "--unix-socket", sock, suite)
+		assertExit(t, r, exitOK)
+	})
+}
+
+func TestE2EUnixSocketRejected(t *testing.T) {
+	saved, _ := saveResponse(t, "/ok")
+
+	for _, tc := range []struct {
+		Name string
+		Args []string
+		Diag string
+	}{
+		{"with --maphost", []string{"--unix-socket", "/tmp/d.sock", "--maphost", "a:80=b:80", "--assert-ok", url("/ok")},
+			"Flags --unix-socket and --maphost cannot be used together"},
+		{"with --http3", []string{"--unix-socket", "/tmp/d.sock", "--http3", "--assert-ok", "https://localhost/"},
+			"Flags --unix-socket and --http3 cannot be used together"},
+		{"with --from-response", []string{"--unix-socket", "/tmp/d.sock", "--from-response", saved, "--assert-ok"},
+			"Flags --from-response and --unix-socket cannot be used together"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := run(t, nil, tc.Args...)
+			assertExit(t, r, exitBadInvocation)
+			assertContains(t, r, tc.Diag)
+		})
+	}
+}
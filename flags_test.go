@@ -127,3 +127,26 @@ func Test_checkRepeats_allows(t *testing.T) {
 
 	checkRepeats(fs) // must return; a repeated collecting flag is legitimate
 }
+
+// The flags a rejection suggests are the repeatable assertion flags the set
+// has, whichever they are.
+func Test_checkRepeats_rejects(t *testing.T) {
+	t.Parallel()
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("assert-status", "", "")
+	fs.StringArray("assert-header", nil, "")
+	fs.StringArray("assert-cookie", nil, "")
+	fs.StringArray("header", nil, "")
+	rejectRepeats(fs)
+
+	if err := fs.Parse([]string{"--assert-status", "200", "--assert-status", "2xx"}); err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+
+	want := "Flag --assert-status was given 2 times but accepts a single value; " +
+		"repeat --assert-cookie or --assert-header to make several assertions"
+	if err := checkRepeats(fs); err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package httpassert
 
 import (
 	"context"
@@ -22,11 +22,18 @@ import (
 // "the service is wrong" from "we could not tell" (#45).
 type Assertion interface {
 	// Kind names the family this assertion belongs to: "ok", "nok",
-	// "status", "header", "body", "redirect" or "jq".
+	// "status", "header", "body", "redirect", "jq", "time", "cert", "tls",
+	// "proto", "alt-svc" or "cookie".
 	Kind() string
 
+	// Target names the subject the caller gave -- a header name, a jq query
+	// -- and is empty when the kind needs none. It is what tells two header
+	// assertions apart in a report that lists the ones that passed, where
+	// there is no Failure to carry it.
+	Target() string
+
 	// Check reports (nil, nil) when the assertion holds.
-	Check(res *httpResponse) (*Failure, error)
+	Check(res *Response) (*Failure, error)
 }
 
 // Failure describes an assertion that did not hold, in parts as well as prose.
@@ -59,15 +66,17 @@ func (f *Failure) Error() string { return f.Message }
 // result needed to carry more than a string. Thirteen one-method structs would
 // have said the same thing at ten times the length.
 type assertionFunc struct {
-	kind  string
-	check func(res *httpResponse) (*Failure, error)
+	kind   string
+	target string
+	check  func(res *Response) (*Failure, error)
 }
 
-func (a assertionFunc) Kind() string { return a.kind }
+func (a assertionFunc) Kind() string   { return a.kind }
+func (a assertionFunc) Target() string { return a.target }
 
 // Check stamps the failure with the assertion's kind, so Kind() and
 // Failure.Kind cannot disagree and no constructor has to repeat itself.
-func (a assertionFunc) Check(res *httpResponse) (*Failure, error) {
+func (a assertionFunc) Check(res *Response) (*Failure, error) {
 	f, err := a.check(res)
 	if f != nil {
 		f.Kind = a.kind
@@ -76,8 +85,8 @@ func (a assertionFunc) Check(res *httpResponse) (*Failure, error) {
 	return f, err
 }
 
-func newAssertion(kind string, check func(res *httpResponse) (*Failure, error)) Assertion {
-	return assertionFunc{kind: kind, check: check}
+func newAssertion(kind, target string, check func(res *Response) (*Failure, error)) Assertion {
+	return assertionFunc{kind: kind, target: target, check: check}
 }
 
 // Pattern-based assertions are built from user input and so can fail before any
@@ -117,17 +126,17 @@ const (
 // one, so every form --assert-status accepts reduces to the same shape.
 type statusRange struct{ lo, hi int }
 
-// statusSpec is the set of status codes an assertion will accept.
+// StatusSpec is the set of status codes an assertion will accept.
 //
 // text is kept as the caller wrote it: a failure saying `expected 2xx` is the
 // question they asked, where `expected 200-299` would be an answer they would
 // have to translate back.
-type statusSpec struct {
+type StatusSpec struct {
 	text   string
 	ranges []statusRange
 }
 
-func (s statusSpec) matches(code int) bool {
+func (s StatusSpec) matches(code int) bool {
 	for _, r := range s.ranges {
 		if code >= r.lo && code <= r.hi {
 			return true
@@ -137,15 +146,15 @@ func (s statusSpec) matches(code int) bool {
 	return false
 }
 
-// parseStatusSpec reads the forms --assert-status accepts: an exact code, a
+// ParseStatusSpec reads the forms --assert-status accepts: an exact code, a
 // class like 2xx, an inclusive range like 401-403, or a comma-separated list
 // mixing any of them.
 //
 // Parsed by hand rather than by regexp, which the linter forbids compiling
 // from user input anyway, and which would be longer than the three cases it
 // replaced.
-func parseStatusSpec(text string) (statusSpec, error) {
-	spec := statusSpec{text: text}
+func ParseStatusSpec(text string) (StatusSpec, error) {
+	spec := StatusSpec{text: text}
 	if strings.TrimSpace(text) == "" {
 		return spec, fmt.Errorf("it is empty; give a status code, a class like 2xx, or a range like 401-403")
 	}
@@ -223,7 +232,7 @@ func parseStatusCode(text string) (int, error) {
 }
 
 func AssertStatusOK() Assertion {
-	return newAssertion("ok", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("ok", "", func(res *Response) (*Failure, error) {
 		if s := res.StatusCode; s < 200 || s >= 400 {
 			return &Failure{
 				Expected: "2xx-3xx",
@@ -238,7 +247,7 @@ func AssertStatusOK() Assertion {
 }
 
 func AssertStatusNOK() Assertion {
-	return newAssertion("nok", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("nok", "", func(res *Response) (*Failure, error) {
 		if s := res.StatusCode; s >= 200 && s < 400 {
 			return &Failure{
 				Expected: "not 2xx-3xx",
@@ -253,8 +262,8 @@ func AssertStatusNOK() Assertion {
 }
 
 // AssertStatus holds when the response carries any status the spec names.
-func AssertStatus(spec statusSpec) Assertion {
-	return newAssertion("status", func(res *httpResponse) (*Failure, error) {
+func AssertStatus(spec StatusSpec) Assertion {
+	return newAssertion("status", "", func(res *Response) (*Failure, error) {
 		if !spec.matches(res.StatusCode) {
 			return &Failure{
 				Expected: spec.text,
@@ -268,8 +277,42 @@ func AssertStatus(spec statusSpec) Assertion {
 	})
 }
 
+// ParseProto reads a protocol version the way a response reports it in Proto:
+// HTTP/1.0, HTTP/1.1, HTTP/2.0 or HTTP/3.0. HTTP/2 and HTTP/3, as curl prints
+// them, mean the same, and so do the bare numbers.
+func ParseProto(text string) (string, error) {
+	v := strings.TrimSpace(text)
+	if len(v) >= 5 && strings.EqualFold(v[:5], "HTTP/") {
+		v = v[5:]
+	}
+	switch v {
+	case "1.0", "1.1", "2.0", "3.0":
+		return "HTTP/" + v, nil
+	case "2", "3":
+		return "HTTP/" + v + ".0", nil
+	}
+
+	return "", fmt.Errorf("%q is not an HTTP version; possible values: HTTP/1.0, HTTP/1.1, HTTP/2.0, HTTP/3.0", text)
+}
+
+// AssertProto holds when the response came over the protocol version, as
+// ParseProto writes it.
+func AssertProto(proto string) Assertion {
+	return newAssertion("proto", "", func(res *Response) (*Failure, error) {
+		if res.Proto != proto {
+			return &Failure{
+				Expected: proto,
+				Actual:   res.Proto,
+				Message:  fmt.Sprintf("proto: expected %s, got %s", proto, res.Proto),
+			}, nil
+		}
+
+		return nil, nil
+	})
+}
+
 func AssertHeaderPresent(name string) Assertion {
-	return newAssertion("header", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("header", name, func(res *Response) (*Failure, error) {
 		if res.Header.Values(name) == nil {
 			return &Failure{
 				Target:   name,
@@ -284,7 +327,7 @@ func AssertHeaderPresent(name string) Assertion {
 }
 
 func AssertHeaderMissing(name string) Assertion {
-	return newAssertion("header", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("header", name, func(res *Response) (*Failure, error) {
 		if vs := res.Header.Values(name); vs != nil {
 			return &Failure{
 				Target:   name,
@@ -300,7 +343,7 @@ func AssertHeaderMissing(name string) Assertion {
 }
 
 func AssertHeaderEqual(name, expValue string) Assertion {
-	return newAssertion("header", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("header", name, func(res *Response) (*Failure, error) {
 		vs := res.Header.Values(name)
 		if vs == nil {
 			return &Failure{
@@ -333,7 +376,7 @@ func AssertHeaderMatch(name, expPattern string) (Assertion, error) {
 		return nil, err
 	}
 
-	return newAssertion("header", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("header", name, func(res *Response) (*Failure, error) {
 		vs := res.Header.Values(name)
 		if vs == nil {
 			return &Failure{
@@ -367,7 +410,7 @@ func AssertHeaderMatch(name, expPattern string) (Assertion, error) {
 // compressed produced a false failure with an unexplained hex dump -- and, with
 // a loose enough pattern, a false pass, which is the one outcome this program
 // exists to refuse (#27).
-func bodyOf(res *httpResponse) ([]byte, error) {
+func bodyOf(res *Response) ([]byte, error) {
 	if res.DecodeErr != nil {
 		return nil, fmt.Errorf("body: response is %s-encoded and was not decoded: %s",
 			res.Encoding, res.DecodeErr)
@@ -377,7 +420,7 @@ func bodyOf(res *httpResponse) ([]byte, error) {
 }
 
 func AssertBodyEmpty() Assertion {
-	return newAssertion("body", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("body", "", func(res *Response) (*Failure, error) {
 		body, err := bodyOf(res)
 		if err != nil {
 			return nil, err
@@ -432,14 +475,14 @@ func AssertJQ(query string) (Assertion, error) {
 		return nil, err
 	}
 
-	return newAssertion("jq", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("jq", query, func(res *Response) (*Failure, error) {
 		return runJQ(code, query, res, jqTimeout)
 	}), nil
 }
 
 // runJQ evaluates one compiled query. The deadline is a parameter so the test
 // for it need not wait out the real one; every caller passes jqTimeout.
-func runJQ(code *gojq.Code, query string, res *httpResponse, timeout time.Duration) (*Failure, error) {
+func runJQ(code *gojq.Code, query string, res *Response, timeout time.Duration) (*Failure, error) {
 	doc, err := res.decodeJSON()
 	if err != nil {
 		return nil, err
@@ -507,7 +550,7 @@ func jqValue(v any) string {
 }
 
 func AssertBodyNotEmpty() Assertion {
-	return newAssertion("body", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("body", "", func(res *Response) (*Failure, error) {
 		body, err := bodyOf(res)
 		if err != nil {
 			return nil, err
@@ -525,7 +568,7 @@ func AssertBodyNotEmpty() Assertion {
 }
 
 func AssertBodyEqual(expContent string) Assertion {
-	return newAssertion("body", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("body", "", func(res *Response) (*Failure, error) {
 		body, err := bodyOf(res)
 		if err != nil {
 			return nil, err
@@ -561,7 +604,7 @@ func AssertBodyMatch(expPattern string) (Assertion, error) {
 		return nil, err
 	}
 
-	return newAssertion("body", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("body", "", func(res *Response) (*Failure, error) {
 		body, err := bodyOf(res)
 		if err != nil {
 			return nil, err
@@ -594,7 +637,7 @@ func AssertBodyMatch(expPattern string) (Assertion, error) {
 // redirectPrecondition reports the two ways a redirect assertion fails before
 // its Location is compared at all. Both redirect assertions share them, and
 // sharing the code is what keeps their wording identical.
-func redirectPrecondition(res *httpResponse, expected any) *Failure {
+func redirectPrecondition(res *Response, expected any) *Failure {
 	if s := res.StatusCode; s < 300 || s >= 400 {
 		return &Failure{
 			Expected: "3xx",
@@ -616,7 +659,7 @@ func redirectPrecondition(res *httpResponse, expected any) *Failure {
 }
 
 func AssertRedirectEqual(expLocation string) Assertion {
-	return newAssertion("redirect", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("redirect", "", func(res *Response) (*Failure, error) {
 		if f := redirectPrecondition(res, expLocation); f != nil {
 			return f, nil
 		}
@@ -641,7 +684,7 @@ func AssertRedirectMatch(expPattern string) (Assertion, error) {
 		return nil, err
 	}
 
-	return newAssertion("redirect", func(res *httpResponse) (*Failure, error) {
+	return newAssertion("redirect", "", func(res *Response) (*Failure, error) {
 		if f := redirectPrecondition(res, expPattern); f != nil {
 			return f, nil
 		}
@@ -659,3 +702,29 @@ func AssertRedirectMatch(expPattern string) (Assertion, error) {
 		return nil, nil
 	}), nil
 }
+
+// AssertTime holds when the phase the spec names took less than its bound.
+//
+// A response with no Timing -- one read with ReadResponse -- was never timed,
+// and the assertion says so as an error rather than passing on a zero nothing
+// measured.
+func AssertTime(spec TimeSpec) Assertion {
+	phase := timingPhases[spec.phase]
+	return newAssertion("time", phase.name, func(res *Response) (*Failure, error) {
+		if res.Timing == nil {
+			return nil, fmt.Errorf("time[%s]: the response has no timing; it was not received by a request", phase.name)
+		}
+
+		if d := phase.of(*res.Timing); !spec.holds(d) {
+			return &Failure{
+				Target:   phase.name,
+				Expected: spec.bound(),
+				Actual:   roundDuration(d).String(),
+				Message: fmt.Sprintf("time[%s]: expected %s, got %s",
+					phase.name, spec.bound(), roundDuration(d)),
+			}, nil
+		}
+
+		return nil, nil
+	})
+}
@@ -0,0 +1,183 @@
+package httpassert
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NetrcEntry is one machine of a .netrc file, or its default when Machine is
+// empty.
+type NetrcEntry struct {
+	Machine  string
+	Login    string
+	Password string
+}
+
+// ParseNetrc reads a .netrc file: machine, default, login and password
+// tokens, with account and macdef definitions skipped, # comments, and
+// double quotes around a token that holds spaces.
+func ParseNetrc(text string) ([]NetrcEntry, error) {
+	var (
+		res    []NetrcEntry
+		tokens []string
+	)
+	sc := bufio.NewScanner(strings.NewReader(text))
+	inMacro := false
+	for sc.Scan() {
+		line := sc.Text()
+		// A macro runs to the first empty line, and nothing in it is a token.
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		fields, err := netrcFields(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %q: %w", line, err)
+		}
+		for i, f := range fields {
+			if f == "macdef" {
+				fields, inMacro = fields[:i], true
+				break
+			}
+		}
+		tokens = append(tokens, fields...)
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok == "default" {
+			res = append(res, NetrcEntry{})
+			continue
+		}
+		if i+1 == len(tokens) {
+			return nil, fmt.Errorf("%q has no value", tok)
+		}
+		i++
+		switch val := tokens[i]; tok {
+		case "machine":
+			res = append(res, NetrcEntry{Machine: val})
+		case "login", "password":
+			if len(res) == 0 {
+				return nil, fmt.Errorf("%q comes before any machine", tok)
+			}
+			if tok == "login" {
+				res[len(res)-1].Login = val
+			} else {
+				res[len(res)-1].Password = val
+			}
+		case "account":
+		default:
+			return nil, fmt.Errorf("unknown token %q", tok)
+		}
+	}
+
+	return res, nil
+}
+
+// netrcFields splits a .netrc line into tokens.
+func netrcFields(line string) ([]string, error) {
+	var res []string
+	for {
+		line = strings.TrimLeft(line, " \t\r")
+		switch {
+		case line == "" || line[0] == '#':
+			return res, nil
+		case line[0] == '"':
+			tok, rest, ok := strings.Cut(line[1:], `"`)
+			if !ok {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			res, line = append(res, tok), rest
+		default:
+			end := strings.IndexAny(line, " \t\r")
+			if end < 0 {
+				end = len(line)
+			}
+			res, line = append(res, line[:end]), line[end:]
+		}
+	}
+}
+
+// netrcFor returns the entry for host: its machine, or the default.
+func netrcFor(entries []NetrcEntry, host string) (NetrcEntry, bool) {
+	for _, e := range entries {
+		if e.Machine != "" && strings.EqualFold(e.Machine, host) {
+			return e, true
+		}
+	}
+	for _, e := range entries {
+		if e.Machine == "" {
+			return e, true
+		}
+	}
+
+	return NetrcEntry{}, false
+}
+
+// authorization is the Authorization value the credentials give a request to
+// u, empty for none: BearerToken, else User, else the Netrc entry for u's
+// host. With Digest the last two wait for a challenge instead.
+func (c Client) authorization(u *url.URL) string {
+	switch {
+	case c.BearerToken != "":
+		return "Bearer " + c.BearerToken
+	case c.Digest:
+		return ""
+	case c.User != nil:
+		return basicAuth(c.User)
+	}
+	if e, ok := netrcFor(c.Netrc, u.Hostname()); ok {
+		return basicAuth(url.UserPassword(e.Login, e.Password))
+	}
+
+	return ""
+}
+
+func basicAuth(u *url.Userinfo) string {
+	pass, _ := u.Password()
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+pass))
+}
+
+// authorize sets the credentials on req. An Authorization header the caller
+// set wins, as curl's -H wins over its -u.
+func (c Client) authorize(req *http.Request) {
+	if v := c.authorization(req.URL); v != "" && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", v)
+	}
+}
+
+// redactAuth returns req with its Authorization and Proxy-Authorization
+// replaced, and the signature the Signer did, for a dump. Every such header is
+// masked, whoever set it: a token passed with -H is as secret as one from
+// --oauth2-bearer, and a dump ends up in CI logs. The scheme and a Basic user
+// stay: they are what a reader needs to tell the wrong account from the wrong
+// password. A signature the Signer redacted keeps its own, more telling form.
+func (c Client) redactAuth(req *http.Request) *http.Request {
+	res := req
+	if c.Signer != nil {
+		res = req.Clone(req.Context())
+		c.Signer.Redact(res.Header)
+	}
+	for _, name := range []string{"Authorization", "Proxy-Authorization"} {
+		v := req.Header.Get(name)
+		if v == "" || res.Header.Get(name) != v {
+			continue
+		}
+		scheme, cred, _ := strings.Cut(v, " ")
+		redacted := scheme + " xxxxx"
+		if dec, err := base64.StdEncoding.DecodeString(cred); err == nil && scheme == "Basic" {
+			user, _, _ := strings.Cut(string(dec), ":")
+			redacted = scheme + " " + user + ":xxxxx"
+		}
+		if res == req {
+			res = req.Clone(req.Context())
+		}
+		res.Header.Set(name, redacted)
+	}
+
+	return res
+}
@@ -0,0 +1,115 @@
+package httpassert
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_ParseNetrc(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseNetrc(`# CI credentials
+machine api.example.com login alice password s3cret
+machine "other.example" login bob
+  password "with space"  # trailing comment
+macdef init
+cd /pub
+machine never.example login mallory
+
+default login anonymous password guest account x
+`)
+	checkErr(t, "netrc", err, "")
+	want := []NetrcEntry{
+		{Machine: "api.example.com", Login: "alice", Password: "s3cret"},
+		{Machine: "other.example", Login: "bob", Password: "with space"},
+		{Login: "anonymous", Password: "guest"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	for text, wantErr := range map[string]string{
+		"login alice":                `"login" comes before any machine`,
+		"machine a login":            `"login" has no value`,
+		"machine a user alice":       `unknown token "user"`,
+		`machine a password "s3cret`: `line "machine a password \"s3cret": unterminated quote`,
+	} {
+		_, err := ParseNetrc(text)
+		checkErr(t, text, err, wantErr)
+	}
+}
+
+func Test_Client_authorization(t *testing.T) {
+	t.Parallel()
+
+	// Kept out of the response, which the dump shows in full.
+	var sent atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent.Store(r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	netrc := []NetrcEntry{{Machine: "127.0.0.1", Login: "carol", Password: "n3trc"}, {Login: "anon"}}
+
+	for _, tt := range []struct {
+		name   string
+		client Client
+		header string
+		want   string
+		dump   string
+	}{
+		{"basic", Client{User: url.UserPassword("alice", "s3cret")}, "",
+			"Basic YWxpY2U6czNjcmV0", "Authorization: Basic alice:xxxxx"},
+		{"bearer wins", Client{User: url.UserPassword("alice", "s3cret"), BearerToken: "t0ken"}, "",
+			"Bearer t0ken", "Authorization: Bearer xxxxx"},
+		{"netrc", Client{Netrc: netrc}, "",
+			"Basic Y2Fyb2w6bjN0cmM=", "Authorization: Basic carol:xxxxx"},
+		{"netrc after -u", Client{User: url.UserPassword("alice", "s3cret"), Netrc: netrc}, "",
+			"Basic YWxpY2U6czNjcmV0", "Authorization: Basic alice:xxxxx"},
+		// The caller's own header is sent as it is, and redacted like the
+		// rest, with credentials of the client's or without.
+		{"-H wins", Client{BearerToken: "t0ken"}, "Bearer mine",
+			"Bearer mine", "Authorization: Bearer xxxxx"},
+		{"-H alone", Client{}, "Basic ZGF2ZTpzM2NyZXQ=",
+			"Basic ZGF2ZTpzM2NyZXQ=", "Authorization: Basic dave:xxxxx"},
+	} {
+		r := Request{URL: srv.URL, Header: http.Header{}}
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		req, err := r.Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := tt.client.Run(req, AssertStatusOK())
+		if res.Err == nil {
+			t.Fatalf("%s: the 401 passed", tt.name)
+		}
+		if got := sent.Load(); got != tt.want {
+			t.Errorf("%s: sent %q, want %q", tt.name, got, tt.want)
+		}
+		if !strings.Contains(res.Err.Error(), tt.dump) {
+			t.Errorf("%s: the dump does not contain %q:\n%s", tt.name, tt.dump, res.Err)
+		}
+		if strings.Contains(res.Err.Error(), "s3cret") || strings.Contains(res.Err.Error(), "t0ken") {
+			t.Errorf("%s: the dump shows a credential:\n%s", tt.name, res.Err)
+		}
+		// The credentials go on each attempt's copy, not the caller's request.
+		if got := req.Header.Get("Authorization"); got != tt.header {
+			t.Errorf("%s: the caller's request was left with %q", tt.name, got)
+		}
+	}
+
+	// Without a machine of its own, a host gets the default.
+	req, _ := Request{URL: "http://localhost" + strings.TrimPrefix(srv.URL, "http://127.0.0.1")}.Build(t.Context())
+	Client{Netrc: netrc}.Run(req, AssertStatusOK())
+	if got := sent.Load(); got != "Basic YW5vbjo=" {
+		t.Errorf("default: sent %q, want anon's", got)
+	}
+}
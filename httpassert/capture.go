@@ -0,0 +1,167 @@
+package httpassert
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/itchyny/gojq"
+)
+
+// A capture takes a value out of a response so a later request can use it: the
+// token a login returned, the id of the thing just created, the Location it
+// lives at. Without one, a flow is a shell script that curls each step again
+// to read what the previous step already answered.
+//
+// It is checked alongside the assertions, and is one. A capture that finds
+// nothing has the same consequence as an assertion that does not hold -- the
+// next request would be sent with a hole where the value should be -- so it
+// fails the run the same way, and every report already knows how to say so.
+
+// Capture is an Assertion that remembers what it found.
+type Capture struct {
+	name    string
+	extract func(res *Response) (string, *Failure, error)
+
+	value string
+	ok    bool
+}
+
+func (c *Capture) Kind() string   { return "capture" }
+func (c *Capture) Target() string { return c.name }
+
+// Check extracts the value. The last attempt's is the one kept: a retried run
+// is judged on its last response, and so is what it captured.
+func (c *Capture) Check(res *Response) (*Failure, error) {
+	v, f, err := c.extract(res)
+	c.value, c.ok = v, f == nil && err == nil
+	if f != nil {
+		f.Kind, f.Target = c.Kind(), c.name
+	}
+
+	return f, err
+}
+
+// Name is what later requests call the value.
+func (c *Capture) Name() string { return c.name }
+
+// Value returns what the last check captured, and whether it captured anything.
+func (c *Capture) Value() (string, bool) { return c.value, c.ok }
+
+// CaptureJQ captures the one value a jq expression yields. A string is taken as
+// it is, so `.token` captures the token rather than the token in quotes; any
+// other value is captured as the JSON jq would print.
+//
+// Exactly one value, and not null. No output, or null, is what `.tokn` yields
+// for a typo, and several values leave no way to say which one was meant --
+// capturing either would send the next request something nobody asked for.
+func CaptureJQ(name, query string) (*Capture, error) {
+	q, err := gojq.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	// Compiled now for the reason AssertJQ compiles: a mistake exits 71 against
+	// the flag rather than 93 in the middle of a flow.
+	code, err := gojq.Compile(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Capture{name: name, extract: func(res *Response) (string, *Failure, error) {
+		doc, err := res.decodeJSON()
+		if err != nil {
+			return "", nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), jqTimeout)
+		defer cancel()
+
+		var outputs []any
+		it := code.RunWithContext(ctx, doc)
+		for {
+			v, ok := it.Next()
+			if !ok {
+				break
+			}
+			// An error value, as in runJQ: the query broke rather than found
+			// nothing.
+			if e, isErr := v.(error); isErr {
+				return "", nil, fmt.Errorf("capture[%s]: jq[%s]: %s", name, query, e)
+			}
+			outputs = append(outputs, v)
+		}
+
+		expected := "one value from jq " + query
+		switch {
+		case len(outputs) == 0:
+			return "", &Failure{
+				Expected: expected,
+				Message:  fmt.Sprintf("capture[%s]: jq[%s] yielded no output", name, query),
+			}, nil
+		case len(outputs) > 1:
+			return "", &Failure{
+				Expected: expected,
+				Actual:   outputs,
+				Message: fmt.Sprintf("capture[%s]: jq[%s] yielded %d values, expected one",
+					name, query, len(outputs)),
+			}, nil
+		case outputs[0] == nil:
+			return "", &Failure{
+				Expected: expected,
+				Actual:   nil,
+				Message:  fmt.Sprintf("capture[%s]: jq[%s] yielded null", name, query),
+			}, nil
+		}
+
+		if s, isString := outputs[0].(string); isString {
+			return s, nil, nil
+		}
+		return jqValue(outputs[0]), nil, nil
+	}}, nil
+}
+
+// CaptureHeader captures a response header. A header sent more than once is
+// captured as its first value.
+func CaptureHeader(name, header string) *Capture {
+	return &Capture{name: name, extract: func(res *Response) (string, *Failure, error) {
+		vs := res.Header.Values(header)
+		if len(vs) == 0 {
+			return "", &Failure{
+				Expected: "header " + header,
+				Message:  fmt.Sprintf("capture[%s]: header %s is missing", name, header),
+			}, nil
+		}
+
+		return vs[0], nil, nil
+	}}
+}
+
+// CaptureBody captures what a regexp matches in the body: its first group if
+// it has one, and the whole match otherwise.
+func CaptureBody(name, pattern string) (*Capture, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Capture{name: name, extract: func(res *Response) (string, *Failure, error) {
+		body, err := bodyOf(res)
+		if err != nil {
+			return "", nil, err
+		}
+
+		m := re.FindSubmatch(body)
+		if m == nil {
+			return "", &Failure{
+				Expected: pattern,
+				Actual:   string(body),
+				Message:  fmt.Sprintf("capture[%s]: body does not match %q", name, pattern),
+			}, nil
+		}
+		if len(m) > 1 {
+			return string(m[1]), nil, nil
+		}
+
+		return string(m[0]), nil, nil
+	}}, nil
+}
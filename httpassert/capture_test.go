@@ -0,0 +1,125 @@
+package httpassert
+
+import (
+	"net/http"
+	"testing"
+)
+
+func Test_CaptureJQ(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name  string
+		Query string
+		Body  string
+		// Value is what a passing capture holds; Want is the error text of
+		// one that fails.
+		Value string
+		Want  string
+	}{
+		{Name: "a string is taken as it is", Query: ".status", Body: jqDoc, Value: "success"},
+		{Name: "a number", Query: ".count", Body: jqDoc, Value: "5"},
+		{Name: "an object is its JSON", Query: ".users[0]", Body: jqDoc,
+			Value: `{"active":true,"id":1,"name":"alice"}`},
+		{Name: "one value among several filtered", Query: `.users[] | select(.name == "bob") | .id`,
+			Body: jqDoc, Value: "2"},
+		{Name: "a missing key", Query: ".tokn", Body: jqDoc,
+			Want: "capture[X]: jq[.tokn] yielded null"},
+		{Name: "no output", Query: ".users[] | select(.id == 99)", Body: jqDoc,
+			Want: "capture[X]: jq[.users[] | select(.id == 99)] yielded no output"},
+		{Name: "several values", Query: ".users[].id", Body: jqDoc,
+			Want: "capture[X]: jq[.users[].id] yielded 2 values, expected one"},
+		{Name: "a runtime error", Query: ".status + 1", Body: jqDoc,
+			Want: `capture[X]: jq[.status + 1]: cannot add: string ("success") and number (1)`},
+		{Name: "not JSON", Query: ".a", Body: "plain",
+			Want: "body: expected JSON, got invalid character 'p' looking for beginning of value"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			c, err := CaptureJQ("X", tc.Query)
+			if err != nil {
+				t.Fatalf("cannot build the capture: %s", err)
+			}
+
+			f, err := c.Check(jqResponse(tc.Body))
+			got := ""
+			switch {
+			case err != nil:
+				got = err.Error()
+			case f != nil:
+				got = f.Message
+				if f.Kind != "capture" || f.Target != "X" {
+					t.Errorf("failure is %s[%s], want capture[X]", f.Kind, f.Target)
+				}
+			}
+			if got != tc.Want {
+				t.Fatalf("got %q, want %q", got, tc.Want)
+			}
+
+			v, ok := c.Value()
+			if ok != (tc.Want == "") || v != tc.Value {
+				t.Errorf("Value() = %q, %t; want %q, %t", v, ok, tc.Value, tc.Want == "")
+			}
+		})
+	}
+
+	t.Run("an expression that does not compile", func(t *testing.T) {
+		if _, err := CaptureJQ("X", "no_such_func(.)"); err == nil {
+			t.Error("want an error")
+		}
+	})
+}
+
+func Test_CaptureHeader(t *testing.T) {
+	t.Parallel()
+
+	c := CaptureHeader("LOC", "Location")
+
+	res := response("201 Created", http.Header{"Location": {"/things/7", "/ignored"}}, "")
+	if f, err := c.Check(&res); f != nil || err != nil {
+		t.Fatalf("Check() = %v, %v", f, err)
+	}
+	if v, ok := c.Value(); !ok || v != "/things/7" {
+		t.Errorf("Value() = %q, %t; want the first value", v, ok)
+	}
+
+	res = response("201 Created", http.Header{}, "")
+	f, _ := c.Check(&res)
+	if f == nil || f.Message != "capture[LOC]: header Location is missing" {
+		t.Errorf("failure = %v", f)
+	}
+	if _, ok := c.Value(); ok {
+		t.Error("a failed check must not leave the earlier value behind")
+	}
+}
+
+func Test_CaptureBody(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		Pattern string
+		Value   string
+	}{
+		{`id=(\d+)`, "42"},
+		{`id=\d+`, "id=42"},
+	} {
+		c, err := CaptureBody("ID", tc.Pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := response("200 OK", http.Header{}, "created id=42\n")
+		if f, err := c.Check(&res); f != nil || err != nil {
+			t.Fatalf("%s: Check() = %v, %v", tc.Pattern, f, err)
+		}
+		if v, _ := c.Value(); v != tc.Value {
+			t.Errorf("%s: Value() = %q, want %q", tc.Pattern, v, tc.Value)
+		}
+	}
+
+	c, _ := CaptureBody("ID", `id=(\d+)`)
+	res := response("200 OK", http.Header{}, "nothing")
+	if f, _ := c.Check(&res); f == nil || f.Message != `capture[ID]: body does not match "id=(\\d+)"` {
+		t.Errorf("failure = %v", f)
+	}
+}
@@ -0,0 +1,310 @@
+package httpassert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The assertions in this file read the TLS connection the response came over:
+// net/http leaves it on the response as res.TLS, and the leaf certificate is
+// the first of its PeerCertificates. They are the checks a certificate monitor
+// makes, done on the handshake this program completes anyway.
+//
+// With -k the chain is not verified, but it is still presented, so these keep
+// working: a self-signed certificate can be checked for expiry as well as any.
+
+// leafOf returns the certificate the server presented for itself, or an error
+// saying why there is none. A plain-HTTP response and a saved one both have no
+// TLS connection, and the assertion cannot be evaluated rather than failing.
+func leafOf(label string, res *Response) (*x509.Certificate, error) {
+	if res.TLS == nil || len(res.TLS.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("%s: the response did not come over TLS", label)
+	}
+
+	return res.TLS.PeerCertificates[0], nil
+}
+
+// ParseCertWindow reads the window --assert-cert-expires-after takes: whole
+// days, as in 14d, or a duration time.ParseDuration accepts, as in 36h.
+// Certificates are renewed on a scale of days, and 336h is not how anyone
+// thinks of two weeks.
+func ParseCertWindow(text string) (time.Duration, error) {
+	text = strings.TrimSpace(text)
+
+	var d time.Duration
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number of days or a duration, e.g. 14d or 36h", text)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(text); err != nil {
+			return 0, fmt.Errorf("%q is not a number of days or a duration, e.g. 14d or 36h", text)
+		}
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%q is in the past; 0d asserts the certificate has not expired", text)
+	}
+
+	return d, nil
+}
+
+// formatDays renders a span the way the window was most likely written: 14d,
+// 3d4h, or 5h30m when it is under a day.
+func formatDays(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	switch {
+	case days > 0 && hours == 0:
+		return fmt.Sprintf("%dd", days)
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0 && minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// AssertCertExpiresAfter holds when the leaf certificate is still valid window
+// from now.
+func AssertCertExpiresAfter(window time.Duration) Assertion {
+	return newAssertion("cert", "expiry", func(res *Response) (*Failure, error) {
+		leaf, err := leafOf("cert[expiry]", res)
+		if err != nil {
+			return nil, err
+		}
+
+		left := time.Until(leaf.NotAfter)
+		if left >= window {
+			return nil, nil
+		}
+
+		when := "in " + formatDays(left)
+		if left < 0 {
+			when = "expired " + formatDays(-left) + " ago"
+		}
+
+		return &Failure{
+			Target:   "expiry",
+			Expected: "valid for " + formatDays(window),
+			Actual:   leaf.NotAfter.UTC().Format(time.RFC3339),
+			Message: fmt.Sprintf("cert[expiry]: expected %s to be valid for %s more, it expires %s (%s)",
+				leaf.Subject, formatDays(window), leaf.NotAfter.UTC().Format(time.RFC3339), when),
+		}, nil
+	})
+}
+
+// certNames lists what a certificate was issued for, in the notation openssl
+// prints: DNS:example.com, IP:127.0.0.1.
+func certNames(cert *x509.Certificate) []string {
+	var names []string
+	for _, n := range cert.DNSNames {
+		names = append(names, "DNS:"+n)
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, "IP:"+ip.String())
+	}
+
+	return names
+}
+
+// AssertCertSAN holds when the leaf certificate covers the name, the way a
+// client verifying it would decide: a wildcard covers one label, and an IP
+// address has to be listed as one. It is a "cert" assertion like the others on
+// the certificate, with the name as its target.
+func AssertCertSAN(name string) Assertion {
+	return newAssertion("cert", name, func(res *Response) (*Failure, error) {
+		leaf, err := leafOf("cert["+name+"]", res)
+		if err != nil {
+			return nil, err
+		}
+
+		if leaf.VerifyHostname(name) == nil {
+			return nil, nil
+		}
+
+		names := certNames(leaf)
+		got := strings.Join(names, ", ")
+		if len(names) == 0 {
+			got = "no names at all"
+		}
+
+		return &Failure{
+			Target:   name,
+			Expected: name,
+			Actual:   names,
+			Message: fmt.Sprintf("cert[%s]: expected %s to cover it, got %s",
+				name, leaf.Subject, got),
+		}, nil
+	})
+}
+
+// AssertCertIssuer holds when the leaf certificate's issuer, written as an RFC
+// 2253 name like CN=R3,O=Let's Encrypt,C=US, matches the pattern.
+func AssertCertIssuer(expPattern string) (Assertion, error) {
+	re, err := regexp.Compile(expPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAssertion("cert", "issuer", func(res *Response) (*Failure, error) {
+		leaf, err := leafOf("cert[issuer]", res)
+		if err != nil {
+			return nil, err
+		}
+
+		if issuer := leaf.Issuer.String(); !re.MatchString(issuer) {
+			return &Failure{
+				Target:   "issuer",
+				Expected: expPattern,
+				Actual:   issuer,
+				Message: fmt.Sprintf("cert[issuer]: expected to match %q, got %q",
+					expPattern, issuer),
+			}, nil
+		}
+
+		return nil, nil
+	}), nil
+}
+
+// tlsVersions are the versions a spec can name, oldest first.
+var tlsVersions = []struct {
+	name    string
+	version uint16
+}{
+	{"1.0", tls.VersionTLS10},
+	{"1.1", tls.VersionTLS11},
+	{"1.2", tls.VersionTLS12},
+	{"1.3", tls.VersionTLS13},
+}
+
+func tlsVersionName(v uint16) string {
+	for _, tv := range tlsVersions {
+		if tv.version == v {
+			return tv.name
+		}
+	}
+
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// TLSVersionSpec is the set of protocol versions an assertion will accept.
+type TLSVersionSpec struct {
+	op      string
+	version uint16
+	text    string
+}
+
+// ParseTLSVersionSpec reads the forms --assert-tls-version accepts: a version,
+// or a comparison and a version, as in >=1.2. The version is 1.0 to 1.3, with
+// or without a TLS in front.
+func ParseTLSVersionSpec(text string) (TLSVersionSpec, error) {
+	spec := TLSVersionSpec{text: strings.TrimSpace(text)}
+
+	rest := spec.text
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if r, ok := strings.CutPrefix(rest, op); ok {
+			spec.op, rest = op, r
+			break
+		}
+	}
+	if spec.op == "" {
+		spec.op = "="
+	}
+
+	rest = strings.TrimSpace(rest)
+	name := strings.TrimSpace(strings.TrimPrefix(strings.ToLower(rest), "tls"))
+	for _, tv := range tlsVersions {
+		if tv.name == name {
+			spec.version = tv.version
+		}
+	}
+	if spec.version == 0 {
+		return spec, fmt.Errorf("%q is not a TLS version; possible values: 1.0, 1.1, 1.2, 1.3", rest)
+	}
+
+	// A bound past either end can never hold, and is a typo rather than a
+	// fact about the server.
+	if (spec.op == ">" && spec.version == tls.VersionTLS13) ||
+		(spec.op == "<" && spec.version == tls.VersionTLS10) {
+		return spec, fmt.Errorf("%q can never hold; TLS versions run from 1.0 to 1.3", text)
+	}
+
+	return spec, nil
+}
+
+func (s TLSVersionSpec) matches(v uint16) bool {
+	switch s.op {
+	case ">=":
+		return v >= s.version
+	case "<=":
+		return v <= s.version
+	case ">":
+		return v > s.version
+	case "<":
+		return v < s.version
+	default:
+		return v == s.version
+	}
+}
+
+// AssertTLSVersion holds when the connection negotiated a version the spec
+// accepts.
+func AssertTLSVersion(spec TLSVersionSpec) Assertion {
+	return newAssertion("tls", "version", func(res *Response) (*Failure, error) {
+		if _, err := leafOf("tls[version]", res); err != nil {
+			return nil, err
+		}
+
+		if v := res.TLS.Version; !spec.matches(v) {
+			return &Failure{
+				Target:   "version",
+				Expected: spec.text,
+				Actual:   tlsVersionName(v),
+				Message: fmt.Sprintf("tls[version]: expected %s, got %s",
+					spec.text, tlsVersionName(v)),
+			}, nil
+		}
+
+		return nil, nil
+	})
+}
+
+// AssertALPN holds when the connection negotiated the application protocol,
+// such as http/1.1 or h2.
+func AssertALPN(proto string) Assertion {
+	return newAssertion("tls", "alpn", func(res *Response) (*Failure, error) {
+		if _, err := leafOf("tls[alpn]", res); err != nil {
+			return nil, err
+		}
+
+		if got := res.TLS.NegotiatedProtocol; got != proto {
+			f := &Failure{
+				Target:   "alpn",
+				Expected: proto,
+				Actual:   got,
+				Message:  fmt.Sprintf("tls[alpn]: expected %q, got %q", proto, got),
+			}
+			if got == "" {
+				f.Message = fmt.Sprintf("tls[alpn]: expected %q, none was negotiated", proto)
+			}
+
+			return f, nil
+		}
+
+		return nil, nil
+	})
+}
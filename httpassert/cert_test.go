@@ -0,0 +1,276 @@
+package httpassert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// overTLS is a response that came over a TLS 1.2 connection, negotiating
+// http/1.1, from a server presenting a certificate for *.example.com and
+// 192.0.2.1 that expires notAfter.
+func overTLS(t *testing.T, notAfter time.Time) *Response {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "api.example.com"},
+		Issuer:       pkix.Name{CommonName: "Test CA", Organization: []string{"Acme"}},
+		DNSNames:     []string{"api.example.com", "*.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("192.0.2.1")},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	// Self-signed, so the issuer is the subject; a parent with the issuer's
+	// name is what puts Acme on the leaf.
+	parent := &x509.Certificate{Subject: tmpl.Issuer}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Response{Response: &http.Response{TLS: &tls.ConnectionState{
+		Version:            tls.VersionTLS12,
+		NegotiatedProtocol: "http/1.1",
+		PeerCertificates:   []*x509.Certificate{cert},
+	}}}
+}
+
+func Test_ParseCertWindow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text    string
+		want    time.Duration
+		wantErr string
+	}{
+		{text: "14d", want: 14 * 24 * time.Hour},
+		{text: " 0d ", want: 0},
+		{text: "36h", want: 36 * time.Hour},
+		{text: "1h30m", want: 90 * time.Minute},
+		{text: "14", wantErr: `"14" is not a number of days or a duration, e.g. 14d or 36h`},
+		{text: "1.5d", wantErr: `"1.5d" is not a number of days or a duration, e.g. 14d or 36h`},
+		{text: "two weeks", wantErr: `"two weeks" is not a number of days or a duration, e.g. 14d or 36h`},
+		{text: "-1d", wantErr: `"-1d" is in the past; 0d asserts the certificate has not expired`},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCertWindow(tt.text)
+		checkErr(t, tt.text, err, tt.wantErr)
+		if err == nil && got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func Test_formatDays(t *testing.T) {
+	t.Parallel()
+
+	for d, want := range map[time.Duration]string{
+		14 * 24 * time.Hour:                        "14d",
+		3*24*time.Hour + 4*time.Hour + time.Minute: "3d4h",
+		36 * time.Hour:                             "1d12h",
+		5*time.Hour + 30*time.Minute:               "5h30m",
+		2 * time.Hour:                              "2h",
+		90 * time.Second:                           "2m",
+		0:                                          "0m",
+	} {
+		if got := formatDays(d); got != want {
+			t.Errorf("formatDays(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func Test_AssertCertExpiresAfter(t *testing.T) {
+	t.Parallel()
+
+	// Whole seconds, since a certificate keeps no more than that.
+	notAfter := time.Now().Add(10*24*time.Hour + time.Hour).Truncate(time.Second)
+	res := overTLS(t, notAfter)
+	stamp := notAfter.UTC().Format(time.RFC3339)
+
+	checkErr(t, "7d", check(AssertCertExpiresAfter(7*24*time.Hour), res), "")
+	checkErr(t, "0d", check(AssertCertExpiresAfter(0), res), "")
+	checkErr(t, "14d", check(AssertCertExpiresAfter(14*24*time.Hour), res),
+		"cert[expiry]: expected CN=api.example.com to be valid for 14d more, it expires "+stamp+" (in 10d1h)")
+
+	expired := overTLS(t, time.Now().Add(-49*time.Hour).Truncate(time.Second))
+	checkErrMatch(t, "expired", check(AssertCertExpiresAfter(0), expired),
+		`^cert\[expiry\]: expected CN=api.example.com to be valid for 0m more, it expires \S+ \(expired 2d1h ago\)$`)
+
+	f, _ := AssertCertExpiresAfter(14 * 24 * time.Hour).Check(res)
+	if f == nil || f.Expected != "valid for 14d" || f.Actual != stamp {
+		t.Errorf("got %+v, want the window and the expiry as parts", f)
+	}
+}
+
+func Test_AssertCertSAN(t *testing.T) {
+	t.Parallel()
+
+	res := overTLS(t, time.Now().Add(time.Hour))
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		{name: "api.example.com"},
+		{name: "www.example.com"},
+		{name: "API.Example.com"},
+		{name: "192.0.2.1"},
+		{name: "example.com", wantErr: "cert[example.com]: expected CN=api.example.com to cover it, " +
+			"got DNS:api.example.com, DNS:*.example.com, IP:192.0.2.1"},
+		// A wildcard covers one label, not two.
+		{name: "a.b.example.com", wantErr: "cert[a.b.example.com]: expected CN=api.example.com to cover it, " +
+			"got DNS:api.example.com, DNS:*.example.com, IP:192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		checkErr(t, tt.name, check(AssertCertSAN(tt.name), res), tt.wantErr)
+	}
+
+	a := AssertCertSAN("example.com")
+	f, err := a.Check(res)
+	if err != nil || f == nil || a.Kind() != "cert" || f.Kind != "cert" || f.Target != "example.com" {
+		t.Errorf("got kind %q and %+v, %v; want a cert failure for example.com", a.Kind(), f, err)
+	}
+}
+
+func Test_AssertCertIssuer(t *testing.T) {
+	t.Parallel()
+
+	res := overTLS(t, time.Now().Add(time.Hour))
+
+	a, err := AssertCertIssuer(`O=Acme\b`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkErr(t, "match", check(a, res), "")
+
+	a, _ = AssertCertIssuer(`Let's Encrypt`)
+	checkErr(t, "mismatch", check(a, res), `cert[issuer]: expected to match "Let's Encrypt", got "CN=Test CA,O=Acme"`)
+
+	if _, err := AssertCertIssuer("("); err == nil {
+		t.Error("a broken pattern compiled")
+	}
+}
+
+func Test_ParseTLSVersionSpec(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text    string
+		holds   []uint16
+		wantErr string
+	}{
+		{text: "1.2", holds: []uint16{tls.VersionTLS12}},
+		{text: "=1.3", holds: []uint16{tls.VersionTLS13}},
+		{text: ">=1.2", holds: []uint16{tls.VersionTLS12, tls.VersionTLS13}},
+		{text: "> TLS1.1", holds: []uint16{tls.VersionTLS12, tls.VersionTLS13}},
+		{text: "<1.2", holds: []uint16{tls.VersionTLS10, tls.VersionTLS11}},
+		{text: "<=tls 1.0", holds: []uint16{tls.VersionTLS10}},
+		{text: ">=1.4", wantErr: `"1.4" is not a TLS version; possible values: 1.0, 1.1, 1.2, 1.3`},
+		{text: "SSLv3", wantErr: `"SSLv3" is not a TLS version; possible values: 1.0, 1.1, 1.2, 1.3`},
+		{text: ">1.3", wantErr: `">1.3" can never hold; TLS versions run from 1.0 to 1.3`},
+		{text: "<1.0", wantErr: `"<1.0" can never hold; TLS versions run from 1.0 to 1.3`},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParseTLSVersionSpec(tt.text)
+		checkErr(t, tt.text, err, tt.wantErr)
+		if err != nil {
+			continue
+		}
+		for _, tv := range tlsVersions {
+			want := false
+			for _, v := range tt.holds {
+				want = want || v == tv.version
+			}
+			if got := spec.matches(tv.version); got != want {
+				t.Errorf("%s: matches(%s) = %v, want %v", tt.text, tv.name, got, want)
+			}
+		}
+	}
+}
+
+func Test_AssertTLSVersion_and_ALPN(t *testing.T) {
+	t.Parallel()
+
+	res := overTLS(t, time.Now().Add(time.Hour))
+	spec := func(text string) TLSVersionSpec {
+		s, err := ParseTLSVersionSpec(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	checkErr(t, ">=1.2", check(AssertTLSVersion(spec(">=1.2")), res), "")
+	checkErr(t, "1.3", check(AssertTLSVersion(spec("1.3")), res), "tls[version]: expected 1.3, got 1.2")
+	checkErr(t, "http/1.1", check(AssertALPN("http/1.1"), res), "")
+	checkErr(t, "h2", check(AssertALPN("h2"), res), `tls[alpn]: expected "h2", got "http/1.1"`)
+
+	res.TLS.NegotiatedProtocol = ""
+	checkErr(t, "none", check(AssertALPN("h2"), res), `tls[alpn]: expected "h2", none was negotiated`)
+}
+
+// Plain HTTP and a saved response have no connection to read, and each
+// assertion says so rather than failing the server for it.
+func Test_TLSAssertions_withoutTLS(t *testing.T) {
+	t.Parallel()
+
+	issuer, _ := AssertCertIssuer(".")
+	version, _ := ParseTLSVersionSpec("1.3")
+	res := &Response{Response: &http.Response{StatusCode: http.StatusOK}}
+
+	for _, tt := range []struct {
+		a    Assertion
+		want string
+	}{
+		{AssertCertExpiresAfter(0), "cert[expiry]: the response did not come over TLS"},
+		{AssertCertSAN("a.example"), "cert[a.example]: the response did not come over TLS"},
+		{issuer, "cert[issuer]: the response did not come over TLS"},
+		{AssertTLSVersion(version), "tls[version]: the response did not come over TLS"},
+		{AssertALPN("h2"), "tls[alpn]: the response did not come over TLS"},
+	} {
+		f, err := tt.a.Check(res)
+		if f != nil {
+			t.Errorf("%s: got a failure, want an error: %s", tt.want, f.Message)
+		}
+		checkErr(t, tt.want, err, tt.want)
+	}
+}
+
+// The transport offers http/1.1, so a server that speaks only that has
+// something to agree to and --assert-alpn http/1.1 can hold.
+func Test_Client_negotiatesALPN(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	req, err := Request{URL: srv.URL}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, _ := ParseTLSVersionSpec(">=1.2")
+	c := Client{SkipSslChecks: true}
+	if err := c.Do(req, AssertALPN("http/1.1"), AssertTLSVersion(version), AssertCertSAN("example.com")); err != nil {
+		t.Error(err)
+	}
+}
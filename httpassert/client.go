@@ -0,0 +1,821 @@
+package httpassert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// LogLevel is how much a Client says about a run while it happens. The zero
+// value is LError, which says nothing: the outcome is the returned error.
+type LogLevel int
+
+const (
+	LError LogLevel = iota
+	LWarn
+	LInfo
+	LDebug
+)
+
+// Client makes a request and checks the response. The zero value sends one
+// attempt with no timeout, follows no redirects, and logs nothing.
+type Client struct {
+	// LogLevel is how much Log hears, and whether the failure dump shows the
+	// response body: below LInfo it is left out, as the CLI's -s leaves it.
+	LogLevel LogLevel
+	// Log receives the sigil lines -- [.] request, [:] response, [+] or [-]
+	// verdict -- one Write per line. Nil discards them.
+	Log io.Writer
+	// Transport sends the requests in place of the one Client builds, which
+	// is how a test reaches an httptest.Server or a bare handler. SkipSslChecks,
+	// the TLS and protocol fields and HostMappings only configure the built one
+	// and are ignored with it.
+	Transport     http.RoundTripper
+	SkipSslChecks bool
+	// Certificates are presented to a server that asks the client for one,
+	// as a service behind mutual TLS does. LoadClientCertificate reads one.
+	Certificates []tls.Certificate
+	// RootCAs verifies the server in place of the system's authorities, for
+	// a service signed by a private CA. Nil trusts the system's; LoadCertPool
+	// reads a pool.
+	RootCAs *x509.CertPool
+	// PinnedPubKeys, when set, refuse a server whose leaf key is none of
+	// them, even one with a valid chain. The failure is a transport error
+	// wrapping a *PinMismatchError.
+	PinnedPubKeys []PubKeyPin
+	// MinTLSVersion and MaxTLSVersion bound the versions offered, as
+	// tls.VersionTLS12 and friends; zero leaves crypto/tls's default.
+	MinTLSVersion uint16
+	MaxTLSVersion uint16
+	// CipherSuites restricts the TLS 1.0-1.2 suites offered. Nil offers
+	// crypto/tls's default set.
+	CipherSuites []uint16
+	// HTTP2 offers h2 alongside http/1.1 in the TLS handshake and speaks
+	// whichever the server picks. A plain http:// request stays HTTP/1.1.
+	HTTP2 bool
+	// HTTP2PriorKnowledge speaks HTTP/2 without negotiating for it: cleartext
+	// h2c for http://, and h2 alone over TLS, so a server that cannot speak it
+	// fails rather than being answered in HTTP/1.1.
+	HTTP2PriorKnowledge bool
+	// HTTP3 sends the request over QUIC, and only over QUIC: a server that
+	// does not answer there fails the attempt rather than being asked over
+	// TCP. It needs an https:// URL, and TLS 1.3.
+	HTTP3   bool
+	Timeout time.Duration
+	// HostMappings send connections elsewhere; the first that matches an
+	// address applies.
+	HostMappings []HostMapping
+	// Resolve gives the addresses of some host:port pairs in place of DNS.
+	// It applies after HostMappings, to the address they map to; ParseResolve
+	// reads an entry.
+	Resolve []ResolveEntry
+	// DNSServers are asked, as ip:port, in place of the system's resolver.
+	DNSServers []string
+	// dnsTurn picks the next of DNSServers for each query, across every
+	// resolver one run builds; see withDNSTurn.
+	dnsTurn *atomic.Uint32
+	// IPVersion, 4 or 6, connects over that address family alone. Zero allows
+	// either.
+	IPVersion int
+	// UnixSocket, when set, is dialled for every connection in place of the
+	// URL's host, which still gives the Host header and, for https://, the
+	// name the certificate is checked against. HostMappings, Resolve,
+	// DNSServers and IPVersion are not consulted.
+	UnixSocket string
+	// Proxy, when set, carries every request in place of the proxies
+	// HTTP_PROXY and HTTPS_PROXY name. ParseProxy reads one; credentials go
+	// in its User. HostMappings and the resolution fields then apply to the
+	// connection to the proxy.
+	Proxy *url.URL
+	// NoProxy lists the hosts reached directly despite a proxy, Proxy or the
+	// environment's: "*" matches every host, an address or CIDR block
+	// matches addresses, and a name matches itself and its subdomains.
+	NoProxy []string
+	// NoProxyEnv ignores HTTP_PROXY, HTTPS_PROXY and NO_PROXY, which are
+	// otherwise honoured as net/http honours them.
+	NoProxyEnv bool
+	// User is sent as Basic credentials, and BearerToken, which wins over
+	// it, as an OAuth 2.0 bearer token. Netrc supplies Basic credentials by
+	// host when neither is set; ParseNetrc reads a .netrc file. A request
+	// with an Authorization header of its own keeps it, and a failure dump
+	// shows these redacted.
+	User        *url.Userinfo
+	BearerToken string
+	Netrc       []NetrcEntry
+	// Digest sends User and Netrc credentials only in answer to a Digest
+	// challenge: a 401 is answered and the request sent again within the
+	// attempt, and Response.Challenge records that it was.
+	Digest bool
+	// OAuth2 gets the bearer token from a token endpoint, in place of
+	// BearerToken, before the first attempt and again whenever the one it
+	// has is about to expire. A failure to get one is a transport failure,
+	// and the attempt's SendErr a *TokenError.
+	OAuth2 *ClientCredentials
+	// Signer signs every attempt afresh, after the credentials are set, so a
+	// retry carries a timestamp of its own. AWSSigV4 and HMACSigner are the
+	// two there are; a failure dump shows their signatures redacted.
+	Signer Signer
+	// Jar keeps the cookies responses set and sends them back, to the later
+	// attempts and any other run sharing it. Nil keeps each attempt's cookies
+	// for its own redirect chain only, unless there are Cookies.
+	Jar *CookieJar
+	// Cookies are sent as curl's -b name=value sends them; a redirect carries
+	// them to the same host. They go into the jar once, when the run starts
+	// -- a new one for the run when Jar is nil -- so one a response sets in
+	// their place replaces them rather than being sent alongside, and one it
+	// deletes is not sent again by a retry.
+	Cookies []*http.Cookie
+	// responseCookies are the cookies the run's responses set, kept apart
+	// from the jar's others for AssertCookie, which asks what the server set.
+	responseCookies *CookieJar
+	// FollowRedirects turns a 3xx into another request rather than the
+	// response the assertions run against.
+	FollowRedirects bool
+	// MaxRedirects bounds the chain. Zero refuses every redirect; it is only
+	// read when FollowRedirects is set.
+	MaxRedirects int
+	// Retries is how many further attempts may follow a failed one. Zero makes
+	// the request once, which is the default and the behaviour that predates
+	// retrying.
+	Retries int
+	// RetryDelay is the fixed wait between attempts. Only read when Retries is
+	// positive.
+	RetryDelay time.Duration
+	// RetryMaxTime bounds the whole run, measured from the first attempt. Zero
+	// means only Retries bounds it. It is checked before each retry rather than
+	// enforced mid-flight, so an attempt already under way can overrun it by up
+	// to one Timeout -- which is curl's meaning for the same option.
+	RetryMaxTime time.Duration
+}
+
+func (c *Client) Init() {
+	// Just print the configuration
+	if len(c.HostMappings) > 0 {
+		c.logDebug("HostMappings %d:\n", len(c.HostMappings))
+		for i := range c.HostMappings {
+			c.logDebug("- %q -> %q\n", c.HostMappings[i].Src, c.HostMappings[i].Dst)
+		}
+	}
+}
+
+// Errors a run ends with wrap one of these, so errors.Is can tell whose fault
+// it was without reading the message: the caller's for ErrNoAssertions, the
+// network's for ErrTransport, the response's for ErrAssertion. The CLI's exit
+// codes are these three.
+var (
+	ErrNoAssertions = errors.New("no assertions defined")
+	ErrTransport    = errors.New("no usable response")
+	ErrAssertion    = errors.New("assertion failed")
+)
+
+// runError is a run's failure: a message written for a person, and a kind for
+// errors.Is.
+type runError struct {
+	kind error
+	msg  string
+}
+
+func (e *runError) Error() string { return e.msg }
+func (e *runError) Unwrap() error { return e.kind }
+
+// errTooManyRedirects marks the one transport-shaped failure this program
+// produces itself. Do needs to tell it apart from a network failure, and
+// matching on net/http's message text would be a promise net/http never made.
+var errTooManyRedirects = errors.New("too many redirects")
+
+// Result is everything one run observed: the attempts in order, and the
+// error Do reports for them.
+//
+// Do keeps returning a bare error, because that is all the process exit needs.
+// The rest exists for the report writers, which have to say which assertion
+// failed on which attempt without parsing the prose the dump is made of.
+type Result struct {
+	Request    *http.Request
+	Assertions []Assertion
+	Attempts   []Attempt
+	Err        error
+}
+
+// Attempt is one try at the request.
+type Attempt struct {
+	StartedAt time.Time
+	Duration  time.Duration
+	// Response is nil when no usable response arrived, and SendErr says why.
+	Response *Response
+	SendErr  error
+	// Checks holds one entry per assertion, in the order they were given. It
+	// is empty when there was no response to check.
+	Checks []CheckResult
+	// Details is the request and response dump a failed attempt printed, and
+	// empty for one that passed.
+	Details string
+	// Err is the attempt's failure as doOnce reports it, nil when it passed.
+	Err error
+}
+
+// CheckResult is one assertion's verdict on one response. At most one of
+// Failure and Err is set, with the meaning Assertion.Check gives them.
+type CheckResult struct {
+	Assertion Assertion
+	Failure   *Failure
+	Err       error
+}
+
+// Do makes the request and checks the response against every assertion,
+// retrying a failed attempt up to Retries times.
+//
+// Any failure is retried, an unreachable host and a wrong answer alike. The
+// case retrying exists for is waiting for a service to come up, and there the
+// response usually arrives perfectly well and says the wrong thing, so a rule
+// that retried only transport errors would miss the whole point.
+//
+// The error wraps ErrAssertion or ErrTransport, or is ErrNoAssertions, and its
+// message is the failure dump the CLI prints.
+func (c Client) Do(req *http.Request, assertions ...Assertion) error {
+	return c.Run(req, assertions...).Err
+}
+
+// Run is Do with the evidence kept, for a caller that reports more than the
+// verdict.
+func (c Client) Run(req *http.Request, assertions ...Assertion) *Result {
+	res := &Result{Request: req, Assertions: assertions}
+	if len(assertions) == 0 {
+		// Not a failed attempt but a malformed invocation, so it is reported
+		// once rather than retried into the ground. The CLI checks this before
+		// calling; the guard is for every other caller.
+		res.Err = ErrNoAssertions
+		return res
+	}
+	c = c.withDNSTurn()
+	if len(c.Cookies) > 0 {
+		if c.Jar == nil {
+			c.Jar = NewCookieJar()
+		}
+		c.Jar.seed(req.URL, c.Cookies)
+	}
+	c.responseCookies = NewCookieJar()
+
+	// Built once rather than per attempt: an http.Transport owns an idle
+	// connection pool, and a fresh one per attempt would leave --retry 100 of
+	// them behind for the lifetime of the process.
+	client := c.getHttpClient()
+	// A QUIC connection holds a UDP socket open until the server times it
+	// out, and nothing will reuse it once the run is over.
+	defer client.CloseIdleConnections()
+	startedAt := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		// A mapping's next destination needs a new connection; a pooled one
+		// would take the attempt back to the last.
+		if attempt > 1 && c.rotates() {
+			client.CloseIdleConnections()
+		}
+		a := c.doOnce(client, req.WithContext(withAttempt(req.Context(), attempt)), assertions)
+		res.Attempts = append(res.Attempts, a)
+		if a.Err == nil {
+			return res
+		}
+
+		switch {
+		case attempt > c.Retries:
+			res.Err = c.giveUp(attempt, "", a.Err)
+			return res
+		// Checked before sleeping rather than after, so the run ends at the
+		// budget instead of one delay past it.
+		case c.RetryMaxTime > 0 && time.Since(startedAt)+c.RetryDelay > c.RetryMaxTime:
+			res.Err = c.giveUp(attempt, "--retry-max-time is "+c.RetryMaxTime.String(), a.Err)
+			return res
+		}
+
+		c.logInfo("[~] retry %d/%d in %s\n", attempt, c.Retries, c.RetryDelay)
+		time.Sleep(c.RetryDelay)
+	}
+}
+
+// giveUp reports the last failure together with how the run ended.
+//
+// Without the prefix a CI log shows a single failed attempt and no sign that
+// five more happened, which reads as a service that was never up rather than
+// one that never came up. Nothing is added when nothing was retried, so a plain
+// run says exactly what it always said.
+func (c Client) giveUp(attempts int, limit string, err error) error {
+	if c.Retries <= 0 {
+		return err
+	}
+
+	if limit != "" {
+		limit = " (" + limit + ")"
+	}
+	return fmt.Errorf("gave up after %d attempts%s:\n%w", attempts, limit, err)
+}
+
+// doOnce performs one request and checks it against every assertion.
+func (c Client) doOnce(client *http.Client, req *http.Request, assertions []Assertion) (a Attempt) {
+	a.StartedAt = time.Now()
+	defer func() { a.Duration = time.Since(a.StartedAt) }()
+
+	if c.OAuth2 != nil {
+		tc := c.tokenClient()
+		token, fresh, err := c.OAuth2.Token(req.Context(), tc)
+		tc.CloseIdleConnections()
+		if err != nil {
+			// Labelled apart from a send failure: the service being checked
+			// was never asked, so nothing here says it is down.
+			if c.Retries > 0 {
+				c.logInfo("[-] FAILED %s: %s\n", time.Since(a.StartedAt), err)
+			}
+			var b strings.Builder
+			c.writeHttpDetails(&b, req, nil)
+			a.SendErr, a.Details = err, b.String()
+			a.Err = &runError{ErrTransport, fmt.Sprintf(
+				"failed to get an OAuth 2.0 token, so the request was not sent:\n- %s\n%s", err, a.Details)}
+			return a
+		}
+		if fresh {
+			c.logInfo("[.] OAuth 2.0 token from %s", c.OAuth2.TokenURL)
+		}
+		c.BearerToken = token
+	}
+	next, err := c.prepareAttempt(req)
+	if err != nil {
+		var b strings.Builder
+		c.writeHttpDetails(&b, req, nil)
+		a.SendErr, a.Details = err, b.String()
+		a.Err = &runError{ErrTransport, fmt.Sprintf(
+			"failed to prepare the request:\n- %s\n%s", err, a.Details)}
+		return a
+	}
+	req = next
+	// Without a Jar of the caller's, the attempt still keeps what its own
+	// redirect chain sets: a login hop's session cookie is what the hop it
+	// redirects to asks for.
+	jar := c.Jar
+	if jar == nil {
+		jar = NewCookieJar()
+	}
+	recorder := cookieRecorder{CookieJar: jar, set: c.responseCookies}
+	attemptClient := *client
+	attemptClient.Jar = recorder
+	client = &attemptClient
+
+	// A stale HTTP_PROXY on a build agent looks like a broken service unless
+	// the log says the request never went to it directly.
+	switch via := c.via(req); via {
+	case "":
+		c.logInfo("[.] %s %s %s", req.Proto, req.Method, req.URL.Redacted())
+	default:
+		c.logInfo("[.] %s %s %s (via %s)", req.Proto, req.Method, req.URL.Redacted(), via)
+	}
+	// G704: the request URL comes from the operator's own command line, and
+	// fetching it is the entire purpose of this tool -- no trust boundary is
+	// crossed, so this is not SSRF.
+	//
+	// --location widens that slightly: the hops after the first are chosen by
+	// the server, not the operator. It stays opt-in for exactly that reason,
+	// and net/http drops Authorization and Cookie when a hop leaves the
+	// original domain, so credentials passed with -H do not travel.
+	//
+	// Timed from here rather than from StartedAt, so that rewinding the body
+	// is not billed to the server.
+	tm := newTimer()
+	// With -L the last connection is the one the response came over.
+	var remoteAddr string
+	var challenge Challenge
+	ctx := withChallenge(req.Context(), &challenge)
+	ctx = httptrace.WithClientTrace(ctx, tm.trace())
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { remoteAddr = info.Conn.RemoteAddr().String() },
+	})
+	req = req.WithContext(ctx)
+	res, err := client.Do(req) // #nosec G704 - user asked for this URL
+	headersAt := time.Now()
+	if err != nil {
+		var b strings.Builder
+		// The transport did its job here; this program stopped the chain.
+		// Filing that under "failed to send request" sends the reader looking
+		// for a network problem that does not exist.
+		var pin *PinMismatchError
+		switch {
+		case errors.Is(err, errTooManyRedirects):
+			fmt.Fprintf(&b, "redirect chain was not followed to the end:\n- %s\n", err)
+		// Not a network failure either: the server answered, with a key
+		// other than the one expected. The observed pin is what tells a
+		// rotation from an impostor, and it is buried in net/http's text.
+		case errors.As(err, &pin):
+			fmt.Fprintf(&b, "the server's public key is not pinned:\n- observed %s\n", pin.Observed)
+			for _, p := range pin.Pinned {
+				fmt.Fprintf(&b, "- pinned   %s\n", p)
+			}
+		default:
+			fmt.Fprintf(&b, "failed to send request:\n- %s\n", err)
+		}
+		// This path logs nothing between [.] and the dump the caller prints,
+		// which is fine for a single attempt and unreadable for twenty. The
+		// line appears only while retrying so that a plain run is untouched.
+		if c.Retries > 0 {
+			c.logInfo("[-] FAILED %s: %s\n", time.Since(a.StartedAt), err)
+		}
+		var d strings.Builder
+		c.writeHttpDetails(&d, req, nil)
+		a.SendErr, a.Details = err, d.String()
+		a.Err = &runError{ErrTransport, b.String() + a.Details}
+		return a
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	// Behind round-robin DNS, which address answered is half the verdict.
+	if remoteAddr != "" {
+		c.logInfo("[:] %s %s (from %s)\n", res.Proto, res.Status, remoteAddr)
+	} else {
+		c.logInfo("[:] %s %s\n", res.Proto, res.Status)
+	}
+	httpRes := &Response{Response: res, RemoteAddr: remoteAddr}
+	if challenge.Status != "" {
+		httpRes.Challenge = &challenge
+	}
+	httpRes.JarCookies = append([]*http.Cookie{}, recorder.setFor(res.Request.URL)...)
+	httpRes.BodyBytes, _ = io.ReadAll(res.Body)
+	httpRes.Timing = tm.done(headersAt)
+	httpRes.decodeBody()
+	a.Response = httpRes
+	c.logDebug("[:] timing: %s\n", httpRes.Timing)
+
+	c.checkResponse(&a, httpRes, assertions, func(w io.Writer) {
+		c.writeHttpDetails(w, req, httpRes)
+	})
+	return a
+}
+
+// checkResponse checks a response against every assertion and records the
+// verdicts on the attempt. details renders the failure dump, which is the one
+// part that depends on where the response came from.
+func (c Client) checkResponse(a *Attempt, httpRes *Response, assertions []Assertion,
+	details func(w io.Writer),
+) {
+	var assertErrors []error
+	for i := range assertions {
+		// A failed assertion and one that could not be evaluated are both
+		// failures of the run and both print the same way, so they share a
+		// list -- which is also what keeps the dump in the order the
+		// assertions were given. Only a machine-readable consumer needs to
+		// tell them apart, and that is what Check separates them for (#45).
+		f, err := assertions[i].Check(httpRes)
+		a.Checks = append(a.Checks, CheckResult{Assertion: assertions[i], Failure: f, Err: err})
+		switch {
+		case err != nil:
+			assertErrors = append(assertErrors, err)
+		case f != nil:
+			assertErrors = append(assertErrors, f)
+		}
+	}
+	if len(assertErrors) > 0 {
+		c.logInfo("[-] FAILED %s\n\n", time.Since(a.StartedAt))
+
+		var b, d strings.Builder
+		fmt.Fprintf(&b, "%d assertions failed:\n", len(assertErrors))
+		for i := range assertErrors {
+			fmt.Fprintf(&b, "- %s\n", assertErrors[i])
+		}
+		details(&d)
+		a.Details = d.String()
+		a.Err = &runError{ErrAssertion, b.String() + a.Details}
+		return
+	}
+
+	c.logInfo("[+] PASSED %s\n\n", time.Since(a.StartedAt))
+}
+
+// cloneForAttempt returns a request that can be sent even if the one it was
+// built from already has been.
+//
+// http.Client consumes and closes req.Body, so re-sending the same *http.Request
+// carries an empty body unless net/http can replay it through GetBody. A request
+// from Request.Build can -- its body is a bytes.Reader, one of the three types
+// http.NewRequest recognises -- but that is a property of the body type rather
+// than a guarantee, and a body without GetBody would silently send
+// nothing on the second attempt. Cloning costs six lines and does not depend on
+// which reader the caller happened to pass.
+func cloneForAttempt(req *http.Request) (*http.Request, error) {
+	res := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("cannot rewind the body: %w", err)
+		}
+		res.Body = body
+	} // else no body, or http.NoBody, which re-reads as empty
+
+	return res, nil
+}
+
+// prepareAttempt is cloneForAttempt with the credentials and the signature set
+// on the clone. The caller's request is left as it was given:
+// credentials written into its headers would be taken for the caller's own on
+// the next attempt, and kept there after a token was refreshed.
+//
+// The clone is signed here, when there is a signer, rather than once for the
+// run: a signature holds a timestamp, and a retry that resent the first one
+// would be refused for its age after a minute or so of --retry.
+func (c Client) prepareAttempt(req *http.Request) (*http.Request, error) {
+	res, err := cloneForAttempt(req)
+	if err != nil {
+		return nil, err
+	}
+	c.authorize(res)
+	if c.Signer == nil {
+		return res, nil
+	}
+
+	// The signature covers the body, so it is read here and handed over as
+	// bytes in its place.
+	var payload []byte
+	if res.Body != nil && res.Body != http.NoBody {
+		if payload, err = io.ReadAll(res.Body); err != nil {
+			return nil, fmt.Errorf("cannot read the body: %w", err)
+		}
+		_ = res.Body.Close()
+		res.Body = io.NopCloser(bytes.NewReader(payload))
+	}
+	if err := c.Signer.Sign(res, payload, time.Now()); err != nil {
+		return nil, fmt.Errorf("cannot sign it: %w", err)
+	}
+
+	return res, nil
+}
+
+func (c Client) writeHttpDetails(w io.Writer, req *http.Request, res *Response) {
+	req = c.redactAuth(req)
+	_, _ = fmt.Fprintf(w, "\nFAILED: %s %s (%s)\n\n", req.Method, req.URL.Redacted(), req.Proto)
+	// The URL names a host that was never dialled. A refused connection
+	// reads as the service being down, when it may be the wrong socket.
+	if c.UnixSocket != "" {
+		_, _ = fmt.Fprintf(w, "Unix socket: %s\n\n", c.UnixSocket)
+	}
+	if p, _ := c.proxyFor(req.URL); p != nil {
+		_, _ = fmt.Fprintf(w, "Proxy: %s\n\n", p.Redacted())
+	}
+	// With --location the response below came from somewhere else, and the
+	// request dumped after this is the one that started the chain rather than
+	// the one that produced it. Say so; a reader cannot infer it. The method
+	// is worth printing too, because a 302 rewrites POST to GET.
+	if res != nil && res.Request != nil && res.Request.URL.String() != req.URL.String() {
+		_, _ = fmt.Fprintf(w, "Followed to: %s %s\n\n", res.Request.Method, res.Request.URL)
+	}
+	if res != nil && res.RemoteAddr != "" && c.UnixSocket == "" {
+		_, _ = fmt.Fprintf(w, "Connected to: %s\n\n", res.RemoteAddr)
+	}
+	// The request below carries no Authorization; the one that answered the
+	// challenge did, and the response is to that one.
+	if res != nil && res.Challenge != nil {
+		_, _ = fmt.Fprintf(w, "Challenged: %s\n\n", res.Challenge)
+	}
+	writeRequest(w, req)
+	_, _ = w.Write([]byte("\n\n"))
+	if res != nil {
+		res.writeTo(w, c.LogLevel >= LInfo)
+		_, _ = w.Write([]byte("\n\n"))
+		// Printed for every failure, not only a failed --assert-time: a
+		// response that is wrong and slow is often wrong because it was slow.
+		if res.Timing != nil {
+			_, _ = fmt.Fprintf(w, "Timing: %s\n\n", res.Timing)
+		}
+	}
+}
+
+// writeRequest renders the request for a person reading a failure report, the
+// way writeTo renders the response.
+//
+// http.Request.Write alone gets this wrong twice. Its body has already been
+// consumed by the send, so it emits headers claiming a Content-Length with
+// nothing behind them -- the first question after a failed POST is "what did I
+// send?", and that was the one thing the dump left out (#19). And it is a
+// wire-format serializer, so a long or binary payload would land in the report
+// raw: the same mistake #18 fixed on the response side.
+//
+// So the headers come from Write, which knows what actually goes on the wire
+// (Host, User-Agent, Content-Length are none of them in req.Header), and the
+// body goes through the shared renderer that crops and hex-dumps.
+func writeRequest(w io.Writer, req *http.Request) {
+	// A fresh clone replays the body; without GetBody there is nothing to
+	// replay and the dump is no worse than it was.
+	dump := req
+	if fresh, err := cloneForAttempt(req); err == nil {
+		dump = fresh
+	}
+
+	var b bytes.Buffer
+	if err := dump.Write(&b); err != nil {
+		// A failed dump must not replace the failure being reported, so
+		// whatever was rendered before the error still goes out.
+		_, _ = w.Write(b.Bytes())
+		return
+	}
+
+	head, body, found := bytes.Cut(b.Bytes(), []byte("\r\n\r\n"))
+	_, _ = w.Write(head)
+	_, _ = w.Write([]byte("\r\n\r\n"))
+	if !found || len(body) == 0 {
+		return
+	}
+
+	if cropped := printPayload(w, body, maxPayloadBytes); cropped > 0 {
+		_, _ = fmt.Fprintf(w, "\n\n  << Payload is cropped: %d bytes are hidden >>", cropped)
+	}
+}
+
+func (c Client) getHttpClient() *http.Client {
+	var tr http.RoundTripper = c.getTransport()
+	if c.Digest {
+		tr = digestTransport{c: c, next: tr}
+	}
+
+	hc := &http.Client{
+		Timeout: c.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !c.FollowRedirects {
+				// The default. Hand the 3xx to the assertions intact --
+				// --assert-redirect* has nothing to look at otherwise.
+				return http.ErrUseLastResponse
+			}
+
+			// via holds the requests already sent, so the k-th redirect sees
+			// len(via) == k. net/http's own default writes >= here and so
+			// follows one fewer hop than its message claims; > is what makes
+			// --max-redirs N mean N, and --max-redirs 0 mean none.
+			if len(via) > c.MaxRedirects {
+				return fmt.Errorf("%w: --max-redirs is %d", errTooManyRedirects, c.MaxRedirects)
+			}
+
+			c.logInfo("[>] %d %s %s", len(via), req.Method, req.URL.Redacted())
+			return nil
+		},
+		Transport: tr,
+	}
+	// A nil *CookieJar in the interface would be a jar that panics.
+	if c.Jar != nil {
+		hc.Jar = c.Jar
+	}
+
+	return hc
+}
+
+// tokenClient returns the client the OAuth 2.0 token request goes through.
+// The token endpoint is another service than the one checked, so it shares
+// only the TLS and proxy settings: the Unix socket, host mappings and DNS
+// servers point at the checked service, and the pins, Digest and the jar
+// answer to it alone.
+func (c Client) tokenClient() *http.Client {
+	t := Client{
+		Transport:     c.Transport,
+		SkipSslChecks: c.SkipSslChecks,
+		Certificates:  c.Certificates,
+		RootCAs:       c.RootCAs,
+		MinTLSVersion: c.MinTLSVersion,
+		MaxTLSVersion: c.MaxTLSVersion,
+		CipherSuites:  c.CipherSuites,
+		Proxy:         c.Proxy,
+		NoProxy:       c.NoProxy,
+		NoProxyEnv:    c.NoProxyEnv,
+	}
+
+	return &http.Client{Timeout: c.Timeout, Transport: t.getTransport()}
+}
+
+// getTransport returns the caller's Transport, or builds the one every other
+// field configures.
+func (c Client) getTransport() http.RoundTripper {
+	if c.Transport != nil {
+		return c.Transport
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 20 * time.Second,
+		Resolver:  c.resolver(),
+	}
+
+	tr := &http.Transport{
+		// net/http decodes a gzip response only when it was the layer that
+		// asked for it, and hands over the raw bytes otherwise. Four unrelated
+		// conditions decide which happens -- a caller-set Accept-Encoding, a
+		// Range header, the method, and this field -- so whether --assert-body
+		// saw the payload or a compressed blob depended on flags that have
+		// nothing to do with the body (#27).
+		//
+		// Decoding is done here instead, in decodeBody, on every response. One
+		// path, and the request carries exactly the headers it was told to.
+		DisableCompression:    true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       20 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			p, err := c.proxyFor(req.URL)
+			if isSOCKS(p) && p == c.Proxy {
+				return nil, err // dialled below
+			}
+			return p, err
+		},
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, _, _ := net.SplitHostPort(addr)
+			switch {
+			case c.UnixSocket != "":
+				return dialer.DialContext(ctx, "unix", c.UnixSocket)
+			case isSOCKS(c.Proxy) && !bypassProxy(c.NoProxy, host):
+				return c.dialSOCKS(ctx, dialer, c.Proxy, addr)
+			}
+			return c.dialTCP(ctx, dialer, addr)
+		},
+	}
+	// HTTP/1.1 unless asked otherwise. A run that quietly moved to HTTP/2
+	// because the server offered it would check a different exchange from the
+	// one the flags describe -- and the bugs worth asserting on are often the
+	// ones only one of the two protocols has.
+	//
+	// The ALPN list says the same thing to the server. http/1.1 is offered
+	// even alone so the server has something to negotiate: without it the
+	// handshake carries no ALPN at all, and --assert-alpn could never hold
+	// against a server that speaks nothing but HTTP/1.1.
+	var protocols http.Protocols
+	nextProtos := []string{"http/1.1"}
+	switch {
+	case c.HTTP2PriorKnowledge:
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		nextProtos = []string{"h2"}
+	case c.HTTP2:
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		nextProtos = []string{"h2", "http/1.1"}
+	default:
+		protocols.SetHTTP1(true)
+	}
+	tr.Protocols = &protocols
+	tr.TLSClientConfig = &tls.Config{
+		NextProtos:   nextProtos,
+		Certificates: c.Certificates,
+		RootCAs:      c.RootCAs,
+		MinVersion:   c.MinTLSVersion,
+		MaxVersion:   c.MaxTLSVersion,
+		CipherSuites: c.CipherSuites,
+	}
+	if len(c.PinnedPubKeys) > 0 {
+		tr.TLSClientConfig.VerifyConnection = verifyPins(c.PinnedPubKeys)
+	}
+	if c.SkipSslChecks {
+		tr.TLSClientConfig.InsecureSkipVerify = true // #nosec G402 - user asked for it
+	}
+	if c.HTTP3 {
+		// The TLS settings carry over; http3 replaces the ALPN with h3.
+		return c.http3Transport(tr.TLSClientConfig)
+	}
+
+	return tr
+}
+
+// via names what a request is sent through instead of straight to its host:
+// the Unix socket or the proxy, with any password redacted. It is empty for a
+// direct connection.
+func (c Client) via(req *http.Request) string {
+	if c.UnixSocket != "" {
+		return c.UnixSocket
+	}
+	if p, _ := c.proxyFor(req.URL); p != nil {
+		return "proxy " + p.Redacted()
+	}
+
+	return ""
+}
+
+func (c Client) logDebug(format string, args ...interface{}) {
+	c.log(LDebug, format, args...)
+}
+
+func (c Client) logInfo(format string, args ...interface{}) {
+	c.log(LInfo, format, args...)
+}
+
+func (c Client) log(l LogLevel, format string, args ...interface{}) {
+	if l > c.LogLevel {
+		return
+	}
+
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	if c.Log == nil {
+		return
+	}
+	_, _ = io.WriteString(c.Log, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,239 @@
+package httpassert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHostMapping_Matches(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		SrcHost string
+		Input   string
+		Output  bool
+	}{
+		{"", "", false},
+		{"src", "", false},
+		{"src", "example.com", false},
+		{"", "example.com", false},
+		{"src", "src", true},
+		{"example.com", "example.com", true},
+		{"example.com:80", "example.com", false},
+		{"example.com:80", "example.com:99", false},
+		{"example.com", "example.com:99", false},
+		{"example.com:99", "example.com:99", true},
+		{"*", "", true},
+		{"*", "example.com", true},
+		{"*", "example.com:99", true},
+		{"*:12", "", false},
+		{"*:12", "example.com", false},
+		{"*:12", "example.com:99", false},
+		{"*:12", "example.com:12", true},
+		{"*:12", "example.com:112", false},
+		{"*:*", "example.com:99", true},
+		{"example.com:*", "example.com:99", true},
+		{"example.com:*", "example.ca:99", false},
+		{"EXAMPLE.com:99", "example.COM:99", true},
+		{"*.example.com:443", "api.example.com:443", true},
+		{"*.example.com:443", "a.b.example.com:443", true},
+		{"*.example.com:443", "example.com:443", false},
+		{"*.example.com:443", "api.example.com:80", false},
+		{"api-?.example.com:*", "api-2.example.com:443", true},
+		{"api-[0-9].example.com:*", "api-x.example.com:443", false},
+		{"10.0.0.0/8:80", "10.1.2.3:80", true},
+		{"10.0.0.0/8:80", "11.1.2.3:80", false},
+		{"10.0.0.0/8:80", "ten.example:80", false},
+		{"[2001:db8::/32]:443", "[2001:db8::1]:443", true},
+		{"[2001:db8::/32]:443", "[2001:db9::1]:443", false},
+		{"[::1]:80", "[::1]:80", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%q matches %q", tc.SrcHost, tc.Input), func(t *testing.T) {
+			m := HostMapping{Src: tc.SrcHost}
+			if got := m.Matches(tc.Input); got != tc.Output {
+				t.Errorf("HostMapping{Src: %q}.Matches(%q) = %v, want %v",
+					tc.SrcHost, tc.Input, got, tc.Output)
+			}
+		})
+	}
+}
+
+func TestHostMapping_DstHost(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		Addr    string
+		DstHost string
+		Attempt int
+		Output  string
+	}{
+		{"", "", 1, ""},
+		{"src", "", 1, ""},
+		{"src", "example.com", 1, "example.com"},
+		{"", "example.com", 1, "example.com"},
+		{"src", "src", 1, "src"},
+		{"example.com", "example.ca", 1, "example.ca"},
+		{"example.com:80", "example.ca", 1, "example.ca:80"},
+		{"example.com:80", "example.ca:99", 1, "example.ca:99"},
+		{"example.com", "example.ca:99", 1, "example.ca:99"},
+		{"example.com:99", "example.ca:99", 1, "example.ca:99"},
+		{"example.com:80", "[::1]", 1, "[::1]:80"},
+		{"example.com:80", "[::1]:99", 1, "[::1]:99"},
+		// Attempts go round the destinations.
+		{"example.com:80", "a,b:99,c", 0, "a:80"},
+		{"example.com:80", "a,b:99,c", 1, "a:80"},
+		{"example.com:80", "a,b:99,c", 2, "b:99"},
+		{"example.com:80", "a,b:99,c", 3, "c:80"},
+		{"example.com:80", "a,b:99,c", 4, "a:80"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("addr=%q dst=%q attempt=%d", tc.Addr, tc.DstHost, tc.Attempt), func(t *testing.T) {
+			m := HostMapping{Src: "*", Dst: tc.DstHost}
+			if got := m.DstHost(tc.Attempt, tc.Addr); got != tc.Output {
+				t.Errorf("HostMapping{Dst: %q}.DstHost(%d, %q) = %q, want %q",
+					tc.DstHost, tc.Attempt, tc.Addr, got, tc.Output)
+			}
+		})
+	}
+}
+
+// Each attempt takes the next destination of a mapping, on a connection of its
+// own.
+func Test_Client_hostMappingRotates(t *testing.T) {
+	t.Parallel()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer up.Close()
+
+	req, err := Request{URL: "http://api.svc.invalid/"}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping := HostMapping{Src: "*.svc.invalid:80", Dst: down.Listener.Addr().String() + "," + up.Listener.Addr().String()}
+
+	c := Client{HostMappings: []HostMapping{mapping}, Retries: 2}
+	res := c.Run(req, AssertStatusOK())
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	var got []string
+	for _, a := range res.Attempts {
+		got = append(got, a.Response.RemoteAddr)
+	}
+	if want := []string{down.Listener.Addr().String(), up.Listener.Addr().String()}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("attempts went to %v, want %v", got, want)
+	}
+}
+
+// HTTP/2 is opt-in: a server that offers h2 is still spoken to in HTTP/1.1
+// unless the client asked for it.
+func Test_Client_http2(t *testing.T) {
+	t.Parallel()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h2 := httptest.NewUnstartedServer(handler)
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	var p http.Protocols
+	p.SetHTTP1(true)
+	p.SetUnencryptedHTTP2(true)
+	h2c := httptest.NewUnstartedServer(handler)
+	h2c.Config.Protocols = &p
+	h2c.Start()
+	defer h2c.Close()
+
+	// Only speaks HTTP/1.1, and refuses a client that offers nothing else.
+	h1 := httptest.NewUnstartedServer(handler)
+	h1.Config.ErrorLog = log.New(io.Discard, "", 0)
+	h1.StartTLS()
+	defer h1.Close()
+
+	for _, tt := range []struct {
+		name    string
+		client  Client
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{name: "default over TLS", client: Client{}, url: h2.URL, want: "HTTP/1.1"},
+		{name: "default in clear", client: Client{}, url: h2c.URL, want: "HTTP/1.1"},
+		{name: "--http2 over TLS", client: Client{HTTP2: true}, url: h2.URL, want: "HTTP/2.0"},
+		{name: "--http2 in clear", client: Client{HTTP2: true}, url: h2c.URL, want: "HTTP/1.1"},
+		{name: "--http2 to an HTTP/1.1 server", client: Client{HTTP2: true}, url: h1.URL, want: "HTTP/1.1"},
+		{name: "prior knowledge over TLS", client: Client{HTTP2PriorKnowledge: true}, url: h2.URL, want: "HTTP/2.0"},
+		{name: "prior knowledge in clear", client: Client{HTTP2PriorKnowledge: true}, url: h2c.URL, want: "HTTP/2.0"},
+		{name: "prior knowledge to an HTTP/1.1 server", client: Client{HTTP2PriorKnowledge: true}, url: h1.URL, wantErr: true},
+	} {
+		req, err := Request{URL: tt.url}.Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.client.SkipSslChecks = true
+		err = tt.client.Do(req, AssertProto(tt.want))
+		if tt.wantErr {
+			if !errors.Is(err, ErrTransport) {
+				t.Errorf("%s: got %v, want a transport failure", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		}
+	}
+}
+
+// The socket is dialled, and the URL still says what to ask it for.
+func Test_Client_unixSocket(t *testing.T) {
+	t.Parallel()
+
+	sock := filepath.Join(t.TempDir(), "d.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host + r.URL.Path))
+	}))
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+
+	req, err := Request{URL: "http://docker/_ping"}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Client{UnixSocket: sock}
+	if err := c.Do(req, AssertBodyEqual("docker/_ping")); err != nil {
+		t.Error(err)
+	}
+
+	// A socket nobody listens on is a transport failure, and the dump says
+	// which socket it was, since the URL does not.
+	c = Client{UnixSocket: sock + ".gone"}
+	err = c.Do(req, AssertStatusOK())
+	if !errors.Is(err, ErrTransport) {
+		t.Fatalf("got %v, want a transport failure", err)
+	}
+	for _, want := range []string{"Unix socket: " + sock + ".gone\n", "no such file or directory"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("the report does not contain %q:\n%s", want, err)
+		}
+	}
+}
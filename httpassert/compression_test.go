@@ -1,4 +1,4 @@
-package main
+package httpassert
 
 import (
 	"bytes"
@@ -61,8 +61,8 @@ func deflatedZlib(t *testing.T, s string) []byte {
 
 // encoded builds the shape decodeBody operates on: a body and the header that
 // claims how it was encoded. It reuses render_test.go's response helper so both
-// files describe an httpResponse the same way.
-func encoded(enc string, body []byte) *httpResponse {
+// files describe an Response the same way.
+func encoded(enc string, body []byte) *Response {
 	h := http.Header{}
 	if enc != "" {
 		h.Set("Content-Encoding", enc)
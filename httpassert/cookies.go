@@ -0,0 +1,332 @@
+package httpassert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar keeps the cookies responses set and sends them back, as a browser
+// would within one session. Unlike net/http/cookiejar it can list what it
+// holds, which is what writing a cookie file afterwards takes.
+//
+// It knows no public suffix list, so a server may set a cookie for a whole
+// top-level domain; a check talks to the servers it is pointed at, and none
+// of them is trying to plant a cookie on another's.
+type CookieJar struct {
+	mu      sync.Mutex
+	entries []jarEntry
+}
+
+type jarEntry struct {
+	cookie http.Cookie
+	// hostOnly marks a cookie set without a Domain attribute, which goes
+	// back to the host that set it and not to its subdomains.
+	hostOnly bool
+}
+
+// NewCookieJar returns an empty jar.
+func NewCookieJar() *CookieJar {
+	return &CookieJar{}
+}
+
+// ParseCookieJar reads a Netscape cookie file, as curl's -c and browsers'
+// export tools write one: tab-separated domain, subdomains flag, path, secure
+// flag, expiry and name and value, with #HttpOnly_ before the domain of an
+// HTTP-only cookie. Expired cookies are dropped.
+func ParseCookieJar(text string) (*CookieJar, error) {
+	j := NewCookieJar()
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		f := strings.Split(line, "\t")
+		if len(f) != 7 {
+			return nil, fmt.Errorf("line %q: want 7 tab-separated fields, got %d", line, len(f))
+		}
+		expires, err := strconv.ParseInt(f[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %q: expiry %q is not a Unix time", line, f[4])
+		}
+		e := jarEntry{
+			cookie: http.Cookie{
+				Name:     f[5],
+				Value:    f[6],
+				Domain:   strings.ToLower(strings.TrimPrefix(f[0], ".")),
+				Path:     f[2],
+				Secure:   strings.EqualFold(f[3], "TRUE"),
+				HttpOnly: httpOnly,
+			},
+			hostOnly: !strings.EqualFold(f[1], "TRUE"),
+		}
+		if expires > 0 {
+			e.cookie.Expires = time.Unix(expires, 0)
+			if e.cookie.Expires.Before(time.Now()) {
+				continue
+			}
+		}
+		j.store(e)
+	}
+
+	return j, sc.Err()
+}
+
+// WriteTo writes the jar as a Netscape cookie file, which ParseCookieJar and
+// curl's -b both read. Session cookies are written with an expiry of 0.
+func (j *CookieJar) WriteTo(w io.Writer) (int64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n")
+	for _, e := range j.entries {
+		if e.expired(time.Now()) {
+			continue
+		}
+		c := e.cookie
+		domain, subdomains := c.Domain, "FALSE"
+		if !e.hostOnly {
+			domain, subdomains = "."+domain, "TRUE"
+		}
+		if c.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, subdomains, c.Path, strings.ToUpper(strconv.FormatBool(c.Secure)), expires, c.Name, c.Value)
+	}
+	n, err := io.WriteString(w, b.String())
+
+	return int64(n), err
+}
+
+// SetCookies keeps the cookies a response from u set, as RFC 6265 has a
+// client do: one for another domain is ignored, and one that has expired
+// removes its namesake.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	for _, c := range cookies {
+		e := jarEntry{cookie: *c, hostOnly: c.Domain == ""}
+		e.cookie.Domain = strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+		if e.hostOnly {
+			e.cookie.Domain = host
+		} else if !domainMatch(host, e.cookie.Domain) {
+			continue
+		}
+		if !strings.HasPrefix(e.cookie.Path, "/") {
+			e.cookie.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			e.cookie.Expires = now.Add(-time.Second)
+		case c.MaxAge > 0:
+			e.cookie.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		e.cookie.MaxAge, e.cookie.Raw, e.cookie.RawExpires, e.cookie.Unparsed = 0, "", "", nil
+		j.store(e)
+	}
+}
+
+// seed adds the cookies the jar holds no namesake of for u, as set by u's host
+// for every path. What a response sets later replaces them, as it would one
+// of its own.
+func (j *CookieJar) seed(u *url.URL, cookies []*http.Cookie) {
+	held := map[string]bool{}
+	for _, c := range j.Cookies(u) {
+		held[c.Name] = true
+	}
+	var missing []*http.Cookie
+	for _, c := range cookies {
+		if !held[c.Name] {
+			missing = append(missing, &http.Cookie{Name: c.Name, Value: c.Value, Path: "/"})
+		}
+	}
+	j.SetCookies(u, missing)
+}
+
+// cookieRecorder is the jar an attempt's client uses: it hands what responses
+// set on to the jar, and notes it in set as well, so that a check can tell
+// those from the cookies the jar was seeded with or loaded from a file.
+type cookieRecorder struct {
+	*CookieJar
+	set *CookieJar
+}
+
+func (r cookieRecorder) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	r.CookieJar.SetCookies(u, cookies)
+	r.set.SetCookies(u, cookies)
+}
+
+// setFor are the cookies the jar holds for u that a response set.
+func (r cookieRecorder) setFor(u *url.URL) []*http.Cookie {
+	set := map[string]bool{}
+	for _, c := range r.set.Cookies(u) {
+		set[c.Name+"="+c.Value] = true
+	}
+
+	return slices.DeleteFunc(r.Cookies(u), func(c *http.Cookie) bool { return !set[c.Name+"="+c.Value] })
+}
+
+// Cookies are the cookies a request to u carries: those whose domain and path
+// it is within, and for a secure one only over https, longest path first.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+	var matched []jarEntry
+	for _, e := range j.entries {
+		switch {
+		case e.expired(now),
+			e.hostOnly && host != e.cookie.Domain,
+			!e.hostOnly && !domainMatch(host, e.cookie.Domain),
+			!pathMatch(path, e.cookie.Path),
+			e.cookie.Secure && u.Scheme != "https":
+			continue
+		}
+		matched = append(matched, e)
+	}
+	slices.SortStableFunc(matched, func(a, b jarEntry) int { return len(b.cookie.Path) - len(a.cookie.Path) })
+
+	res := make([]*http.Cookie, len(matched))
+	for i, e := range matched {
+		res[i] = &http.Cookie{Name: e.cookie.Name, Value: e.cookie.Value}
+	}
+
+	return res
+}
+
+// store replaces the entry with the same name, domain and path, or adds one;
+// an expired cookie only removes.
+func (j *CookieJar) store(e jarEntry) {
+	j.entries = slices.DeleteFunc(j.entries, func(old jarEntry) bool {
+		return old.cookie.Name == e.cookie.Name && old.cookie.Domain == e.cookie.Domain &&
+			old.cookie.Path == e.cookie.Path
+	})
+	if !e.expired(time.Now()) {
+		j.entries = append(j.entries, e)
+	}
+}
+
+func (e jarEntry) expired(now time.Time) bool {
+	return !e.cookie.Expires.IsZero() && !e.cookie.Expires.After(now)
+}
+
+// domainMatch reports whether host is domain or one of its subdomains. An
+// address has no subdomains.
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+
+	return net.ParseIP(host) == nil && strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatch reports whether path is within the cookie path cp.
+func pathMatch(path, cp string) bool {
+	if !strings.HasPrefix(path, cp) {
+		return false
+	}
+
+	return len(path) == len(cp) || strings.HasSuffix(cp, "/") || path[len(cp)] == '/'
+}
+
+// defaultCookiePath is the path a cookie set without one gets: the request
+// path up to its last slash.
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
+
+// ParseCookies reads cookies written as a Cookie header is,
+// name=value; name=value.
+func ParseCookies(text string) ([]*http.Cookie, error) {
+	cookies, err := http.ParseCookie(text)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not name=value; name=value: %w", text, err)
+	}
+
+	return cookies, nil
+}
+
+// AssertCookie holds when a cookie named name is held for the response's URL,
+// whichever response of the run set it -- or, for a saved response, when it
+// set one -- and its value, with a pattern, matches it. One the jar started
+// with, from Cookies or a file, does not count until a response sets it.
+func AssertCookie(name, pattern string) (Assertion, error) {
+	var re *regexp.Regexp
+	if pattern != "" {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	return newAssertion("cookie", name, func(res *Response) (*Failure, error) {
+		cookies := res.JarCookies
+		if cookies == nil {
+			cookies = res.Cookies()
+		}
+		var names, values []string
+		for _, c := range cookies {
+			names = append(names, c.Name)
+			if c.Name == name {
+				values = append(values, c.Value)
+				if re == nil || re.MatchString(c.Value) {
+					return nil, nil
+				}
+			}
+		}
+
+		if values == nil {
+			held := "none"
+			if names != nil {
+				held = strings.Join(names, ", ")
+			}
+			return &Failure{
+				Target:   name,
+				Expected: "present",
+				Message:  fmt.Sprintf("cookie[%s]: expected to be set, got %s", name, held),
+			}, nil
+		}
+		return &Failure{
+			Target:   name,
+			Expected: pattern,
+			Actual:   values,
+			Message: fmt.Sprintf("cookie[%s]: expected to match %q, got %s",
+				name, pattern, headerValues(values)),
+		}, nil
+	}), nil
+}
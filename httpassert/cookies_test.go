@@ -0,0 +1,239 @@
+package httpassert
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_ParseCookieJar(t *testing.T) {
+	t.Parallel()
+
+	const file = "# Netscape HTTP Cookie File\n" +
+		"#HttpOnly_example.com\tFALSE\t/\tTRUE\t0\tsession\ts3ss10n\n" +
+		".example.com\tTRUE\t/app\tFALSE\t4102444800\ttheme\tdark\n" +
+		"example.com\tFALSE\t/\tFALSE\t1\tstale\tgone\n"
+	j, err := ParseCookieJar(file)
+	checkErr(t, "parse", err, "")
+
+	var b strings.Builder
+	if _, err := j.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Netscape HTTP Cookie File\n" +
+		"#HttpOnly_example.com\tFALSE\t/\tTRUE\t0\tsession\ts3ss10n\n" +
+		".example.com\tTRUE\t/app\tFALSE\t4102444800\ttheme\tdark\n"
+	if b.String() != want {
+		t.Errorf("written back as\n%s\nwant\n%s", b.String(), want)
+	}
+
+	for text, want := range map[string]string{
+		"example.com\tFALSE\t/":                             `line "example.com\tFALSE\t/": want 7 tab-separated fields, got 3`,
+		"example.com\tFALSE\t/\tFALSE\tsoon\tname\tvalue\n": `line "example.com\tFALSE\t/\tFALSE\tsoon\tname\tvalue": expiry "soon" is not a Unix time`,
+	} {
+		_, err := ParseCookieJar(text)
+		checkErr(t, text, err, want)
+	}
+}
+
+func Test_CookieJar_Cookies(t *testing.T) {
+	t.Parallel()
+
+	u := func(s string) *url.URL {
+		res, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	names := func(cookies []*http.Cookie) string {
+		var res []string
+		for _, c := range cookies {
+			res = append(res, c.Name+"="+c.Value)
+		}
+		return strings.Join(res, "; ")
+	}
+
+	j := NewCookieJar()
+	j.SetCookies(u("https://www.example.com/app/login"), []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "secure", Value: "3", Path: "/", Secure: true},
+		{Name: "other", Value: "4", Domain: "example.org"},
+		{Name: "gone", Value: "5", MaxAge: -1},
+	})
+
+	for _, tt := range []struct {
+		url  string
+		want string
+	}{
+		{"https://www.example.com/app/x", "host=1; domain=2; secure=3"},
+		{"http://www.example.com/app", "host=1; domain=2"},
+		{"https://www.example.com/application", "domain=2; secure=3"},
+		{"https://api.example.com/app/x", "domain=2"},
+		{"https://example.org/", ""},
+	} {
+		if got := names(j.Cookies(u(tt.url))); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	// An expired namesake removes the cookie.
+	j.SetCookies(u("https://www.example.com/app/logout"), []*http.Cookie{{Name: "host", MaxAge: -1}})
+	if got := names(j.Cookies(u("https://www.example.com/app/x"))); got != "domain=2; secure=3" {
+		t.Errorf("after expiry got %q", got)
+	}
+}
+
+// A cookie set on one hop of a redirect chain is sent on the next, and a jar
+// shared between runs carries it to the later ones.
+func Test_Client_cookies(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3ss10n", Path: "/"})
+			http.Redirect(w, r, "/dashboard", http.StatusFound)
+		case "/dashboard":
+			if c := r.CookiesNamed("session"); len(c) != 1 || c[0].Value != "s3ss10n" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	build := func(path string) *http.Request {
+		req, err := Request{URL: srv.URL + path}.Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	session, err := AssertCookie("session", "^s3ss10n$")
+	checkErr(t, "session", err, "")
+
+	if err := (Client{FollowRedirects: true, MaxRedirects: 5}).Do(build("/login"), AssertStatusOK(), session); err != nil {
+		t.Errorf("without a jar: %v", err)
+	}
+	// Nothing outlives the attempt without one.
+	if err := (Client{}).Do(build("/dashboard"), AssertStatusOK()); !errors.Is(err, ErrAssertion) {
+		t.Errorf("a fresh run was let in: %v", err)
+	}
+
+	found, err := ParseStatusSpec("302")
+	checkErr(t, "302", err, "")
+	c := Client{Jar: NewCookieJar()}
+	if err := c.Do(build("/login"), AssertStatus(found)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Do(build("/dashboard"), AssertStatusOK()); err != nil {
+		t.Errorf("with a jar: %v", err)
+	}
+	// The cookie an earlier run set is sent, and is not one this run set.
+	err = c.Do(build("/dashboard"), session)
+	if err == nil || !strings.Contains(err.Error(), "cookie[session]: expected to be set, got none") {
+		t.Errorf("an earlier run's cookie: got %v", err)
+	}
+
+	// Cookies go with every request as they are.
+	c = Client{Cookies: []*http.Cookie{{Name: "session", Value: "s3ss10n"}}}
+	if err := c.Do(build("/dashboard"), AssertStatusOK()); err != nil {
+		t.Errorf("with Cookies: %v", err)
+	}
+
+	// One a response sets in their place replaces them for the runs after it,
+	// rather than going along as well.
+	c = Client{Jar: NewCookieJar(), Cookies: []*http.Cookie{{Name: "session", Value: "stale"}}}
+	if err := c.Do(build("/login"), AssertStatus(found)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Do(build("/dashboard"), AssertStatusOK()); err != nil {
+		t.Errorf("Cookies replaced by the jar's: %v", err)
+	}
+
+	missing, _ := AssertCookie("theme", "")
+	err = (Client{}).Do(build("/login"), missing)
+	if err == nil || !strings.Contains(err.Error(), "cookie[theme]: expected to be set, got session") {
+		t.Errorf("got %v", err)
+	}
+}
+
+// Cookies go into the jar when the run starts, not before every attempt, so
+// one the server deletes is not sent again by a retry.
+func Test_Client_cookiesRetry(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cookie") != "" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	req, err := Request{URL: srv.URL}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := []*http.Cookie{{Name: "session", Value: "stale"}}
+	for _, c := range []Client{
+		{Cookies: cookies, Retries: 1},
+		{Cookies: cookies, Retries: 1, Jar: NewCookieJar()},
+	} {
+		res := c.Run(req, AssertStatusOK())
+		if res.Err != nil || len(res.Attempts) != 2 {
+			t.Errorf("jar %t: got %d attempts, %v; want the retry to pass without the cookie",
+				c.Jar != nil, len(res.Attempts), res.Err)
+		}
+	}
+}
+
+// A cookie the jar was seeded with or loaded from a file is sent, and does
+// not satisfy AssertCookie until a response sets it.
+func Test_Client_cookiesAsserted(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3ss10n", Path: "/"})
+		}
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	build := func(path string) *http.Request {
+		req, err := Request{URL: srv.URL + path}.Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	session, err := AssertCookie("session", "")
+	checkErr(t, "session", err, "")
+
+	loaded, err := ParseCookieJar(u.Hostname() + "\tFALSE\t/\tFALSE\t0\tsession\tfrom-file\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name string
+		c    Client
+	}{
+		{"Cookies", Client{Cookies: []*http.Cookie{{Name: "session", Value: "given"}}}},
+		{"a loaded jar", Client{Jar: loaded}},
+	} {
+		err := tt.c.Do(build("/"), session)
+		if err == nil || !strings.Contains(err.Error(), "cookie[session]: expected to be set, got none") {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+		if err := tt.c.Do(build("/login"), session); err != nil {
+			t.Errorf("%s, once a response sets it: %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package httpassert
+
+import (
+	"context"
+	"crypto/md5" // #nosec G501 - RFC 7616 names it, and the server picks it
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Challenge is the Digest round a request went through before its response:
+// the 401 that asked for credentials, and whether it was answered.
+type Challenge struct {
+	// Status is the 401's status line, as "401 Unauthorized".
+	Status string
+	// WWWAuthenticate is the challenge as the server sent it.
+	WWWAuthenticate string
+	// User is who the answer was computed for, and empty when there was none.
+	User string
+	// Err is why the challenge went unanswered, in which case the 401 is the
+	// response.
+	Err error
+}
+
+// String renders the round for the failure dump.
+func (ch Challenge) String() string {
+	if ch.Err != nil {
+		return fmt.Sprintf("%s, %s -- not answered: %s", ch.Status, ch.WWWAuthenticate, ch.Err)
+	}
+
+	return fmt.Sprintf("%s, %s -- answered as %s", ch.Status, ch.WWWAuthenticate, ch.User)
+}
+
+// challengeKey is the context key for the *Challenge a Digest round reports to.
+type challengeKey struct{}
+
+// digestTransport answers a Digest challenge within the round trip, so that an
+// attempt, and each hop of a redirect chain, is one request as far as the
+// client, the assertions and --retry are concerned.
+type digestTransport struct {
+	c    Client
+	next http.RoundTripper
+}
+
+func (t digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// With -L only the last hop's round is the response's.
+	round, _ := req.Context().Value(challengeKey{}).(*Challenge)
+	if round != nil {
+		*round = Challenge{}
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	params, ok := digestChallenge(res.Header.Values("WWW-Authenticate"))
+	if !ok {
+		return res, nil // not a Digest server; the 401 is the answer
+	}
+
+	ch := Challenge{Status: res.Status, WWWAuthenticate: params.raw}
+	defer func() {
+		if round != nil {
+			*round = ch
+		}
+	}()
+	user := t.c.digestUser(req)
+	if user == nil {
+		ch.Err = errors.New("no credentials for " + req.URL.Hostname())
+		return res, nil
+	}
+	ch.User = user.Username()
+	// A body read by the first send has to be read again for the second.
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		ch.Err = errors.New("the request body cannot be sent twice")
+		return res, nil
+	}
+	next, err := cloneForAttempt(req)
+	if err != nil {
+		ch.Err = err
+		return res, nil
+	}
+	v, err := params.authorization(user, req.Method, req.URL.RequestURI())
+	if err != nil {
+		ch.Err = err
+		return res, nil
+	}
+
+	t.c.logInfo("[:] %s %s (digest challenge)\n", res.Proto, res.Status)
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+	next.Header.Set("Authorization", v)
+
+	return t.next.RoundTrip(next)
+}
+
+// digestUser is who answers a challenge to req: User, else the Netrc entry for
+// its host. User goes no further than the host the redirect chain started at,
+// as net/http keeps a Basic header from going.
+func (c Client) digestUser(req *http.Request) *url.Userinfo {
+	first := req
+	for first.Response != nil && first.Response.Request != nil {
+		first = first.Response.Request
+	}
+	if c.User != nil && strings.EqualFold(first.URL.Host, req.URL.Host) {
+		return c.User
+	}
+	if e, ok := netrcFor(c.Netrc, req.URL.Hostname()); ok {
+		return url.UserPassword(e.Login, e.Password)
+	}
+
+	return nil
+}
+
+// digestParams is one Digest challenge, parsed.
+type digestParams struct {
+	raw    string
+	params map[string]string
+}
+
+// digestChallenge picks the Digest challenge out of a 401's WWW-Authenticate
+// values. A server offering SHA-256 and MD5 sends one of each, and the
+// stronger is taken.
+func digestChallenge(values []string) (digestParams, bool) {
+	var (
+		res   digestParams
+		found bool
+	)
+	for _, v := range values {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(v), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		p := digestParams{raw: v, params: map[string]string{}}
+		for _, kv := range splitQuoted(rest, ',') {
+			k, val, _ := strings.Cut(kv, "=")
+			p.params[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(val), `"`)
+		}
+		if !found || strings.HasPrefix(strings.ToUpper(p.params["algorithm"]), "SHA-256") {
+			res, found = p, true
+		}
+	}
+
+	return res, found
+}
+
+// authorization answers the challenge for method and uri, as RFC 7616 does
+// with qop=auth, or as RFC 2069 did when the server offers no qop.
+func (p digestParams) authorization(user *url.Userinfo, method, uri string) (string, error) {
+	algorithm := p.params["algorithm"]
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	h := func(s string) string {
+		sum := newHash()
+		_, _ = io.WriteString(sum, s)
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	qop := ""
+	if offered := p.params["qop"]; offered != "" {
+		for q := range strings.SplitSeq(offered, ",") {
+			if strings.TrimSpace(q) == "auth" {
+				qop = "auth"
+			}
+		}
+		if qop == "" {
+			return "", fmt.Errorf("unsupported qop %q", offered)
+		}
+	}
+
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	cnonce, nc := hex.EncodeToString(buf), "00000001"
+	realm, nonce := p.params["realm"], p.params["nonce"]
+	pass, _ := user.Password()
+
+	ha1 := h(user.Username() + ":" + realm + ":" + pass)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	response := h(ha1 + ":" + nonce + ":" + ha2)
+	if qop != "" {
+		response = h(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `Digest username=%q, realm=%q, nonce=%q, uri=%q, response=%q`,
+		user.Username(), realm, nonce, uri, response)
+	if algorithm != "" {
+		fmt.Fprintf(&b, ", algorithm=%s", algorithm)
+	}
+	if qop != "" {
+		fmt.Fprintf(&b, `, qop=%s, nc=%s, cnonce=%q`, qop, nc, cnonce)
+	}
+	if opaque, ok := p.params["opaque"]; ok {
+		fmt.Fprintf(&b, ", opaque=%q", opaque)
+	}
+
+	return b.String(), nil
+}
+
+// withChallenge returns a context whose round trips report a Digest round to
+// ch.
+func withChallenge(ctx context.Context, ch *Challenge) context.Context {
+	return context.WithValue(ctx, challengeKey{}, ch)
+}
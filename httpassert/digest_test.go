@@ -0,0 +1,161 @@
+package httpassert
+
+import (
+	"crypto/md5" // #nosec G501 - the algorithm under test
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// digestServer admits alice:s3cret by Digest with the given algorithm, and
+// counts the requests it is sent.
+func digestServer(t *testing.T, algorithm string, sent *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	h := md5Hex
+	if strings.HasPrefix(algorithm, "SHA-256") {
+		h = func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+	}
+	challenge := `Digest realm="test", nonce="n0nce", qop="auth", opaque="0paque", algorithm=` + algorithm
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		got, ok := digestChallenge([]string{r.Header.Get("Authorization")})
+		p := got.params
+		if ok && p["opaque"] == "0paque" && p["uri"] == r.URL.RequestURI() {
+			ha1 := h("alice:test:s3cret")
+			if strings.HasSuffix(algorithm, "-sess") {
+				ha1 = h(ha1 + ":n0nce:" + p["cnonce"])
+			}
+			want := h(ha1 + ":n0nce:" + p["nc"] + ":" + p["cnonce"] + ":auth:" + h(r.Method+":"+p["uri"]))
+			if p["response"] == want {
+				_, _ = w.Write(body)
+				return
+			}
+		}
+		w.Header().Add("WWW-Authenticate", challenge)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func Test_Client_digest(t *testing.T) {
+	t.Parallel()
+
+	for _, algorithm := range []string{"MD5", "SHA-256", "SHA-256-sess"} {
+		var sent atomic.Int32
+		srv := digestServer(t, algorithm, &sent)
+		c := Client{User: url.UserPassword("alice", "s3cret"), Digest: true}
+
+		// The body goes out twice, and the assertions see the second response.
+		req, err := Request{Method: http.MethodPost, URL: srv.URL + "/a?b=c", Body: []byte("payload")}.Build(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := c.Run(req, AssertStatusOK(), AssertBodyEqual("payload"))
+		if res.Err != nil {
+			t.Fatalf("%s: %v", algorithm, res.Err)
+		}
+		if len(res.Attempts) != 1 || sent.Load() != 2 {
+			t.Errorf("%s: %d attempts sent %d requests, want 1 and 2", algorithm, len(res.Attempts), sent.Load())
+		}
+		ch := res.Attempts[0].Response.Challenge
+		if ch == nil || ch.User != "alice" || ch.Err != nil || !strings.Contains(ch.WWWAuthenticate, `realm="test"`) {
+			t.Errorf("%s: challenge %+v", algorithm, ch)
+		}
+	}
+}
+
+func Test_Client_digestRefused(t *testing.T) {
+	t.Parallel()
+
+	var sent atomic.Int32
+	srv := digestServer(t, "MD5", &sent)
+	req, err := Request{URL: srv.URL}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		client Client
+		dump   string
+		sent   int32
+	}{
+		{"wrong password", Client{User: url.UserPassword("alice", "wrong"), Digest: true},
+			`Challenged: 401 Unauthorized, Digest realm="test"`, 2},
+		{"no credentials", Client{Digest: true},
+			"-- not answered: no credentials for 127.0.0.1", 1},
+		// Without Digest the challenge is just a 401.
+		{"basic", Client{User: url.UserPassword("alice", "s3cret")},
+			"", 1},
+	} {
+		sent.Store(0)
+		err := tt.client.Do(req, AssertStatusOK())
+		if err == nil {
+			t.Fatalf("%s: the 401 passed", tt.name)
+		}
+		if tt.dump == "" {
+			if strings.Contains(err.Error(), "Challenged:") {
+				t.Errorf("%s: the dump shows a challenge round:\n%s", tt.name, err)
+			}
+		} else if !strings.Contains(err.Error(), tt.dump) {
+			t.Errorf("%s: the dump does not contain %q:\n%s", tt.name, tt.dump, err)
+		}
+		if sent.Load() != tt.sent {
+			t.Errorf("%s: sent %d requests, want %d", tt.name, sent.Load(), tt.sent)
+		}
+	}
+}
+
+func Test_digestParams_authorization(t *testing.T) {
+	t.Parallel()
+
+	user := url.UserPassword("alice", "s3cret")
+	for _, tt := range []struct {
+		challenge string
+		want      string
+	}{
+		// RFC 2069: no qop, so no nc or cnonce either.
+		{`Digest realm="r", nonce="n"`, `Digest username="alice", realm="r", nonce="n", uri="/", response="` +
+			md5Hex(md5Hex("alice:r:s3cret")+":n:"+md5Hex("GET:/")) + `"`},
+		{`Digest realm="r", nonce="n", algorithm=SHA-512-256`, `unsupported algorithm "SHA-512-256"`},
+		{`Digest realm="r", nonce="n", qop="auth-int"`, `unsupported qop "auth-int"`},
+	} {
+		p, ok := digestChallenge([]string{`Basic realm="r"`, tt.challenge})
+		if !ok {
+			t.Fatalf("%s: not a Digest challenge", tt.challenge)
+		}
+		got, err := p.authorization(user, http.MethodGet, "/")
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.challenge, got, tt.want)
+		}
+	}
+
+	// Offered both, the client takes SHA-256.
+	p, _ := digestChallenge([]string{`Digest realm="r", algorithm=MD5`, `Digest realm="r", algorithm=SHA-256`})
+	if p.params["algorithm"] != "SHA-256" {
+		t.Errorf("picked %s, want SHA-256", p.params["algorithm"])
+	}
+}
+
+func md5Hex(s string) string {
+	sum := md5.New() // #nosec G401 - the algorithm under test
+	_, _ = io.WriteString(sum, s)
+	return hex.EncodeToString(sum.Sum(nil))
+}
@@ -0,0 +1,26 @@
+// Package httpassert makes an HTTP request and checks the response against a
+// list of assertions. It is the engine of the http-assert command, which only
+// turns flags into the values this package takes, so a Go program asserting
+// on a response gets the command's verdicts, down to the failure dump.
+//
+// A Request builds the request, the Assert* constructors build the checks,
+// and a Client sends one and applies the other:
+//
+//	req, err := httpassert.Request{URL: "https://api.example.com/health"}.Build(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	jq, err := httpassert.AssertJQ(`.status == "up"`)
+//	if err != nil {
+//		return err
+//	}
+//	err = httpassert.Client{Retries: 5, RetryDelay: time.Second}.
+//		Do(req, httpassert.AssertStatusOK(), jq)
+//
+// The error Do returns is the report: its message lists every assertion that
+// failed and dumps the request and response, and errors.Is tells a wrong
+// answer (ErrAssertion) from no answer at all (ErrTransport).
+//
+// Package httpasserttest runs the same checks from a test, against an
+// httptest.Server or a bare handler.
+package httpassert
@@ -0,0 +1,97 @@
+package httpassert
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// AddressResult is the run against one of the addresses a host has.
+type AddressResult struct {
+	// Addr is the address the run connected to, as ip:port.
+	Addr string
+	*Result
+}
+
+// RunEachAddress runs the request against every address its host has, in
+// turn, each with every assertion, and fails unless all of them pass. Behind
+// round-robin DNS a plain run reaches whichever replica the resolver offered
+// first, so one bad replica passes most checks.
+//
+// The addresses are the ones a connection would use -- after HostMappings,
+// every destination of one that rotates, through Resolve, DNSServers and
+// IPVersion -- and each run pins its
+// connections to one of them with a HostMapping ahead of the others. No proxy
+// is used: a proxy would pick the address itself.
+//
+// The error wraps ErrTransport when any address gave no usable response, for
+// the reason a suite's does, and ErrAssertion otherwise. Its message names
+// the addresses that failed, then gives each one's dump.
+func (c Client) RunEachAddress(req *http.Request, assertions ...Assertion) ([]AddressResult, error) {
+	if len(assertions) == 0 {
+		return nil, ErrNoAssertions
+	}
+	c = c.withDNSTurn()
+
+	src := hostPort(req.URL)
+	var addrs []string
+	for _, dst := range c.dstHosts(src) {
+		found, err := c.lookup(req.Context(), nil, dst)
+		if err != nil {
+			return nil, &runError{ErrTransport, fmt.Sprintf("cannot resolve %s:\n- %s\n", src, err)}
+		}
+		for _, a := range found {
+			if !slices.Contains(addrs, a) {
+				addrs = append(addrs, a)
+			}
+		}
+	}
+
+	var (
+		res    []AddressResult
+		failed []string
+		dumps  strings.Builder
+	)
+	kind := ErrAssertion
+	for i, addr := range addrs {
+		c.logInfo("[#] %s (%d/%d)\n", addr, i+1, len(addrs))
+
+		one := c
+		one.HostMappings = append([]HostMapping{{Src: src, Dst: addr}}, c.HostMappings...)
+		one.NoProxy = []string{"*"}
+		r := one.Run(req, assertions...)
+		res = append(res, AddressResult{Addr: addr, Result: r})
+		if r.Err == nil {
+			continue
+		}
+
+		failed = append(failed, addr)
+		if errors.Is(r.Err, ErrTransport) {
+			kind = ErrTransport
+		}
+		fmt.Fprintf(&dumps, "\n%s:\n%s\n", addr, r.Err)
+	}
+	if len(failed) == 0 {
+		return res, nil
+	}
+
+	return res, &runError{kind, fmt.Sprintf("%d of %d addresses failed: %s\n%s",
+		len(failed), len(addrs), strings.Join(failed, ", "), dumps.String())}
+}
+
+// hostPort is the host:port a request to u dials, as net/http writes it.
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,88 @@
+package httpassert
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// replicas serves on 127.0.0.1 and 127.0.0.2 at the same port, the second
+// one broken, and returns the port.
+func replicas(t *testing.T) string {
+	t.Helper()
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(good.Close)
+	_, port, _ := net.SplitHostPort(good.Listener.Addr().String())
+
+	l, err := net.Listen("tcp", "127.0.0.2:"+port)
+	if err != nil {
+		t.Skip("cannot listen on 127.0.0.2:", err)
+	}
+	bad := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	bad.Listener = l
+	bad.Start()
+	t.Cleanup(bad.Close)
+
+	return port
+}
+
+func Test_Client_RunEachAddress(t *testing.T) {
+	t.Parallel()
+
+	port := replicas(t)
+	req, err := Request{URL: "http://svc.invalid:" + port + "/"}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolve := func(addrs string) []ResolveEntry {
+		e, _ := ParseResolve("svc.invalid:" + port + ":" + addrs)
+		return []ResolveEntry{e}
+	}
+
+	c := Client{Resolve: resolve("127.0.0.1")}
+	res, err := c.RunEachAddress(req, AssertStatusOK())
+	if err != nil || len(res) != 1 || res[0].Addr != "127.0.0.1:"+port {
+		t.Fatalf("one address: got %v, %v", res, err)
+	}
+
+	// Every address is asked, whichever fails, and the error names the ones
+	// that did.
+	c = Client{Resolve: resolve("127.0.0.2,127.0.0.1")}
+	res, err = c.RunEachAddress(req, AssertStatusOK())
+	if len(res) != 2 || res[0].Err == nil || res[1].Err != nil {
+		t.Fatalf("got %v", res)
+	}
+	if !errors.Is(err, ErrAssertion) {
+		t.Errorf("got %v, want an assertion failure", err)
+	}
+	if want := "1 of 2 addresses failed: 127.0.0.2:" + port + "\n\n127.0.0.2:" + port + ":\n1 assertions failed:\n"; err == nil ||
+		!strings.HasPrefix(err.Error(), want) {
+		t.Errorf("the report does not start %q:\n%s", want, err)
+	}
+
+	// An address that does not answer is the bigger news.
+	c = Client{Resolve: resolve("127.0.0.2,127.0.0.3,127.0.0.1")}
+	_, err = c.RunEachAddress(req, AssertStatusOK())
+	if !errors.Is(err, ErrTransport) || !strings.Contains(err.Error(), "2 of 3 addresses failed") {
+		t.Errorf("got %v, want a transport failure naming two addresses", err)
+	}
+
+	// The mapping is followed to the host whose addresses are asked.
+	req, _ = Request{URL: "http://api.invalid:" + port + "/"}.Build(t.Context())
+	c = Client{Resolve: resolve("127.0.0.1"), HostMappings: []HostMapping{{Src: "api.invalid:" + port, Dst: "svc.invalid"}}}
+	if _, err := c.RunEachAddress(req, AssertStatusOK()); err != nil {
+		t.Error(err)
+	}
+
+	c = Client{}
+	if _, err := c.RunEachAddress(req, AssertStatusOK()); !errors.Is(err, ErrTransport) ||
+		!strings.HasPrefix(err.Error(), "cannot resolve api.invalid:"+port+":\n") {
+		t.Errorf("an unknown host: got %v", err)
+	}
+}
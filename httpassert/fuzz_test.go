@@ -0,0 +1,62 @@
+package httpassert
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// The functions below slice at offsets derived from their input. Reading
+// them suggests every index is guarded; fuzzing is what turns that reading into
+// evidence. None of them should panic on any input, so each target simply calls
+// the function -- an out-of-range slice or nil dereference fails the test by
+// crashing it.
+//
+// Seed corpora run as ordinary subtests under `go test`, so these guard CI for
+// free. To search for new inputs:
+//
+//	go test -run '^$' -fuzz FuzzHostMapping -fuzztime 60s
+
+func FuzzHostMapping(f *testing.F) {
+	for _, pair := range [][2]string{
+		{"", ""}, {"a:1", "b:2"}, {"*:80", "b"}, {"*", "b"}, {"*.a:*", "b,c:2"}, {"10.0.0.0/8:80", "[::1]"},
+		{":", ":"}, {"a", ""}, {strings.Repeat(":", 50), strings.Repeat(":", 50)},
+	} {
+		f.Add(pair[0], pair[1])
+	}
+
+	f.Fuzz(func(t *testing.T, src, dst string) {
+		m := HostMapping{Src: src, Dst: dst}
+		_ = m.Matches(dst)
+		_ = m.Matches(src)
+
+		// A destination that already carries a port is returned unchanged.
+		_, _, err := net.SplitHostPort(dst)
+		if got := m.DstHost(1, src); err == nil && !strings.Contains(dst, ",") && got != dst {
+			t.Errorf("HostMapping{%q, %q}.DstHost(1, %q) = %q, want the destination unchanged", src, dst, src, got)
+		}
+	})
+}
+
+func FuzzPrintPayload(f *testing.F) {
+	for _, seed := range []struct {
+		Body []byte
+		Max  int
+	}{
+		{nil, 0}, {[]byte("plain"), 100}, {[]byte("plain"), 2},
+		{[]byte{0x00, 0xff, 0x07}, 100}, {[]byte("héllo"), 3}, {[]byte("x"), -1},
+	} {
+		f.Add(seed.Body, seed.Max)
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte, maxSize int) {
+		cropped := printPayload(io.Discard, body, maxSize)
+		if cropped < 0 {
+			t.Errorf("printPayload(%d bytes, max %d) reported %d cropped", len(body), maxSize, cropped)
+		}
+		if cropped > len(body) {
+			t.Errorf("printPayload reported %d cropped from a %d-byte body", cropped, len(body))
+		}
+	})
+}
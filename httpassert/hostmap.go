@@ -0,0 +1,141 @@
+package httpassert
+
+import (
+	"context"
+	"net"
+	"path"
+	"strings"
+)
+
+// HostMapping sends the connections for the host:port pairs Src matches to
+// Dst instead.
+type HostMapping struct {
+	// Src is the host:port pair to map. The host may be a glob, such as
+	// *.example.com, that path.Match accepts, or a CIDR block, such as
+	// 10.0.0.0/8, that the host has to be an address in; either part may be
+	// *, and * alone matches everything.
+	Src string
+	// Dst is the destination in the form of either `hostname:port` or just
+	// `hostname`, and an IPv6 address in brackets. If just the hostname is
+	// specified without a port then the source port will be used.
+	//
+	// Several destinations are separated by commas. Attempt n connects to the
+	// n-th of them, wrapping around, so a run with --retry goes round them.
+	Dst string
+}
+
+// Matches reports whether the mapping applies to addr, a host:port pair.
+func (r HostMapping) Matches(addr string) bool {
+	if r.Src == "" {
+		return false
+	}
+	if r.Src == "*" || strings.EqualFold(r.Src, addr) {
+		return true
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	srcHost, srcPort, ok := splitPattern(r.Src)
+	if !ok || (srcPort != "*" && srcPort != port) {
+		return false
+	}
+
+	switch {
+	case srcHost == "*":
+		return true
+	case strings.Contains(srcHost, "/"):
+		_, block, err := net.ParseCIDR(srcHost)
+		ip := net.ParseIP(host)
+		return err == nil && ip != nil && block.Contains(ip)
+	case strings.ContainsAny(srcHost, "*?["):
+		ok, _ := path.Match(strings.ToLower(srcHost), strings.ToLower(host))
+		return ok
+	}
+
+	return strings.EqualFold(srcHost, host)
+}
+
+// splitPattern splits a Src into host and port. net.SplitHostPort would do,
+// but for the brackets of a glob such as api-[0-9].example.com.
+func splitPattern(src string) (host, port string, ok bool) {
+	if strings.HasPrefix(src, "[") {
+		host, port, err := net.SplitHostPort(src)
+		return host, port, err == nil
+	}
+	host, port, ok = strings.Cut(src, ":")
+
+	return host, port, ok && !strings.Contains(port, ":")
+}
+
+// Dsts lists the destinations in Dst.
+func (r HostMapping) Dsts() []string {
+	return strings.Split(r.Dst, ",")
+}
+
+// DstHost is where attempt n, counted from 1, connects to for addr, the
+// host:port pair the mapping matched: the destination whose turn it is, with
+// addr's port when the destination gives none.
+func (r HostMapping) DstHost(attempt int, addr string) string {
+	dsts := r.Dsts()
+	dst := dsts[max(attempt-1, 0)%len(dsts)]
+	if _, _, err := net.SplitHostPort(dst); err == nil {
+		return dst
+	}
+
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return dst
+	}
+	return net.JoinHostPort(strings.Trim(dst, "[]"), port)
+}
+
+// rotates reports whether any mapping has several destinations, and so whether
+// attempts should not share connections.
+func (c Client) rotates() bool {
+	for _, r := range c.HostMappings {
+		if strings.Contains(r.Dst, ",") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// attemptKey is the context key for the number of the attempt a dial is for.
+type attemptKey struct{}
+
+// withAttempt returns a context whose dials are for attempt n.
+func withAttempt(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, n)
+}
+
+// getDstHost is where a dial for addr connects to: the destination of the
+// first mapping that matches, as of the attempt ctx is for, or addr itself.
+func (c Client) getDstHost(ctx context.Context, addr string) string {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	for _, r := range c.HostMappings {
+		if r.Matches(addr) {
+			return r.DstHost(attempt, addr)
+		}
+	}
+
+	return addr
+}
+
+// dstHosts lists every destination a dial for addr may connect to, in the
+// order attempts take them.
+func (c Client) dstHosts(addr string) []string {
+	for _, r := range c.HostMappings {
+		if r.Matches(addr) {
+			res := make([]string, len(r.Dsts()))
+			for i := range res {
+				res[i] = r.DstHost(i+1, addr)
+			}
+			return res
+		}
+	}
+
+	return []string{addr}
+}
@@ -0,0 +1,134 @@
+package httpassert
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// http3Transport sends every request over QUIC, with no fallback: --http3
+// exists to check the QUIC path, and a run that quietly retreated to TCP when
+// that path was broken would pass the one time it should fail.
+//
+// quic-go's own dialer knows nothing of HostMappings or Resolve and resolves
+// the name out of sight of the trace, so it is replaced by one that does all
+// three the way the TCP dialer and net/http do. The QUIC handshake is the TLS
+// handshake, so the connect and tls phases time the same thing.
+func (c Client) http3Transport(tlsConf *tls.Config) *http3.Transport {
+	return &http3.Transport{
+		TLSClientConfig: tlsConf,
+		// The same reason as for the TCP transport: decodeBody decodes.
+		DisableCompression: true,
+		Dial: func(ctx context.Context, addr string, tlsConf *tls.Config, conf *quic.Config) (*quic.Conn, error) {
+			trace := httptrace.ContextClientTrace(ctx)
+			udpAddr, err := c.resolveAddr(ctx, trace, c.getDstHost(ctx, addr))
+			if err != nil {
+				return nil, err
+			}
+
+			if trace != nil && trace.ConnectStart != nil {
+				trace.ConnectStart("udp", udpAddr)
+			}
+			if trace != nil && trace.TLSHandshakeStart != nil {
+				trace.TLSHandshakeStart()
+			}
+			conn, err := quic.DialAddrEarly(ctx, udpAddr, tlsConf, conf)
+			var state tls.ConnectionState
+			if conn != nil {
+				state = conn.ConnectionState().TLS
+			}
+			if trace != nil && trace.TLSHandshakeDone != nil {
+				trace.TLSHandshakeDone(state, err)
+			}
+			if trace != nil && trace.ConnectDone != nil {
+				trace.ConnectDone("udp", udpAddr, err)
+			}
+
+			return conn, err
+		},
+	}
+}
+
+// altSvc is one alternative an Alt-Svc header advertises: a protocol, such as
+// h3, and where it is served, such as ":443" for the same host.
+type altSvc struct {
+	protocol  string
+	authority string
+}
+
+// parseAltSvc reads the alternatives in an Alt-Svc value (RFC 7838). "clear",
+// which withdraws every earlier advertisement, yields none, and so does an
+// entry that does not parse: it advertises nothing a client could use.
+func parseAltSvc(value string) []altSvc {
+	var res []altSvc
+	for _, entry := range splitQuoted(value, ',') {
+		alt, _, _ := strings.Cut(entry, ";")
+		proto, authority, ok := strings.Cut(strings.TrimSpace(alt), "=")
+		if !ok || proto == "" {
+			continue
+		}
+		// The protocol id is percent-encoded, as in h3%2D29; the authority is
+		// a quoted-string, though some servers leave the quotes off.
+		if p, err := url.PathUnescape(proto); err == nil {
+			proto = p
+		}
+		authority = strings.Trim(strings.TrimSpace(authority), `"`)
+		res = append(res, altSvc{protocol: proto, authority: authority})
+	}
+
+	return res
+}
+
+// splitQuoted splits s at sep wherever it is not inside double quotes.
+func splitQuoted(s string, sep rune) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// AssertAltSvc holds when the response's Alt-Svc header advertises the
+// protocol, such as h3. Written as h3=:443, the authority has to match as
+// well; the quotes the header puts around it are optional here.
+func AssertAltSvc(spec string) Assertion {
+	proto, authority, withAuthority := strings.Cut(spec, "=")
+	authority = strings.Trim(authority, `"`)
+
+	return newAssertion("alt-svc", spec, func(res *Response) (*Failure, error) {
+		vs := res.Header.Values("Alt-Svc")
+		for _, v := range vs {
+			for _, alt := range parseAltSvc(v) {
+				if alt.protocol == proto && (!withAuthority || alt.authority == authority) {
+					return nil, nil
+				}
+			}
+		}
+
+		f := &Failure{
+			Target:   spec,
+			Expected: spec,
+			Message:  fmt.Sprintf("alt-svc[%s]: expected to be advertised, no Alt-Svc header", spec),
+		}
+		if vs != nil {
+			f.Actual = vs
+			f.Message = fmt.Sprintf("alt-svc[%s]: expected to be advertised, got %s", spec, headerValues(vs))
+		}
+
+		return f, nil
+	})
+}
@@ -0,0 +1,135 @@
+package httpassert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func Test_parseAltSvc(t *testing.T) {
+	t.Parallel()
+
+	for value, want := range map[string][]altSvc{
+		`h3=":443"; ma=86400`:                   {{"h3", ":443"}},
+		`h3=":443"; ma=86400, h3-29=":443"`:     {{"h3", ":443"}, {"h3-29", ":443"}},
+		`h3%2D29="cdn.example:8443"; persist=1`: {{"h3-29", "cdn.example:8443"}},
+		`h2="alt.example:443", h3=:443`:         {{"h2", "alt.example:443"}, {"h3", ":443"}},
+		`h3="a,b:443"`:                          {{"h3", "a,b:443"}},
+		`clear`:                                 nil,
+		`garbage, h3=":443"`:                    {{"h3", ":443"}},
+	} {
+		got := parseAltSvc(value)
+		if len(got) != len(want) {
+			t.Errorf("%s: got %v, want %v", value, got, want)
+			continue
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s: alternative %d is %v, want %v", value, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func Test_AssertAltSvc(t *testing.T) {
+	t.Parallel()
+
+	res := func(vs ...string) *Response {
+		return &Response{Response: &http.Response{StatusCode: 200, Header: http.Header{"Alt-Svc": vs}}}
+	}
+
+	for _, tt := range []struct {
+		spec string
+		res  *Response
+		want string
+	}{
+		{"h3", res(`h3=":443"; ma=86400`), ""},
+		{"h3=:443", res(`h3=":443"; ma=86400`), ""},
+		{`h3=":443"`, res(`h2=":443"`, `h3=":443"`), ""},
+		{"h3", res(`h3-29=":443"`), `alt-svc[h3]: expected to be advertised, got "h3-29=\":443\""`},
+		{"h3=:8443", res(`h3=":443"`), `alt-svc[h3=:8443]: expected to be advertised, got "h3=\":443\""`},
+		{"h3", res("clear"), `alt-svc[h3]: expected to be advertised, got "clear"`},
+		{"h3", res(), "alt-svc[h3]: expected to be advertised, no Alt-Svc header"},
+	} {
+		checkErr(t, tt.spec, check(AssertAltSvc(tt.spec), tt.res), tt.want)
+	}
+}
+
+// http3Server serves HTTP/3 on a UDP port of 127.0.0.1, with the PKI's server
+// certificate, and nothing on TCP.
+func (p pki) http3Server(t *testing.T, handler http.Handler) string {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: []tls.Certificate{p.server}}),
+	}
+	go func() { _ = srv.Serve(conn) }()
+	t.Cleanup(func() { _ = srv.Close(); _ = conn.Close() })
+
+	return "https://" + conn.LocalAddr().String()
+}
+
+func Test_Client_http3(t *testing.T) {
+	t.Parallel()
+
+	p := newPKI(t)
+	url := p.http3Server(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	}))
+	roots := x509.NewCertPool()
+	roots.AddCert(p.ca)
+
+	req, err := Request{URL: url}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, _ := ParseTLSVersionSpec("1.3")
+
+	c := Client{HTTP3: true, RootCAs: roots}
+	result := c.Run(req, AssertProto("HTTP/3.0"), AssertBodyEqual("HTTP/3.0"),
+		AssertTLSVersion(version), AssertALPN("h3"))
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if tm := result.Attempts[0].Response.Timing; tm == nil || tm.TLS <= 0 || tm.Total < tm.TLS {
+		t.Errorf("timing = %v, want the QUIC handshake timed", tm)
+	}
+
+	// Verified like any other TLS connection, and never over TCP.
+	c = Client{HTTP3: true}
+	if err := c.Do(req, AssertStatusOK()); !errors.Is(err, ErrTransport) {
+		t.Errorf("without the CA: got %v, want a transport failure", err)
+	}
+	c = Client{RootCAs: roots}
+	if err := c.Do(req, AssertStatusOK()); !errors.Is(err, ErrTransport) {
+		t.Errorf("over TCP: got %v, want a transport failure", err)
+	}
+}
+
+// A mapping sends the QUIC connection elsewhere, as it does a TCP one.
+func Test_Client_http3HostMapping(t *testing.T) {
+	t.Parallel()
+
+	p := newPKI(t)
+	url := p.http3Server(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req, err := Request{URL: "https://cdn.invalid/"}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping := HostMapping{Src: "cdn.invalid:443", Dst: url[len("https://"):]}
+
+	c := Client{HTTP3: true, SkipSslChecks: true, HostMappings: []HostMapping{mapping}}
+	if err := c.Do(req, AssertProto("HTTP/3.0")); err != nil {
+		t.Error(err)
+	}
+}
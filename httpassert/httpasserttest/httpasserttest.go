@@ -0,0 +1,113 @@
+// Package httpasserttest checks a response from a Go test with the assertions
+// of package httpassert, and reports a failed check through t.Errorf with the
+// dump the http-assert command prints.
+//
+//	func TestHealth(t *testing.T) {
+//		srv := httptest.NewServer(newRouter())
+//		defer srv.Close()
+//
+//		jq, _ := httpassert.AssertJQ(`.status == "up"`)
+//		httpasserttest.Server(t, srv, httpassert.Request{URL: "/health"},
+//			httpassert.AssertStatusOK(), jq)
+//	}
+//
+// A test that needs more than one attempt, or redirects followed, sets up an
+// httpassert.Client itself; Transport is the field that points it at a test
+// server.
+package httpasserttest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/korya/http-assert/httpassert"
+)
+
+// Server sends req to srv and checks the response. req.URL is resolved against
+// srv.URL, so "/health" names the server's own path; a TLS server is trusted.
+// It reports whether every assertion held.
+func Server(t testing.TB, srv *httptest.Server, req httpassert.Request, assertions ...httpassert.Assertion) bool {
+	t.Helper()
+
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Errorf("httpasserttest: bad server URL %q: %s", srv.URL, err)
+		return false
+	}
+	ref, err := url.Parse(req.URL)
+	if err != nil {
+		t.Errorf("httpasserttest: bad request URL %q: %s", req.URL, err)
+		return false
+	}
+	req.URL = base.ResolveReference(ref).String()
+
+	// The server's own client trusts its certificate, but decompresses what it
+	// asked to be compressed, and the assertions would see neither the
+	// Content-Encoding nor the bytes a real client gets. The CLI's transport
+	// leaves that to the assertions, and so does this one.
+	tr := srv.Client().Transport
+	if ht, ok := tr.(*http.Transport); ok {
+		ht = ht.Clone()
+		ht.DisableCompression = true
+		tr = ht
+	}
+
+	return check(t, tr, req, assertions)
+}
+
+// Handler serves req with h and checks the response, with no listener at all.
+// A URL with no host is served as if sent to example.com.
+func Handler(t testing.TB, h http.Handler, req httpassert.Request, assertions ...httpassert.Assertion) bool {
+	t.Helper()
+
+	if strings.HasPrefix(req.URL, "/") {
+		req.URL = "http://example.com" + req.URL
+	}
+
+	return check(t, handlerTransport{h}, req, assertions)
+}
+
+func check(t testing.TB, tr http.RoundTripper, req httpassert.Request, assertions []httpassert.Assertion) bool {
+	t.Helper()
+
+	r, err := req.Build(t.Context())
+	if err != nil {
+		t.Errorf("httpasserttest: cannot build the request: %s", err)
+		return false
+	}
+
+	c := httpassert.Client{LogLevel: httpassert.LInfo, Log: t.Output(), Transport: tr}
+	if err := c.Do(r, assertions...); err != nil {
+		t.Errorf("%s", err)
+		return false
+	}
+
+	return true
+}
+
+// handlerTransport answers a request by calling a handler, the way a server
+// would have: the handler sees a server-side request, and the client sees the
+// response the handler wrote.
+type handlerTransport struct{ h http.Handler }
+
+func (tr handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	in := req.Clone(req.Context())
+	in.RequestURI = req.URL.RequestURI()
+	in.RemoteAddr = "192.0.2.1:1234" // httptest.NewRequest's
+	if in.Host == "" {
+		in.Host = req.URL.Host
+	}
+	if in.Body == nil {
+		in.Body = http.NoBody
+	}
+
+	rec := httptest.NewRecorder()
+	tr.h.ServeHTTP(rec, in)
+	res := rec.Result()
+	res.Request = req
+
+	return res, nil
+}
@@ -0,0 +1,120 @@
+package httpasserttest
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/korya/http-assert/httpassert"
+)
+
+// recorder keeps what the helpers report instead of failing the test that
+// checks them.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func echo() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Path", r.URL.Path)
+		_, _ = fmt.Fprintf(w, `{"method":%q,"body":%q}`, r.Method, body)
+	})
+}
+
+func Test_helpers(t *testing.T) {
+	t.Parallel()
+
+	// Closed by Cleanup rather than defer: the subtests are parallel, and run
+	// after this function has returned.
+	srv := httptest.NewServer(echo())
+	t.Cleanup(srv.Close)
+	tls := httptest.NewTLSServer(echo())
+	t.Cleanup(tls.Close)
+
+	run := map[string]func(t testing.TB, req httpassert.Request, as ...httpassert.Assertion) bool{
+		"Server": func(t testing.TB, req httpassert.Request, as ...httpassert.Assertion) bool {
+			return Server(t, srv, req, as...)
+		},
+		"Server over TLS": func(t testing.TB, req httpassert.Request, as ...httpassert.Assertion) bool {
+			return Server(t, tls, req, as...)
+		},
+		"Handler": func(t testing.TB, req httpassert.Request, as ...httpassert.Assertion) bool {
+			return Handler(t, echo(), req, as...)
+		},
+	}
+
+	for name, check := range run {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jq, err := httpassert.AssertJQ(`.method == "PUT" and .body == "hi"`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &recorder{TB: t}
+			req := httpassert.Request{Method: http.MethodPut, URL: "/things/1", Body: []byte("hi")}
+			if !check(r, req, httpassert.AssertStatusOK(), httpassert.AssertHeaderEqual("X-Path", "/things/1"), jq) {
+				t.Errorf("a passing check reported failure: %v", r.errors)
+			}
+
+			r = &recorder{TB: t}
+			if check(r, httpassert.Request{URL: "/x"}, httpassert.AssertStatus(mustSpec(t, "201"))) {
+				t.Fatal("a failing check reported success")
+			}
+			if len(r.errors) != 1 {
+				t.Fatalf("got %d errors, want one: %v", len(r.errors), r.errors)
+			}
+			// The CLI's dump, body included.
+			for _, want := range []string{
+				"1 assertions failed:", `status: expected 201, got 200 ("200 OK")`,
+				"FAILED: GET ", `{"method":"GET","body":""}`,
+			} {
+				if !strings.Contains(r.errors[0], want) {
+					t.Errorf("the report does not contain %q:\n%s", want, r.errors[0])
+				}
+			}
+		})
+	}
+}
+
+// The server's own client would gunzip the body and drop the header, and the
+// assertions would then disagree with what the CLI says about the same server.
+func Test_Server_leavesCompressionToTheAssertions(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		_, _ = zw.Write([]byte(`{"ok":true}`))
+		_ = zw.Close()
+	}))
+	defer srv.Close()
+
+	jq, _ := httpassert.AssertJQ(".ok")
+	r := &recorder{TB: t}
+	if !Server(r, srv, httpassert.Request{URL: "/"}, httpassert.AssertHeaderEqual("Content-Encoding", "gzip"), jq) {
+		t.Errorf("the compressed response did not pass: %v", r.errors)
+	}
+}
+
+func mustSpec(t *testing.T, text string) httpassert.StatusSpec {
+	t.Helper()
+
+	spec, err := httpassert.ParseStatusSpec(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return spec
+}
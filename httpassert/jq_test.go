@@ -1,4 +1,4 @@
-package main
+package httpassert
 
 import (
 	"errors"
@@ -15,7 +15,7 @@ const jqDoc = `{"status":"success","count":5,"active":true,"nothing":null,
 
 // jqResponse builds a response carrying the document above, or whatever body a
 // case needs.
-func jqResponse(body string) *httpResponse {
+func jqResponse(body string) *Response {
 	r := response("200 OK", http.Header{}, "")
 	r.BodyBytes = []byte(body)
 
@@ -0,0 +1,125 @@
+package httpassert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientCredentials gets an OAuth 2.0 bearer token from a token endpoint with
+// the client credentials grant, and keeps it for as long as it is valid. Share
+// one between runs by pointer, and they share the token.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// Scope is sent as it is, space-separated scopes and all; empty sends
+	// none, and the server's default applies.
+	Scope string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time // zero for a token that said nothing of expiring
+}
+
+// tokenRefreshMargin is how long before its expiry a token is replaced, so
+// that it does not run out between being sent and being checked.
+const tokenRefreshMargin = 10 * time.Second
+
+// TokenError is a failure to get a token. It is a transport failure of the
+// run, since the request was never sent, but one of the token endpoint rather
+// than the service being checked.
+type TokenError struct {
+	URL string
+	Err error
+}
+
+func (e *TokenError) Error() string {
+	return fmt.Sprintf("token endpoint %s: %s", e.URL, e.Err)
+}
+
+func (e *TokenError) Unwrap() error { return e.Err }
+
+// Token returns the cached token, or a new one from the endpoint when there is
+// none or it is about to expire. client sends the token request; a run uses
+// one that shares only its TLS and proxy settings.
+func (cc *ClientCredentials) Token(ctx context.Context, client *http.Client) (token string, fresh bool, err error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.token != "" && (cc.expiry.IsZero() || time.Until(cc.expiry) > tokenRefreshMargin) {
+		return cc.token, false, nil
+	}
+	token, lifetime, err := cc.fetch(ctx, client)
+	if err != nil {
+		return "", false, &TokenError{URL: cc.TokenURL, Err: err}
+	}
+	cc.token, cc.expiry = token, time.Time{}
+	if lifetime > 0 {
+		cc.expiry = time.Now().Add(lifetime)
+	}
+
+	return token, true, nil
+}
+
+// fetch asks the endpoint for a token, the client authenticating with HTTP
+// Basic as RFC 6749 has servers support.
+func (cc *ClientCredentials) fetch(ctx context.Context, client *http.Client) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if cc.Scope != "" {
+		form.Set("scope", cc.Scope)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cc.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cc.ClientID), url.QueryEscape(cc.ClientSecret))
+
+	res, err := client.Do(req) // #nosec G704 - the operator's own token endpoint
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = res.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", 0, err
+	}
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	jsonErr := json.Unmarshal(body, &tok)
+	switch {
+	// The error the endpoint names is the whole diagnosis: invalid_client is
+	// the secret, invalid_scope the scope.
+	case tok.Error != "":
+		msg := fmt.Sprintf("%s: %s", res.Status, tok.Error)
+		if tok.Description != "" {
+			msg += " (" + tok.Description + ")"
+		}
+		return "", 0, errors.New(msg)
+	case res.StatusCode != http.StatusOK:
+		return "", 0, errors.New(res.Status)
+	case jsonErr != nil:
+		return "", 0, fmt.Errorf("the response is not a token: %w", jsonErr)
+	case tok.AccessToken == "":
+		return "", 0, errors.New("the response has no access_token")
+	case tok.TokenType != "" && !strings.EqualFold(tok.TokenType, "Bearer"):
+		return "", 0, fmt.Errorf("the token is of type %q, not Bearer", tok.TokenType)
+	}
+
+	return tok.AccessToken, time.Duration(tok.ExpiresIn) * time.Second, nil
+}
@@ -0,0 +1,203 @@
+package httpassert
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer issues tok1, tok2 and so on to client:s3cret, each valid for
+// expiresIn seconds, and counts them.
+func tokenServer(t *testing.T, expiresIn int, issued *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "client_credentials" ||
+			id != "client" || secret != "s3cret" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"bad secret"}`))
+			return
+		}
+		n := issued.Add(1)
+		_, _ = fmt.Fprintf(w, `{"access_token":"tok%d","token_type":"bearer","expires_in":%d,"scope":%q}`,
+			n, expiresIn, r.Form.Get("scope"))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func Test_Client_oauth2(t *testing.T) {
+	t.Parallel()
+
+	var issued atomic.Int32
+	tokens := tokenServer(t, 3600, &issued)
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cc := &ClientCredentials{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cret", Scope: "read"}
+	req, err := Request{URL: srv.URL}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Client{OAuth2: cc, Retries: 2}.Do(req, AssertStatusOK())
+	if !errors.Is(err, ErrAssertion) {
+		t.Fatalf("got %v, want the 503's assertion failure", err)
+	}
+
+	// One token for the run, sent with every attempt, and redacted.
+	mu.Lock()
+	defer mu.Unlock()
+	if issued.Load() != 1 || strings.Join(sent, ",") != "Bearer tok1,Bearer tok1,Bearer tok1" {
+		t.Errorf("%d tokens issued, sent %q", issued.Load(), sent)
+	}
+	if !strings.Contains(err.Error(), "Authorization: Bearer xxxxx") || strings.Contains(err.Error(), "tok1") {
+		t.Errorf("the dump does not redact the token:\n%s", err)
+	}
+
+	// One that has run out is replaced.
+	cc.expiry = time.Now().Add(tokenRefreshMargin - time.Second)
+	token, fresh, err := cc.Token(t.Context(), http.DefaultClient)
+	if err != nil || !fresh || token != "tok2" {
+		t.Errorf("after expiry got %q, %t, %v; want a fresh tok2", token, fresh, err)
+	}
+}
+
+// A token that expires within tokenRefreshMargin is replaced before every
+// attempt, and each attempt sends the one it got, redacted all the same.
+func Test_Client_oauth2Refresh(t *testing.T) {
+	t.Parallel()
+
+	var issued atomic.Int32
+	tokens := tokenServer(t, 5, &issued)
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cc := &ClientCredentials{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cret"}
+	req, err := Request{URL: srv.URL}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Client{OAuth2: cc, Retries: 2}.Do(req, AssertStatusOK())
+	if !errors.Is(err, ErrAssertion) {
+		t.Fatalf("got %v, want the 503's assertion failure", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if issued.Load() != 3 || strings.Join(sent, ",") != "Bearer tok1,Bearer tok2,Bearer tok3" {
+		t.Errorf("%d tokens issued, sent %q", issued.Load(), sent)
+	}
+	if !strings.Contains(err.Error(), "Authorization: Bearer xxxxx") || strings.Contains(err.Error(), "tok3") {
+		t.Errorf("the dump does not redact the token:\n%s", err)
+	}
+	if got := req.Header.Get("Authorization"); got != "" {
+		t.Errorf("the caller's request was left with %q", got)
+	}
+}
+
+func Test_Client_oauth2Fails(t *testing.T) {
+	t.Parallel()
+
+	var issued atomic.Int32
+	tokens := tokenServer(t, 0, &issued)
+	var asked atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asked.Add(1)
+	}))
+	defer srv.Close()
+	req, err := Request{URL: srv.URL}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"refused", tokens.URL, "401 Unauthorized: invalid_client (bad secret)"},
+		{"not a token endpoint", srv.URL, "the response is not a token"},
+	} {
+		cc := &ClientCredentials{TokenURL: tt.url, ClientID: "client", ClientSecret: "wrong"}
+		asked.Store(0)
+		res := Client{OAuth2: cc}.Run(req, AssertStatusOK())
+		err := res.Err
+
+		var tokErr *TokenError
+		if !errors.Is(err, ErrTransport) || !errors.As(res.Attempts[0].SendErr, &tokErr) {
+			t.Fatalf("%s: got %v, want a transport failure sent by a *TokenError", tt.name, err)
+		}
+		want := "failed to get an OAuth 2.0 token, so the request was not sent:\n- token endpoint " + tt.url + ": " + tt.want
+		if !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, err, want)
+		}
+		if tt.name == "refused" && asked.Load() != 0 {
+			t.Errorf("%s: the service was asked %d times", tt.name, asked.Load())
+		}
+	}
+}
+
+// The token endpoint is reached as itself: over TCP although the run dials a
+// Unix socket, and without the run's jar, so its cookies stay its own.
+func Test_Client_oauth2TokenClient(t *testing.T) {
+	t.Parallel()
+
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "idp", Value: "s3ss10n", Path: "/"})
+		_, _ = w.Write([]byte(`{"access_token":"tok1","token_type":"bearer"}`))
+	}))
+	defer tokens.Close()
+
+	sock := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s|%s", r.Header.Get("Authorization"), r.Header.Get("Cookie"))
+	}))
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+
+	// The same host as the token endpoint, for its cookie to be sent if the
+	// jar were shared.
+	req, err := Request{URL: "http://127.0.0.1/me"}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := &ClientCredentials{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cret"}
+	c := Client{OAuth2: cc, UnixSocket: sock, Jar: NewCookieJar()}
+	if err := c.Do(req, AssertBodyEqual("Bearer tok1|")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,150 @@
+package httpassert
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/proxy"
+)
+
+// ParseProxy reads curl's form of --proxy: a URL whose scheme is http, https,
+// socks5 or socks5h, or a bare host:port, which is an HTTP proxy. A proxy
+// without a port is on curl's default for its scheme: 443 for https, 1080 for
+// the others.
+func ParseProxy(text string) (*url.URL, error) {
+	raw := text
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a URL", text)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	port := "1080"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "http", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q; possible values: http, https, socks5, socks5h", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("%q names no host", text)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return u, nil
+}
+
+// proxyFor returns the proxy a request to u goes through, nil for none. Proxy
+// replaces the environment's choice rather than adding to it, and NoProxy
+// applies to both. Unlike NO_PROXY, NoProxy does not exempt localhost on its
+// own: a proxy the caller named is used for whatever the caller points it at.
+func (c Client) proxyFor(u *url.URL) (*url.URL, error) {
+	switch {
+	case c.UnixSocket != "":
+		// There is no host to ask a proxy for.
+		return nil, nil
+	case c.HTTP3:
+		// Nor a proxy that carries QUIC.
+		return nil, nil
+	case bypassProxy(c.NoProxy, u.Hostname()):
+		return nil, nil
+	case c.Proxy != nil:
+		return c.Proxy, nil
+	case c.NoProxyEnv:
+		return nil, nil
+	}
+
+	return http.ProxyFromEnvironment(&http.Request{URL: u})
+}
+
+// bypassProxy reports whether host is on a --noproxy list: "*" matches every
+// host, an address or CIDR block matches addresses, and a name matches itself
+// and its subdomains, with or without a leading dot.
+func bypassProxy(list []string, host string) bool {
+	ip := net.ParseIP(host)
+	for _, entry := range list {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		}
+		if _, block, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && block.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if entryIP := net.ParseIP(strings.Trim(entry, "[]")); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		name := strings.TrimPrefix(entry, ".")
+		host := strings.ToLower(host)
+		if name != "" && (host == name || strings.HasSuffix(host, "."+name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSOCKS reports whether u is a SOCKS proxy, which the dialer speaks to
+// rather than net/http.
+func isSOCKS(u *url.URL) bool {
+	return u != nil && (u.Scheme == "socks5" || u.Scheme == "socks5h")
+}
+
+// dialSOCKS connects to addr through a SOCKS proxy. net/http can do this
+// itself but treats socks5 as socks5h, resolving every name at the proxy;
+// curl's socks5 resolves it here and sends the proxy an address, and a run
+// that resolved it elsewhere would check a different path from the one asked
+// for.
+func (c Client) dialSOCKS(ctx context.Context, forward *net.Dialer, socks *url.URL, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if socks.User != nil {
+		password, _ := socks.User.Password()
+		auth = &proxy.Auth{User: socks.User.Username(), Password: password}
+	}
+
+	if socks.Scheme == "socks5" {
+		var err error
+		if addr, err = c.resolveAddr(ctx, httptrace.ContextClientTrace(ctx), addr); err != nil {
+			return nil, err
+		}
+	}
+
+	// The connection to the proxy is mapped and resolved like any other.
+	toProxy := dialFunc(func(ctx context.Context, _, proxyAddr string) (net.Conn, error) {
+		return c.dialTCP(ctx, forward, proxyAddr)
+	})
+	d, err := proxy.SOCKS5("tcp", socks.Host, auth, toProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+}
+
+// dialFunc is a function as a proxy.ContextDialer.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f dialFunc) Dial(network, addr string) (net.Conn, error) {
+	return f(context.Background(), network, addr)
+}
+
+func (f dialFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
@@ -0,0 +1,250 @@
+package httpassert
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_ParseProxy(t *testing.T) {
+	t.Parallel()
+
+	for text, want := range map[string]string{
+		"proxy.corp:3128":           "http://proxy.corp:3128",
+		"proxy.corp":                "http://proxy.corp:1080",
+		"HTTPS://proxy.corp":        "https://proxy.corp:443",
+		"socks5h://u:p@[::1]":       "socks5h://u:p@[::1]:1080",
+		"socks5://127.0.0.1:9050/":  "socks5://127.0.0.1:9050/",
+		"http://user@proxy.corp:80": "http://user@proxy.corp:80",
+	} {
+		got, err := ParseProxy(text)
+		checkErr(t, text, err, "")
+		if got != nil && got.String() != want {
+			t.Errorf("%q: got %s, want %s", text, got, want)
+		}
+	}
+
+	for text, wantErr := range map[string]string{
+		"socks4://proxy.corp": `unsupported proxy scheme "socks4"; possible values: http, https, socks5, socks5h`,
+		"http://":             `"http://" names no host`,
+		"http://%zz":          `"http://%zz" is not a URL`,
+	} {
+		_, err := ParseProxy(text)
+		checkErr(t, text, err, wantErr)
+	}
+}
+
+func Test_bypassProxy(t *testing.T) {
+	t.Parallel()
+
+	list := []string{"internal.example", ".corp", "10.0.0.0/8", "::1", " 192.168.1.5 "}
+	for host, want := range map[string]bool{
+		"internal.example":     true,
+		"api.internal.example": true,
+		"xinternal.example":    false,
+		"CI.CORP":              true,
+		"corp":                 true,
+		"10.1.2.3":             true,
+		"11.1.2.3":             false,
+		"::1":                  true,
+		"192.168.1.5":          true,
+		"example.com":          false,
+		// Unlike NO_PROXY, localhost is not exempt unless it is listed.
+		"localhost": false,
+	} {
+		if got := bypassProxy(list, host); got != want {
+			t.Errorf("%s: got %v, want %v", host, got, want)
+		}
+	}
+	if !bypassProxy([]string{"*"}, "example.com") {
+		t.Error("* does not match every host")
+	}
+}
+
+func Test_Client_proxy(t *testing.T) {
+	t.Parallel()
+
+	// A forward proxy receives the absolute URL, and answers for the origin.
+	var auth string
+	proxySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Proxy-Authorization")
+		_, _ = w.Write([]byte("proxied " + r.URL.String()))
+	}))
+	defer proxySrv.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("direct"))
+	}))
+	defer origin.Close()
+
+	p, err := ParseProxy(proxySrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.User = url.UserPassword("alice", "s3cret")
+	req, err := Request{URL: "http://origin.invalid/x"}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log strings.Builder
+	c := Client{Proxy: p, LogLevel: LInfo, Log: &log}
+	if err := c.Do(req, AssertBodyEqual("proxied http://origin.invalid/x")); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Basic YWxpY2U6czNjcmV0" {
+		t.Errorf("Proxy-Authorization = %q, want alice's credentials", auth)
+	}
+	if want := "(via proxy http://alice:xxxxx@" + p.Host + ")"; !strings.Contains(log.String(), want) {
+		t.Errorf("the log does not contain %q:\n%s", want, log.String())
+	}
+
+	// Listed hosts go direct, and the dump names the proxy otherwise.
+	direct, err := Request{URL: origin.URL}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = Client{Proxy: p, NoProxy: []string{"127.0.0.1"}}
+	if err := c.Do(direct, AssertBodyEqual("direct")); err != nil {
+		t.Error(err)
+	}
+	c = Client{Proxy: p}
+	err = c.Do(direct, AssertBodyEqual("direct"))
+	if err == nil || !strings.Contains(err.Error(), "Proxy: http://alice:xxxxx@"+p.Host+"\n") {
+		t.Errorf("got %v, want a dump naming the proxy", err)
+	}
+}
+
+// socksServer is enough of a SOCKS5 server for a test: it takes an optional
+// username and password and CONNECTs to the target, recording the address it
+// was sent, which is a name for socks5h and an address for socks5.
+type socksServer struct {
+	addr, user, password string
+
+	mu      sync.Mutex
+	targets []string
+}
+
+func newSOCKSServer(t *testing.T, user, password string) *socksServer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	s := &socksServer{addr: l.Addr().String(), user: user, password: password}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *socksServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	read := func(n int) []byte {
+		b := make([]byte, n)
+		_, _ = io.ReadFull(r, b)
+		return b
+	}
+
+	methods := read(int(read(2)[1]))
+	if s.user == "" {
+		_, _ = conn.Write([]byte{5, 0})
+	} else {
+		if !strings.Contains(string(methods), "\x02") {
+			_, _ = conn.Write([]byte{5, 0xff})
+			return
+		}
+		_, _ = conn.Write([]byte{5, 2})
+		user := string(read(int(read(2)[1])))
+		password := string(read(int(read(1)[0])))
+		if user != s.user || password != s.password {
+			_, _ = conn.Write([]byte{1, 1})
+			return
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	}
+
+	var host string
+	switch head := read(4); head[3] {
+	case 1:
+		host = net.IP(read(4)).String()
+	case 4:
+		host = net.IP(read(16)).String()
+	default:
+		host = string(read(int(read(1)[0])))
+	}
+	port := binary.BigEndian.Uint16(read(2))
+	s.mu.Lock()
+	s.targets = append(s.targets, host)
+	s.mu.Unlock()
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer func() { _ = target.Close() }()
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go func() { _, _ = io.Copy(target, r) }()
+	_, _ = io.Copy(conn, target)
+}
+
+func Test_Client_socksProxy(t *testing.T) {
+	t.Parallel()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	defer origin.Close()
+	_, port, _ := net.SplitHostPort(origin.Listener.Addr().String())
+	s := newSOCKSServer(t, "bob", "hunter2")
+
+	req, err := Request{URL: "http://localhost:" + port + "/"}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// socks5h hands the proxy the name; socks5 resolves it first.
+	for scheme, want := range map[string]string{"socks5h": "localhost", "socks5": "127.0.0.1"} {
+		p, err := ParseProxy(scheme + "://bob:hunter2@" + s.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := Client{Proxy: p}
+		if err := c.Do(req, AssertBodyEqual("localhost:"+port)); err != nil {
+			t.Errorf("%s: %s", scheme, err)
+			continue
+		}
+		s.mu.Lock()
+		got := s.targets[len(s.targets)-1]
+		s.mu.Unlock()
+		if got != want {
+			t.Errorf("%s: the proxy was asked for %s, want %s", scheme, got, want)
+		}
+	}
+
+	p, _ := ParseProxy("socks5h://bob:wrong@" + s.addr)
+	c := Client{Proxy: p}
+	if err := c.Do(req, AssertStatusOK()); !errors.Is(err, ErrTransport) {
+		t.Errorf("wrong credentials: got %v, want a transport failure", err)
+	}
+}
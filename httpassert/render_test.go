@@ -1,4 +1,4 @@
-package main
+package httpassert
 
 import (
 	"io"
@@ -9,8 +9,8 @@ import (
 
 // response builds the shape Client.Do hands to the renderer: a real
 // *http.Response plus the body already read off the wire.
-func response(status string, header http.Header, body string) httpResponse {
-	return httpResponse{
+func response(status string, header http.Header, body string) Response {
+	return Response{
 		Response: &http.Response{
 			Proto:  "HTTP/1.1",
 			Status: status,
@@ -32,7 +32,7 @@ func Test_httpResponse_writeTo(t *testing.T) {
 
 	tests := []struct {
 		Name     string
-		Response httpResponse
+		Response Response
 		WithBody bool
 		Want     string
 	}{{
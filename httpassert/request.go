@@ -0,0 +1,48 @@
+package httpassert
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// Request describes a request to build: the fields the CLI's request flags
+// set, with none of the flag syntax.
+type Request struct {
+	// Method defaults to GET.
+	Method string
+	URL    string
+	Header http.Header
+	// Body is sent as it is. Empty sends no body, which goes on the wire the
+	// same way as an empty one.
+	Body []byte
+}
+
+// Build returns the *http.Request r describes.
+//
+// The body is a bytes.Reader, one of the three types net/http knows how to
+// replay, so a retried attempt and the failure dump both send or show the body
+// that was given rather than whatever the first send left of it.
+func (r Request) Build(ctx context.Context) (*http.Request, error) {
+	m := r.Method
+	if m == "" {
+		m = http.MethodGet
+	}
+	b := io.Reader(http.NoBody)
+	if len(r.Body) > 0 {
+		b = bytes.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, m, r.URL, b)
+	if err != nil {
+		return nil, err
+	}
+	for name, vs := range r.Header {
+		for _, v := range vs {
+			req.Header.Add(name, v)
+		}
+	}
+
+	return req, nil
+}
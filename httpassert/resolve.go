@@ -0,0 +1,259 @@
+package httpassert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ResolveEntry gives the addresses of one host and port, in place of asking
+// DNS, as curl's --resolve does. Connections try Addrs in order.
+type ResolveEntry struct {
+	Host  string
+	Port  string
+	Addrs []string
+}
+
+// ParseResolve reads curl's form of --resolve, host:port:addr[,addr]..., in
+// which an IPv6 address may be written in brackets.
+func ParseResolve(text string) (ResolveEntry, error) {
+	parts := strings.SplitN(text, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+		return ResolveEntry{}, fmt.Errorf("%q is not host:port:addr[,addr]...", text)
+	}
+	host, port, list := parts[0], parts[1], parts[2]
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return ResolveEntry{}, fmt.Errorf("%q is not a port, in %q", port, text)
+	}
+
+	e := ResolveEntry{Host: strings.ToLower(host), Port: port}
+	for _, addr := range strings.Split(list, ",") {
+		addr = strings.TrimSpace(addr)
+		ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"))
+		if ip == nil {
+			return ResolveEntry{}, fmt.Errorf("%q is not an IP address, in %q", addr, text)
+		}
+		e.Addrs = append(e.Addrs, ip.String())
+	}
+
+	return e, nil
+}
+
+// ParseDNSServers reads curl's form of --dns-servers: a comma-separated list
+// of addresses, each with an optional port, 53 by default. An IPv6 address
+// with a port goes in brackets.
+func ParseDNSServers(text string) ([]string, error) {
+	var servers []string
+	for _, s := range strings.Split(text, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")); ip != nil {
+			servers = append(servers, net.JoinHostPort(ip.String(), "53"))
+			continue
+		}
+		host, port, err := net.SplitHostPort(s)
+		if err != nil || net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("%q is not an IP address, with an optional port", s)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return nil, fmt.Errorf("%q is not a port, in %q", port, s)
+		}
+		servers = append(servers, net.JoinHostPort(host, port))
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no DNS servers in %q", text)
+	}
+
+	return servers, nil
+}
+
+// resolver returns the resolver DNSServers name, or the system's. Each query
+// goes to the next server in turn, so a retried lookup moves on from one
+// that did not answer.
+func (c Client) resolver() *net.Resolver {
+	if len(c.DNSServers) == 0 {
+		return net.DefaultResolver
+	}
+
+	turn := c.dnsTurn
+	if turn == nil {
+		turn = new(atomic.Uint32)
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			server := c.DNSServers[int(turn.Add(1)-1)%len(c.DNSServers)]
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// network narrows "tcp" or "udp" to the family IPVersion asks for.
+func (c Client) network(base string) string {
+	switch c.IPVersion {
+	case 4:
+		return base + "4"
+	case 6:
+		return base + "6"
+	}
+
+	return base
+}
+
+// resolvedAddrs returns the addresses Resolve gives host:port, or nil when it
+// leaves them to DNS. Addresses of the wrong family are left out, and an
+// entry that leaves none is an error rather than a fallback to DNS.
+func (c Client) resolvedAddrs(host, port string) ([]string, error) {
+	for _, e := range c.Resolve {
+		if e.Port != port || !strings.EqualFold(e.Host, host) {
+			continue
+		}
+		var addrs []string
+		for _, a := range e.Addrs {
+			if c.familyOK(net.ParseIP(a)) {
+				addrs = append(addrs, a)
+			}
+		}
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("no IPv%d address for %s:%s among %s",
+				c.IPVersion, host, port, strings.Join(e.Addrs, ", "))
+		}
+		return addrs, nil
+	}
+
+	return nil, nil
+}
+
+// withDNSTurn returns c with a turn of its own for DNSServers, unless it has
+// one already, for a run to share among the resolvers it builds: the one its
+// transport dials with and the ones QUIC and proxy dials make afresh.
+func (c Client) withDNSTurn() Client {
+	if c.dnsTurn == nil {
+		c.dnsTurn = new(atomic.Uint32)
+	}
+
+	return c
+}
+
+// dnsError corrects the server a failed lookup names when DNSServers were
+// asked. net picks a server from resolv.conf before Dial replaces it, and
+// reports that one, which sends the reader to the wrong machine.
+func (c Client) dnsError(err error) error {
+	var dnsErr *net.DNSError
+	if len(c.DNSServers) > 0 && errors.As(err, &dnsErr) {
+		dnsErr.Server = strings.Join(c.DNSServers, ",")
+	}
+
+	return err
+}
+
+func (c Client) familyOK(ip net.IP) bool {
+	switch c.IPVersion {
+	case 4:
+		return ip.To4() != nil
+	case 6:
+		return ip.To4() == nil
+	}
+
+	return true
+}
+
+// dialTCP connects to addr after HostMappings, trying the addresses Resolve
+// gives it in order, or resolving it with DNSServers. The dialer looks the
+// name up itself, so the trace sees the lookup as it does without either.
+func (c Client) dialTCP(ctx context.Context, dialer *net.Dialer, addr string) (net.Conn, error) {
+	addr = c.getDstHost(ctx, addr)
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := c.resolvedAddrs(host, port)
+	if err != nil {
+		return nil, err
+	}
+	if addrs == nil {
+		conn, err := dialer.DialContext(ctx, c.network("tcp"), addr)
+		return conn, c.dnsError(err)
+	}
+
+	var errs []error
+	for _, a := range addrs {
+		conn, err := dialer.DialContext(ctx, c.network("tcp"), net.JoinHostPort(a, port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// resolveAddr looks up the host of addr the way dialTCP would, reporting a
+// DNS lookup to the trace as net/http does for TCP, and returns the first
+// address as host:port. It is for the dials net.Dialer does not make: QUIC's,
+// and the address a socks5 proxy is handed.
+func (c Client) resolveAddr(ctx context.Context, trace *httptrace.ClientTrace, addr string) (string, error) {
+	addrs, err := c.lookup(ctx, trace, addr)
+	if err != nil {
+		return "", err
+	}
+
+	return addrs[0], nil
+}
+
+// lookup returns every address the host of addr has, as host:port: addr
+// itself when the host is an address, the ones Resolve gives it, or DNS's,
+// narrowed to IPVersion's family.
+func (c Client) lookup(ctx context.Context, trace *httptrace.ClientTrace, addr string) ([]string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !c.familyOK(ip) {
+			return nil, fmt.Errorf("%s is not an IPv%d address", host, c.IPVersion)
+		}
+		return []string{addr}, nil
+	}
+
+	var res []string
+	if addrs, err := c.resolvedAddrs(host, port); addrs != nil || err != nil {
+		for _, a := range addrs {
+			res = append(res, net.JoinHostPort(a, port))
+		}
+		return res, err
+	}
+
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	ips, err := c.resolver().LookupNetIP(ctx, c.network("ip"), host)
+	if trace != nil && trace.DNSDone != nil {
+		info := httptrace.DNSDoneInfo{Err: err}
+		for _, ip := range ips {
+			info.Addrs = append(info.Addrs, net.IPAddr{IP: ip.AsSlice(), Zone: ip.Zone()})
+		}
+		trace.DNSDone(info)
+	}
+	if err != nil {
+		return nil, c.dnsError(err)
+	}
+
+	for _, ip := range ips {
+		a := net.JoinHostPort(ip.Unmap().String(), port)
+		if !slices.Contains(res, a) {
+			res = append(res, a)
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,229 @@
+package httpassert
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func Test_ParseResolve(t *testing.T) {
+	t.Parallel()
+
+	e, err := ParseResolve("API.example.com:443:10.0.0.1, [2001:db8::1],::2")
+	checkErr(t, "entry", err, "")
+	if e.Host != "api.example.com" || e.Port != "443" ||
+		strings.Join(e.Addrs, " ") != "10.0.0.1 2001:db8::1 ::2" {
+		t.Errorf("got %+v", e)
+	}
+
+	for text, wantErr := range map[string]string{
+		"api.example.com:443":            `"api.example.com:443" is not host:port:addr[,addr]...`,
+		"api.example.com:https:10.0.0.1": `"https" is not a port, in "api.example.com:https:10.0.0.1"`,
+		"api.example.com:443:backend":    `"backend" is not an IP address, in "api.example.com:443:backend"`,
+		":443:10.0.0.1":                  `":443:10.0.0.1" is not host:port:addr[,addr]...`,
+	} {
+		_, err := ParseResolve(text)
+		checkErr(t, text, err, wantErr)
+	}
+}
+
+func Test_ParseDNSServers(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseDNSServers("10.0.0.53, 10.0.0.54:5353,::1,[2001:db8::53]:5353")
+	checkErr(t, "list", err, "")
+	if want := "10.0.0.53:53 10.0.0.54:5353 [::1]:53 [2001:db8::53]:5353"; strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+
+	for text, wantErr := range map[string]string{
+		"ns1.example.com": `"ns1.example.com" is not an IP address, with an optional port`,
+		"10.0.0.53:dns":   `"dns" is not a port, in "10.0.0.53:dns"`,
+		" , ":             `no DNS servers in " , "`,
+	} {
+		_, err := ParseDNSServers(text)
+		checkErr(t, text, err, wantErr)
+	}
+}
+
+func Test_Client_resolve(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	req, err := Request{URL: "http://api.invalid:" + port + "/"}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing listens on 127.0.0.2, so the second address is the one that
+	// answers, and the response says so.
+	entry, _ := ParseResolve("api.invalid:" + port + ":127.0.0.2,127.0.0.1")
+	c := Client{Resolve: []ResolveEntry{entry}}
+	result := c.Run(req, AssertBodyEqual("api.invalid:"+port))
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if got := result.Attempts[0].Response.RemoteAddr; got != "127.0.0.1:"+port {
+		t.Errorf("RemoteAddr = %s, want the second address", got)
+	}
+
+	c.IPVersion = 6
+	err = c.Do(req, AssertStatusOK())
+	if !errors.Is(err, ErrTransport) || !strings.Contains(err.Error(), "no IPv6 address for api.invalid:"+port) {
+		t.Errorf("-6 with IPv4 addresses only: got %v", err)
+	}
+}
+
+func Test_Client_ipVersion(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("no IPv6 loopback:", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	req, err := Request{URL: "http://dual.invalid:" + port + "/"}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, _ := ParseResolve("dual.invalid:" + port + ":127.0.0.1,::1")
+	for version, wantErr := range map[int]bool{4: true, 6: false} {
+		c := Client{Resolve: []ResolveEntry{entry}, IPVersion: version}
+		result := c.Run(req, AssertStatusOK())
+		if got := result.Err != nil; got != wantErr {
+			t.Errorf("IPv%d: got %v", version, result.Err)
+		}
+		if version == 6 && result.Err == nil && result.Attempts[0].Response.RemoteAddr != "[::1]:"+port {
+			t.Errorf("IPv6: RemoteAddr = %s", result.Attempts[0].Response.RemoteAddr)
+		}
+	}
+}
+
+// dnsServer answers every A query with 127.0.0.1, and every other with
+// nothing, counting the queries.
+func dnsServer(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	var queries atomic.Int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var p dnsmessage.Parser
+			h, err := p.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			q, err := p.Question()
+			if err != nil {
+				continue
+			}
+			queries.Add(1)
+
+			b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true, Authoritative: true})
+			_ = b.StartQuestions()
+			_ = b.Question(q)
+			_ = b.StartAnswers()
+			if q.Type == dnsmessage.TypeA {
+				_ = b.AResource(dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 60},
+					dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}})
+			}
+			msg, err := b.Finish()
+			if err == nil {
+				_, _ = conn.WriteTo(msg, from)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String(), &queries
+}
+
+func Test_Client_dnsServers(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	req, err := Request{URL: "http://svc.http-assert.test:" + port + "/"}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns, queries := dnsServer(t)
+	c := Client{DNSServers: []string{ns}}
+	result := c.Run(req, AssertStatusOK())
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if queries.Load() == 0 {
+		t.Error("the DNS server was never asked")
+	}
+	if tm := result.Attempts[0].Response.Timing; tm == nil || tm.DNS <= 0 {
+		t.Errorf("timing = %v, want the lookup timed", tm)
+	}
+}
+
+// A retry asks the next server: the turn is the run's, shared by whatever
+// resolvers it builds, and no other client's.
+func Test_Client_dnsServers_rotate(t *testing.T) {
+	t.Parallel()
+
+	// Closing each connection makes every attempt dial, and so look up, anew.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	req, err := Request{URL: "http://svc.http-assert.test:" + port + "/"}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns1, queries1 := dnsServer(t)
+	ns2, queries2 := dnsServer(t)
+	// One A query per lookup, with no AAAA to take a turn.
+	c := Client{DNSServers: []string{ns1, ns2}, IPVersion: 4, Retries: 3}
+	if err := c.Do(req, AssertStatusOK()); !errors.Is(err, ErrAssertion) {
+		t.Fatalf("got %v, want the 503's assertion failure", err)
+	}
+	if q1, q2 := queries1.Load(), queries2.Load(); q1 != 2 || q2 != 2 {
+		t.Errorf("the servers were asked %d and %d times, want 2 each", q1, q2)
+	}
+
+	// A QUIC or proxy dial looks up with a resolver of its own, on the same
+	// turn.
+	c = c.withDNSTurn()
+	for range 2 {
+		if _, err := c.lookup(t.Context(), nil, "svc.http-assert.test:443"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q1, q2 := queries1.Load(), queries2.Load(); q1 != 3 || q2 != 3 {
+		t.Errorf("after two lookups the servers were asked %d and %d times, want 3 each", q1, q2)
+	}
+}
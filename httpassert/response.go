@@ -0,0 +1,289 @@
+package httpassert
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Response is an *http.Response with its body read and decoded for assertions.
+type Response struct {
+	*http.Response
+	BodyBytes []byte
+	// Encoding is the response's Content-Encoding, verbatim, and empty when
+	// there was none.
+	//
+	// The header itself is left alone. net/http deletes it (and Content-Length)
+	// when it decodes, which makes a response that was compressed
+	// indistinguishable from one that never was -- and the whole reason to set
+	// Accept-Encoding by hand is to find out which happened.
+	Encoding string
+	// DecodeErr is why BodyBytes is still encoded. Nil means BodyBytes is the
+	// payload, whether or not anything had to be removed to get there.
+	DecodeErr error
+	// Timing is how long the attempt took, phase by phase. Nil for a response
+	// no request of ours received, which has nothing to time.
+	Timing *Timing
+	// RemoteAddr is the address the response came from: the one a name
+	// resolved to and was connected to, or the proxy's or Unix socket's. It
+	// is empty for a saved response, which no connection of ours received.
+	RemoteAddr string
+	// JarCookies are the cookies held for the response's URL once it arrived,
+	// by the Client's Jar or the attempt's own, that the run's responses set:
+	// the ones the next request there would send, less those the jar was
+	// seeded with or loaded from a file. Nil for a saved response.
+	JarCookies []*http.Cookie
+	// Challenge is the Digest round the request went through before this
+	// response, and nil when it went through none.
+	Challenge *Challenge
+	// The decoded JSON body, filled by decodeJSON on first use. Plain fields
+	// rather than a sync.Once because Response is passed around by value in
+	// places, and a value copy of a mutex is what go vet exists to catch.
+	jsonBody   any
+	jsonErr    error
+	jsonParsed bool
+}
+
+// decodeJSON decodes the body as JSON once and shares the result.
+//
+// Every --assert-jq in a run reads the same response, so ten queries should
+// parse it once rather than ten times. Failure is reported as a property of the
+// body, not of the query: a response that is not JSON fails every jq assertion
+// for the same reason, and saying so once per assertion is clearer than saying
+// the expression did not hold.
+func (r *Response) decodeJSON() (any, error) {
+	if r.jsonParsed {
+		return r.jsonBody, r.jsonErr
+	}
+	r.jsonParsed = true
+
+	// Through bodyOf like every other body assertion, so a body that is still
+	// compressed reports that rather than reporting invalid JSON (#27).
+	body, err := bodyOf(r)
+	if err != nil {
+		r.jsonErr = err
+		return nil, r.jsonErr
+	}
+
+	if err := json.Unmarshal(body, &r.jsonBody); err != nil {
+		r.jsonErr = fmt.Errorf("body: expected JSON, got %s", err)
+		return nil, r.jsonErr
+	}
+
+	return r.jsonBody, nil
+}
+
+// decoders maps a Content-Encoding to something that removes it. Content
+// coding names are case-insensitive per RFC 9110, so lookups are lowered.
+//
+// deflate is absent by name because it is two formats: RFC 9110 says zlib, and
+// a good deal of the web sends raw. decodeDeflate tries both.
+var decoders = map[string]func([]byte) ([]byte, error){
+	"gzip":    decodeGzip,
+	"deflate": decodeDeflate,
+	"br":      decodeBrotli,
+	"zstd":    decodeZstd,
+}
+
+// supportedCodings names the decoders in a stable order, so the failure for an
+// encoding with no decoder can say what it does have without drifting from the
+// map as it grows.
+func supportedCodings() string {
+	return strings.Join(slices.Sorted(maps.Keys(decoders)), ", ")
+}
+
+// decodeBody removes the Content-Encoding from BodyBytes, leaving every header
+// exactly as it arrived.
+//
+// An encoding nothing here can remove is not an error by itself: a response
+// body the tool cannot read still has a status and headers worth asserting on.
+// It is recorded instead, and only the body assertions refuse (see bodyOf).
+func (r *Response) decodeBody() {
+	r.Encoding = strings.TrimSpace(r.Header.Get("Content-Encoding"))
+
+	// An empty body has nothing to decode, and an empty gzip stream is an error
+	// rather than an empty payload -- so a 204 that carries the header anyway
+	// must not fail --assert-body-empty.
+	if len(r.BodyBytes) == 0 {
+		return
+	}
+
+	switch enc := strings.ToLower(r.Encoding); enc {
+	case "", "identity":
+		return
+	default:
+		decode, ok := decoders[enc]
+		if !ok {
+			r.DecodeErr = fmt.Errorf("no decoder for %q; %s are supported", r.Encoding, supportedCodings())
+			return
+		}
+
+		b, err := decode(r.BodyBytes)
+		if err != nil {
+			r.DecodeErr = err
+			return
+		}
+		r.BodyBytes = b
+	}
+}
+
+// decodeBrotli removes a brotli coding. There is no brotli in the standard
+// library, which is the whole reason this took a dependency; andybalholm/brotli
+// is pure Go and brings nothing else with it.
+func decodeBrotli(b []byte) ([]byte, error) {
+	return io.ReadAll(brotli.NewReader(bytes.NewReader(b)))
+}
+
+// decodeZstd removes a zstd coding (RFC 8878).
+//
+// klauspost/compress is a large repository, but only the zstd package links
+// into the binary, so the cost is the decoder rather than the library.
+func decodeZstd(b []byte) ([]byte, error) {
+	zr, err := zstd.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	return io.ReadAll(zr)
+}
+
+func decodeGzip(b []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = zr.Close() }()
+
+	return io.ReadAll(zr)
+}
+
+// decodeDeflate tries zlib first and raw DEFLATE second.
+//
+// RFC 9110 defines the deflate coding as the zlib format, but servers sending
+// raw DEFLATE under the same name are common enough that net/http declines to
+// negotiate it at all ("Deflate is ambiguous and not as universally supported
+// anyway", transport.go). Guessing is safe here because neither reader accepts
+// the other's input: a wrong guess fails rather than producing plausible bytes.
+func decodeDeflate(b []byte) ([]byte, error) {
+	if zr, err := zlib.NewReader(bytes.NewReader(b)); err == nil {
+		defer func() { _ = zr.Close() }()
+		if out, err := io.ReadAll(zr); err == nil {
+			return out, nil
+		}
+	}
+
+	fr := flate.NewReader(bytes.NewReader(b))
+	defer func() { _ = fr.Close() }()
+
+	out, err := io.ReadAll(fr)
+	if err != nil {
+		return nil, fmt.Errorf("not valid zlib or raw DEFLATE: %w", err)
+	}
+
+	return out, nil
+}
+
+// maxPayloadBytes is how much of a body the failure dump shows before cropping.
+const maxPayloadBytes = 256
+
+// writeTo renders the response for a person reading a failure report.
+//
+// Deliberately not http.Response.Write. That is a wire-format serializer: it
+// honours ContentLength and Transfer-Encoding, which describe the body that
+// arrived rather than the rendering that replaces it here. A placeholder or a
+// hex dump longer than the original body was cut to the original's length, and
+// a chunked response had its framing interleaved with the rendering (#18).
+//
+// Go reported the mismatch on every such run -- "http: ContentLength=4 with
+// Body length 26" -- and the caller discarded it. Nothing to discard now: the
+// bytes below are the whole output.
+//
+// Write errors are ignored, following utils.go, because every caller renders
+// into an in-memory strings.Builder that cannot fail.
+func (r Response) writeTo(w io.Writer, withBody bool) {
+	_, _ = fmt.Fprintf(w, "%s %s\n", r.Proto, r.Status)
+	writeHeaders(w, r.Header)
+	_, _ = fmt.Fprintln(w)
+
+	if !withBody {
+		_, _ = fmt.Fprint(w, "  << Payload is omitted >>")
+		return
+	}
+	// The headers above still say how the body arrived, so plain text under a
+	// Content-Encoding header needs explaining -- as does a hex dump under one.
+	switch {
+	case r.DecodeErr != nil:
+		_, _ = fmt.Fprintf(w, "  << Payload is %s-encoded and was not decoded >>\n\n", r.Encoding)
+	case r.Encoding != "" && !strings.EqualFold(r.Encoding, "identity"):
+		_, _ = fmt.Fprintf(w, "  << Payload decoded from %s >>\n\n", r.Encoding)
+	}
+	if cropped := printPayload(w, r.BodyBytes, maxPayloadBytes); cropped > 0 {
+		_, _ = fmt.Fprintf(w, "\n\n  << Payload is cropped: %d bytes are hidden >>", cropped)
+	}
+}
+
+// writeHeaders renders headers one per line, sorted by name so that two dumps
+// of the same response can be compared.
+func writeHeaders(w io.Writer, h http.Header) {
+	for _, name := range slices.Sorted(maps.Keys(h)) {
+		for _, value := range h[name] {
+			_, _ = fmt.Fprintf(w, "%s: %s\n", name, value)
+		}
+	}
+}
+
+func printPayload(w io.Writer, bs []byte, maxSize int) (croppedBytes int) {
+	// A negative limit would slice to a negative bound and panic. The only
+	// caller passes a constant, so this is unreachable today -- but the
+	// function is exported to the rest of the package and should be total.
+	if maxSize < 0 {
+		maxSize = 0
+	}
+
+	if n := len(bs); n > maxSize {
+		bs = bs[:maxSize]
+		croppedBytes = n - maxSize
+	}
+	if isPrintable(bs) {
+		_, _ = w.Write(bs)
+	} else {
+		d := hex.Dumper(w)
+		defer func() { _ = d.Close() }()
+		_, _ = d.Write(bs)
+	}
+	return
+}
+
+// isPrintable reports whether bs should be shown as text rather than dumped as
+// hex.
+//
+// Whitespace counts as text. unicode.IsPrint answers false for '\n', '\t' and
+// '\r', so testing it alone sent every multi-line body -- pretty-printed JSON,
+// HTML, a log excerpt, anything with a line break in the first 256 bytes -- to
+// the hex dumper, which is the least readable way to show text a human was
+// about to read.
+//
+// Known gap: bytes that are not valid UTF-8 decode to U+FFFD, which is itself
+// printable, so a body of high bytes still reads as text. Cropping happens
+// before this check and can split a multi-byte rune, so the obvious utf8.Valid
+// guard would misfile legitimate text; tracked separately.
+func isPrintable(bs []byte) bool {
+	nonPrintableIdx := bytes.IndexFunc(bs, func(r rune) bool {
+		return !unicode.IsPrint(r) && !unicode.IsSpace(r)
+	})
+	return nonPrintableIdx < 0
+}
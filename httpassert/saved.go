@@ -0,0 +1,128 @@
+package httpassert
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// ReadResponse parses a raw HTTP response, as curl -i saves one, and decodes
+// its body the way a live one is decoded.
+//
+// What curl -i prints is not always one response. A 100 Continue, the 200 a
+// proxy answers CONNECT with, and with -L every hop of the redirect chain come
+// before it, each as a head with nothing after it but the next one. The last
+// response is the one that answered the request, so it is the one asserted on,
+// as it would have been with --location.
+func ReadResponse(raw []byte) (*Response, error) {
+	for {
+		res, after, err := readHead(raw)
+		if err != nil {
+			return nil, err
+		}
+		if isResponseStart(after) {
+			raw = after
+			continue
+		}
+
+		body, rest, err := responseBody(res, after)
+		if err != nil {
+			return nil, err
+		}
+		if isResponseStart(rest) {
+			raw = rest
+			continue
+		}
+
+		r := &Response{Response: res, BodyBytes: body}
+		r.Body = http.NoBody
+		r.decodeBody()
+
+		return r, nil
+	}
+}
+
+// readHead parses the status line and headers and returns what follows them.
+//
+// curl prints an HTTP/2 or HTTP/3 status line as "HTTP/2 200", which net/http
+// refuses for want of a minor version. The head that follows it is the same
+// text either way, so the version is completed rather than the response
+// refused.
+func readHead(raw []byte) (*http.Response, []byte, error) {
+	for _, v := range []string{"HTTP/2 ", "HTTP/3 "} {
+		if bytes.HasPrefix(raw, []byte(v)) {
+			raw = slices.Concat([]byte(v[:6]+".0 "), raw[len(v):])
+		}
+	}
+
+	rd := bytes.NewReader(raw)
+	br := bufio.NewReader(rd)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not an HTTP response: %s", err)
+	}
+	consumed := len(raw) - rd.Len() - br.Buffered()
+	// HTTP/2 has no reason phrase, and every dump and report prints one.
+	if res.Status == strconv.Itoa(res.StatusCode) {
+		res.Status += " " + http.StatusText(res.StatusCode)
+	}
+
+	return res, raw[consumed:], nil
+}
+
+// responseBody separates a response's body from whatever follows it.
+//
+// The bytes after the head are trusted over the headers that describe them.
+// curl -i prints a chunked body already reassembled under its original
+// Transfer-Encoding, so a body that does not parse as chunks is taken as it
+// stands. Content-Length is honoured when the bytes reach it -- that is what
+// separates a hop's body from the next hop -- and refused when they do not,
+// since a truncated body would be asserted on as if it were the whole one.
+func responseBody(res *http.Response, after []byte) (body, rest []byte, err error) {
+	if slices.Contains(res.TransferEncoding, "chunked") {
+		if b, err := io.ReadAll(httputil.NewChunkedReader(bytes.NewReader(after))); err == nil {
+			return b, nil, nil
+		}
+		return after, nil, nil
+	}
+
+	if n := res.ContentLength; n >= 0 {
+		if int64(len(after)) < n {
+			return nil, nil, fmt.Errorf("the body is %d bytes, and Content-Length says %d; "+
+				"the response is truncated", len(after), n)
+		}
+		return after[:n], after[n:], nil
+	}
+
+	return after, nil, nil
+}
+
+func isResponseStart(b []byte) bool {
+	return bytes.HasPrefix(b, []byte("HTTP/"))
+}
+
+// RunSaved checks a response received elsewhere -- read with ReadResponse --
+// the way Run checks a live one, as a run of one attempt with no request behind
+// it. source names where it came from in the log and the dump; "-" is stdin.
+func (c Client) RunSaved(source string, res *Response, assertions ...Assertion) *Result {
+	if source == "-" {
+		source = "stdin"
+	}
+
+	a := Attempt{StartedAt: time.Now(), Response: res}
+	c.logInfo("[:] %s %s (from %s)\n", res.Proto, res.Status, source)
+	c.checkResponse(&a, res, assertions, func(w io.Writer) {
+		_, _ = fmt.Fprintf(w, "\nFAILED: the response read from %s\n\n", source)
+		res.writeTo(w, c.LogLevel >= LInfo)
+		_, _ = w.Write([]byte("\n\n"))
+	})
+	a.Duration = time.Since(a.StartedAt)
+
+	return &Result{Assertions: assertions, Attempts: []Attempt{a}, Err: a.Err}
+}
@@ -0,0 +1,112 @@
+package httpassert
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func Test_readResponse(t *testing.T) {
+	t.Parallel()
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(`{"a":1}`))
+	_ = zw.Close()
+
+	tests := []struct {
+		Name   string
+		Raw    string
+		Status string
+		Body   string
+		Header string // NAME: value the response must carry
+	}{
+		{
+			Name:   "as curl -i prints it",
+			Raw:    "HTTP/1.1 201 Created\r\nContent-Type: application/json\r\nContent-Length: 7\r\n\r\n{\"a\":1}",
+			Status: "201 Created", Body: `{"a":1}`, Header: "Content-Type: application/json",
+		},
+		{
+			Name:   "a fixture written with bare newlines",
+			Raw:    "HTTP/1.1 200 OK\nX-A: 1\n\nbody\n",
+			Status: "200 OK", Body: "body\n", Header: "X-A: 1",
+		},
+		{
+			Name:   "an HTTP/2 status line with no reason",
+			Raw:    "HTTP/2 404\r\nx-a: 1\r\n\r\n",
+			Status: "404 Not Found", Header: "X-A: 1",
+		},
+		{
+			Name:   "a 100 Continue before the response",
+			Raw:    "HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok",
+			Status: "200 OK", Body: "ok",
+		},
+		{
+			Name:   "a proxy's answer to CONNECT",
+			Raw:    "HTTP/1.1 200 Connection established\r\n\r\nHTTP/2 204\r\n\r\n",
+			Status: "204 No Content",
+		},
+		{
+			Name: "curl -iL: the last hop is the response",
+			Raw: "HTTP/1.1 302 Found\r\nLocation: /b\r\nContent-Length: 5\r\n\r\n" +
+				"HTTP/1.1 301 Moved Permanently\r\nLocation: /c\r\nContent-Length: 3\r\n\r\nc!\n" +
+				"HTTP/1.1 200 OK\r\nContent-Length: 4\r\n\r\ndone",
+			Status: "200 OK", Body: "done",
+		},
+		{
+			Name:   "chunks on the wire",
+			Raw:    "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n2\r\nde\r\n0\r\n\r\n",
+			Status: "200 OK", Body: "abcde",
+		},
+		{
+			Name:   "chunks curl already reassembled",
+			Raw:    "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\nabcde",
+			Status: "200 OK", Body: "abcde",
+		},
+		{
+			Name:   "a compressed body is decoded",
+			Raw:    "HTTP/1.1 200 OK\r\nContent-Encoding: gzip\r\n\r\n" + gz.String(),
+			Status: "200 OK", Body: `{"a":1}`, Header: "Content-Encoding: gzip",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			res, err := ReadResponse([]byte(tc.Raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != tc.Status {
+				t.Errorf("status = %q, want %q", res.Status, tc.Status)
+			}
+			if string(res.BodyBytes) != tc.Body || res.DecodeErr != nil {
+				t.Errorf("body = %q (%v), want %q", res.BodyBytes, res.DecodeErr, tc.Body)
+			}
+			if tc.Header != "" {
+				name, value, _ := strings.Cut(tc.Header, ": ")
+				if got := res.Header.Get(name); got != value {
+					t.Errorf("%s = %q, want %q", name, got, value)
+				}
+			}
+		})
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Raw  string
+		Want string
+	}{
+		{"not HTTP", `{"a":1}`, "not an HTTP response"},
+		{"empty", "", "not an HTTP response"},
+		{"a request", "GET / HTTP/1.1\r\nHost: a\r\n\r\n", "not an HTTP response"},
+		{"truncated", "HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\nshort", "the response is truncated"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := ReadResponse([]byte(tc.Raw))
+			if err == nil || !strings.Contains(err.Error(), tc.Want) {
+				t.Errorf("err = %v, want it to contain %q", err, tc.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package httpassert
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505 - a webhook scheme may still name it
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer signs a request just before each attempt sends it. A signature
+// carries a timestamp the server holds it to, so one computed once for the
+// run would be stale by the time --retry got to it.
+type Signer interface {
+	// Sign adds the signature to req, whose body is body, as of now.
+	Sign(req *http.Request, body []byte, now time.Time) error
+	// Redact replaces what Sign set that a failure dump must not show.
+	Redact(h http.Header)
+}
+
+// AWSSigV4 signs requests with AWS Signature Version 4, for API Gateway, S3
+// and the services that borrow the scheme under another provider's name.
+type AWSSigV4 struct {
+	// Provider names the scheme's strings: aws gives AWS4-HMAC-SHA256 and
+	// X-Amz-Date, and osc gives OSC4-HMAC-SHA256 and X-Osc-Date.
+	Provider        string
+	Region          string
+	Service         string
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is sent when set, as temporary credentials need.
+	SessionToken string
+}
+
+// ParseAWSSigV4 reads provider:region:service, as aws:eu-west-1:execute-api.
+// The credentials are left for the caller to fill in.
+func ParseAWSSigV4(spec string) (AWSSigV4, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 {
+		return AWSSigV4{}, fmt.Errorf("%q is not provider:region:service", spec)
+	}
+	for i, name := range []string{"provider", "region", "service"} {
+		if parts[i] == "" {
+			return AWSSigV4{}, fmt.Errorf("%q has no %s", spec, name)
+		}
+	}
+
+	return AWSSigV4{Provider: parts[0], Region: parts[1], Service: parts[2]}, nil
+}
+
+// Sign sets the date, the session token, for S3 the payload hash, and the
+// Authorization header over them and every header req already has.
+func (s AWSSigV4) Sign(req *http.Request, body []byte, now time.Time) error {
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return errors.New("no AWS credentials")
+	}
+	prefix, algorithm := s.headerPrefix(), s.algorithm()
+	stamp := now.UTC().Format("20060102T150405Z")
+	scope := strings.Join([]string{stamp[:8], s.Region, s.Service, s.provider() + "4_request"}, "/")
+
+	payload := sha256.Sum256(body)
+	req.Header.Set(prefix+"Date", stamp)
+	// S3 refuses a request without it; other services do not look.
+	if s.Service == "s3" {
+		req.Header.Set(prefix+"Content-Sha256", hex.EncodeToString(payload[:]))
+	}
+	if s.SessionToken != "" {
+		req.Header.Set(prefix+"Security-Token", s.SessionToken)
+	}
+	req.Header.Del("Authorization")
+
+	headers, signed := s.canonicalHeaders(req)
+	canonical := strings.Join([]string{
+		req.Method,
+		s.canonicalPath(req.URL),
+		canonicalQuery(req.URL),
+		headers,
+		signed,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{algorithm, stamp, scope, hex.EncodeToString(hashed[:])}, "\n")
+
+	key := []byte(strings.ToUpper(s.provider()) + "4" + s.SecretAccessKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSum(sha256.New, key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%x",
+		algorithm, s.AccessKeyID, scope, signed, hmacSum(sha256.New, key, toSign)))
+
+	return nil
+}
+
+// Redact keeps the credential scope, which tells a wrong region from a wrong
+// key, and drops the signature and the session token.
+func (s AWSSigV4) Redact(h http.Header) {
+	if v := h.Get("Authorization"); strings.HasPrefix(v, s.algorithm()) {
+		if i := strings.Index(v, "Signature="); i >= 0 {
+			h.Set("Authorization", v[:i]+"Signature=xxxxx")
+		}
+	}
+	if token := s.headerPrefix() + "Security-Token"; h.Get(token) != "" {
+		h.Set(token, "xxxxx")
+	}
+}
+
+// provider is Provider lowercased, and aws when it is empty.
+func (s AWSSigV4) provider() string {
+	if s.Provider == "" {
+		return "aws"
+	}
+
+	return strings.ToLower(s.Provider)
+}
+
+func (s AWSSigV4) algorithm() string {
+	return strings.ToUpper(s.provider()) + "4-HMAC-SHA256"
+}
+
+// headerPrefix is X-Amz- for aws, whose headers are named for Amazon, and
+// the provider's own name for any other.
+func (s AWSSigV4) headerPrefix() string {
+	p := s.provider()
+	if p == "aws" {
+		p = "amz"
+	}
+
+	return "X-" + strings.ToUpper(p[:1]) + p[1:] + "-"
+}
+
+// canonicalPath is the path with each segment escaped: once for S3, and twice,
+// as the scheme has it, for every other service.
+func (s AWSSigV4) canonicalPath(u *url.URL) string {
+	segments := strings.Split(u.Path, "/")
+	for i, seg := range segments {
+		segments[i] = awsEscape(seg)
+		if s.Service != "s3" {
+			segments[i] = awsEscape(segments[i])
+		}
+	}
+	if res := strings.Join(segments, "/"); res != "" {
+		return res
+	}
+
+	return "/"
+}
+
+// canonicalQuery is the query with every name and value escaped, sorted by
+// name and then by value. Sorting the joined name=value strings instead would
+// put page1=2 before page=1, since '1' sorts before '='.
+func canonicalQuery(u *url.URL) string {
+	var pairs [][2]string
+	for name, values := range u.Query() {
+		for _, v := range values {
+			pairs = append(pairs, [2]string{awsEscape(name), awsEscape(v)})
+		}
+	}
+	slices.SortFunc(pairs, func(a, b [2]string) int {
+		if c := strings.Compare(a[0], b[0]); c != 0 {
+			return c
+		}
+		return strings.Compare(a[1], b[1])
+	})
+
+	joined := make([]string, len(pairs))
+	for i, p := range pairs {
+		joined[i] = p[0] + "=" + p[1]
+	}
+
+	return strings.Join(joined, "&")
+}
+
+// canonicalHeaders lists the Host header and every header of req, lowercased
+// and sorted, and the names alone as SignedHeaders.
+func (s AWSSigV4) canonicalHeaders(req *http.Request) (headers, signed string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for name, vs := range req.Header {
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s:%s\n", name, values[name])
+	}
+
+	return b.String(), strings.Join(names, ";")
+}
+
+// awsEscape escapes everything but the unreserved characters, as the scheme
+// wants and url.PathEscape does not quite do.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// HMACSigner signs the body with a shared key, as webhook schemes do: Header
+// gets the hex HMAC of the body, or, with TimestampHeader, of the Unix time
+// that header is sent with, a dot, and the body.
+type HMACSigner struct {
+	// Algorithm is sha1, sha256 or sha512.
+	Algorithm       string
+	Key             []byte
+	Header          string
+	TimestampHeader string
+}
+
+// ParseHMAC reads algorithm:header[:timestamp-header], as
+// sha256:X-Signature:X-Timestamp, and pairs it with key.
+func ParseHMAC(spec string, key []byte) (HMACSigner, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+		return HMACSigner{}, fmt.Errorf("%q is not algorithm:header[:timestamp-header]", spec)
+	}
+	s := HMACSigner{Algorithm: strings.ToLower(parts[0]), Key: key, Header: parts[1]}
+	if len(parts) == 3 {
+		s.TimestampHeader = parts[2]
+	}
+	if _, err := s.hash(); err != nil {
+		return HMACSigner{}, err
+	}
+
+	return s, nil
+}
+
+func (s HMACSigner) hash() (func() hash.Hash, error) {
+	switch s.Algorithm {
+	case "sha1":
+		return sha1.New, nil
+	case "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	}
+
+	return nil, fmt.Errorf("unknown algorithm %q; use sha1, sha256 or sha512", s.Algorithm)
+}
+
+// Sign sets Header, and TimestampHeader when there is one.
+func (s HMACSigner) Sign(req *http.Request, body []byte, now time.Time) error {
+	newHash, err := s.hash()
+	if err != nil {
+		return err
+	}
+	msg := string(body)
+	if s.TimestampHeader != "" {
+		ts := strconv.FormatInt(now.Unix(), 10)
+		req.Header.Set(s.TimestampHeader, ts)
+		msg = ts + "." + msg
+	}
+	req.Header.Set(s.Header, hex.EncodeToString(hmacSum(newHash, s.Key, msg)))
+
+	return nil
+}
+
+// Redact hides the signature; the timestamp stays, being half of what the
+// server will check.
+func (s HMACSigner) Redact(h http.Header) {
+	if h.Get(s.Header) != "" {
+		h.Set(s.Header, "xxxxx")
+	}
+}
+
+func hmacSum(newHash func() hash.Hash, key []byte, msg string) []byte {
+	m := hmac.New(newHash, key)
+	_, _ = m.Write([]byte(msg))
+	return m.Sum(nil)
+}
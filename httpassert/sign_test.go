@@ -0,0 +1,190 @@
+package httpassert
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The vectors are from AWS's Signature Version 4 test suite.
+func Test_AWSSigV4_Sign(t *testing.T) {
+	t.Parallel()
+
+	s := AWSSigV4{
+		Provider: "aws", Region: "us-east-1", Service: "service",
+		AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	at := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	scope := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "
+
+	for _, tt := range []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"get-vanilla", "https://example.amazonaws.com/",
+			"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			"SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"get-vanilla-query-order-value", "https://example.amazonaws.com/?Param1=value2&Param1=value1",
+			"SignedHeaders=host;x-amz-date, Signature=5772eed61e12b33fae39ee5e7012498b51d56abc0abb7c60486157bd471c4694"},
+		// Not from the suite, which has no names that share a prefix: worked
+		// out by hand from its rules, with the canonical query
+		// a=1&a-b=2&page=1&page1=2.
+		{"query-order-key-prefix", "https://example.amazonaws.com/?page1=2&a-b=2&page=1&a=1",
+			"SignedHeaders=host;x-amz-date, Signature=296bc5fabbe79a81543306fa8b2a86a33a93659a2b173debd14e99ea7b0a9504"},
+	} {
+		req, err := http.NewRequest(http.MethodGet, tt.url, http.NoBody)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Sign(req, nil, at); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := req.Header.Get("Authorization"); got != scope+tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, scope+tt.want)
+		}
+		if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+			t.Errorf("%s: X-Amz-Date %q", tt.name, got)
+		}
+	}
+
+	// S3 gets the payload hash as a header, and a session token is sent and
+	// signed; the dump shows neither the signature nor the token.
+	s3 := s
+	s3.Service, s3.SessionToken = "s3", "t0ken"
+	req, _ := http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/a%20b", http.NoBody)
+	if err := s3.Sign(req, []byte("payload"), at); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("payload"))
+	if got := req.Header.Get("X-Amz-Content-Sha256"); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("s3: X-Amz-Content-Sha256 %q", got)
+	}
+	if got := req.Header.Get("Authorization"); !strings.Contains(got,
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token, ") {
+		t.Errorf("s3: %s", got)
+	}
+	s3.Redact(req.Header)
+	if got := req.Header.Get("Authorization"); !strings.HasSuffix(got, "Signature=xxxxx") {
+		t.Errorf("s3: redacted to %s", got)
+	}
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "xxxxx" {
+		t.Errorf("s3: token redacted to %s", got)
+	}
+
+	if err := (AWSSigV4{Region: "r", Service: "s"}).Sign(req, nil, at); err == nil ||
+		err.Error() != "no AWS credentials" {
+		t.Errorf("no credentials: %v", err)
+	}
+}
+
+func Test_ParseAWSSigV4(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseAWSSigV4("osc:eu-west-2:api")
+	checkErr(t, "osc", err, "")
+	if got != (AWSSigV4{Provider: "osc", Region: "eu-west-2", Service: "api"}) {
+		t.Errorf("got %+v", got)
+	}
+	if got.algorithm() != "OSC4-HMAC-SHA256" || got.headerPrefix() != "X-Osc-" {
+		t.Errorf("osc: %s, %s", got.algorithm(), got.headerPrefix())
+	}
+
+	for spec, want := range map[string]string{
+		"aws:us-east-1":        `"aws:us-east-1" is not provider:region:service`,
+		"aws:amz:us-east-1:s3": `"aws:amz:us-east-1:s3" is not provider:region:service`,
+		"aws::s3":              `"aws::s3" has no region`,
+	} {
+		_, err := ParseAWSSigV4(spec)
+		checkErr(t, spec, err, want)
+	}
+}
+
+func Test_HMACSigner(t *testing.T) {
+	t.Parallel()
+
+	mac := func(msg string) string {
+		m := hmac.New(sha256.New, []byte("k3y"))
+		_, _ = m.Write([]byte(msg))
+		return hex.EncodeToString(m.Sum(nil))
+	}
+	at := time.Unix(1700000000, 0)
+
+	for _, tt := range []struct {
+		spec      string
+		timestamp string
+		want      string
+	}{
+		{"sha256:X-Signature", "", mac("payload")},
+		{"SHA256:X-Signature:X-Timestamp", "1700000000", mac("1700000000.payload")},
+	} {
+		s, err := ParseHMAC(tt.spec, []byte("k3y"))
+		checkErr(t, tt.spec, err, "")
+		req, _ := http.NewRequest(http.MethodPost, "http://example.com/hook", http.NoBody)
+		if err := s.Sign(req, []byte("payload"), at); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get("X-Signature"); got != tt.want {
+			t.Errorf("%s: signature %s, want %s", tt.spec, got, tt.want)
+		}
+		if got := req.Header.Get("X-Timestamp"); got != tt.timestamp {
+			t.Errorf("%s: timestamp %q, want %q", tt.spec, got, tt.timestamp)
+		}
+	}
+
+	for spec, want := range map[string]string{
+		"sha256":           `"sha256" is not algorithm:header[:timestamp-header]`,
+		"sha256:":          `"sha256:" is not algorithm:header[:timestamp-header]`,
+		"md5:X-Signature":  `unknown algorithm "md5"; use sha1, sha256 or sha512`,
+		"sha256:a:b:c":     `"sha256:a:b:c" is not algorithm:header[:timestamp-header]`,
+		"sha512:X-Sig:X-T": "",
+	} {
+		_, err := ParseHMAC(spec, nil)
+		checkErr(t, spec, err, want)
+	}
+}
+
+// Every attempt is signed afresh, over the body it actually sends.
+func Test_Client_signsEachAttempt(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		sigs []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sigs = append(sigs, r.Header.Get("X-Signature")+" "+r.Header.Get("X-Timestamp"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	signer, err := ParseHMAC("sha256:X-Signature:X-Timestamp", []byte("k3y"))
+	checkErr(t, "hmac", err, "")
+	req, err := Request{Method: http.MethodPost, URL: srv.URL, Body: []byte("payload")}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Client{Signer: signer, Retries: 1, RetryDelay: 1100 * time.Millisecond}
+	err = c.Do(req, AssertStatusOK())
+	if err == nil {
+		t.Fatal("the 503 passed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sigs) != 2 || sigs[0] == sigs[1] {
+		t.Errorf("the attempts were signed %q", sigs)
+	}
+	if !strings.Contains(err.Error(), "X-Signature: xxxxx") || strings.Contains(err.Error(), strings.Fields(sigs[1])[0]) {
+		t.Errorf("the dump shows the signature:\n%s", err)
+	}
+}
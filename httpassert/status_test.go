@@ -1,4 +1,4 @@
-package main
+package httpassert
 
 import (
 	"strings"
@@ -38,7 +38,7 @@ func Test_parseStatusSpec(t *testing.T) {
 
 		for _, tc := range tests {
 			t.Run(tc.Spec, func(t *testing.T) {
-				spec, err := parseStatusSpec(tc.Spec)
+				spec, err := ParseStatusSpec(tc.Spec)
 				if err != nil {
 					t.Fatalf("unexpected error: %s", err)
 				}
@@ -83,7 +83,7 @@ func Test_parseStatusSpec(t *testing.T) {
 
 		for _, tc := range tests {
 			t.Run(tc.Spec, func(t *testing.T) {
-				_, err := parseStatusSpec(tc.Spec)
+				_, err := ParseStatusSpec(tc.Spec)
 				if err == nil {
 					t.Fatalf("expected %q to be rejected", tc.Spec)
 				}
@@ -0,0 +1,215 @@
+package httpassert
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timing is how long one attempt spent in each phase, as net/http/httptrace
+// reports them.
+//
+// DNS, Connect and TLS are the time the phase took, summed over the hops of a
+// followed redirect, and zero when it did not happen: a reused connection
+// dials nothing, an address needs no lookup, and plain HTTP has no handshake.
+// TTFB and Total are measured from the start of the attempt, so they include
+// every hop; Transfer is the final response's body, from its first byte to its
+// last.
+type Timing struct {
+	DNS      time.Duration
+	Connect  time.Duration
+	TLS      time.Duration
+	TTFB     time.Duration
+	Transfer time.Duration
+	Total    time.Duration
+}
+
+// timingPhases names the phases in the order a request goes through them,
+// which is the order the breakdown prints them in.
+var timingPhases = []struct {
+	name string
+	of   func(Timing) time.Duration
+}{
+	{"dns", func(t Timing) time.Duration { return t.DNS }},
+	{"connect", func(t Timing) time.Duration { return t.Connect }},
+	{"tls", func(t Timing) time.Duration { return t.TLS }},
+	{"ttfb", func(t Timing) time.Duration { return t.TTFB }},
+	{"transfer", func(t Timing) time.Duration { return t.Transfer }},
+	{"total", func(t Timing) time.Duration { return t.Total }},
+}
+
+func phaseNames() string {
+	names := make([]string, len(timingPhases))
+	for i, p := range timingPhases {
+		names[i] = p.name
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// String is the breakdown the verbose log and the failure dump print.
+func (t Timing) String() string {
+	parts := make([]string, len(timingPhases))
+	for i, p := range timingPhases {
+		parts[i] = p.name + " " + roundDuration(p.of(t)).String()
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// roundDuration keeps a duration readable: 12.3ms rather than 12.345678ms.
+// Anything under a millisecond keeps its microseconds, since rounding those
+// away would print 0s for a phase that did happen.
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Millisecond {
+		return d.Round(100 * time.Microsecond)
+	}
+
+	return d.Round(time.Microsecond)
+}
+
+// timer collects one attempt's Timing from the httptrace hooks.
+//
+// The hooks are not all called from the goroutine that sent the request -- a
+// dual-stack dial races its connects -- hence the mutex. A connect that lost
+// the race reports an error and is not counted, so Connect is the time to the
+// connection that was used rather than the sum of every attempt at one.
+type timer struct {
+	mu sync.Mutex
+
+	start     time.Time
+	dnsStart  time.Time
+	connStart time.Time
+	tlsStart  time.Time
+	firstByte time.Time
+	t         Timing
+}
+
+func newTimer() *timer {
+	return &timer{start: time.Now()}
+}
+
+func (tm *timer) trace() *httptrace.ClientTrace {
+	lap := func(since *time.Time, into *time.Duration) {
+		tm.mu.Lock()
+		defer tm.mu.Unlock()
+
+		if !since.IsZero() {
+			*into += time.Since(*since)
+			*since = time.Time{}
+		}
+	}
+	mark := func(at *time.Time) {
+		tm.mu.Lock()
+		defer tm.mu.Unlock()
+
+		*at = time.Now()
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { mark(&tm.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { lap(&tm.dnsStart, &tm.t.DNS) },
+		ConnectStart: func(string, string) {
+			tm.mu.Lock()
+			defer tm.mu.Unlock()
+
+			// The first of racing connects starts the clock.
+			if tm.connStart.IsZero() {
+				tm.connStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				lap(&tm.connStart, &tm.t.Connect)
+			}
+		},
+		TLSHandshakeStart:    func() { mark(&tm.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { lap(&tm.tlsStart, &tm.t.TLS) },
+		GotFirstResponseByte: func() { mark(&tm.firstByte) },
+	}
+}
+
+// done completes the Timing once the body has been read. headersAt stands in
+// for the first byte when no hook reported one, as with a Transport that is not
+// net/http's.
+func (tm *timer) done(headersAt time.Time) *Timing {
+	end := time.Now()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	first := tm.firstByte
+	if first.IsZero() {
+		first = headersAt
+	}
+	t := tm.t
+	t.TTFB = first.Sub(tm.start)
+	t.Transfer = end.Sub(first)
+	t.Total = end.Sub(tm.start)
+
+	return &t
+}
+
+// TimeSpec is a bound on one phase of the Timing.
+type TimeSpec struct {
+	phase     int
+	max       time.Duration
+	inclusive bool
+}
+
+// ParseTimeSpec reads the form --assert-time accepts: a phase, < or <=, and a
+// duration, as in total<300ms or ttfb <= 1s.
+func ParseTimeSpec(text string) (TimeSpec, error) {
+	var spec TimeSpec
+
+	phase, bound, found := strings.Cut(text, "<")
+	if !found {
+		return spec, fmt.Errorf("%q has no <; write PHASE<DURATION, e.g. total<300ms", text)
+	}
+	if rest, ok := strings.CutPrefix(bound, "="); ok {
+		bound, spec.inclusive = rest, true
+	}
+
+	phase = strings.TrimSpace(phase)
+	spec.phase = -1
+	for i, p := range timingPhases {
+		if p.name == phase {
+			spec.phase = i
+		}
+	}
+	if spec.phase < 0 {
+		return spec, fmt.Errorf("unknown phase %q; possible values: %s", phase, phaseNames())
+	}
+
+	d, err := time.ParseDuration(strings.TrimSpace(bound))
+	if err != nil {
+		return spec, fmt.Errorf("%q is not a duration, e.g. 300ms or 1.5s", strings.TrimSpace(bound))
+	}
+	// <=0 still means something -- no lookup, no handshake -- but nothing
+	// takes less than no time.
+	if d < 0 || (d == 0 && !spec.inclusive) {
+		return spec, fmt.Errorf("%q can never hold; nothing takes less than no time", text)
+	}
+	spec.max = d
+
+	return spec, nil
+}
+
+func (s TimeSpec) holds(d time.Duration) bool {
+	if s.inclusive {
+		return d <= s.max
+	}
+
+	return d < s.max
+}
+
+func (s TimeSpec) bound() string {
+	if s.inclusive {
+		return "<= " + s.max.String()
+	}
+
+	return "< " + s.max.String()
+}
@@ -0,0 +1,132 @@
+package httpassert
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ParseTimeSpec(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text    string
+		bound   string
+		wantErr string
+	}{
+		{text: "total<300ms", bound: "< 300ms"},
+		{text: "ttfb<=1s", bound: "<= 1s"},
+		{text: " dns < 1.5ms ", bound: "< 1.5ms"},
+		{text: "tls<=0s", bound: "<= 0s"},
+
+		{text: "total300ms", wantErr: `"total300ms" has no <; write PHASE<DURATION, e.g. total<300ms`},
+		{text: "total>300ms", wantErr: `"total>300ms" has no <; write PHASE<DURATION, e.g. total<300ms`},
+		{text: "latency<1s", wantErr: `unknown phase "latency"; possible values: dns, connect, tls, ttfb, transfer, total`},
+		{text: "TOTAL<1s", wantErr: `unknown phase "TOTAL"; possible values: dns, connect, tls, ttfb, transfer, total`},
+		{text: "total<300", wantErr: `"300" is not a duration, e.g. 300ms or 1.5s`},
+		{text: "total<", wantErr: `"" is not a duration, e.g. 300ms or 1.5s`},
+		{text: "total<0s", wantErr: `"total<0s" can never hold; nothing takes less than no time`},
+		{text: "total<=-1s", wantErr: `"total<=-1s" can never hold; nothing takes less than no time`},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParseTimeSpec(tt.text)
+		checkErr(t, tt.text, err, tt.wantErr)
+		if err == nil && spec.bound() != tt.bound {
+			t.Errorf("%s: bound = %q, want %q", tt.text, spec.bound(), tt.bound)
+		}
+	}
+}
+
+func Test_AssertTime(t *testing.T) {
+	t.Parallel()
+
+	timed := &Response{Timing: &Timing{
+		DNS: 0, Connect: 2 * time.Millisecond, TTFB: 300 * time.Millisecond,
+		Transfer: 1234567 * time.Nanosecond, Total: 302 * time.Millisecond,
+	}}
+
+	tests := []struct {
+		spec    string
+		res     *Response
+		wantErr string
+	}{
+		{spec: "total<1s", res: timed},
+		{spec: "ttfb<=300ms", res: timed},
+		{spec: "dns<=0s", res: timed},
+		{spec: "ttfb<300ms", res: timed, wantErr: "time[ttfb]: expected < 300ms, got 300ms"},
+		// Rounded the way the breakdown prints it.
+		{spec: "transfer<1ms", res: timed, wantErr: "time[transfer]: expected < 1ms, got 1.2ms"},
+		{spec: "connect<=1ms", res: timed, wantErr: "time[connect]: expected <= 1ms, got 2ms"},
+		{spec: "total<1s", res: &Response{}, wantErr: "time[total]: the response has no timing; it was not received by a request"},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParseTimeSpec(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkErr(t, tt.spec, check(AssertTime(spec), tt.res), tt.wantErr)
+	}
+}
+
+func Test_Timing_String(t *testing.T) {
+	t.Parallel()
+
+	got := Timing{
+		DNS: 201 * time.Microsecond, Connect: 1234 * time.Microsecond, TTFB: 3712 * time.Microsecond,
+		Transfer: 266 * time.Microsecond, Total: 4 * time.Millisecond,
+	}.String()
+	want := "dns 201µs, connect 1.2ms, tls 0s, ttfb 3.7ms, transfer 266µs, total 4ms"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// The phases are measured rather than asserted against a clock, so this only
+// pins what holds however loaded the machine is: a TLS server has a handshake,
+// an address has no lookup, and the parts fit inside the whole.
+func Test_Client_timing(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte("late"))
+	}))
+	defer srv.Close()
+
+	req, err := Request{URL: srv.URL}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log strings.Builder
+	c := Client{LogLevel: LDebug, Log: &log, Transport: srv.Client().Transport}
+	result := c.Run(req, AssertStatusOK())
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+
+	tm := result.Attempts[0].Response.Timing
+	if tm == nil {
+		t.Fatal("a sent request has no Timing")
+	}
+	if tm.DNS != 0 {
+		t.Errorf("DNS = %s for an IP address, want 0", tm.DNS)
+	}
+	if tm.Connect <= 0 || tm.TLS <= 0 {
+		t.Errorf("Connect = %s, TLS = %s, want both measured", tm.Connect, tm.TLS)
+	}
+	if tm.Transfer < 20*time.Millisecond {
+		t.Errorf("Transfer = %s, want at least the 20ms the body was held back", tm.Transfer)
+	}
+	if tm.TTFB+tm.Transfer != tm.Total {
+		t.Errorf("TTFB %s + Transfer %s != Total %s", tm.TTFB, tm.Transfer, tm.Total)
+	}
+	if !strings.Contains(log.String(), "[:] timing: dns 0s, connect ") {
+		t.Errorf("the debug log has no breakdown:\n%s", log.String())
+	}
+}
@@ -0,0 +1,130 @@
+package httpassert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// The files a TLS client is configured from come in two encodings, and which
+// one a file is can be told from its first bytes: PEM is text that says
+// "-----BEGIN", PKCS#12 is DER. The extension is not consulted: .crt, .pem and
+// .cer are each seen holding either.
+
+func isPEM(data []byte) bool {
+	return bytes.Contains(data, []byte("-----BEGIN "))
+}
+
+// LoadClientCertificate reads the certificate a client presents when a server
+// asks for one, as curl's --cert and --key do.
+//
+// A PEM certFile holds the certificate chain, leaf first, and keyFile the
+// private key; with no keyFile, the key is looked for in certFile. A PKCS#12
+// certFile carries its own key, decrypted with password, and takes no
+// keyFile.
+func LoadClientCertificate(certFile, keyFile, password string) (tls.Certificate, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if !isPEM(data) {
+		if keyFile != "" {
+			return tls.Certificate{}, fmt.Errorf("%s is PKCS#12, which carries its own key; "+
+				"a separate key file is for a PEM certificate", certFile)
+		}
+		key, leaf, chain, err := pkcs12.DecodeChain(data, password)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("%s: cannot read as PKCS#12: %w", certFile, err)
+		}
+		cert := tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: key, Leaf: leaf}
+		for _, c := range chain {
+			cert.Certificate = append(cert.Certificate, c.Raw)
+		}
+
+		return cert, nil
+	}
+
+	keyData := data
+	if keyFile != "" {
+		if keyData, err = os.ReadFile(keyFile); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	cert, err := tls.X509KeyPair(data, keyData)
+	if err != nil {
+		if keyFile == "" {
+			return tls.Certificate{}, fmt.Errorf("%s: %w; give the key file too if it is not in the same file", certFile, err)
+		}
+		return tls.Certificate{}, fmt.Errorf("%s and %s: %w", certFile, keyFile, err)
+	}
+
+	return cert, nil
+}
+
+// LoadCertPool reads the certificate authorities to verify a server against,
+// in place of the system's, as curl's --cacert and --capath do. caFile is a
+// bundle, PEM or a PKCS#12 trust store; caDir is a directory of PEM files,
+// each holding one or more certificates. Either may be empty.
+//
+// A source that yields no certificate at all is an error rather than an empty
+// pool: every server would then fail verification, and the report would blame
+// the server for a mistake in the invocation.
+func LoadCertPool(caFile, caDir string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		if isPEM(data) {
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("%s: no certificates in it", caFile)
+			}
+		} else {
+			certs, err := pkcs12.DecodeTrustStore(data, "")
+			if err != nil {
+				return nil, fmt.Errorf("%s: neither PEM nor a PKCS#12 trust store: %w", caFile, err)
+			}
+			if len(certs) == 0 {
+				return nil, fmt.Errorf("%s: no certificates in it", caFile)
+			}
+			for _, c := range certs {
+				pool.AddCert(c)
+			}
+		}
+	}
+
+	if caDir != "" {
+		entries, err := os.ReadDir(caDir)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, e := range entries {
+			// Stat follows the symlinks c_rehash fills such a directory with.
+			path := filepath.Join(caDir, e.Name())
+			if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			// Anything else -- a README, a CRL -- is not an error: the
+			// directory is the user's, and curl skips such files too.
+			found = pool.AppendCertsFromPEM(data) || found
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: no PEM certificates in it", caDir)
+		}
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,257 @@
+package httpassert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// pki is a private CA and two certificates it signed: one for a server on
+// 127.0.0.1, and one for a client to present to it.
+type pki struct {
+	ca     *x509.Certificate
+	server tls.Certificate
+	client *x509.Certificate
+	key    *ecdsa.PrivateKey // the client's
+}
+
+func newPKI(t *testing.T) pki {
+	t.Helper()
+
+	issue := func(tmpl *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		tmpl.NotBefore, tmpl.NotAfter = time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+
+	ca, caKey := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "Test Root CA"},
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}, nil, nil)
+	srv, srvKey := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	client, clientKey := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "ci"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	return pki{
+		ca:     ca,
+		server: tls.Certificate{Certificate: [][]byte{srv.Raw}, PrivateKey: srvKey},
+		client: client,
+		key:    clientKey,
+	}
+}
+
+// mutualTLSServer answers only a client presenting a certificate the CA
+// signed, and names it in X-Client.
+func (p pki) mutualTLSServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Client", r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(p.ca)
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{p.server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	// The tests that connect without a certificate are refused on purpose.
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func certPEM(c *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+}
+
+func keyPEM(t *testing.T, k *ecdsa.PrivateKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func Test_LoadClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	p := newPKI(t)
+	dir := t.TempDir()
+	crt := writeFile(t, dir, "client.crt", certPEM(p.client))
+	key := writeFile(t, dir, "client.key", keyPEM(t, p.key))
+	both := writeFile(t, dir, "client.pem", append(certPEM(p.client), keyPEM(t, p.key)...))
+	p12, err := pkcs12.Modern.Encode(p.key, p.client, []*x509.Certificate{p.ca}, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfx := writeFile(t, dir, "client.p12", p12)
+
+	tests := []struct {
+		name              string
+		cert, key, passwd string
+		wantMatch         string
+		chain             int
+	}{
+		{name: "PEM and key", cert: crt, key: key, chain: 1},
+		{name: "PEM holding its key", cert: both, chain: 1},
+		{name: "PKCS#12 with its chain", cert: pfx, passwd: "s3cret", chain: 2},
+
+		{name: "PEM without a key", cert: crt, wantMatch: `^.*client\.crt: tls: .*; give the key file too if it is not in the same file$`},
+		{name: "the key for the cert", cert: key, key: key, wantMatch: `^.*client\.key and .*client\.key: tls: .*`},
+		{name: "PKCS#12 and a key", cert: pfx, key: key, wantMatch: `client\.p12 is PKCS#12, which carries its own key; a separate key file is for a PEM certificate$`},
+		{name: "wrong password", cert: pfx, passwd: "guess", wantMatch: `client\.p12: cannot read as PKCS#12: pkcs12: decryption password incorrect$`},
+		{name: "missing", cert: filepath.Join(dir, "nope.crt"), wantMatch: `nope\.crt: no such file or directory$`},
+	}
+
+	for _, tt := range tests {
+		cert, err := LoadClientCertificate(tt.cert, tt.key, tt.passwd)
+		if tt.wantMatch != "" {
+			checkErrMatch(t, tt.name, err, tt.wantMatch)
+			continue
+		}
+		checkErr(t, tt.name, err, "")
+		if len(cert.Certificate) != tt.chain {
+			t.Errorf("%s: got a chain of %d, want %d", tt.name, len(cert.Certificate), tt.chain)
+		}
+	}
+}
+
+func Test_LoadCertPool(t *testing.T) {
+	t.Parallel()
+
+	p := newPKI(t)
+	dir := t.TempDir()
+	bundle := writeFile(t, dir, "ca.pem", certPEM(p.ca))
+	store, err := pkcs12.Passwordless.EncodeTrustStore([]*x509.Certificate{p.ca}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p12 := writeFile(t, dir, "ca.p12", store)
+	notCerts := writeFile(t, dir, "key.pem", keyPEM(t, p.key))
+
+	caDir := t.TempDir()
+	writeFile(t, caDir, "README", []byte("The CAs we trust.\n"))
+	writeFile(t, caDir, "root.pem", certPEM(p.ca))
+	if err := os.Symlink("root.pem", filepath.Join(caDir, "9d66eef0.0")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(caDir, "old"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, file, dir string
+		wantMatch       string
+	}{
+		{name: "PEM bundle", file: bundle},
+		{name: "PKCS#12 trust store", file: p12},
+		{name: "directory", dir: caDir},
+		{name: "both", file: bundle, dir: caDir},
+
+		{name: "no certificates", file: notCerts, wantMatch: `key\.pem: no certificates in it$`},
+		{name: "not a bundle", file: writeFile(t, dir, "junk", []byte{0x30, 0x03, 0x02, 0x01, 0x01}),
+			wantMatch: `junk: neither PEM nor a PKCS#12 trust store: `},
+		{name: "empty directory", dir: t.TempDir(), wantMatch: `: no PEM certificates in it$`},
+		{name: "missing directory", dir: filepath.Join(dir, "nope"), wantMatch: `nope: no such file or directory$`},
+	}
+
+	for _, tt := range tests {
+		pool, err := LoadCertPool(tt.file, tt.dir)
+		if tt.wantMatch != "" {
+			checkErrMatch(t, tt.name, err, tt.wantMatch)
+			continue
+		}
+		checkErr(t, tt.name, err, "")
+		if _, err := p.client.Verify(x509.VerifyOptions{
+			Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}); err != nil {
+			t.Errorf("%s: the pool does not hold the CA: %s", tt.name, err)
+		}
+	}
+}
+
+// The server is verified against the private CA, with no -k, and the client
+// is let in on the certificate it presents.
+func Test_Client_mutualTLS(t *testing.T) {
+	t.Parallel()
+
+	p := newPKI(t)
+	srv := p.mutualTLSServer(t)
+	cert := tls.Certificate{Certificate: [][]byte{p.client.Raw}, PrivateKey: p.key}
+	roots := x509.NewCertPool()
+	roots.AddCert(p.ca)
+
+	req, err := Request{URL: srv.URL}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Client{Certificates: []tls.Certificate{cert}, RootCAs: roots}
+	if err := c.Do(req, AssertStatusOK(), AssertHeaderEqual("X-Client", "ci")); err != nil {
+		t.Error(err)
+	}
+
+	c = Client{RootCAs: roots}
+	if err := c.Do(req, AssertStatusOK()); !errors.Is(err, ErrTransport) {
+		t.Errorf("without a certificate: got %v, want a transport failure", err)
+	}
+
+	c = Client{Certificates: []tls.Certificate{cert}}
+	if err := c.Do(req, AssertStatusOK()); !errors.Is(err, ErrTransport) {
+		t.Errorf("without the CA: got %v, want a transport failure", err)
+	}
+}
@@ -0,0 +1,164 @@
+package httpassert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PubKeyPin is the SHA-256 digest of a DER-encoded SubjectPublicKeyInfo, which
+// is what curl's --pinnedpubkey compares. It pins the key rather than the
+// certificate, so a renewal that keeps the key keeps the pin.
+type PubKeyPin [sha256.Size]byte
+
+// PinOf returns the pin of the key in a certificate.
+func PinOf(cert *x509.Certificate) PubKeyPin {
+	return sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+}
+
+// String writes the pin the way --pinnedpubkey takes it.
+func (p PubKeyPin) String() string {
+	return "sha256//" + base64.StdEncoding.EncodeToString(p[:])
+}
+
+// ParsePinnedPubKey reads curl's forms of --pinnedpubkey: one or more
+// sha256//<base64> digests separated by semicolons, or the name of a file
+// holding the public key itself, PEM or DER.
+func ParsePinnedPubKey(text string) ([]PubKeyPin, error) {
+	if !strings.HasPrefix(text, "sha256//") {
+		return readPinFile(text)
+	}
+
+	var pins []PubKeyPin
+	for _, part := range strings.Split(text, ";") {
+		enc, ok := strings.CutPrefix(strings.TrimSpace(part), "sha256//")
+		if !ok {
+			return nil, fmt.Errorf("%q is not a pin; write sha256//<base64>, and separate pins with ;", part)
+		}
+		digest, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("%q is not the base64 of a SHA-256 digest", enc)
+		}
+		pins = append(pins, PubKeyPin(digest))
+	}
+
+	return pins, nil
+}
+
+// readPinFile pins the public key in a file. A certificate is refused rather
+// than reduced to its key, because curl refuses it, and a pin that passes here
+// and fails there would be a trap.
+func readPinFile(name string) ([]PubKeyPin, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w; a pin is sha256//<base64> or the name of a public key file", err)
+	}
+
+	der := data
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "PUBLIC KEY" {
+			return nil, fmt.Errorf("%s holds a %s, not a PUBLIC KEY; "+
+				"openssl x509 -pubkey -noout extracts one from a certificate", name, block.Type)
+		}
+		der = block.Bytes
+	} else if bytes.Contains(data, []byte("-----BEGIN ")) {
+		return nil, fmt.Errorf("%s: malformed PEM", name)
+	}
+	if _, err := x509.ParsePKIXPublicKey(der); err != nil {
+		return nil, fmt.Errorf("%s: not a public key: %w", name, err)
+	}
+
+	return []PubKeyPin{sha256.Sum256(der)}, nil
+}
+
+// PinMismatchError is the handshake refusing a server whose key is none of the
+// pinned ones. It names the key that was observed, which is the one thing the
+// reader needs to tell a rotated key from an impostor, and to update the pin
+// if it was rotated on purpose.
+type PinMismatchError struct {
+	Observed PubKeyPin
+	Pinned   []PubKeyPin
+}
+
+func (e *PinMismatchError) Error() string {
+	pinned := make([]string, len(e.Pinned))
+	for i, p := range e.Pinned {
+		pinned[i] = p.String()
+	}
+
+	return fmt.Sprintf("public key pin mismatch: the server's key is %s, pinned %s",
+		e.Observed, strings.Join(pinned, ";"))
+}
+
+// verifyPins is a tls.Config.VerifyConnection that accepts only a leaf whose
+// key is pinned. It runs after the chain was verified, and with -k instead of
+// it, so a pin holds either way.
+func verifyPins(pins []PubKeyPin) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return &PinMismatchError{Pinned: pins}
+		}
+		got := PinOf(cs.PeerCertificates[0])
+		for _, p := range pins {
+			if p == got {
+				return nil
+			}
+		}
+
+		return &PinMismatchError{Observed: got, Pinned: pins}
+	}
+}
+
+// ParseTLSVersion reads one version, 1.0 to 1.3, for --tls-min and --tls-max.
+func ParseTLSVersion(text string) (uint16, error) {
+	name := strings.TrimSpace(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(text)), "tls"))
+	for _, tv := range tlsVersions {
+		if tv.name == name {
+			return tv.version, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%q is not a TLS version; possible values: 1.0, 1.1, 1.2, 1.3", text)
+}
+
+// ParseCipherSuites reads a list of cipher suites, separated by commas or
+// colons, by their IANA names as Go spells them:
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. The suites Go considers insecure are
+// accepted too; asking for one is what checking that a server still offers it
+// takes.
+//
+// TLS 1.3 suites are refused: Go negotiates all of them and cannot be told
+// otherwise, so naming one would promise a restriction that never happens.
+func ParseCipherSuites(text string) ([]uint16, error) {
+	known := map[string]*tls.CipherSuite{}
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[cs.Name] = cs
+	}
+
+	var ids []uint16
+	for _, name := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ':' }) {
+		name = strings.TrimSpace(name)
+		cs, ok := known[name]
+		switch {
+		case name == "":
+			continue
+		case !ok:
+			return nil, fmt.Errorf("unknown cipher suite %q; write its IANA name, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", name)
+		case len(cs.SupportedVersions) == 1 && cs.SupportedVersions[0] == tls.VersionTLS13:
+			return nil, fmt.Errorf("%s is a TLS 1.3 suite, and those cannot be chosen; "+
+				"use --tls-max 1.2 to test the others alone", name)
+		}
+		ids = append(ids, cs.ID)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no cipher suites in %q", text)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,187 @@
+package httpassert
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_ParsePinnedPubKey(t *testing.T) {
+	t.Parallel()
+
+	p := newPKI(t)
+	spki, err := x509.MarshalPKIXPublicKey(&p.key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PubKeyPin(sha256.Sum256(spki))
+	other := PubKeyPin(sha256.Sum256([]byte("other")))
+
+	dir := t.TempDir()
+	pemFile := writeFile(t, dir, "key.pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: spki}))
+	derFile := writeFile(t, dir, "key.der", spki)
+	certFile := writeFile(t, dir, "client.crt", certPEM(p.client))
+
+	tests := []struct {
+		text      string
+		want      []PubKeyPin
+		wantMatch string
+	}{
+		{text: want.String(), want: []PubKeyPin{want}},
+		{text: want.String() + ";" + other.String(), want: []PubKeyPin{want, other}},
+		{text: want.String() + " ; " + other.String(), want: []PubKeyPin{want, other}},
+		{text: pemFile, want: []PubKeyPin{want}},
+		{text: derFile, want: []PubKeyPin{want}},
+
+		{text: "sha256//not base64!", wantMatch: `^"not base64!" is not the base64 of a SHA-256 digest$`},
+		{text: "sha256//" + base64.StdEncoding.EncodeToString([]byte("short")), wantMatch: `is not the base64 of a SHA-256 digest$`},
+		{text: want.String() + ";md5//abc", wantMatch: `^"md5//abc" is not a pin; write sha256//<base64>, and separate pins with ;$`},
+		{text: certFile, wantMatch: `client\.crt holds a CERTIFICATE, not a PUBLIC KEY; openssl x509 -pubkey -noout extracts one from a certificate$`},
+		{text: writeFile(t, dir, "junk", []byte("junk")), wantMatch: `junk: not a public key: `},
+		{text: filepath.Join(dir, "nope"), wantMatch: `nope: no such file or directory; a pin is sha256//<base64> or the name of a public key file$`},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePinnedPubKey(tt.text)
+		if tt.wantMatch != "" {
+			checkErrMatch(t, tt.text, err, tt.wantMatch)
+			continue
+		}
+		checkErr(t, tt.text, err, "")
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d pins, want %d", tt.text, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: pin %d is %s, want %s", tt.text, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func Test_ParseTLSVersion(t *testing.T) {
+	t.Parallel()
+
+	for text, want := range map[string]uint16{
+		"1.0": tls.VersionTLS10, "1.2": tls.VersionTLS12, " TLS1.3 ": tls.VersionTLS13, "tls 1.1": tls.VersionTLS11,
+	} {
+		got, err := ParseTLSVersion(text)
+		checkErr(t, text, err, "")
+		if got != want {
+			t.Errorf("%q: got %#x, want %#x", text, got, want)
+		}
+	}
+
+	_, err := ParseTLSVersion("1.4")
+	checkErr(t, "1.4", err, `"1.4" is not a TLS version; possible values: 1.0, 1.1, 1.2, 1.3`)
+}
+
+func Test_ParseCipherSuites(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseCipherSuites("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_RSA_WITH_AES_128_CBC_SHA:TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256")
+	checkErr(t, "list", err, "")
+	want := []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("suite %d is %#x, want %#x", i, got[i], want[i])
+		}
+	}
+
+	for text, wantErr := range map[string]string{
+		"ECDHE-RSA-AES128-GCM-SHA256": `unknown cipher suite "ECDHE-RSA-AES128-GCM-SHA256"; write its IANA name, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256`,
+		"TLS_AES_128_GCM_SHA256":      "TLS_AES_128_GCM_SHA256 is a TLS 1.3 suite, and those cannot be chosen; use --tls-max 1.2 to test the others alone",
+		" , ":                         `no cipher suites in " , "`,
+	} {
+		_, err := ParseCipherSuites(text)
+		checkErr(t, text, err, wantErr)
+	}
+}
+
+func Test_Client_pinnedPubKey(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	pin := PinOf(srv.Certificate())
+	other := PubKeyPin(sha256.Sum256([]byte("other")))
+
+	req, err := Request{URL: srv.URL}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// -k skips the chain, not the pin: the key is checked either way.
+	c := Client{SkipSslChecks: true, PinnedPubKeys: []PubKeyPin{other, pin}}
+	if err := c.Do(req, AssertStatusOK()); err != nil {
+		t.Errorf("the server's own pin: %s", err)
+	}
+
+	c.PinnedPubKeys = []PubKeyPin{other}
+	err = c.Do(req, AssertStatusOK())
+	var mismatch *PinMismatchError
+	if !errors.Is(err, ErrTransport) {
+		t.Fatalf("got %v, want a transport failure", err)
+	}
+	result := c.Run(req, AssertStatusOK())
+	if !errors.As(result.Attempts[0].SendErr, &mismatch) || mismatch.Observed != pin {
+		t.Errorf("SendErr = %v, want a PinMismatchError observing %s", result.Attempts[0].SendErr, pin)
+	}
+	for _, want := range []string{
+		"the server's public key is not pinned:\n- observed " + pin.String() + "\n- pinned   " + other.String() + "\n",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("the report does not contain %q:\n%s", want, err)
+		}
+	}
+}
+
+func Test_Client_tlsPolicy(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	// The client asking for 1.3 only is refused on purpose.
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	req, err := Request{URL: srv.URL}.Build(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v12, _ := ParseTLSVersionSpec("1.2")
+
+	c := Client{SkipSslChecks: true, MaxTLSVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}}
+	result := c.Run(req, AssertTLSVersion(v12))
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if got := result.Attempts[0].Response.TLS.CipherSuite; got != tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 {
+		t.Errorf("negotiated %s, want the one suite offered", tls.CipherSuiteName(got))
+	}
+
+	c = Client{SkipSslChecks: true, MinTLSVersion: tls.VersionTLS13}
+	if err := c.Do(req, AssertStatusOK()); !errors.Is(err, ErrTransport) ||
+		!strings.Contains(err.Error(), "protocol version") {
+		t.Errorf("a 1.2 server with --tls-min 1.3: got %v, want a handshake failure", err)
+	}
+}
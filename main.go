@@ -6,9 +6,10 @@
 // declared as flags, and the request is made once and checked against all of
 // them.
 //
-// The three header assertions and --assert-jq can be repeated to assert
-// several things at once. Every other assertion flag takes a single value and
-// is rejected if given twice, rather than quietly keeping the last one.
+// The three header assertions, --assert-jq, --assert-time, --assert-cert-san,
+// --assert-alt-svc and --assert-cookie can be repeated to assert several
+// things at once. Every other assertion flag takes a single value and is
+// rejected if given twice, rather than quietly keeping the last one.
 //
 // The two boolean assertions negate with =false, which selects the opposite
 // assertion rather than cancelling the flag.
@@ -18,6 +19,15 @@
 // no path-and-value syntax and no question of whether 5 means the number or the
 // string -- jq already has types, comparison and regexp.
 //
+// --assert-time bounds a phase of the request -- dns, connect, tls, ttfb,
+// transfer or total -- as in total<300ms. The breakdown of every phase is in
+// the -v log and at the end of the failure dump, so a slow response says where
+// the time went.
+//
+// The --assert-cert-* and --assert-tls-* flags, and --assert-alpn, check the
+// TLS connection the response came over: the leaf certificate's expiry, names
+// and issuer, and the protocol version and ALPN the handshake settled on.
+//
 //	http-assert --assert-ok https://example.com
 //	http-assert --assert-status 201 -X POST -d '{"n":1}' https://api.example.com/things
 //	http-assert --assert-header 'Content-Type: application/json' \
@@ -47,6 +57,13 @@
 // variable on whitespace, which suits host mappings and would corrupt header
 // values.
 //
+// # Request bodies
+//
+// -d sends its value as it is given. --data-binary @FILE and --data-file FILE
+// send a file, and @- and - send stdin; --data-urlencode name=value encodes
+// the value, and repeats joined with &. The body is read once and held, so
+// every --retry attempt sends all of it and the failure dump crops it.
+//
 // # Redirects
 //
 // Redirects are not followed by default. A 3xx reaches the assertions as it
@@ -73,6 +90,105 @@
 // latter is checked before each retry, so an attempt already in flight can
 // overrun it by up to one --max-time.
 //
+// # TLS
+//
+// --cert presents a client certificate to a server that asks for one, and
+// --cacert or --capath verify the server against a private CA instead of the
+// system's, which is what -k was being used to get around. --cert takes PEM,
+// with --key when the key is in a file of its own, or PKCS#12 as
+// <file:password>. A file that cannot be loaded exits 71 before any request is
+// made.
+//
+// --pinnedpubkey accepts only a server whose key hashes to one of the given
+// sha256//<base64> pins, -k or not; any other key fails the handshake with exit
+// 92, and the report names the key that was seen. --tls-min and --tls-max
+// bound the version negotiated, and --ciphers limits the TLS 1.0-1.2 suites
+// offered, by IANA name.
+//
+// # HTTP/2
+//
+// Requests use HTTP/1.1 unless asked otherwise. --http2 offers h2 in the TLS
+// handshake and speaks it if the server agrees; --http2-prior-knowledge speaks
+// it without asking, as h2c over plain http://. --assert-proto checks the
+// version the response came over.
+//
+// # HTTP/3
+//
+// --http3 sends the request over QUIC and nowhere else, so a broken QUIC path
+// fails the run rather than being hidden by a fallback to TCP. --assert-alt-svc
+// checks that a response over TCP advertises it: --assert-alt-svc h3.
+//
+// # Unix sockets
+//
+// --unix-socket connects through a Unix domain socket instead of to the URL's
+// host, which still gives the Host header and the path:
+//
+//	http-assert --unix-socket /var/run/docker.sock --assert-ok http://docker/_ping
+//
+// # Host mapping
+//
+// --maphost src=dst sends the connections for src, a host:port pair, to dst.
+// The host of src may be a glob, *.example.com, or a CIDR block, 10.0.0.0/8,
+// either part may be *, and * alone maps everything. Several destinations,
+// separated by commas, are taken in turn, one per attempt:
+//
+//	http-assert --retry 2 --maphost 'api.example.com:443=10.0.1.10,10.0.1.11' --assert-ok https://api.example.com/
+//
+// # Name resolution
+//
+// --resolve host:port:addr[,addr] connects to those addresses without asking
+// DNS, after --maphost has had its say; --dns-servers asks other servers than
+// the system's; -4 and -6 keep to one address family. -v names the address
+// each response came from, and so does a failure dump.
+//
+// --all-addresses runs the request against every address the host resolves
+// to, one after the other, and passes only if each of them does: one bad
+// replica behind round-robin DNS fails the run, and the report names it.
+//
+// # Proxies
+//
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY are honoured unless --no-proxy-env says
+// otherwise. --proxy names a proxy in their place -- http, https, socks5 or
+// socks5h -- with --proxy-user for its credentials, and --noproxy lists the
+// hosts to reach directly. -v says which proxy each attempt went through.
+//
+// # Authentication
+//
+// -u user:password sends Basic credentials, and --oauth2-bearer a bearer
+// token, or the one in a file as @FILE, or in a variable with
+// --oauth2-bearer-env. --netrc and --netrc-file take Basic credentials for the
+// host from a .netrc file. The two flags that keep the secret off the command
+// line are the ones to use in CI, and a failure dump shows the credentials as
+// xxxxx either way.
+//
+// --oauth2-token-url gets the bearer token from a token endpoint, with the
+// client credentials grant and --oauth2-client-id,
+// --oauth2-client-secret-file and --oauth2-scope: once for the run, and again
+// if it expires during --retry. A token endpoint that refuses is a transport
+// failure of its own, labelled as such.
+//
+// --digest sends the same credentials by Digest instead: the server's 401
+// challenge is answered and the request sent again within the attempt, the
+// assertions check the response to the answer, and the dump says the round
+// happened.
+//
+// # Signing
+//
+// --aws-sigv4 provider:region:service signs each attempt with AWS Signature
+// Version 4, with the credentials in $AWS_ACCESS_KEY_ID,
+// $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN. --hmac algorithm:header
+// puts an HMAC of the body in a header, keyed by --hmac-key or --hmac-key-env,
+// and with a third part stamps the time in another header and signs it too.
+// Every attempt is signed afresh, so a long --retry is not refused for a stale
+// timestamp.
+//
+// # Cookies
+//
+// A cookie one hop of a -L chain sets goes to the hops after it. -b and -c keep
+// cookies for the whole run, suite included: -b sends name=value cookies or
+// starts from a Netscape cookie file, and -c writes the jar to one when the run
+// ends, whatever the verdict. --assert-cookie checks what the jar holds.
+//
 // # Compression
 //
 // A compressed body is decoded before the assertions run, so --assert-body and
@@ -85,30 +201,74 @@
 // what it had asked for itself and would delete Content-Encoding and
 // Content-Length on the way, which made a response that was compressed
 // indistinguishable from one that never was.
+//
+// # Reports
+//
+// --report json writes one document per run to stdout, or to --report-file:
+// every assertion with its verdict, every attempt with its timing, and the exit
+// code with its category. A failure and an evaluation error are separate
+// verdicts there, which is the distinction Assertion.Check draws (#45).
+// --report tap writes the same verdicts as TAP test points, with the failure
+// details in YAML blocks.
+//
+// --junit writes the same run as a JUnit XML testsuite, one testcase per
+// assertion, for CI systems that render test results natively. It is written
+// alongside --report, not instead of it.
+//
+// # Captures
+//
+// --capture, --capture-header and --capture-body print what they found as
+// NAME=value lines on stdout once the run has passed: single-quoted for eval,
+// or in the format $GITHUB_ENV reads. A capture that finds nothing fails the
+// run like an assertion, so a script never evals half an answer.
+//
+// # Saved responses
+//
+// --from-response reads a raw HTTP response from a file or stdin -- curl -i
+// output, a proxy log, a fixture -- and runs the assertions against it in
+// place of a request. The body is decoded and the verdicts are reported as a
+// live run's would be, so an assertion set can be tried out with no server.
+//
+// # Suites
+//
+// http-assert run performs every request in a YAML or JSON suite file. Each
+// request's keys are the long names of the flags, and are parsed by the same
+// code, so a suite cannot say anything the command line means differently.
+// Every request is validated before the first is sent, and one exit code
+// covers them all.
+//
+// A request can capture values from its response -- with jq, from a header, or
+// with a regexp -- and a later one interpolates them as {{NAME}}. A capture is
+// an assertion, so one that finds nothing fails its request the same way.
+//
+// # Go package
+//
+// Everything past the flags -- the request, the assertions, the retries, the
+// dump -- is package github.com/korya/http-assert/httpassert, which a Go
+// program can import to get the same verdicts. Its httpasserttest package runs
+// them from go test against an httptest.Server or a handler.
 package main
 
 import (
-	"bytes"
-	"compress/flate"
-	"compress/gzip"
-	"compress/zlib"
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"maps"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/andybalholm/brotli"
-	"github.com/klauspost/compress/zstd"
+	"github.com/korya/http-assert/httpassert"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -130,10 +290,11 @@ func main() {
 Assertions are declared as flags. The request is made once and checked
 against all of them, and every failure is reported, not just the first.
 
-Repeat --assert-header, --assert-header-eq, --assert-header-missing or
---assert-jq to make several assertions of that kind. Every other assertion flag
-takes a single value and is rejected if given twice, rather than quietly
-keeping the last.
+Repeat --assert-header, --assert-header-eq, --assert-header-missing,
+--assert-jq, --assert-time, --assert-cert-san, --assert-alt-svc or
+--assert-cookie to make several assertions of that kind. Every other
+assertion flag takes a single value and is rejected if given twice, rather
+than quietly keeping the last.
 
 A response header can also carry several values -- Set-Cookie routinely does --
 and that is a different thing from repeating the flag. --assert-header and
@@ -163,6 +324,18 @@ Repeat --assert-jq to assert several jq expressions against a JSON body. Each
 must yield true; a query that yields nothing has checked nothing and fails.
 A query is compiled before the request is made, so a broken one exits 71.
 
+--assert-time bounds how long a phase of the attempt took, as PHASE<DURATION
+or PHASE<=DURATION: 'total<300ms', 'ttfb<=100ms'. The phases are dns,
+connect, tls, ttfb (to the first byte of the response), transfer (from there
+to the last) and total. -v logs the breakdown of each attempt, and the failure
+dump ends with it.
+
+--assert-cert-expires-after, --assert-cert-san and --assert-cert-issuer check
+the certificate the server presented for itself, and --assert-tls-version and
+--assert-alpn the handshake: '--assert-cert-expires-after 14d' fails a
+certificate due to expire within two weeks. They work with -k too, which skips
+verifying the chain but not reading it.
+
 Exit codes:
   0    every assertion passed
   71   the invocation was rejected; no request was attempted
@@ -179,8 +352,18 @@ Environment:
   empty variable counts as unset, and a value that does not parse is rejected
   rather than quietly replaced by a zero.
 
-  HTTP_PROXY, HTTPS_PROXY and NO_PROXY are honoured for the request itself.
-  There is no flag for them.
+  HTTP_PROXY, HTTPS_PROXY and NO_PROXY are honoured for the request itself,
+  unless --no-proxy-env or --proxy says otherwise (see Proxies).
+
+Request bodies:
+  -d DATA sends DATA as it is given, a leading @ included. --data-binary
+  @FILE sends the file, byte for byte, and @- stdin; without the @ it is -d.
+  --data-file FILE sends the file too, and - stdin. --data-urlencode
+  name=value URL-encodes the value, name@FILE the file's contents; repeat it
+  to join several with &. Any of them implies POST and, unless -H says
+  otherwise, Content-Type: application/x-www-form-urlencoded, and only one of
+  them may be given. Every attempt sends the whole body, and a failure dump
+  crops a long one.
 
 Redirects:
   By default a 3xx response is asserted on as it stands and the redirect is
@@ -206,6 +389,112 @@ Retries:
   checked before each retry, so an attempt already in flight can overrun it.
   Both --retry-delay and --retry-max-time need --retry to mean anything.
 
+TLS:
+  --cert presents a client certificate to a server that requires one: PEM, with
+  --key when the key is in a separate file, or PKCS#12, written as
+  <file:password> when it is encrypted. --cacert (a PEM bundle or PKCS#12 trust
+  store) and --capath (a directory of PEM files) verify the server against
+  those CAs instead of the system's. A file that cannot be loaded exits 71
+  before any request is made.
+
+  --pinnedpubkey takes sha256//<base64> pins separated by ; or a public key
+  file, and accepts only a server whose key matches one, with or without -k.
+  Any other key exits 92 and the report names the key observed. --tls-min and
+  --tls-max bound the version (1.0 to 1.3); --ciphers lists the TLS 1.0-1.2
+  suites to offer by IANA name (TLS 1.3 suites cannot be chosen).
+
+HTTP/2:
+  Requests use HTTP/1.1 unless one of these asks for HTTP/2. --http2 offers h2
+  in the TLS handshake and falls back to HTTP/1.1 when the server does not
+  agree; plain http:// stays HTTP/1.1. --http2-prior-knowledge speaks HTTP/2
+  without asking: h2c for http://, h2 alone for https://, exit 92 from a server
+  that cannot. '--assert-proto HTTP/2.0' checks what was spoken.
+
+HTTP/3:
+  --http3 sends the request over QUIC, to the same host and port over UDP, and
+  never falls back to TCP: a QUIC path that does not answer exits 92. It needs
+  an https:// URL, and cannot be combined with --tls-max below 1.3 or with
+  --ciphers. '--assert-alt-svc h3' (or h3=:443) checks that a response
+  advertises HTTP/3 in its Alt-Svc header.
+
+Unix sockets:
+  --unix-socket PATH connects through a Unix domain socket instead of to the
+  URL's host; the URL still gives the Host header, the path and, for https://,
+  the name the certificate is checked against. A socket that is missing or not
+  listening exits 92, and the failure dump names it. It cannot be combined
+  with --maphost or --http3.
+
+Host mapping:
+  --maphost src=dst[,dst]... connects to dst whenever the request -- or a
+  redirect it follows -- would connect to src, a host:port pair. The host of
+  src may be a glob (*.example.com, api-[0-9].example.com) or a CIDR block
+  (10.0.0.0/8, [2001:db8::/32]); either part may be *, and * alone maps every
+  connection. A dst without a port keeps the one it replaces; write an IPv6
+  address in brackets. With several destinations each attempt takes the next,
+  so --retry goes round them. The first mapping that matches wins, and a
+  value that does not parse exits 71 saying why.
+
+Name resolution:
+  --resolve host:port:addr[,addr]... connects to host:port at those addresses,
+  in order, without asking DNS; repeat it for several hosts. It applies to the
+  address --maphost points at. --dns-servers ip[:port],... asks those servers
+  in place of the system's. -4 and -6 connect over IPv4 or IPv6 only. The
+  address a response came from follows the [:] line with -v, and a failure
+  dump names it.
+  --all-addresses runs the request once against each address the host
+  resolves to -- A and AAAA records, or the --resolve list -- with every
+  assertion, and passes only if all of them pass. A failure lists the
+  addresses that broke, each with its dump; --report writes one result per
+  address. It cannot be combined with --capture, --unix-socket or --proxy.
+
+Proxies:
+  --proxy URL sends every request through a proxy in place of the ones
+  HTTP_PROXY and HTTPS_PROXY name: http:// (the default), https://, socks5://,
+  which resolves the host here, or socks5h://, which leaves it to the proxy.
+  --proxy-user user:password authenticates to it. --noproxy takes a
+  comma-separated list of hosts, domains and CIDR blocks to reach directly, or
+  * for all. --no-proxy-env, or an empty --proxy, ignores the environment's
+  proxies. -v names the proxy each attempt went through, password redacted.
+
+Authentication:
+  -u user:password sends HTTP Basic credentials. --oauth2-bearer TOKEN sends a
+  bearer token; @FILE reads it from a file, and --oauth2-bearer-env NAME from
+  the variable $NAME, which keeps it out of ps and the shell history. -u and a
+  token cannot be combined. --netrc reads credentials for the host from
+  ~/.netrc, or the file $NETRC names, and --netrc-file from another file;
+  -u and a token win over it. An Authorization header given with -H wins over
+  all of them. A failure dump shows them as 'Basic alice:xxxxx' and
+  'Bearer xxxxx', and a password in the URL is redacted in the log.
+  --oauth2-token-url URL gets the bearer token from an OAuth 2.0 token
+  endpoint with the client credentials grant, as --oauth2-client-id and the
+  secret in --oauth2-client-secret-file, for --oauth2-scope if given. It is
+  fetched once for the run, suite included, and again if it is about to
+  expire during --retry; an endpoint that refuses fails the run with exit 92
+  and says it was the token endpoint.
+  --digest sends the -u or .netrc credentials by HTTP Digest instead, MD5 or
+  SHA-256: a 401 challenge is answered within the attempt, the assertions
+  check the response to the answer, and the dump shows the challenge.
+
+Signing:
+  --aws-sigv4 provider:region:service, as aws:eu-west-1:execute-api, signs
+  each attempt with AWS Signature Version 4, using $AWS_ACCESS_KEY_ID,
+  $AWS_SECRET_ACCESS_KEY and, when set, $AWS_SESSION_TOKEN. --hmac
+  algorithm:header[:timestamp-header], as sha256:X-Signature:X-Timestamp,
+  sets the header to the hex HMAC of the body, or of the Unix time, a dot and
+  the body when a timestamp header is named; sha1, sha256 and sha512 are
+  understood. --hmac-key KEY, @FILE or --hmac-key-env NAME gives the key.
+  Each attempt is signed afresh, and the dump shows signatures as xxxxx.
+
+Cookies:
+  A cookie set by one hop of a -L chain is sent to the hops after it. -b and
+  -c keep the cookies responses set for the whole run, suite included, and
+  send them where their domain and path allow. -b 'name=value; name=value'
+  sends those cookies; any -b value without a = is a Netscape cookie file to
+  start from, and one that does not exist yet starts empty. -c FILE writes
+  the cookies held when the run ends to FILE, whatever the verdict.
+  --assert-cookie NAME[=REGEXP] checks the jar holds the cookie for the final
+  URL, whichever response set it.
+
 Compression:
   A compressed body is decoded before the assertions run, so --assert-body and
   the other body assertions always see the payload. gzip, deflate, br (brotli)
@@ -217,6 +506,40 @@ Compression:
   headers are reported exactly as they arrived -- so a body can be asserted on
   and its Content-Encoding at the same time.
 
+Reports:
+  --report json writes a machine-readable account of the run to stdout, or to
+  --report-file: every assertion with its verdict (passed, failed, error or
+  not_run), every attempt with its timing, and the exit code. The log and the
+  failure dump still go to stderr. The file is opened before the request, so a
+  path that cannot be written exits 71.
+  --report tap writes TAP version 14 instead: ok or not ok per assertion, a
+  YAML block with expected and actual under each not ok, and "severity: error"
+  where the assertion could not be evaluated. A transport failure is a
+  "Bail out!".
+  --junit <file> writes the run as a JUnit XML testsuite as well: a testcase per
+  assertion, the failure dump as system-out, and the attempt count as a
+  property. It composes with --report.
+
+Captures:
+  --capture NAME=<jq>, --capture-header NAME=<header> and --capture-body
+  NAME=<regexp> print NAME=value lines to stdout once the run has passed, and
+  nothing when it fails. A capture that finds nothing fails the run with 93.
+  --capture-format shell, the default, single-quotes each value for eval;
+  github writes the format $GITHUB_ENV reads.
+
+Saved responses:
+  --from-response <file> asserts on a raw HTTP response saved elsewhere, such as
+  curl -i output, instead of making a request; - reads it from stdin. The body
+  is decoded and the dump, reports and exit codes are those of a live run.
+  When curl printed several heads (100 Continue, -L hops), the last response
+  is the one asserted on. No URL or request flag may be given with it.
+
+Suites:
+  http-assert run <file> performs every request in a YAML or JSON suite file,
+  whose keys are the long names of these flags, and exits once for all of
+  them. A request can capture values from its response for the requests after
+  it. See http-assert run --help.
+
 Colour:
   --color decides whether the sigil lines and Error: carry ANSI colour. auto,
   the default, colours only when stderr is a terminal, so a pipe or a CI log
@@ -243,9 +566,12 @@ Colour:
   http-assert --maphost 'example.com:443=127.0.0.1:8443' --assert-ok https://example.com/
 
   # Follow the redirect chain and assert on where it lands
-  http-assert -L --assert-status 200 https://example.com/old`,
+  http-assert -L --assert-status 200 https://example.com/old
+
+  # Assert on a response saved by curl instead of making a request
+  curl -si https://example.com/health | http-assert --from-response - --assert-ok`,
 		Version: versionString(),
-		Args:    cobra.ExactArgs(1),
+		Args:    rootArgs,
 		// Errors exit through dief with one line and the invocation code;
 		// cobra's own reporting would add a 40-line usage dump that buries
 		// the error and print it twice. --help is unaffected: cobra renders
@@ -258,121 +584,97 @@ Colour:
 			// the caller asked.
 			mustSetPalette(cmd)
 
-			insecure, _ := cmd.Flags().GetBool("insecure")
-			maxTime, _ := cmd.Flags().GetInt("max-time")
-			maphost, _ := cmd.Flags().GetStringArray("maphost")
-			location, _ := cmd.Flags().GetBool("location")
-			maxRedirs, _ := cmd.Flags().GetInt("max-redirs")
-			retry, _ := cmd.Flags().GetInt("retry")
-			retryDelay, _ := cmd.Flags().GetDuration("retry-delay")
-			retryMaxTime, _ := cmd.Flags().GetDuration("retry-max-time")
-			c := Client{
-				LogLevel:        mustParseLogLevel(cmd),
-				Palette:         errPalette,
-				SkipSslChecks:   insecure,
-				Timeout:         time.Duration(maxTime) * time.Second,
-				HostMappings:    mustParseHostMappings(maphost),
-				FollowRedirects: location,
-				MaxRedirects:    maxRedirs,
-				Retries:         retry,
-				RetryDelay:      retryDelay,
-				RetryMaxTime:    retryMaxTime,
-			}
+			logLevel := mustParseLogLevel(cmd)
+			c, err := newClient(cmd.Flags())
+			dieOn(err)
+			c.LogLevel, c.Log = logLevel, logWriter{errPalette}
 			c.Init()
 
-			// -d implies POST, as it does in curl; an explicit -X wins even
-			// when it repeats the default, which Changed distinguishes from
-			// "not passed" -- applyEnv cannot fake it because neither flag is
-			// env-applied and Value.Set does not mark Changed.
-			m, _ := cmd.Flags().GetString("request")
-			dataGiven := cmd.Flags().Changed("data")
-			if dataGiven && !cmd.Flags().Changed("request") {
-				m = http.MethodPost
-			}
-			b := io.Reader(http.NoBody)
-			if dataGiven {
-				d, _ := cmd.Flags().GetString("data")
-				b = strings.NewReader(d)
+			assertions, err := parseAssertionFlags(cmd.Flags())
+			dieOn(err)
+			captures, err := parseCaptureFlags(cmd.Flags())
+			dieOn(err)
+			// A capture that finds nothing fails the run, so it is a check
+			// in its own right, and --capture alone is a complete invocation.
+			for _, c := range captures {
+				assertions = append(assertions, c)
 			}
-			assertions := parseAssertionFlags(cmd)
 			if len(assertions) == 0 {
 				dief(exitBadInvocation, "No assertions specified; pass at "+
 					"least one --assert-* flag (e.g. --assert-ok)")
 			}
 
-			req, err := http.NewRequestWithContext(cmd.Context(), m, args[0], b)
-			if err != nil {
-				dief(exitBadInvocation, "Cannot create request '%s %s': %s", m, args[0], err)
+			var req *http.Request
+			var saved *httpassert.Response
+			savedPath, _ := cmd.Flags().GetString("from-response")
+			if cmd.Flags().Changed("from-response") {
+				saved, err = loadResponse(savedPath)
+			} else {
+				req, err = newRequest(cmd.Context(), cmd.Flags(), args[0])
 			}
+			dieOn(err)
+			reports := mustOpenReports(cmd)
 
-			vs, _ := cmd.Flags().GetStringArray("header")
-			for _, v := range vs {
-				name, value := mustParseRequestHeader(v)
-				req.Header.Add(name, value)
+			if all, _ := cmd.Flags().GetBool("all-addresses"); all {
+				runEachAddress(c, req, assertions, reports, logLevel)
+				return
 			}
-			// curl's default for -d; presence is what suppresses it, so an
-			// explicit empty `-H 'Content-Type:'` is respected, not replaced.
-			if dataGiven && len(req.Header.Values("Content-Type")) == 0 {
-				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			var res *httpassert.Result
+			if saved != nil {
+				res = c.RunSaved(savedPath, saved, assertions...)
+			} else {
+				res = c.Run(req, assertions...)
 			}
-			if err := c.Do(req, assertions...); err != nil {
-				// An error nobody tagged stays in the transport bucket: wrong
-				// by at most one category, and never reported as a usage
-				// mistake the caller did not make.
-				e := &exitError{code: exitTransportFail}
-				_ = errors.As(err, &e)
-				if e.code == exitAssertFail {
+			saveCookieJars()
+			writeReports(reports, func(w io.Writer, format string) error {
+				return writeReport(w, format, res)
+			})
+			if err := res.Err; err != nil {
+				code := exitCodeOf(err)
+				if code == exitAssertFail {
 					// The assertion dump names itself; a transport-flavoured
 					// prefix would send the reader to the network.
 					dief(exitAssertFail, "%s", err)
 				}
-				dief(e.code, "Cannot perform request: %s", err)
+				dief(code, "Cannot perform request: %s", err)
+			}
+
+			// Only now: a failed run prints nothing, so a script that
+			// evals the output cannot proceed with half of it.
+			if len(captures) > 0 {
+				format, _ := cmd.Flags().GetString("capture-format")
+				if err := writeCaptures(os.Stdout, format, captures); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: cannot write the captured values: %s\n", err)
+				}
 			}
 		},
 	}
-	// Deviations from curl's --resolve:
-	// - use `=` to separate src and dst
-	// - add [:dstport]
-	cmd.PersistentFlags().StringArray("maphost", nil,
-		"Provide a custom address for a specific host and port pair; "+
-			"e.g. <srchostname:srcport=dsthostname[:dstport]>")
-	cmd.PersistentFlags().BoolP("verbose", "v", false,
-		"Be verbose; log debug messages (same as --log-level debug; overrides --log-level)")
-	cmd.PersistentFlags().BoolP("silent", "s", false,
-		"Be silent; log error messages only (same as --log-level error; overrides -v)")
-	cmd.PersistentFlags().String("log-level", "",
-		"Set log level; possible values: debug, info (default), warn, error")
-	cmd.PersistentFlags().String("color", "auto",
-		"Colour the verdict; possible values: auto (default), always, never")
-	cmd.PersistentFlags().BoolP("insecure", "k", false, "Disable checking SSL certificates")
-	cmd.PersistentFlags().IntP("max-time", "m", 20,
-		"Maximum time in seconds that you allow each request to take")
-	cmd.Flags().StringP("request", "X", "GET",
-		"Set method for HTTP request; overrides the POST that -d implies")
-	cmd.Flags().StringArrayP("header", "H", nil,
-		"Set header for HTTP request, as <name: value>; a name alone is rejected")
-	cmd.Flags().StringP("data", "d", "",
-		"Sends the specified data in a POST request to the HTTP server")
-	cmd.Flags().BoolP("location", "L", false,
-		"Follow redirects; assertions then apply to the end of the chain")
-	cmd.Flags().Int("max-redirs", 10,
-		"Maximum number of redirects to follow; requires --location")
-	cmd.Flags().Int("retry", 0,
-		"Number of times to retry a failed attempt; 0 makes the request once")
-	cmd.Flags().Duration("retry-delay", time.Second,
-		"Fixed delay between attempts, e.g. 1s or 250ms; requires --retry")
-	cmd.Flags().Duration("retry-max-time", 0,
-		"Stop retrying after this long; 0 means only --retry bounds it; requires --retry")
-	registerAssertionFlags(cmd)
+	registerRootFlags(cmd.PersistentFlags())
+	registerRequestFlags(cmd.Flags())
+	registerReportFlags(cmd.Flags())
+	registerAssertionFlags(cmd.Flags())
+	cmd.Flags().String("from-response", "",
+		"Assert on a saved HTTP response read from this file (- for stdin) instead of making a request")
+	cmd.Flags().Bool("all-addresses", false,
+		"Run the request against every address the host resolves to; pass only if all of them pass")
+	registerCaptureFlags(cmd.Flags())
+	cmd.Flags().String("capture-format", "shell",
+		"Print captured values for this consumer; possible values: shell (default), github")
 	rejectRepeats(cmd.Flags())
+	cmd.AddCommand(newRunCommand())
 
 	cmd.PersistentPreRun = func(cmd *cobra.Command, _ []string) {
 		// Before applyEnv, so that a value the environment supplies can never
 		// be mistaken for a second occurrence on the command line.
-		checkRepeats(cmd.Flags())
+		dieOn(checkRepeats(cmd.Flags()))
 		applyEnv(cmd.Flags())
-		checkRedirectFlags(cmd.Flags())
-		checkRetryFlags(cmd.Flags())
+		dieOn(checkRedirectFlags(cmd.Flags()))
+		dieOn(checkRetryFlags(cmd.Flags()))
+		dieOn(checkReportFlags(cmd.Flags()))
+		dieOn(checkCaptureFlags(cmd.Flags()))
+		dieOn(checkFromResponseFlags(cmd.Flags()))
+		dieOn(checkAllAddressesFlags(cmd.Flags()))
 	}
 
 	if err := cmd.ExecuteContext(context.Background()); err != nil {
@@ -434,13 +736,13 @@ func applyEnv(fs *pflag.FlagSet) {
 // will read because nothing is being followed, and a negative bound that would
 // refuse the redirect it was meant to permit. Zero is allowed and means what it
 // means in curl -- follow none.
-func checkRedirectFlags(fs *pflag.FlagSet) {
+func checkRedirectFlags(fs *pflag.FlagSet) error {
 	follow, _ := fs.GetBool("location")
 
 	if follow {
 		for _, name := range []string{"assert-redirect", "assert-redirect-eq"} {
 			if fs.Changed(name) {
-				dief(exitBadInvocation, "Flags --location and --%s cannot be used together: "+
+				return invalidf("Flags --location and --%s cannot be used together: "+
 					"--location follows the redirect, which consumes the 3xx "+
 					"response --%s inspects", name, name)
 			}
@@ -448,16 +750,18 @@ func checkRedirectFlags(fs *pflag.FlagSet) {
 	}
 
 	if !fs.Changed("max-redirs") {
-		return
+		return nil
 	}
 	if !follow {
-		dief(exitBadInvocation, "Flag --max-redirs bounds a redirect chain that is not being "+
+		return invalidf("Flag --max-redirs bounds a redirect chain that is not being " +
 			"followed; pass --location, or drop --max-redirs")
 	}
 	if n, _ := fs.GetInt("max-redirs"); n < 0 {
-		dief(exitBadInvocation, "Invalid value for --max-redirs flag: %d; it counts redirects, "+
+		return invalidf("Invalid value for --max-redirs flag: %d; it counts redirects, "+
 			"so the smallest meaningful value is 0", n)
 	}
+
+	return nil
 }
 
 // checkRetryFlags rejects the ways the retry options can be asked for something
@@ -471,9 +775,9 @@ func checkRedirectFlags(fs *pflag.FlagSet) {
 // The test for the durations is whether --retry was named, not what it was set
 // to. `--retry ${RETRIES:-0} --retry-delay 1s` is an ordinary shape for a
 // script, and refusing it would break a caller who did nothing wrong.
-func checkRetryFlags(fs *pflag.FlagSet) {
+func checkRetryFlags(fs *pflag.FlagSet) error {
 	if n, _ := fs.GetInt("retry"); n < 0 {
-		dief(exitBadInvocation, "Invalid value for --retry flag: %d; it counts retries, so the "+
+		return invalidf("Invalid value for --retry flag: %d; it counts retries, so the "+
 			"smallest meaningful value is 0", n)
 	}
 
@@ -482,14 +786,169 @@ func checkRetryFlags(fs *pflag.FlagSet) {
 			continue
 		}
 		if !fs.Changed("retry") {
-			dief(exitBadInvocation, "Flag --%s configures retrying that is not switched on; "+
+			return invalidf("Flag --%s configures retrying that is not switched on; "+
 				"pass --retry, or drop --%s", name, name)
 		}
 		if d, _ := fs.GetDuration(name); d < 0 {
-			dief(exitBadInvocation, "Invalid value for --%s flag: %s; it is a length of time, "+
+			return invalidf("Invalid value for --%s flag: %s; it is a length of time, "+
 				"so the smallest meaningful value is 0", name, d)
 		}
 	}
+
+	return nil
+}
+
+// rootArgs takes the URL to request, or nothing when --from-response supplies
+// the response instead.
+func rootArgs(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("from-response") {
+		if len(args) > 0 {
+			return fmt.Errorf("--from-response reads the response instead of requesting %q; drop one of them", args[0])
+		}
+		return nil
+	}
+
+	return cobra.ExactArgs(1)(cmd, args)
+}
+
+// requestSettings are the root flags that shape how a request is sent, past
+// the ones registerRequestFlags adds. It is the one list of them: a suite
+// request can set each, defaulting to the command line's value, the run help
+// names them, and --from-response refuses them all.
+var requestSettings = []string{
+	"maphost", "insecure", "max-time",
+	"cert", "key", "cacert", "capath", "pinnedpubkey", "tls-min", "tls-max", "ciphers",
+	"http2", "http2-prior-knowledge", "http3", "unix-socket",
+	"proxy", "proxy-user", "noproxy", "no-proxy-env", "resolve", "dns-servers", "ipv4", "ipv6",
+	"user", "oauth2-bearer", "oauth2-bearer-env", "netrc", "netrc-file", "digest",
+	"oauth2-token-url", "oauth2-client-id", "oauth2-client-secret-file", "oauth2-scope",
+	"aws-sigv4", "hmac", "hmac-key", "hmac-key-env", "cookie", "cookie-jar",
+}
+
+// requestFlagNames are the flags registerRequestFlags adds, in its order.
+func requestFlagNames() []string {
+	fs := pflag.NewFlagSet("request", pflag.ContinueOnError)
+	fs.SortFlags = false
+	registerRequestFlags(fs)
+	var names []string
+	fs.VisitAll(func(f *pflag.Flag) { names = append(names, f.Name) })
+
+	return names
+}
+
+// fromResponseExcludes are the flags that shape a request or its sending, all
+// of which --from-response leaves nothing for.
+func fromResponseExcludes() []string {
+	return append(append(requestFlagNames(), requestSettings...), "all-addresses")
+}
+
+// checkFromResponseFlags rejects a request flag alongside --from-response. No
+// request is made, so the flag would be accepted and then do nothing -- and
+// --retry in particular would read as a promise the run never kept.
+func checkFromResponseFlags(fs *pflag.FlagSet) error {
+	if !fs.Changed("from-response") {
+		return nil
+	}
+	for _, name := range fromResponseExcludes() {
+		if fs.Changed(name) {
+			return invalidf("Flags --from-response and --%s cannot be used together; "+
+				"--%s shapes a request, and --from-response makes none", name, name)
+		}
+	}
+
+	return nil
+}
+
+// checkAllAddressesFlags rejects what --all-addresses cannot honour. A capture
+// would find one value per address and print which? A Unix socket has no
+// addresses, and a proxy picks the address itself.
+func checkAllAddressesFlags(fs *pflag.FlagSet) error {
+	if !fs.Changed("all-addresses") {
+		return nil
+	}
+	for _, name := range []string{"capture", "capture-header", "capture-body"} {
+		if fs.Changed(name) {
+			return invalidf("Flags --all-addresses and --%s cannot be used together: "+
+				"each address could answer with a different value", name)
+		}
+	}
+	if fs.Changed("unix-socket") {
+		return invalidf("Flags --all-addresses and --unix-socket cannot be used together: " +
+			"a socket has no addresses to go through")
+	}
+	if p, _ := fs.GetString("proxy"); p != "" {
+		return invalidf("Flags --all-addresses and --proxy cannot be used together: " +
+			"the proxy picks the address it connects to")
+	}
+
+	return nil
+}
+
+// runEachAddress is the run for --all-addresses: one per address, reported as
+// a suite whose requests are named after the addresses.
+func runEachAddress(c httpassert.Client, req *http.Request, assertions []httpassert.Assertion,
+	reports []reportSink, logLevel httpassert.LogLevel) {
+	results, err := c.RunEachAddress(req, assertions...)
+	saveCookieJars()
+	res := &suiteResult{}
+	for _, r := range results {
+		res.Cases = append(res.Cases, caseResult{r.Addr, r.Result})
+	}
+	writeReports(reports, func(w io.Writer, format string) error {
+		return writeSuiteReport(w, format, res)
+	})
+	if err != nil {
+		if len(results) == 0 {
+			dief(exitCodeOf(err), "Cannot perform request: %s", err)
+		}
+		dief(exitCodeOf(err), "%s", err)
+	}
+	logInfo(logLevel, "[+] PASSED all %d addresses\n", len(results))
+}
+
+// checkCaptureFlags rejects a format there is no writer for, a format with no
+// values to print, and values printed into the same stdout as a report: the
+// two would interleave, and neither eval nor a JSON parser could read the
+// result.
+func checkCaptureFlags(fs *pflag.FlagSet) error {
+	capturing := fs.Changed("capture") || fs.Changed("capture-header") || fs.Changed("capture-body")
+
+	if fs.Changed("capture-format") {
+		if f, _ := fs.GetString("capture-format"); !slices.Contains(captureFormats, f) {
+			return invalidf("Invalid value for --capture-format flag: %q; possible values: %s",
+				f, strings.Join(captureFormats, ", "))
+		}
+		if !capturing {
+			return invalidf("Flag --capture-format says how to print values that are not " +
+				"being captured; pass --capture, or drop --capture-format")
+		}
+	}
+
+	if capturing && fs.Changed("report") && !fs.Changed("report-file") {
+		return invalidf("Flags --capture and --report both write to stdout; " +
+			"pass --report-file to send the report elsewhere")
+	}
+
+	return nil
+}
+
+// checkReportFlags rejects a report nobody asked for and one in a format there
+// is no writer for. --report-file without --report is inert in the same way
+// --retry-delay is without --retry, and is refused on the same grounds.
+func checkReportFlags(fs *pflag.FlagSet) error {
+	if fs.Changed("report") {
+		if f, _ := fs.GetString("report"); !slices.Contains(reportFormats, f) {
+			return invalidf("Invalid value for --report flag: %q; possible values: %s",
+				f, strings.Join(reportFormats, ", "))
+		}
+	}
+
+	if fs.Changed("report-file") && !fs.Changed("report") {
+		return invalidf("Flag --report-file says where to write a report that is not " +
+			"being written; pass --report, or drop --report-file")
+	}
+
+	return nil
 }
 
 // Exit codes. The code answers whose fault the failure is: the invocation
@@ -506,10 +965,9 @@ const (
 	exitAssertFail    = 93 // a response arrived and at least one assertion failed
 )
 
-// exitError carries the exit category from the place a failure is understood
-// -- inside the attempt, where transport and assertion failures are told
-// apart -- to the place the process exits. It survives the retry wrapper
-// because giveUp wraps with %w and errors.As unwraps it.
+// exitError carries an exit category the CLI decided on itself -- a rejected
+// flag, a suite request that was never sent -- to the place the process exits.
+// A run's own failures are classified by the library instead (see exitCodeOf).
 type exitError struct {
 	code int
 	msg  string
@@ -517,6 +975,21 @@ type exitError struct {
 
 func (e *exitError) Error() string { return e.msg }
 
+// invalidf is dief(exitBadInvocation, ...) as a value, for the validation that
+// also runs where exiting is not the caller's decision to make: a request in a
+// suite file is checked by the same code as the command line, and the suite
+// wants to say which request it was before it gives up.
+func invalidf(format string, args ...any) error {
+	return &exitError{exitBadInvocation, fmt.Sprintf(format, args...)}
+}
+
+// dieOn exits with err's category and message, and does nothing for nil.
+func dieOn(err error) {
+	if err != nil {
+		dief(exitCodeOf(err), "%s", err)
+	}
+}
+
 // dief formats a message to stderr and terminates the process with rc.
 // ANSI colours, kept to the three the verdict needs. A 16-colour palette works
 // on everything that renders escapes at all, so there is no capability to
@@ -583,6 +1056,26 @@ func (p palette) line(s string) string {
 	return body + tail
 }
 
+// logWriter is the Client's log: stderr, each line coloured by its sigil. A
+// Client writes one line per Write, which is what line needs to see.
+type logWriter struct{ p palette }
+
+func (w logWriter) Write(b []byte) (int, error) {
+	if _, err := io.WriteString(os.Stderr, w.p.line(string(b))); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// logInfo writes one of the CLI's own sigil lines -- a suite's [#] -- to the
+// same log, at the level a Client would.
+func logInfo(level httpassert.LogLevel, format string, args ...any) {
+	if level >= httpassert.LInfo {
+		_, _ = fmt.Fprintf(logWriter{errPalette}, format, args...)
+	}
+}
+
 // isTerminal reports whether anything is watching f.
 //
 // A character device is the stdlib's answer to the question, and it is the
@@ -632,27 +1125,25 @@ func mustSetPalette(cmd *cobra.Command) {
 }
 
 func dief(rc int, format string, args ...interface{}) {
+	errorf(format, args...)
+	os.Exit(rc)
+}
+
+// errorf writes the line dief writes, and leaves the process running: a failed
+// request in a suite is reported the way a failed run is, and then the next
+// request is sent.
+func errorf(format string, args ...interface{}) {
 	if !strings.HasSuffix(format, "\n") {
 		format += "\n"
 	}
 	fmt.Fprintf(os.Stderr, "\n%s "+format, append([]interface{}{errPalette.wrap(ansiRed, "Error:")}, args...)...)
-	os.Exit(rc)
 }
 
-type LogLevel int
-
-const (
-	LError LogLevel = iota
-	LWarn
-	LInfo
-	LDebug
-)
-
 // levelRequest is one verbosity option asking for a level, named the way the
 // caller spelled it so a conflict warning can point at the right surface.
 type levelRequest struct {
 	name  string
-	level LogLevel
+	level httpassert.LogLevel
 }
 
 // levelRequests returns the options that ask for a log level on one channel:
@@ -673,12 +1164,12 @@ func levelRequests(fs *pflag.FlagSet, fromCLI bool) []levelRequest {
 	var res []levelRequest
 	if fs.Changed("silent") == fromCLI {
 		if v, _ := fs.GetBool("silent"); v {
-			res = append(res, levelRequest{name("-s", "HTTP_ASSERT_SILENT"), LError})
+			res = append(res, levelRequest{name("-s", "HTTP_ASSERT_SILENT"), httpassert.LError})
 		}
 	}
 	if fs.Changed("verbose") == fromCLI {
 		if v, _ := fs.GetBool("verbose"); v {
-			res = append(res, levelRequest{name("-v", "HTTP_ASSERT_VERBOSE"), LDebug})
+			res = append(res, levelRequest{name("-v", "HTTP_ASSERT_VERBOSE"), httpassert.LDebug})
 		}
 	}
 	if fs.Changed("log-level") == fromCLI {
@@ -702,7 +1193,7 @@ func levelRequests(fs *pflag.FlagSet, fromCLI bool) []levelRequest {
 // The announcement goes straight to stderr rather than through the logger:
 // a warn-severity log line would be suppressed by the very level it reports,
 // so `-v -s` would resolve to silent and swallow its own explanation.
-func mustParseLogLevel(cmd *cobra.Command) LogLevel {
+func mustParseLogLevel(cmd *cobra.Command) httpassert.LogLevel {
 	fs := cmd.Flags()
 	// The command-line requests come first, so the head of the combined list
 	// is the overall winner and the environment's requests can only lead when
@@ -710,7 +1201,7 @@ func mustParseLogLevel(cmd *cobra.Command) LogLevel {
 	// list is what makes a cross-channel override visible in the warning.
 	reqs := append(levelRequests(fs, true), levelRequests(fs, false)...)
 	if len(reqs) == 0 {
-		return LInfo
+		return httpassert.LInfo
 	}
 
 	winner := reqs[0]
@@ -722,22 +1213,22 @@ func mustParseLogLevel(cmd *cobra.Command) LogLevel {
 	return winner.level
 }
 
-func parseLogLevel(s string) (LogLevel, bool) {
+func parseLogLevel(s string) (httpassert.LogLevel, bool) {
 	switch s {
 	case "error":
-		return LError, true
+		return httpassert.LError, true
 	case "warn":
-		return LWarn, true
+		return httpassert.LWarn, true
 	case "info":
-		return LInfo, true
+		return httpassert.LInfo, true
 	case "debug":
-		return LDebug, true
+		return httpassert.LDebug, true
 	default:
 		return 0, false
 	}
 }
 
-// mustParseRequestHeader parses a -H value, refusing the two forms that would
+// parseRequestHeader parses a -H value, refusing the two forms that would
 // put a header on the wire the caller did not describe.
 //
 // parseHeaderLine is shared with --assert-header*, where a name on its own is
@@ -749,32 +1240,37 @@ func parseLogLevel(s string) (LogLevel, bool) {
 //
 // The validation lives here rather than in the parser for that reason: the
 // parser is right for one caller and wrong for the other.
-func mustParseRequestHeader(v string) (name, value string) {
+func parseRequestHeader(v string) (name, value string, err error) {
 	if !strings.Contains(v, ":") {
-		dief(exitBadInvocation, "Invalid value for --header flag: %q has no ':' separator; "+
+		return "", "", invalidf("Invalid value for --header flag: %q has no ':' separator; "+
 			"write %q to send the header with an empty value", v, v+":")
 	}
 
 	name, value = parseHeaderLine(v)
 	if name == "" {
-		dief(exitBadInvocation, "Invalid value for --header flag: %q has no header name "+
+		return "", "", invalidf("Invalid value for --header flag: %q has no header name "+
 			"before the ':'", v)
 	}
 
-	return name, value
+	return name, value, nil
 }
 
-func mustParseHostMappings(vals []string) []hostMapping {
+// hostMappingsFlag is parseHostMappings with the error a --maphost value gets.
+func hostMappingsFlag(vals []string) ([]httpassert.HostMapping, error) {
 	res, err := parseHostMappings(vals)
 	if err != nil {
-		dief(exitBadInvocation, "Invalid value for --maphost flag: %s", vals)
+		return nil, invalidf("Invalid value for --maphost flag: %s", err)
 	}
 
-	return res
+	return res, nil
 }
 
-func parseHostMappings(vals []string) ([]hostMapping, error) {
-	var res []hostMapping
+// parseHostMappings reads --maphost values, src=dst[,dst]...: src is
+// host:port, where the host may be a glob or a CIDR block and either part *,
+// or * alone; each dst is a host with an optional port, an IPv6 address in
+// brackets.
+func parseHostMappings(vals []string) ([]httpassert.HostMapping, error) {
+	var res []httpassert.HostMapping
 
 	for _, v := range vals {
 		// format: srchostname:srcport=dsthostname:dstport
@@ -784,813 +1280,1281 @@ func parseHostMappings(vals []string) ([]hostMapping, error) {
 		}
 
 		srchost, dsthost := v[:i], v[i+1:]
-		if j := strings.Index(srchost, ":"); j < 0 {
-			return nil, fmt.Errorf("value %q has no src port %q", v, srchost)
-		} else if _, err := strconv.Atoi(srchost[j+1:]); err != nil {
-			return nil, fmt.Errorf("value %q has invalid src port %q", v, srchost[j+1:])
-		} else if k := strings.Index(dsthost, ":"); k > 0 {
-			if _, err := strconv.Atoi(dsthost[k+1:]); err != nil {
-				return nil, fmt.Errorf("value %q has invalid dst port %q", v, dsthost[k+1:])
+		if strings.Contains(dsthost, "=") {
+			// HTTP_ASSERT_MAPHOST="a:80=b,c:80=d" reads as one mapping.
+			return nil, fmt.Errorf("value %q holds more than one mapping; "+
+				"repeat --maphost, or separate them with spaces", v)
+		}
+		if err := checkMapSrc(srchost); err != nil {
+			return nil, fmt.Errorf("value %q %w", v, err)
+		}
+		for _, dst := range strings.Split(dsthost, ",") {
+			if err := checkMapDst(dst); err != nil {
+				return nil, fmt.Errorf("value %q %w", v, err)
 			}
 		}
 
-		res = append(res, hostMapping{Src: srchost, Dst: dsthost})
+		res = append(res, httpassert.HostMapping{Src: srchost, Dst: dsthost})
 	}
 
 	return res, nil
 }
 
-func registerAssertionFlags(cmd *cobra.Command) {
-	cmd.Flags().String("assert-status", "",
-		"Assert response status; a code, a class like 2xx, a range like 401-403, or a list of those")
-	cmd.Flags().StringArray("assert-header", nil,
-		"Assert any value of the header matches the provided regexp; NAME alone asserts it is present")
-	cmd.Flags().StringArray("assert-header-eq", nil,
-		"Assert any value of the header equals the provided value; NAME alone asserts it is present")
-	cmd.Flags().StringArray("assert-header-missing", nil, "Assert header is missing")
-	cmd.Flags().String("assert-body", "", "Assert body matches the provided regexp")
-	cmd.Flags().String("assert-body-eq", "", "Assert body equals the provided value")
-	cmd.Flags().Bool("assert-body-empty", false,
-		"Assert body is empty; =false asserts it is not")
-	cmd.Flags().StringArray("assert-jq", nil,
-		"Assert the jq expression yields true; repeat to assert several")
+// checkMapSrc rejects a --maphost source HostMapping.Matches could never
+// match.
+func checkMapSrc(src string) error {
+	if src == "*" {
+		return nil
+	}
+	// Split as HostMapping.Matches splits it: the brackets of a glob, as in
+	// api-[0-9].example.com, are not an IPv6 address's.
+	var host, port string
+	var ok bool
+	if strings.HasPrefix(src, "[") {
+		h, p, err := net.SplitHostPort(src)
+		host, port, ok = h, p, err == nil
+	} else {
+		host, port, ok = strings.Cut(src, ":")
+		ok = ok && !strings.Contains(port, ":")
+	}
+	switch {
+	case !strings.Contains(src, ":"):
+		return fmt.Errorf("has no src port %q", src)
+	case !ok:
+		return fmt.Errorf("has invalid src %q; write an IPv6 address in brackets", src)
+	case host == "":
+		return fmt.Errorf("has no src host %q", src)
+	case port != "*" && !validPort(port):
+		return fmt.Errorf("has invalid src port %q", port)
+	}
+	if strings.Contains(host, "/") {
+		if _, _, err := net.ParseCIDR(host); err != nil {
+			return fmt.Errorf("has invalid src CIDR block %q", host)
+		}
+	} else if _, err := path.Match(host, ""); err != nil {
+		return fmt.Errorf("has invalid src pattern %q", host)
+	}
 
-	// Common shorthands
-	cmd.Flags().Bool("assert-ok", false,
-		"Assert response status is not an error (2xx or 3xx); =false asserts it is")
-	cmd.Flags().String("assert-redirect", "",
-		"Assert redirect location matches the provided regexp; redirects are not followed")
-	cmd.Flags().String("assert-redirect-eq", "",
-		"Assert redirect location equals the provided URL; redirects are not followed")
+	return nil
 }
 
-// singleValue wraps a flag that holds one value, counting how many times the
-// parser assigned to it.
-//
-// pflag records only whether a flag was set, never how often, so a second
-// occurrence of a scalar flag overwrites the first and leaves nothing behind
-// to notice. For an assertion that means the run goes green having checked
-// less than it was asked to (#35). The count is the missing evidence.
-type singleValue struct {
-	inner pflag.Value
-	count int
+// checkMapDst rejects a --maphost destination that could not be dialled.
+func checkMapDst(dst string) error {
+	if dst == "" {
+		return fmt.Errorf("has an empty dst")
+	}
+	if strings.HasPrefix(dst, "[") && strings.HasSuffix(dst, "]") {
+		if net.ParseIP(dst[1:len(dst)-1]) == nil {
+			return fmt.Errorf("has invalid dst %q", dst)
+		}
+		return nil
+	}
+	if !strings.Contains(dst, ":") && !strings.Contains(dst, "[") {
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(dst)
+	switch {
+	case err != nil:
+		return fmt.Errorf("has invalid dst %q; write an IPv6 address in brackets", dst)
+	case host == "":
+		return fmt.Errorf("has no dst host %q", dst)
+	case !validPort(port):
+		return fmt.Errorf("has invalid dst port %q", port)
+	}
+
+	return nil
 }
 
-func (v *singleValue) Set(s string) error { v.count++; return v.inner.Set(s) }
-func (v *singleValue) String() string     { return v.inner.String() }
-func (v *singleValue) Type() string       { return v.inner.Type() }
+// validPort reports whether s is a port number.
+func validPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 0 && n <= 65535
+}
 
-// collects reports whether a flag type accumulates its values rather than
-// replacing them. Repeating those is how you ask for several assertions.
-func collects(flagType string) bool {
-	return strings.HasSuffix(flagType, "Array") || strings.HasSuffix(flagType, "Slice")
+// registerRequestFlags declares the options that describe one request: what to
+// send, and how hard to try. They are what a request in a suite file can set,
+// alongside the assertion flags.
+// registerRootFlags adds the flags that apply to the single request and to
+// every request of a suite alike.
+func registerRootFlags(fs *pflag.FlagSet) {
+	// Deviations from curl's --resolve:
+	// - use `=` to separate src and dst
+	// - add [:dstport]
+	fs.StringArray("maphost", nil,
+		"Provide a custom address for a specific host and port pair; "+
+			"e.g. <srchostname:srcport=dsthostname[:dstport]>; globs, CIDR blocks and * match several, "+
+			"and a comma-separated dst list is rotated per attempt")
+	fs.StringArray("resolve", nil,
+		"Connect to host:port at these addresses instead of asking DNS: host:port:addr[,addr]...")
+	fs.String("dns-servers", "",
+		"Comma-separated DNS servers to ask instead of the system's, as ip[:port]")
+	fs.BoolP("ipv4", "4", false, "Connect over IPv4 only")
+	fs.BoolP("ipv6", "6", false, "Connect over IPv6 only")
+	fs.String("unix-socket", "",
+		"Connect through this Unix domain socket instead of to the URL's host")
+	fs.String("proxy", "",
+		"Send requests through this proxy: [http|https|socks5|socks5h://][user:password@]host[:port]")
+	fs.String("proxy-user", "", "Credentials for --proxy, as user:password")
+	fs.String("noproxy", "",
+		"Comma-separated hosts, domains and CIDR blocks to reach without a proxy; * for all")
+	fs.Bool("no-proxy-env", false, "Ignore HTTP_PROXY, HTTPS_PROXY and NO_PROXY")
+	fs.StringP("user", "u", "", "Send these credentials with HTTP Basic authentication, as user:password")
+	fs.String("oauth2-bearer", "",
+		"Send this OAuth 2.0 bearer token; @FILE reads it from a file")
+	fs.String("oauth2-bearer-env", "",
+		"Send the OAuth 2.0 bearer token held in this environment variable")
+	fs.Bool("netrc", false,
+		"Take credentials for the host from ~/.netrc, or the file $NETRC names")
+	fs.String("netrc-file", "", "Take credentials for the host from this .netrc file")
+	fs.String("oauth2-token-url", "",
+		"Get the bearer token from this OAuth 2.0 token endpoint, with the client credentials grant")
+	fs.String("oauth2-client-id", "", "The client ID for --oauth2-token-url")
+	fs.String("oauth2-client-secret-file", "",
+		"Read the client secret for --oauth2-token-url from this file")
+	fs.String("oauth2-scope", "", "Ask --oauth2-token-url for this scope; separate several with spaces")
+	fs.Bool("digest", false,
+		"Send the -u or .netrc credentials with HTTP Digest authentication, in answer to a 401")
+	fs.String("aws-sigv4", "",
+		"Sign each attempt with AWS Signature Version 4, as provider:region:service; "+
+			"credentials come from $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY")
+	fs.String("hmac", "",
+		"Sign each attempt's body with an HMAC, as algorithm:header[:timestamp-header]")
+	fs.String("hmac-key", "", "The key for --hmac; @FILE reads it from a file")
+	fs.String("hmac-key-env", "", "Take the key for --hmac from this environment variable")
+	fs.StringP("cookie", "b", "",
+		"Send these cookies, as name=value; name=value, or start from this Netscape cookie file; "+
+			"either way, keep the cookies responses set")
+	fs.StringP("cookie-jar", "c", "",
+		"Write the cookies held at the end of the run to this file, as a Netscape cookie file")
+	fs.BoolP("verbose", "v", false,
+		"Be verbose; log debug messages (same as --log-level debug; overrides --log-level)")
+	fs.BoolP("silent", "s", false,
+		"Be silent; log error messages only (same as --log-level error; overrides -v)")
+	fs.String("log-level", "",
+		"Set log level; possible values: debug, info (default), warn, error")
+	fs.String("color", "auto",
+		"Colour the verdict; possible values: auto (default), always, never")
+	fs.BoolP("insecure", "k", false, "Disable checking SSL certificates")
+	fs.String("cert", "",
+		"Present this client certificate, PEM or PKCS#12; write <file:password> for an encrypted PKCS#12 file")
+	fs.String("key", "",
+		"Private key for a PEM --cert, when the certificate file does not hold it")
+	fs.String("cacert", "",
+		"Verify the server against the CA certificates in this file, PEM or PKCS#12, instead of the system's")
+	fs.String("capath", "",
+		"Verify the server against the PEM CA certificates in this directory, instead of the system's")
+	fs.String("pinnedpubkey", "",
+		"Fail unless the server's public key matches; sha256//<base64>[;sha256//...] or a public key file")
+	fs.String("tls-min", "", "Lowest TLS version to offer; possible values: 1.0, 1.1, 1.2, 1.3")
+	fs.String("tls-max", "", "Highest TLS version to offer; possible values: 1.0, 1.1, 1.2, 1.3")
+	fs.String("ciphers", "",
+		"Offer only these TLS 1.0-1.2 cipher suites, by IANA name, separated by commas")
+	fs.Bool("http2", false,
+		"Offer HTTP/2 in the TLS handshake, and speak it if the server agrees")
+	fs.Bool("http2-prior-knowledge", false,
+		"Speak HTTP/2 without negotiating for it: h2c for http://, h2 alone for https://")
+	fs.Bool("http3", false,
+		"Send the request over QUIC as HTTP/3, with no fallback to TCP; needs https://")
+	fs.IntP("max-time", "m", 20,
+		"Maximum time in seconds that you allow each request to take")
 }
 
-// rejectRepeats prepares every single-valued assertion flag to notice a repeat.
-//
-// Derived from the flag's own type rather than a list, because a list is how
-// this went wrong in the first place: --assert-header was made repeatable and
-// the others were not, and nothing connected the two decisions. An assertion
-// flag added later is covered the day it is added.
-func rejectRepeats(fs *pflag.FlagSet) {
-	fs.VisitAll(func(f *pflag.Flag) {
-		if strings.HasPrefix(f.Name, "assert-") && !collects(f.Value.Type()) {
-			f.Value = &singleValue{inner: f.Value}
-		}
-	})
+func registerRequestFlags(fs *pflag.FlagSet) {
+	fs.StringP("request", "X", "GET",
+		"Set method for HTTP request; overrides the POST that -d implies")
+	fs.StringArrayP("header", "H", nil,
+		"Set header for HTTP request, as <name: value>; a name alone is rejected")
+	fs.StringP("data", "d", "",
+		"Sends the specified data in a POST request to the HTTP server, as it is given")
+	fs.String("data-binary", "",
+		"Send this data as the body, or the contents of @FILE, or stdin for @-")
+	fs.StringArray("data-urlencode", nil,
+		"Send name=value URL-encoded as the body; repeat to join several with &")
+	fs.String("data-file", "", "Send the contents of this file as the body; - reads stdin")
+	fs.BoolP("location", "L", false,
+		"Follow redirects; assertions then apply to the end of the chain")
+	fs.Int("max-redirs", 10,
+		"Maximum number of redirects to follow; requires --location")
+	fs.Int("retry", 0,
+		"Number of times to retry a failed attempt; 0 makes the request once")
+	fs.Duration("retry-delay", time.Second,
+		"Fixed delay between attempts, e.g. 1s or 250ms; requires --retry")
+	fs.Duration("retry-max-time", 0,
+		"Stop retrying after this long; 0 means only --retry bounds it; requires --retry")
 }
 
-// checkRepeats terminates when an assertion was named more than once. Taking
-// the last value silently is the one outcome worth refusing: the alternative
-// is a tool that reports success for a check it never ran.
-func checkRepeats(fs *pflag.FlagSet) {
-	fs.VisitAll(func(f *pflag.Flag) {
-		if v, ok := f.Value.(*singleValue); ok && v.count > 1 {
-			dief(exitBadInvocation, "Flag --%s was given %d times but accepts a single value; "+
-				"repeat --assert-header, --assert-header-eq or --assert-header-missing "+
-				"to make several assertions", f.Name, v.count)
-		}
-	})
+func registerReportFlags(fs *pflag.FlagSet) {
+	fs.String("report", "",
+		"Write a machine-readable report of the run; possible values: json, tap")
+	fs.String("report-file", "",
+		"Write the --report to this file instead of stdout; requires --report")
+	fs.String("junit", "",
+		"Write a JUnit XML report of the run to this file, one test case per assertion")
 }
 
-// mustCompileAssertion builds a pattern-based assertion, reporting an
-// unparseable pattern the way every other invalid flag value is reported.
-//
-// Without this the pattern reached regexp.MustCompile and the process died with
-// a stack trace and exit code 2, which is not part of the documented contract
-// and gave the user no idea which flag was at fault (#17).
-func mustCompileAssertion(flag, pattern string, build func(string) (Assertion, error)) Assertion {
-	a, err := build(pattern)
+// newClient configures a Client from the request and connection flags. The
+// log level and the palette are the caller's to set: they describe the
+// process, not the request.
+func newClient(fs *pflag.FlagSet) (httpassert.Client, error) {
+	insecure, _ := fs.GetBool("insecure")
+	maxTime, _ := fs.GetInt("max-time")
+	maphost, _ := fs.GetStringArray("maphost")
+	location, _ := fs.GetBool("location")
+	maxRedirs, _ := fs.GetInt("max-redirs")
+	retry, _ := fs.GetInt("retry")
+	retryDelay, _ := fs.GetDuration("retry-delay")
+	retryMaxTime, _ := fs.GetDuration("retry-max-time")
+
+	mappings, err := hostMappingsFlag(maphost)
 	if err != nil {
-		dief(exitBadInvocation, "Invalid value for %s flag: %s", flag, err)
+		return httpassert.Client{}, err
+	}
+
+	c := httpassert.Client{
+		SkipSslChecks:   insecure,
+		Timeout:         time.Duration(maxTime) * time.Second,
+		HostMappings:    mappings,
+		FollowRedirects: location,
+		MaxRedirects:    maxRedirs,
+		Retries:         retry,
+		RetryDelay:      retryDelay,
+		RetryMaxTime:    retryMaxTime,
+	}
+	if err := tlsFilesFlags(fs, &c); err != nil {
+		return httpassert.Client{}, err
+	}
+	if err := tlsPolicyFlags(fs, &c); err != nil {
+		return httpassert.Client{}, err
+	}
+	if err := protocolFlags(fs, &c); err != nil {
+		return httpassert.Client{}, err
+	}
+	if err := resolveFlags(fs, &c); err != nil {
+		return httpassert.Client{}, err
+	}
+	if err := unixSocketFlag(fs, &c); err != nil {
+		return httpassert.Client{}, err
+	}
+	if err := proxyFlags(fs, &c); err != nil {
+		return httpassert.Client{}, err
+	}
+	if err := authFlags(fs, &c); err != nil {
+		return httpassert.Client{}, err
+	}
+	if err := oauth2Flags(fs, &c); err != nil {
+		return httpassert.Client{}, err
+	}
+	if err := signFlags(fs, &c); err != nil {
+		return httpassert.Client{}, err
+	}
+	if err := cookieFlags(fs, &c); err != nil {
+		return httpassert.Client{}, err
 	}
 
-	return a
+	return c, nil
 }
 
-// boolAssertion turns a boolean assertion flag into the assertion it asks for.
-//
-// A boolean assertion has two of them, and =false selects the second rather
-// than cancelling the flag: --assert-ok asserts the status is not an error,
-// --assert-ok=false asserts that it is. Naming an assertion and getting no
-// assertion would be the one outcome worth refusing, and it is what
-// --assert-body-empty=false used to do -- the run died with "no assertions
-// defined" after the user had named one (#32).
-//
-// The pairing lives here, once, rather than being written out per flag. The two
-// flags drifted apart because nothing connected them; a helper is what connects
-// them, in the same way rejectRepeats derives from the flag's type rather than
-// from a list.
-func boolAssertion(cmd *cobra.Command, name string, whenTrue, whenFalse func() Assertion) []Assertion {
-	if !cmd.Flags().Changed(name) {
+// unixSocketFlag reads --unix-socket. A socket that is missing is not checked
+// for here: that is the daemon being down, which is what the run reports, with
+// the transport's exit code.
+func unixSocketFlag(fs *pflag.FlagSet, c *httpassert.Client) error {
+	c.UnixSocket, _ = fs.GetString("unix-socket")
+	if c.UnixSocket == "" {
 		return nil
 	}
 
-	if v, _ := cmd.Flags().GetBool(name); v {
-		return []Assertion{whenTrue()}
+	// Each of these picks where to connect as well, and would be ignored.
+	switch {
+	case len(c.HostMappings) > 0:
+		return invalidf("Flags --unix-socket and --maphost cannot be used together: " +
+			"both say where to connect, and the socket replaces the host")
+	case c.HTTP3:
+		return invalidf("Flags --unix-socket and --http3 cannot be used together: QUIC runs over UDP")
+	}
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"resolve", len(c.Resolve) > 0},
+		{"dns-servers", len(c.DNSServers) > 0},
+		{"ipv4", c.IPVersion == 4},
+		{"ipv6", c.IPVersion == 6},
+	} {
+		if f.set {
+			return invalidf("Flags --unix-socket and --%s cannot be used together: "+
+				"a socket has no address to resolve", f.name)
+		}
 	}
 
-	return []Assertion{whenFalse()}
+	return nil
 }
 
-func parseAssertionFlags(cmd *cobra.Command) []Assertion {
-	var res []Assertion
-
-	res = append(res, boolAssertion(cmd, "assert-ok", AssertStatusOK, AssertStatusNOK)...)
-	res = append(res, boolAssertion(cmd, "assert-body-empty", AssertBodyEmpty, AssertBodyNotEmpty)...)
-
-	if cmd.Flags().Changed("assert-redirect") {
-		v, _ := cmd.Flags().GetString("assert-redirect")
-		res = append(res, mustCompileAssertion("--assert-redirect", v, AssertRedirectMatch))
-	}
-	if cmd.Flags().Changed("assert-redirect-eq") {
-		v, _ := cmd.Flags().GetString("assert-redirect-eq")
-		res = append(res, AssertRedirectEqual(v))
+// resolveFlags reads --resolve, --dns-servers, -4 and -6.
+func resolveFlags(fs *pflag.FlagSet, c *httpassert.Client) error {
+	entries, _ := fs.GetStringArray("resolve")
+	for _, v := range entries {
+		e, err := httpassert.ParseResolve(v)
+		if err != nil {
+			return invalidf("Invalid value for --resolve flag: %s", err)
+		}
+		c.Resolve = append(c.Resolve, e)
 	}
 
-	if cmd.Flags().Changed("assert-status") {
-		v, _ := cmd.Flags().GetString("assert-status")
-		spec, err := parseStatusSpec(v)
+	if v, _ := fs.GetString("dns-servers"); v != "" {
+		servers, err := httpassert.ParseDNSServers(v)
 		if err != nil {
-			dief(exitBadInvocation, "Invalid value for --assert-status flag: %s", err)
+			return invalidf("Invalid value for --dns-servers flag: %s", err)
 		}
-		res = append(res, AssertStatus(spec))
+		c.DNSServers = servers
 	}
 
-	if cmd.Flags().Changed("assert-header") {
-		vs, _ := cmd.Flags().GetStringArray("assert-header")
-		res = append(res, parseHeaderAssertions(vs, false)...)
+	v4, _ := fs.GetBool("ipv4")
+	v6, _ := fs.GetBool("ipv6")
+	switch {
+	case v4 && v6:
+		return invalidf("Flags --ipv4 and --ipv6 cannot be used together: each allows only its own address family")
+	case v4:
+		c.IPVersion = 4
+	case v6:
+		c.IPVersion = 6
 	}
-	if cmd.Flags().Changed("assert-header-eq") {
-		vs, _ := cmd.Flags().GetStringArray("assert-header-eq")
-		res = append(res, parseHeaderAssertions(vs, true)...)
+
+	return nil
+}
+
+// proxyFlags reads --proxy, --proxy-user, --noproxy and --no-proxy-env. An
+// empty --proxy is curl's way of saying no proxy at all, so it means what
+// --no-proxy-env means.
+func proxyFlags(fs *pflag.FlagSet, c *httpassert.Client) error {
+	c.NoProxyEnv, _ = fs.GetBool("no-proxy-env")
+	if v, _ := fs.GetString("noproxy"); v != "" {
+		for _, host := range strings.Split(v, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				c.NoProxy = append(c.NoProxy, host)
+			}
+		}
 	}
-	if cmd.Flags().Changed("assert-header-missing") {
-		vs, _ := cmd.Flags().GetStringArray("assert-header-missing")
-		for _, v := range vs {
-			res = append(res, AssertHeaderMissing(strings.TrimSpace(v)))
+
+	proxy, _ := fs.GetString("proxy")
+	user, _ := fs.GetString("proxy-user")
+	if proxy == "" {
+		if fs.Changed("proxy") {
+			c.NoProxyEnv = true
+		}
+		if user != "" {
+			return invalidf("Flag --proxy-user needs --proxy; " +
+				"the credentials would otherwise go to whichever proxy the environment names")
 		}
+		return nil
 	}
 
-	if cmd.Flags().Changed("assert-body") {
-		v, _ := cmd.Flags().GetString("assert-body")
-		res = append(res, mustCompileAssertion("--assert-body", v, AssertBodyMatch))
+	var err error
+	if c.Proxy, err = httpassert.ParseProxy(proxy); err != nil {
+		return invalidf("Invalid value for --proxy flag: %s", err)
 	}
-	if cmd.Flags().Changed("assert-body-eq") {
-		v, _ := cmd.Flags().GetString("assert-body-eq")
-		res = append(res, AssertBodyEqual(v))
+	if user != "" {
+		name, password, ok := strings.Cut(user, ":")
+		if !ok {
+			return invalidf("Invalid value for --proxy-user flag: write user:password")
+		}
+		c.Proxy.User = url.UserPassword(name, password)
 	}
 
-	// One assertion per occurrence. --assert-jq is a stringArray, so rejectRepeats
-	// leaves it alone and repeating it accumulates, exactly as it does for the
-	// three header assertions.
-	if cmd.Flags().Changed("assert-jq") {
-		vs, _ := cmd.Flags().GetStringArray("assert-jq")
-		for _, v := range vs {
-			res = append(res, mustCompileAssertion("--assert-jq", v, AssertJQ))
-		}
+	switch {
+	case c.UnixSocket != "":
+		return invalidf("Flags --proxy and --unix-socket cannot be used together: " +
+			"both say where to connect")
+	case c.HTTP3:
+		return invalidf("Flags --proxy and --http3 cannot be used together: QUIC is not proxied")
 	}
 
-	return res
+	return nil
 }
 
-func parseHeaderAssertions(vs []string, exactMatch bool) []Assertion {
-	var res []Assertion
+// authFlags reads -u, --oauth2-bearer, --oauth2-bearer-env, --netrc,
+// --netrc-file and --digest. -u and a token are refused together, since only
+// one of them can be the Authorization header; a .netrc stands behind either,
+// as curl's does, and supplies the hosts they leave bare.
+func authFlags(fs *pflag.FlagSet, c *httpassert.Client) error {
+	user, _ := fs.GetString("user")
+	bearer, _ := fs.GetString("oauth2-bearer")
+	bearerEnv, _ := fs.GetString("oauth2-bearer-env")
+	netrc, _ := fs.GetBool("netrc")
+	netrcFile, _ := fs.GetString("netrc-file")
+	c.Digest, _ = fs.GetBool("digest")
 
-	for _, v := range vs {
-		name, value := parseHeaderLine(v)
-		if exactMatch {
-			if value == "" {
-				res = append(res, AssertHeaderPresent(name))
-			} else {
-				res = append(res, AssertHeaderEqual(name, value))
-			}
-		} else {
-			if value == "" {
-				res = append(res, AssertHeaderPresent(name))
-			} else {
-				res = append(res, mustCompileAssertion("--assert-header", value,
-					func(p string) (Assertion, error) { return AssertHeaderMatch(name, p) }))
+	switch {
+	case user != "" && (bearer != "" || bearerEnv != ""):
+		return invalidf("Flags --user and --oauth2-bearer cannot be used together: " +
+			"both are the Authorization header")
+	case bearer != "" && bearerEnv != "":
+		return invalidf("Flags --oauth2-bearer and --oauth2-bearer-env cannot be used together: " +
+			"both name the token")
+	case c.Digest && (bearer != "" || bearerEnv != ""):
+		return invalidf("Flags --digest and --oauth2-bearer cannot be used together: " +
+			"a bearer token is not answered to a challenge")
+	// Without credentials the 401 would be the answer, and the run would
+	// fail on something the command line could have said.
+	case c.Digest && user == "" && !netrc && netrcFile == "":
+		return invalidf("Flag --digest needs credentials: give -u user:password, --netrc or --netrc-file")
+	}
+
+	if user != "" {
+		name, password, ok := strings.Cut(user, ":")
+		if !ok {
+			// curl would prompt for the password, and nothing here can.
+			return invalidf("Invalid value for --user flag: write user:password")
+		}
+		c.User = url.UserPassword(name, password)
+	}
+
+	var err error
+	if c.BearerToken, err = secretFlags(fs, "oauth2-bearer", "oauth2-bearer-env"); err != nil {
+		return err
+	}
+
+	if netrc && netrcFile == "" {
+		netrcFile = os.Getenv("NETRC")
+		if netrcFile == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return invalidf("Flag --netrc finds no home directory: %s", err)
 			}
+			netrcFile = filepath.Join(home, ".netrc")
+		}
+	}
+	if netrcFile != "" {
+		data, err := os.ReadFile(netrcFile)
+		if err != nil {
+			return invalidf("Cannot read the .netrc file: %s", err)
+		}
+		if c.Netrc, err = httpassert.ParseNetrc(string(data)); err != nil {
+			return invalidf("Cannot read the .netrc file %s: %s", netrcFile, err)
 		}
 	}
 
-	return res
+	return nil
 }
 
-type Client struct {
-	LogLevel LogLevel
-	// Palette colours the sigil lines. The zero value writes none.
-	Palette       palette
-	SkipSslChecks bool
-	Timeout       time.Duration
-	HostMappings  []hostMapping
-	// FollowRedirects turns a 3xx into another request rather than the
-	// response the assertions run against.
-	FollowRedirects bool
-	// MaxRedirects bounds the chain. Zero refuses every redirect; it is only
-	// read when FollowRedirects is set.
-	MaxRedirects int
-	// Retries is how many further attempts may follow a failed one. Zero makes
-	// the request once, which is the default and the behaviour that predates
-	// retrying.
-	Retries int
-	// RetryDelay is the fixed wait between attempts. Only read when Retries is
-	// positive.
-	RetryDelay time.Duration
-	// RetryMaxTime bounds the whole run, measured from the first attempt. Zero
-	// means only Retries bounds it. It is checked before each retry rather than
-	// enforced mid-flight, so an attempt already under way can overrun it by up
-	// to one Timeout -- which is curl's meaning for the same option.
-	RetryMaxTime time.Duration
-}
-
-func (c *Client) Init() {
-	// Just print the configuration
-	if len(c.HostMappings) > 0 {
-		c.logDebug("HostMappings %d:\n", len(c.HostMappings))
-		for i := range c.HostMappings {
-			c.logDebug("- %q -> %q\n", c.HostMappings[i].Src, c.HostMappings[i].Dst)
-		}
-	}
-}
-
-// errTooManyRedirects marks the one transport-shaped failure this program
-// produces itself. Do needs to tell it apart from a network failure, and
-// matching on net/http's message text would be a promise net/http never made.
-var errTooManyRedirects = errors.New("too many redirects")
-
-// Do makes the request and checks the response against every assertion,
-// retrying a failed attempt up to Retries times.
-//
-// Any failure is retried, an unreachable host and a wrong answer alike. The
-// case retrying exists for is waiting for a service to come up, and there the
-// response usually arrives perfectly well and says the wrong thing, so a rule
-// that retried only transport errors would miss the whole point.
-func (c Client) Do(req *http.Request, assertions ...Assertion) error {
-	if len(assertions) == 0 {
-		// Not a failed attempt but a malformed invocation, so it is reported
-		// once rather than retried into the ground. The CLI checks this before
-		// calling; the guard backstops any other caller.
-		return &exitError{exitBadInvocation, "no assertions defined"}
-	}
-
-	// Built once rather than per attempt: an http.Transport owns an idle
-	// connection pool, and a fresh one per attempt would leave --retry 100 of
-	// them behind for the lifetime of the process.
-	client := c.getHttpClient()
-	startedAt := time.Now()
-
-	for attempt := 1; ; attempt++ {
-		err := c.doOnce(client, req, assertions)
-		if err == nil {
-			return nil
-		}
-
-		switch {
-		case attempt > c.Retries:
-			return c.giveUp(attempt, "", err)
-		// Checked before sleeping rather than after, so the run ends at the
-		// budget instead of one delay past it.
-		case c.RetryMaxTime > 0 && time.Since(startedAt)+c.RetryDelay > c.RetryMaxTime:
-			return c.giveUp(attempt, "--retry-max-time is "+c.RetryMaxTime.String(), err)
-		}
-
-		c.logInfo("[~] retry %d/%d in %s\n", attempt, c.Retries, c.RetryDelay)
-		time.Sleep(c.RetryDelay)
-	}
-}
-
-// giveUp reports the last failure together with how the run ended.
-//
-// Without the prefix a CI log shows a single failed attempt and no sign that
-// five more happened, which reads as a service that was never up rather than
-// one that never came up. Nothing is added when nothing was retried, so a plain
-// run says exactly what it always said.
-func (c Client) giveUp(attempts int, limit string, err error) error {
-	if c.Retries <= 0 {
-		return err
-	}
+// secretFlags reads a secret given as the value of flag, from the file
+// @FILE names, or from the variable envFlag names.
+func secretFlags(fs *pflag.FlagSet, flag, envFlag string) (string, error) {
+	value, _ := fs.GetString(flag)
+	env, _ := fs.GetString(envFlag)
 
-	if limit != "" {
-		limit = " (" + limit + ")"
+	switch {
+	case strings.HasPrefix(value, "@"):
+		data, err := os.ReadFile(value[1:])
+		if err != nil {
+			return "", invalidf("Invalid value for --%s flag: %s", flag, err)
+		}
+		secret := strings.TrimSpace(string(data))
+		if secret == "" {
+			return "", invalidf("Invalid value for --%s flag: %s is empty", flag, value[1:])
+		}
+		return secret, nil
+	case env != "":
+		// Empty counts as unset, as it does for HTTP_ASSERT_*: a secret that
+		// CI failed to inject must not send an anonymous request that passes.
+		secret := os.Getenv(env)
+		if secret == "" {
+			return "", invalidf("Invalid value for --%s flag: $%s is not set", envFlag, env)
+		}
+		return secret, nil
 	}
-	return fmt.Errorf("gave up after %d attempts%s:\n%w", attempts, limit, err)
+
+	return value, nil
 }
 
-// doOnce performs one request and checks it against every assertion.
-func (c Client) doOnce(client *http.Client, req *http.Request, assertions []Assertion) error {
-	next, err := cloneForAttempt(req)
-	if err != nil {
-		var b strings.Builder
-		fmt.Fprintf(&b, "failed to rewind the request body:\n- %s\n", err)
-		c.writeHttpDetails(&b, req, nil)
-		return &exitError{exitTransportFail, b.String()}
-	}
-	req = next
-
-	c.logInfo("[.] %s %s %s", req.Proto, req.Method, req.URL)
-	startedAt := time.Now()
-	// G704: the request URL comes from the operator's own command line, and
-	// fetching it is the entire purpose of this tool -- no trust boundary is
-	// crossed, so this is not SSRF.
-	//
-	// --location widens that slightly: the hops after the first are chosen by
-	// the server, not the operator. It stays opt-in for exactly that reason,
-	// and net/http drops Authorization and Cookie when a hop leaves the
-	// original domain, so credentials passed with -H do not travel.
-	res, err := client.Do(req) // #nosec G704 - user asked for this URL
+// tokenSources are the token endpoints of this run, by everything that
+// identifies a token, so that the requests of a suite share one rather than
+// each fetching its own.
+var tokenSources = map[string]*httpassert.ClientCredentials{}
+
+// oauth2Flags reads --oauth2-token-url, --oauth2-client-id,
+// --oauth2-client-secret-file and --oauth2-scope. The token they get is the
+// Authorization header, so -u, a token of the caller's and --digest are
+// refused with them.
+func oauth2Flags(fs *pflag.FlagSet, c *httpassert.Client) error {
+	tokenURL, _ := fs.GetString("oauth2-token-url")
+	clientID, _ := fs.GetString("oauth2-client-id")
+	secretFile, _ := fs.GetString("oauth2-client-secret-file")
+	scope, _ := fs.GetString("oauth2-scope")
+
+	switch {
+	case tokenURL == "" && (clientID != "" || secretFile != "" || scope != ""):
+		return invalidf("Flags --oauth2-client-id, --oauth2-client-secret-file and --oauth2-scope " +
+			"need --oauth2-token-url")
+	case tokenURL == "":
+		return nil
+	case c.User != nil || c.BearerToken != "" || c.Digest:
+		return invalidf("Flag --oauth2-token-url cannot be used with --user, --oauth2-bearer or --digest: " +
+			"all of them are the Authorization header")
+	case clientID == "":
+		return invalidf("Flag --oauth2-token-url needs --oauth2-client-id")
+	case secretFile == "":
+		// Only a file: the secret outlives any one token, and the command
+		// line is the one place it must never be.
+		return invalidf("Flag --oauth2-token-url needs --oauth2-client-secret-file")
+	}
+
+	u, err := url.Parse(tokenURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalidf("Invalid value for --oauth2-token-url flag: %q is not an http:// or https:// URL", tokenURL)
+	}
+	data, err := os.ReadFile(secretFile)
 	if err != nil {
-		var b strings.Builder
-		// The transport did its job here; this program stopped the chain.
-		// Filing that under "failed to send request" sends the reader looking
-		// for a network problem that does not exist.
-		if errors.Is(err, errTooManyRedirects) {
-			fmt.Fprintf(&b, "redirect chain was not followed to the end:\n- %s\n", err)
-		} else {
-			fmt.Fprintf(&b, "failed to send request:\n- %s\n", err)
-		}
-		// This path logs nothing between [.] and the dump the caller prints,
-		// which is fine for a single attempt and unreadable for twenty. The
-		// line appears only while retrying so that a plain run is untouched.
-		if c.Retries > 0 {
-			c.logInfo("[-] FAILED %s: %s\n", time.Since(startedAt), err)
+		return invalidf("Invalid value for --oauth2-client-secret-file flag: %s", err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return invalidf("Invalid value for --oauth2-client-secret-file flag: %s is empty", secretFile)
+	}
+
+	key := strings.Join([]string{tokenURL, clientID, secret, scope}, "\x00")
+	if tokenSources[key] == nil {
+		tokenSources[key] = &httpassert.ClientCredentials{
+			TokenURL: tokenURL, ClientID: clientID, ClientSecret: secret, Scope: scope,
 		}
-		c.writeHttpDetails(&b, req, nil)
-		return &exitError{exitTransportFail, b.String()}
 	}
-	defer func() { _ = res.Body.Close() }()
+	c.OAuth2 = tokenSources[key]
+
+	return nil
+}
 
-	c.logInfo("[:] %s %s\n", res.Proto, res.Status)
-	httpRes := &httpResponse{Response: res}
-	httpRes.BodyBytes, _ = io.ReadAll(res.Body)
-	httpRes.decodeBody()
+// signFlags reads --aws-sigv4, --hmac, --hmac-key and --hmac-key-env. A
+// request is signed by one scheme or the other, and SigV4's Authorization
+// header leaves no room for -u or a token.
+func signFlags(fs *pflag.FlagSet, c *httpassert.Client) error {
+	sigv4, _ := fs.GetString("aws-sigv4")
+	hmacSpec, _ := fs.GetString("hmac")
+	hmacKey, _ := fs.GetString("hmac-key")
+	hmacKeyEnv, _ := fs.GetString("hmac-key-env")
 
-	var assertErrors []error
-	for i := range assertions {
-		// A failed assertion and one that could not be evaluated are both
-		// failures of the run and both print the same way, so they share a
-		// list -- which is also what keeps the dump in the order the
-		// assertions were given. Only a machine-readable consumer needs to
-		// tell them apart, and that is what Check separates them for (#45).
-		f, err := assertions[i].Check(httpRes)
-		switch {
-		case err != nil:
-			assertErrors = append(assertErrors, err)
-		case f != nil:
-			assertErrors = append(assertErrors, f)
+	switch {
+	case sigv4 != "" && hmacSpec != "":
+		return invalidf("Flags --aws-sigv4 and --hmac cannot be used together: a request is signed once")
+	case sigv4 != "" && (c.User != nil || c.BearerToken != "" || c.Digest || c.OAuth2 != nil):
+		return invalidf("Flag --aws-sigv4 cannot be used with --user, --oauth2-bearer, --oauth2-token-url " +
+			"or --digest: all of them are the Authorization header")
+	case hmacSpec == "" && (hmacKey != "" || hmacKeyEnv != ""):
+		return invalidf("Flags --hmac-key and --hmac-key-env need --hmac")
+	case hmacKey != "" && hmacKeyEnv != "":
+		return invalidf("Flags --hmac-key and --hmac-key-env cannot be used together: both name the key")
+	case hmacSpec != "" && hmacKey == "" && hmacKeyEnv == "":
+		return invalidf("Flag --hmac needs a key: give --hmac-key or --hmac-key-env")
+	}
+
+	if sigv4 != "" {
+		s, err := httpassert.ParseAWSSigV4(sigv4)
+		if err != nil {
+			return invalidf("Invalid value for --aws-sigv4 flag: %s", err)
+		}
+		// The variables the AWS CLI and SDKs read, so a CI job that can run
+		// one can run this.
+		s.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		s.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+			return invalidf("Flag --aws-sigv4 needs $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY")
 		}
+		c.Signer = s
 	}
-	if len(assertErrors) > 0 {
-		c.logInfo("[-] FAILED %s\n\n", time.Since(startedAt))
 
-		var b strings.Builder
-		fmt.Fprintf(&b, "%d assertions failed:\n", len(assertErrors))
-		for i := range assertErrors {
-			fmt.Fprintf(&b, "- %s\n", assertErrors[i])
+	if hmacSpec != "" {
+		key, err := secretFlags(fs, "hmac-key", "hmac-key-env")
+		if err != nil {
+			return err
 		}
-		c.writeHttpDetails(&b, req, httpRes)
-		return &exitError{exitAssertFail, b.String()}
+		s, err := httpassert.ParseHMAC(hmacSpec, []byte(key))
+		if err != nil {
+			return invalidf("Invalid value for --hmac flag: %s", err)
+		}
+		c.Signer = s
 	}
 
-	c.logInfo("[+] PASSED %s\n\n", time.Since(startedAt))
 	return nil
 }
 
-// cloneForAttempt returns a request that can be sent even if the one it was
-// built from already has been.
-//
-// http.Client consumes and closes req.Body, so re-sending the same *http.Request
-// carries an empty body unless net/http can replay it through GetBody. Today it
-// can -- the CLI builds the body from a strings.Reader, which is one of the
-// three types http.NewRequest recognises -- but that is a property of the body
-// type rather than a guarantee, and a body without GetBody would silently send
-// nothing on the second attempt. Cloning costs six lines and does not depend on
-// which reader the caller happened to pass.
-func cloneForAttempt(req *http.Request) (*http.Request, error) {
-	res := req.Clone(req.Context())
-	if req.GetBody == nil {
-		return res, nil // no body, or http.NoBody, which re-reads as empty
+// cookieJars are the cookie jars of this run, by the -b and -c they came from,
+// so that the requests of a suite share their cookies as one session would.
+var cookieJars = map[string]*cookieJar{}
+
+type cookieJar struct {
+	jar  *httpassert.CookieJar
+	path string // -c, or empty to keep the cookies for the run alone
+}
+
+// cookieFlags reads -b and -c, either of which keeps the cookies responses set
+// for the rest of the run, as curl's cookie engine does. -b takes cookies to
+// send when it holds a =, as curl decides it, and otherwise a cookie file to
+// start from; a file that is not there yet starts an empty jar, so -b and -c
+// can name the same one from the first run on.
+func cookieFlags(fs *pflag.FlagSet, c *httpassert.Client) error {
+	cookie, _ := fs.GetString("cookie")
+	jarPath, _ := fs.GetString("cookie-jar")
+	if cookie == "" && jarPath == "" {
+		return nil
 	}
 
-	body, err := req.GetBody()
-	if err != nil {
-		return nil, err
+	isFile := cookie != "" && !strings.Contains(cookie, "=")
+	if !isFile && cookie != "" {
+		cookies, err := httpassert.ParseCookies(cookie)
+		if err != nil {
+			return invalidf("Invalid value for --cookie flag: %s", err)
+		}
+		c.Cookies = cookies
+	}
+
+	key := cookie + "\x00" + jarPath
+	if cookieJars[key] == nil {
+		jar := httpassert.NewCookieJar()
+		if isFile {
+			data, err := os.ReadFile(cookie) // #nosec G304 - the caller named the file to read
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+				// The first run of -b jar -c jar, which writes it.
+			case err != nil:
+				return invalidf("Invalid value for --cookie flag: %s", err)
+			default:
+				if jar, err = httpassert.ParseCookieJar(string(data)); err != nil {
+					return invalidf("Invalid value for --cookie flag: %s: %s", cookie, err)
+				}
+			}
+		}
+		cookieJars[key] = &cookieJar{jar: jar, path: jarPath}
 	}
-	res.Body = body
+	c.Jar = cookieJars[key].jar
 
-	return res, nil
+	return nil
+}
+
+// saveCookieJars writes each jar that has a -c file, whatever the verdict: the
+// cookies a failed run was left with are the ones worth looking at.
+func saveCookieJars() {
+	for _, key := range slices.Sorted(maps.Keys(cookieJars)) {
+		j := cookieJars[key]
+		if j.path == "" {
+			continue
+		}
+		if err := writeCookieJar(j.path, j.jar); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot write the cookie jar: %s\n", err)
+		}
+	}
 }
 
-func (c Client) writeHttpDetails(w io.Writer, req *http.Request, res *httpResponse) {
-	_, _ = fmt.Fprintf(w, "\nFAILED: %s %s (%s)\n\n", req.Method, req.URL, req.Proto)
-	// With --location the response below came from somewhere else, and the
-	// request dumped after this is the one that started the chain rather than
-	// the one that produced it. Say so; a reader cannot infer it. The method
-	// is worth printing too, because a 302 rewrites POST to GET.
-	if res != nil && res.Request != nil && res.Request.URL.String() != req.URL.String() {
-		_, _ = fmt.Fprintf(w, "Followed to: %s %s\n\n", res.Request.Method, res.Request.URL)
+func writeCookieJar(path string, jar *httpassert.CookieJar) error {
+	f, err := os.Create(path) // #nosec G304 - the caller named the file to write
+	if err != nil {
+		return err
 	}
-	writeRequest(w, req)
-	_, _ = w.Write([]byte("\n\n"))
-	if res != nil {
-		res.writeTo(w, c.LogLevel >= LInfo)
-		_, _ = w.Write([]byte("\n\n"))
+	if _, err := jar.WriteTo(f); err != nil {
+		_ = f.Close()
+		return err
 	}
+
+	return f.Close()
 }
 
-// writeRequest renders the request for a person reading a failure report, the
-// way writeTo renders the response.
-//
-// http.Request.Write alone gets this wrong twice. Its body has already been
-// consumed by the send, so it emits headers claiming a Content-Length with
-// nothing behind them -- the first question after a failed POST is "what did I
-// send?", and that was the one thing the dump left out (#19). And it is a
-// wire-format serializer, so a long or binary payload would land in the report
-// raw: the same mistake #18 fixed on the response side.
-//
-// So the headers come from Write, which knows what actually goes on the wire
-// (Host, User-Agent, Content-Length are none of them in req.Header), and the
-// body goes through the shared renderer that crops and hex-dumps.
-func writeRequest(w io.Writer, req *http.Request) {
-	// A fresh clone replays the body; without GetBody there is nothing to
-	// replay and the dump is no worse than it was.
-	dump := req
-	if fresh, err := cloneForAttempt(req); err == nil {
-		dump = fresh
+// protocolFlags reads --http2, --http2-prior-knowledge and --http3. At most one
+// of them can be given: curl lets the last one win, but here no order is
+// right -- one asks whether the server will agree to HTTP/2, one assumes it
+// already has, and one leaves TCP altogether.
+func protocolFlags(fs *pflag.FlagSet, c *httpassert.Client) error {
+	c.HTTP2, _ = fs.GetBool("http2")
+	c.HTTP2PriorKnowledge, _ = fs.GetBool("http2-prior-knowledge")
+	c.HTTP3, _ = fs.GetBool("http3")
+
+	var given []string
+	for _, name := range []string{"http2", "http2-prior-knowledge", "http3"} {
+		if on, _ := fs.GetBool(name); on {
+			given = append(given, name)
+		}
+	}
+	if len(given) > 1 {
+		return invalidf("Flags --%s and --%s cannot be used together: each picks the protocol, "+
+			"and a run speaks one", given[0], given[1])
 	}
 
-	var b bytes.Buffer
-	if err := dump.Write(&b); err != nil {
-		// A failed dump must not replace the failure being reported, so
-		// whatever was rendered before the error still goes out.
-		_, _ = w.Write(b.Bytes())
-		return
+	if !c.HTTP3 {
+		return nil
+	}
+	// QUIC carries TLS 1.3 and nothing older, so these could only ever be
+	// ignored or make every attempt fail.
+	if c.MaxTLSVersion != 0 && c.MaxTLSVersion < tls.VersionTLS13 {
+		maxV, _ := fs.GetString("tls-max")
+		return invalidf("Flags --http3 and --tls-max %s cannot be used together: QUIC runs over TLS 1.3 only", maxV)
+	}
+	if len(c.CipherSuites) > 0 {
+		return invalidf("Flags --http3 and --ciphers cannot be used together: " +
+			"QUIC runs over TLS 1.3, whose suites cannot be chosen")
 	}
 
-	head, body, found := bytes.Cut(b.Bytes(), []byte("\r\n\r\n"))
-	_, _ = w.Write(head)
-	_, _ = w.Write([]byte("\r\n\r\n"))
-	if !found || len(body) == 0 {
-		return
+	return nil
+}
+
+// tlsFilesFlags loads what --cert, --key, --cacert and --capath name. A file
+// that cannot be read is the invocation's fault, and is reported before any
+// request is made: sent without its certificate, the request would fail at the
+// server, and the report would blame the service for it.
+func tlsFilesFlags(fs *pflag.FlagSet, c *httpassert.Client) error {
+	certFile, _ := fs.GetString("cert")
+	keyFile, _ := fs.GetString("key")
+	caFile, _ := fs.GetString("cacert")
+	caDir, _ := fs.GetString("capath")
+
+	if keyFile != "" && certFile == "" {
+		return invalidf("Flag --key needs --cert; a key is only the other half of a certificate")
+	}
+	if certFile != "" {
+		file, password := splitCertPassword(certFile)
+		cert, err := httpassert.LoadClientCertificate(file, keyFile, password)
+		if err != nil {
+			return invalidf("Cannot load client certificate: %s", err)
+		}
+		c.Certificates = append(c.Certificates, cert)
 	}
 
-	if cropped := printPayload(w, body, maxPayloadBytes); cropped > 0 {
-		_, _ = fmt.Fprintf(w, "\n\n  << Payload is cropped: %d bytes are hidden >>", cropped)
+	if caFile != "" || caDir != "" {
+		var err error
+		if c.RootCAs, err = httpassert.LoadCertPool(caFile, caDir); err != nil {
+			return invalidf("Cannot load CA certificates: %s", err)
+		}
 	}
+
+	return nil
 }
 
-func (c Client) getHttpClient() *http.Client {
-	dialer := &net.Dialer{
-		Timeout:   10 * time.Second,
-		KeepAlive: 20 * time.Second,
+// tlsPolicyFlags reads --pinnedpubkey, --tls-min, --tls-max and --ciphers.
+func tlsPolicyFlags(fs *pflag.FlagSet, c *httpassert.Client) error {
+	if v, _ := fs.GetString("pinnedpubkey"); v != "" {
+		pins, err := httpassert.ParsePinnedPubKey(v)
+		if err != nil {
+			return invalidf("Invalid value for --pinnedpubkey flag: %s", err)
+		}
+		c.PinnedPubKeys = pins
 	}
 
-	tr := &http.Transport{
-		ForceAttemptHTTP2: false,
-		// net/http decodes a gzip response only when it was the layer that
-		// asked for it, and hands over the raw bytes otherwise. Four unrelated
-		// conditions decide which happens -- a caller-set Accept-Encoding, a
-		// Range header, the method, and this field -- so whether --assert-body
-		// saw the payload or a compressed blob depended on flags that have
-		// nothing to do with the body (#27).
-		//
-		// Decoding is done here instead, in decodeBody, on every response. One
-		// path, and the request carries exactly the headers it was told to.
-		DisableCompression:    true,
-		MaxIdleConns:          10,
-		IdleConnTimeout:       20 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		Proxy:                 http.ProxyFromEnvironment,
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return dialer.DialContext(ctx, network, c.getDstHost(addr))
-		},
+	for _, f := range []struct {
+		name string
+		into *uint16
+	}{{"tls-min", &c.MinTLSVersion}, {"tls-max", &c.MaxTLSVersion}} {
+		if v, _ := fs.GetString(f.name); v != "" {
+			version, err := httpassert.ParseTLSVersion(v)
+			if err != nil {
+				return invalidf("Invalid value for --%s flag: %s", f.name, err)
+			}
+			*f.into = version
+		}
 	}
-	if c.SkipSslChecks {
-		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // #nosec G402 - user asked for it
+	if c.MinTLSVersion != 0 && c.MaxTLSVersion != 0 && c.MinTLSVersion > c.MaxTLSVersion {
+		minV, _ := fs.GetString("tls-min")
+		maxV, _ := fs.GetString("tls-max")
+		return invalidf("Flags --tls-min %s and --tls-max %s leave no version to negotiate", minV, maxV)
 	}
 
-	return &http.Client{
-		Timeout: c.Timeout,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if !c.FollowRedirects {
-				// The default. Hand the 3xx to the assertions intact --
-				// --assert-redirect* has nothing to look at otherwise.
-				return http.ErrUseLastResponse
-			}
+	if v, _ := fs.GetString("ciphers"); v != "" {
+		suites, err := httpassert.ParseCipherSuites(v)
+		if err != nil {
+			return invalidf("Invalid value for --ciphers flag: %s", err)
+		}
+		c.CipherSuites = suites
+	}
 
-			// via holds the requests already sent, so the k-th redirect sees
-			// len(via) == k. net/http's own default writes >= here and so
-			// follows one fewer hop than its message claims; > is what makes
-			// --max-redirs N mean N, and --max-redirs 0 mean none.
-			if len(via) > c.MaxRedirects {
-				return fmt.Errorf("%w: --max-redirs is %d", errTooManyRedirects, c.MaxRedirects)
-			}
+	return nil
+}
 
-			c.logInfo("[>] %d %s %s", len(via), req.Method, req.URL)
-			return nil
-		},
-		Transport: tr,
+// splitCertPassword reads curl's <file:password> form of --cert. A name that
+// is itself a file is taken whole, so a colon in a path does not need the
+// escaping curl asks for.
+func splitCertPassword(arg string) (file, password string) {
+	if _, err := os.Stat(arg); err == nil {
+		return arg, ""
 	}
+	if i := strings.LastIndex(arg, ":"); i > 0 {
+		return arg[:i], arg[i+1:]
+	}
+
+	return arg, ""
 }
 
-func (c Client) getDstHost(addr string) string {
-	for _, r := range c.HostMappings {
-		if r.Matches(addr) {
-			return r.DstHost()
+// dataFlags are the flags that give a request its body. Each gives the whole
+// of it, so one is all a request may have.
+var dataFlags = []string{"data", "data-binary", "data-file", "data-urlencode"}
+
+// requestBody reads the body the data flags give, and whether one did. -d is
+// sent as it is given, a leading @ included; --data-binary reads @FILE, and
+// stdin for @-; --data-file names only a file; and the --data-urlencode parts
+// are joined with &. A body read from a file is held whole, so every attempt
+// and the failure dump have all of it.
+func requestBody(fs *pflag.FlagSet) ([]byte, bool, error) {
+	var given []string
+	for _, name := range dataFlags {
+		if fs.Changed(name) {
+			given = append(given, name)
+		}
+	}
+	switch {
+	case len(given) == 0:
+		return nil, false, nil
+	case len(given) > 1:
+		return nil, false, invalidf("Flags --%s and --%s cannot be used together: each gives the whole body",
+			given[0], given[1])
+	}
+
+	var body []byte
+	var err error
+	switch given[0] {
+	case "data":
+		d, _ := fs.GetString("data")
+		body = []byte(d)
+	case "data-binary":
+		d, _ := fs.GetString("data-binary")
+		if path, ok := strings.CutPrefix(d, "@"); ok {
+			body, err = readDataFile("--data-binary", path)
+		} else {
+			body = []byte(d)
+		}
+	case "data-file":
+		path, _ := fs.GetString("data-file")
+		body, err = readDataFile("--data-file", path)
+	case "data-urlencode":
+		vs, _ := fs.GetStringArray("data-urlencode")
+		parts := make([]string, len(vs))
+		for i, v := range vs {
+			if parts[i], err = urlencodePart(v); err != nil {
+				break
+			}
 		}
+		body = []byte(strings.Join(parts, "&"))
+	}
+	if err != nil {
+		return nil, false, err
 	}
 
-	return addr
+	return body, true, nil
 }
 
-func (c Client) logDebug(format string, args ...interface{}) {
-	c.log(LDebug, format, args...)
+// urlencodePart reads a --data-urlencode value as curl does: name=value
+// encodes the value, name@FILE the file's contents, and =value, @FILE or a
+// value with neither sends the encoded value with no name. A = anywhere wins
+// over an @, so an address is a value rather than a file. The encoding is
+// curl's too: a space is %20, not the + of url.QueryEscape.
+func urlencodePart(v string) (string, error) {
+	i := strings.Index(v, "=")
+	if i < 0 {
+		i = strings.Index(v, "@")
+	}
+	if i < 0 {
+		return percentEscape(v), nil
+	}
+
+	name, value := v[:i], []byte(v[i+1:])
+	if v[i] == '@' {
+		var err error
+		if value, err = readDataFile("--data-urlencode", v[i+1:]); err != nil {
+			return "", err
+		}
+	}
+	if name == "" {
+		return percentEscape(string(value)), nil
+	}
+
+	return name + "=" + percentEscape(string(value)), nil
 }
 
-func (c Client) logInfo(format string, args ...interface{}) {
-	c.log(LInfo, format, args...)
+// stdinData is stdin, read the first time a data flag asks for it, so that
+// every request of a suite that says @- sends it rather than only the first.
+var stdinData = sync.OnceValues(func() ([]byte, error) { return io.ReadAll(os.Stdin) })
+
+// readDataFile reads a body from path, or from stdin for "-".
+func readDataFile(flag, path string) ([]byte, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = stdinData()
+	} else {
+		data, err = os.ReadFile(path) // #nosec G304 - the caller named the file to read
+	}
+	if err != nil {
+		return nil, invalidf("Invalid value for %s flag: %s", flag, err)
+	}
+
+	return data, nil
 }
 
-func (c Client) log(l LogLevel, format string, args ...interface{}) {
-	if l > c.LogLevel {
-		return
+// newRequest builds the request the flags describe, for rawURL.
+func newRequest(ctx context.Context, fs *pflag.FlagSet, rawURL string) (*http.Request, error) {
+	// -d implies POST, as it does in curl; an explicit -X wins even when it
+	// repeats the default, which Changed distinguishes from "not passed" --
+	// applyEnv cannot fake it because neither flag is env-applied and
+	// Value.Set does not mark Changed.
+	r := httpassert.Request{URL: rawURL, Header: http.Header{}}
+	r.Method, _ = fs.GetString("request")
+	body, dataGiven, err := requestBody(fs)
+	if err != nil {
+		return nil, err
+	}
+	if dataGiven && !fs.Changed("request") {
+		r.Method = http.MethodPost
 	}
+	r.Body = body
 
-	if !strings.HasSuffix(format, "\n") {
-		format += "\n"
+	vs, _ := fs.GetStringArray("header")
+	for _, v := range vs {
+		name, value, err := parseRequestHeader(v)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Add(name, value)
 	}
-	fmt.Fprint(os.Stderr, c.Palette.line(fmt.Sprintf(format, args...)))
-}
-
-type httpResponse struct {
-	*http.Response
-	BodyBytes []byte
-	// Encoding is the response's Content-Encoding, verbatim, and empty when
-	// there was none.
-	//
-	// The header itself is left alone. net/http deletes it (and Content-Length)
-	// when it decodes, which makes a response that was compressed
-	// indistinguishable from one that never was -- and the whole reason to set
-	// Accept-Encoding by hand is to find out which happened.
-	Encoding string
-	// DecodeErr is why BodyBytes is still encoded. Nil means BodyBytes is the
-	// payload, whether or not anything had to be removed to get there.
-	DecodeErr error
-	// The decoded JSON body, filled by decodeJSON on first use. Plain fields
-	// rather than a sync.Once because httpResponse is passed around by value in
-	// places, and a value copy of a mutex is what go vet exists to catch.
-	jsonBody   any
-	jsonErr    error
-	jsonParsed bool
-}
-
-// decodeJSON decodes the body as JSON once and shares the result.
-//
-// Every --assert-jq in a run reads the same response, so ten queries should
-// parse it once rather than ten times. Failure is reported as a property of the
-// body, not of the query: a response that is not JSON fails every jq assertion
-// for the same reason, and saying so once per assertion is clearer than saying
-// the expression did not hold.
-func (r *httpResponse) decodeJSON() (any, error) {
-	if r.jsonParsed {
-		return r.jsonBody, r.jsonErr
-	}
-	r.jsonParsed = true
-
-	// Through bodyOf like every other body assertion, so a body that is still
-	// compressed reports that rather than reporting invalid JSON (#27).
-	body, err := bodyOf(r)
-	if err != nil {
-		r.jsonErr = err
-		return nil, r.jsonErr
+	// curl's default for every data flag; presence is what suppresses it, so an explicit
+	// empty `-H 'Content-Type:'` is respected, not replaced.
+	if dataGiven && len(r.Header.Values("Content-Type")) == 0 {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	}
 
-	if err := json.Unmarshal(body, &r.jsonBody); err != nil {
-		r.jsonErr = fmt.Errorf("body: expected JSON, got %s", err)
-		return nil, r.jsonErr
+	req, err := r.Build(ctx)
+	if err != nil {
+		return nil, invalidf("Cannot create request '%s %s': %s", r.Method, rawURL, err)
 	}
 
-	return r.jsonBody, nil
+	return req, nil
 }
 
-// decoders maps a Content-Encoding to something that removes it. Content
-// coding names are case-insensitive per RFC 9110, so lookups are lowered.
+func registerAssertionFlags(fs *pflag.FlagSet) {
+	fs.String("assert-status", "",
+		"Assert response status; a code, a class like 2xx, a range like 401-403, or a list of those")
+	fs.StringArray("assert-header", nil,
+		"Assert any value of the header matches the provided regexp; NAME alone asserts it is present")
+	fs.StringArray("assert-header-eq", nil,
+		"Assert any value of the header equals the provided value; NAME alone asserts it is present")
+	fs.StringArray("assert-header-missing", nil, "Assert header is missing")
+	fs.String("assert-body", "", "Assert body matches the provided regexp")
+	fs.String("assert-body-eq", "", "Assert body equals the provided value")
+	fs.Bool("assert-body-empty", false,
+		"Assert body is empty; =false asserts it is not")
+	fs.StringArray("assert-jq", nil,
+		"Assert the jq expression yields true; repeat to assert several")
+	fs.StringArray("assert-time", nil,
+		"Assert a phase took less than a duration, e.g. total<300ms; phases: "+
+			"dns, connect, tls, ttfb, transfer, total; repeat to assert several")
+	fs.String("assert-cert-expires-after", "",
+		"Assert the server's certificate is still valid after a window, e.g. 14d or 36h")
+	fs.StringArray("assert-cert-san", nil,
+		"Assert the server's certificate covers the name; repeat to assert several")
+	fs.String("assert-cert-issuer", "", "Assert the server's certificate issuer matches the provided regexp")
+	fs.String("assert-tls-version", "",
+		"Assert the negotiated TLS version, e.g. 1.3 or '>=1.2'")
+	fs.String("assert-alpn", "", "Assert the protocol negotiated by ALPN, e.g. http/1.1")
+	fs.String("assert-proto", "", "Assert the HTTP version of the response, e.g. HTTP/2.0")
+	fs.StringArray("assert-alt-svc", nil,
+		"Assert the Alt-Svc header advertises a protocol, e.g. h3 or h3=:443; repeat to assert several")
+	fs.StringArray("assert-cookie", nil,
+		"Assert a cookie is held for the final URL; NAME=REGEXP asserts its value matches; repeat to assert several")
+
+	// Common shorthands
+	fs.Bool("assert-ok", false,
+		"Assert response status is not an error (2xx or 3xx); =false asserts it is")
+	fs.String("assert-redirect", "",
+		"Assert redirect location matches the provided regexp; redirects are not followed")
+	fs.String("assert-redirect-eq", "",
+		"Assert redirect location equals the provided URL; redirects are not followed")
+}
+
+// singleValue wraps a flag that holds one value, counting how many times the
+// parser assigned to it.
 //
-// deflate is absent by name because it is two formats: RFC 9110 says zlib, and
-// a good deal of the web sends raw. decodeDeflate tries both.
-var decoders = map[string]func([]byte) ([]byte, error){
-	"gzip":    decodeGzip,
-	"deflate": decodeDeflate,
-	"br":      decodeBrotli,
-	"zstd":    decodeZstd,
+// pflag records only whether a flag was set, never how often, so a second
+// occurrence of a scalar flag overwrites the first and leaves nothing behind
+// to notice. For an assertion that means the run goes green having checked
+// less than it was asked to (#35). The count is the missing evidence.
+type singleValue struct {
+	inner pflag.Value
+	count int
 }
 
-// supportedCodings names the decoders in a stable order, so the failure for an
-// encoding with no decoder can say what it does have without drifting from the
-// map as it grows.
-func supportedCodings() string {
-	return strings.Join(slices.Sorted(maps.Keys(decoders)), ", ")
+func (v *singleValue) Set(s string) error { v.count++; return v.inner.Set(s) }
+func (v *singleValue) String() string     { return v.inner.String() }
+func (v *singleValue) Type() string       { return v.inner.Type() }
+
+// collects reports whether a flag type accumulates its values rather than
+// replacing them. Repeating those is how you ask for several assertions.
+func collects(flagType string) bool {
+	return strings.HasSuffix(flagType, "Array") || strings.HasSuffix(flagType, "Slice")
 }
 
-// decodeBody removes the Content-Encoding from BodyBytes, leaving every header
-// exactly as it arrived.
+// rejectRepeats prepares every single-valued assertion flag to notice a repeat.
 //
-// An encoding nothing here can remove is not an error by itself: a response
-// body the tool cannot read still has a status and headers worth asserting on.
-// It is recorded instead, and only the body assertions refuse (see bodyOf).
-func (r *httpResponse) decodeBody() {
-	r.Encoding = strings.TrimSpace(r.Header.Get("Content-Encoding"))
-
-	// An empty body has nothing to decode, and an empty gzip stream is an error
-	// rather than an empty payload -- so a 204 that carries the header anyway
-	// must not fail --assert-body-empty.
-	if len(r.BodyBytes) == 0 {
-		return
-	}
+// Derived from the flag's own type rather than a list, because a list is how
+// this went wrong in the first place: --assert-header was made repeatable and
+// the others were not, and nothing connected the two decisions. An assertion
+// flag added later is covered the day it is added.
+func rejectRepeats(fs *pflag.FlagSet) {
+	fs.VisitAll(func(f *pflag.Flag) {
+		if strings.HasPrefix(f.Name, "assert-") && !collects(f.Value.Type()) {
+			f.Value = &singleValue{inner: f.Value}
+		}
+	})
+}
 
-	switch enc := strings.ToLower(r.Encoding); enc {
-	case "", "identity":
-		return
-	default:
-		decode, ok := decoders[enc]
-		if !ok {
-			r.DecodeErr = fmt.Errorf("no decoder for %q; %s are supported", r.Encoding, supportedCodings())
-			return
+// checkRepeats terminates when an assertion was named more than once. Taking
+// the last value silently is the one outcome worth refusing: the alternative
+// is a tool that reports success for a check it never ran.
+//
+// The flags the message suggests instead are the ones rejectRepeats left
+// alone, found the same way, so the two cannot disagree.
+func checkRepeats(fs *pflag.FlagSet) error {
+	var repeatable []string
+	fs.VisitAll(func(f *pflag.Flag) {
+		if strings.HasPrefix(f.Name, "assert-") && collects(f.Value.Type()) {
+			repeatable = append(repeatable, "--"+f.Name)
 		}
+	})
 
-		b, err := decode(r.BodyBytes)
-		if err != nil {
-			r.DecodeErr = err
-			return
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if v, ok := f.Value.(*singleValue); ok && v.count > 1 && err == nil {
+			err = invalidf("Flag --%s was given %d times but accepts a single value; "+
+				"repeat %s to make several assertions", f.Name, v.count, joinOr(repeatable))
 		}
-		r.BodyBytes = b
-	}
-}
+	})
 
-// decodeBrotli removes a brotli coding. There is no brotli in the standard
-// library, which is the whole reason this took a dependency; andybalholm/brotli
-// is pure Go and brings nothing else with it.
-func decodeBrotli(b []byte) ([]byte, error) {
-	return io.ReadAll(brotli.NewReader(bytes.NewReader(b)))
+	return err
 }
 
-// decodeZstd removes a zstd coding (RFC 8878).
-//
-// klauspost/compress is a large repository, but only the zstd package links
-// into the binary, so the cost is the decoder rather than the library.
-func decodeZstd(b []byte) ([]byte, error) {
-	zr, err := zstd.NewReader(bytes.NewReader(b))
-	if err != nil {
-		return nil, err
+// joinOr lists names as "a, b or c".
+func joinOr(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
 	}
-	defer zr.Close()
 
-	return io.ReadAll(zr)
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
 }
 
-func decodeGzip(b []byte) ([]byte, error) {
-	zr, err := gzip.NewReader(bytes.NewReader(b))
+// compileAssertion builds a pattern-based assertion, reporting an unparseable
+// pattern the way every other invalid flag value is reported.
+//
+// Without this the pattern reached regexp.MustCompile and the process died with
+// a stack trace and exit code 2, which is not part of the documented contract
+// and gave the user no idea which flag was at fault (#17).
+func compileAssertion(flag, pattern string, build func(string) (httpassert.Assertion, error)) (httpassert.Assertion, error) {
+	a, err := build(pattern)
 	if err != nil {
-		return nil, err
+		return nil, invalidf("Invalid value for %s flag: %s", flag, err)
 	}
-	defer func() { _ = zr.Close() }()
 
-	return io.ReadAll(zr)
+	return a, nil
 }
 
-// decodeDeflate tries zlib first and raw DEFLATE second.
+// boolAssertion turns a boolean assertion flag into the assertion it asks for.
 //
-// RFC 9110 defines the deflate coding as the zlib format, but servers sending
-// raw DEFLATE under the same name are common enough that net/http declines to
-// negotiate it at all ("Deflate is ambiguous and not as universally supported
-// anyway", transport.go). Guessing is safe here because neither reader accepts
-// the other's input: a wrong guess fails rather than producing plausible bytes.
-func decodeDeflate(b []byte) ([]byte, error) {
-	if zr, err := zlib.NewReader(bytes.NewReader(b)); err == nil {
-		defer func() { _ = zr.Close() }()
-		if out, err := io.ReadAll(zr); err == nil {
-			return out, nil
-		}
+// A boolean assertion has two of them, and =false selects the second rather
+// than cancelling the flag: --assert-ok asserts the status is not an error,
+// --assert-ok=false asserts that it is. Naming an assertion and getting no
+// assertion would be the one outcome worth refusing, and it is what
+// --assert-body-empty=false used to do -- the run died with "no assertions
+// defined" after the user had named one (#32).
+//
+// The pairing lives here, once, rather than being written out per flag. The two
+// flags drifted apart because nothing connected them; a helper is what connects
+// them, in the same way rejectRepeats derives from the flag's type rather than
+// from a list.
+func boolAssertion(fs *pflag.FlagSet, name string, whenTrue, whenFalse func() httpassert.Assertion) []httpassert.Assertion {
+	if !fs.Changed(name) {
+		return nil
 	}
 
-	fr := flate.NewReader(bytes.NewReader(b))
-	defer func() { _ = fr.Close() }()
-
-	out, err := io.ReadAll(fr)
-	if err != nil {
-		return nil, fmt.Errorf("not valid zlib or raw DEFLATE: %w", err)
+	if v, _ := fs.GetBool(name); v {
+		return []httpassert.Assertion{whenTrue()}
 	}
 
-	return out, nil
+	return []httpassert.Assertion{whenFalse()}
 }
 
-// maxPayloadBytes is how much of a body the failure dump shows before cropping.
-const maxPayloadBytes = 256
+// parseAssertionFlags builds the assertions the flags ask for, in a fixed order
+// that does not depend on the order they were given in. The first value that
+// does not parse is the error.
+func parseAssertionFlags(fs *pflag.FlagSet) ([]httpassert.Assertion, error) {
+	var res []httpassert.Assertion
 
-// writeTo renders the response for a person reading a failure report.
-//
-// Deliberately not http.Response.Write. That is a wire-format serializer: it
-// honours ContentLength and Transfer-Encoding, which describe the body that
-// arrived rather than the rendering that replaces it here. A placeholder or a
-// hex dump longer than the original body was cut to the original's length, and
-// a chunked response had its framing interleaved with the rendering (#18).
-//
-// Go reported the mismatch on every such run -- "http: ContentLength=4 with
-// Body length 26" -- and the caller discarded it. Nothing to discard now: the
-// bytes below are the whole output.
-//
-// Write errors are ignored, following utils.go, because every caller renders
-// into an in-memory strings.Builder that cannot fail.
-func (r httpResponse) writeTo(w io.Writer, withBody bool) {
-	_, _ = fmt.Fprintf(w, "%s %s\n", r.Proto, r.Status)
-	writeHeaders(w, r.Header)
-	_, _ = fmt.Fprintln(w)
+	res = append(res, boolAssertion(fs, "assert-ok", httpassert.AssertStatusOK, httpassert.AssertStatusNOK)...)
+	res = append(res, boolAssertion(fs, "assert-body-empty", httpassert.AssertBodyEmpty, httpassert.AssertBodyNotEmpty)...)
 
-	if !withBody {
-		_, _ = fmt.Fprint(w, "  << Payload is omitted >>")
-		return
-	}
-	// The headers above still say how the body arrived, so plain text under a
-	// Content-Encoding header needs explaining -- as does a hex dump under one.
-	switch {
-	case r.DecodeErr != nil:
-		_, _ = fmt.Fprintf(w, "  << Payload is %s-encoded and was not decoded >>\n\n", r.Encoding)
-	case r.Encoding != "" && !strings.EqualFold(r.Encoding, "identity"):
-		_, _ = fmt.Fprintf(w, "  << Payload decoded from %s >>\n\n", r.Encoding)
+	if fs.Changed("assert-redirect") {
+		v, _ := fs.GetString("assert-redirect")
+		a, err := compileAssertion("--assert-redirect", v, httpassert.AssertRedirectMatch)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, a)
 	}
-	if cropped := printPayload(w, r.BodyBytes, maxPayloadBytes); cropped > 0 {
-		_, _ = fmt.Fprintf(w, "\n\n  << Payload is cropped: %d bytes are hidden >>", cropped)
+	if fs.Changed("assert-redirect-eq") {
+		v, _ := fs.GetString("assert-redirect-eq")
+		res = append(res, httpassert.AssertRedirectEqual(v))
 	}
-}
 
-// writeHeaders renders headers one per line, sorted by name so that two dumps
-// of the same response can be compared.
-func writeHeaders(w io.Writer, h http.Header) {
-	for _, name := range slices.Sorted(maps.Keys(h)) {
-		for _, value := range h[name] {
-			_, _ = fmt.Fprintf(w, "%s: %s\n", name, value)
+	if fs.Changed("assert-status") {
+		v, _ := fs.GetString("assert-status")
+		spec, err := httpassert.ParseStatusSpec(v)
+		if err != nil {
+			return nil, invalidf("Invalid value for --assert-status flag: %s", err)
 		}
+		res = append(res, httpassert.AssertStatus(spec))
 	}
-}
 
-type hostMapping struct {
-	// Src is the source host in the form of `hostname:port`.
-	Src string
-	// Dst is the destination host in the form of either `hostname:port` or just
-	// `hostname`. If just the hostname is specified without a port then the
-	// source port will be used.
-	Dst string
-}
+	if fs.Changed("assert-header") {
+		vs, _ := fs.GetStringArray("assert-header")
+		as, err := parseHeaderAssertions(vs, false)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, as...)
+	}
+	if fs.Changed("assert-header-eq") {
+		vs, _ := fs.GetStringArray("assert-header-eq")
+		as, err := parseHeaderAssertions(vs, true)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, as...)
+	}
+	if fs.Changed("assert-header-missing") {
+		vs, _ := fs.GetStringArray("assert-header-missing")
+		for _, v := range vs {
+			res = append(res, httpassert.AssertHeaderMissing(strings.TrimSpace(v)))
+		}
+	}
 
-func (r hostMapping) Matches(host string) bool {
-	if r.Src == "" {
-		return false
+	if fs.Changed("assert-body") {
+		v, _ := fs.GetString("assert-body")
+		a, err := compileAssertion("--assert-body", v, httpassert.AssertBodyMatch)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	if fs.Changed("assert-body-eq") {
+		v, _ := fs.GetString("assert-body-eq")
+		res = append(res, httpassert.AssertBodyEqual(v))
 	}
 
-	if r.Src == "*" || r.Src == "*:*" {
-		return true
+	// One assertion per occurrence. --assert-jq is a stringArray, so rejectRepeats
+	// leaves it alone and repeating it accumulates, exactly as it does for the
+	// three header assertions.
+	if fs.Changed("assert-jq") {
+		vs, _ := fs.GetStringArray("assert-jq")
+		for _, v := range vs {
+			a, err := compileAssertion("--assert-jq", v, httpassert.AssertJQ)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, a)
+		}
+	}
+	if fs.Changed("assert-time") {
+		vs, _ := fs.GetStringArray("assert-time")
+		for _, v := range vs {
+			spec, err := httpassert.ParseTimeSpec(v)
+			if err != nil {
+				return nil, invalidf("Invalid value for --assert-time flag: %s", err)
+			}
+			res = append(res, httpassert.AssertTime(spec))
+		}
 	}
 
-	if strings.HasPrefix(r.Src, "*:") {
-		// Match by port only
-		return strings.HasSuffix(host, r.Src[1:])
+	if fs.Changed("assert-cert-expires-after") {
+		v, _ := fs.GetString("assert-cert-expires-after")
+		window, err := httpassert.ParseCertWindow(v)
+		if err != nil {
+			return nil, invalidf("Invalid value for --assert-cert-expires-after flag: %s", err)
+		}
+		res = append(res, httpassert.AssertCertExpiresAfter(window))
+	}
+	if fs.Changed("assert-cert-san") {
+		vs, _ := fs.GetStringArray("assert-cert-san")
+		for _, v := range vs {
+			res = append(res, httpassert.AssertCertSAN(strings.TrimSpace(v)))
+		}
+	}
+	if fs.Changed("assert-cert-issuer") {
+		v, _ := fs.GetString("assert-cert-issuer")
+		a, err := compileAssertion("--assert-cert-issuer", v, httpassert.AssertCertIssuer)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	if fs.Changed("assert-tls-version") {
+		v, _ := fs.GetString("assert-tls-version")
+		spec, err := httpassert.ParseTLSVersionSpec(v)
+		if err != nil {
+			return nil, invalidf("Invalid value for --assert-tls-version flag: %s", err)
+		}
+		res = append(res, httpassert.AssertTLSVersion(spec))
+	}
+	if fs.Changed("assert-alpn") {
+		v, _ := fs.GetString("assert-alpn")
+		res = append(res, httpassert.AssertALPN(strings.TrimSpace(v)))
+	}
+	if fs.Changed("assert-proto") {
+		v, _ := fs.GetString("assert-proto")
+		proto, err := httpassert.ParseProto(v)
+		if err != nil {
+			return nil, invalidf("Invalid value for --assert-proto flag: %s", err)
+		}
+		res = append(res, httpassert.AssertProto(proto))
+	}
+	altSvcs, _ := fs.GetStringArray("assert-alt-svc")
+	for _, v := range altSvcs {
+		if v = strings.TrimSpace(v); v == "" || strings.HasPrefix(v, "=") {
+			return nil, invalidf("Invalid value for --assert-alt-svc flag: %q names no protocol; write e.g. h3 or h3=:443", v)
+		}
+		res = append(res, httpassert.AssertAltSvc(v))
+	}
+	cookies, _ := fs.GetStringArray("assert-cookie")
+	for _, v := range cookies {
+		name, pattern, _ := strings.Cut(v, "=")
+		if name = strings.TrimSpace(name); name == "" {
+			return nil, invalidf("Invalid value for --assert-cookie flag: %q names no cookie; write NAME or NAME=REGEXP", v)
+		}
+		a, err := compileAssertion("--assert-cookie", pattern, func(p string) (httpassert.Assertion, error) {
+			return httpassert.AssertCookie(name, p)
+		})
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, a)
 	}
 
-	return r.Src == host
+	return res, nil
 }
 
-func (r hostMapping) DstHost() string {
-	// Dst already has a port
-	if idx := strings.Index(r.Dst, ":"); idx >= 0 {
-		return r.Dst
-	}
+func parseHeaderAssertions(vs []string, exactMatch bool) ([]httpassert.Assertion, error) {
+	var res []httpassert.Assertion
 
-	// Use the source port
-	var port string
-	if idx := strings.Index(r.Src, ":"); idx >= 0 {
-		port = r.Src[idx:]
+	for _, v := range vs {
+		name, value := parseHeaderLine(v)
+		if exactMatch {
+			if value == "" {
+				res = append(res, httpassert.AssertHeaderPresent(name))
+			} else {
+				res = append(res, httpassert.AssertHeaderEqual(name, value))
+			}
+		} else {
+			if value == "" {
+				res = append(res, httpassert.AssertHeaderPresent(name))
+			} else {
+				a, err := compileAssertion("--assert-header", value,
+					func(p string) (httpassert.Assertion, error) { return httpassert.AssertHeaderMatch(name, p) })
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, a)
+			}
+		}
 	}
-	return r.Dst + port
+
+	return res, nil
 }
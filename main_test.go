@@ -1,9 +1,10 @@
 package main
 
 import (
-	"fmt"
 	"reflect"
 	"testing"
+
+	"github.com/korya/http-assert/httpassert"
 )
 
 func Test_parseHostMappings(t *testing.T) {
@@ -12,7 +13,7 @@ func Test_parseHostMappings(t *testing.T) {
 	testCases := []struct {
 		CaseName string
 		Input    []string
-		Output   []hostMapping
+		Output   []httpassert.HostMapping
 		Error    string
 	}{
 		{CaseName: "Null"},
@@ -21,12 +22,12 @@ func Test_parseHostMappings(t *testing.T) {
 		{
 			CaseName: "Simple",
 			Input:    []string{"a:11=bbb:2222"},
-			Output:   []hostMapping{{Src: "a:11", Dst: "bbb:2222"}},
+			Output:   []httpassert.HostMapping{{Src: "a:11", Dst: "bbb:2222"}},
 		},
 		{
 			CaseName: "Simple: no dst port",
 			Input:    []string{"a:11=bbb"},
-			Output:   []hostMapping{{Src: "a:11", Dst: "bbb"}},
+			Output:   []httpassert.HostMapping{{Src: "a:11", Dst: "bbb"}},
 		},
 		{
 			CaseName: "Simple: no separators",
@@ -48,6 +49,66 @@ func Test_parseHostMappings(t *testing.T) {
 			Input:    []string{"a:11=bbb:zzzzz"},
 			Error:    `value "a:11=bbb:zzzzz" has invalid dst port "zzzzz"`,
 		},
+		{
+			CaseName: "Wildcards",
+			Input:    []string{"*=a", "*:*=a", "*:80=a", "*.example.com:443=a", "api-[0-9].example.com:*=a"},
+			Output: []httpassert.HostMapping{
+				{Src: "*", Dst: "a"}, {Src: "*:*", Dst: "a"}, {Src: "*:80", Dst: "a"},
+				{Src: "*.example.com:443", Dst: "a"}, {Src: "api-[0-9].example.com:*", Dst: "a"},
+			},
+		},
+		{
+			CaseName: "CIDR and IPv6",
+			Input:    []string{"10.0.0.0/8:80=[::1]", "[2001:db8::/32]:443=[::1]:8443"},
+			Output: []httpassert.HostMapping{
+				{Src: "10.0.0.0/8:80", Dst: "[::1]"}, {Src: "[2001:db8::/32]:443", Dst: "[::1]:8443"},
+			},
+		},
+		{
+			CaseName: "Several destinations",
+			Input:    []string{"a:11=b,c:22,[::1]"},
+			Output:   []httpassert.HostMapping{{Src: "a:11", Dst: "b,c:22,[::1]"}},
+		},
+		{
+			CaseName: "Several destinations: invalid dst port",
+			Input:    []string{"a:11=b,c:zz"},
+			Error:    `value "a:11=b,c:zz" has invalid dst port "zz"`,
+		},
+		{
+			CaseName: "Several destinations: empty dst",
+			Input:    []string{"a:11=b,"},
+			Error:    `value "a:11=b," has an empty dst`,
+		},
+		{
+			CaseName: "Mappings separated by a comma",
+			Input:    []string{"a:11=b,c:22=d"},
+			Error:    `value "a:11=b,c:22=d" holds more than one mapping; repeat --maphost, or separate them with spaces`,
+		},
+		{
+			CaseName: "Invalid src CIDR block",
+			Input:    []string{"10.0.0.0/33:80=b"},
+			Error:    `value "10.0.0.0/33:80=b" has invalid src CIDR block "10.0.0.0/33"`,
+		},
+		{
+			CaseName: "Invalid src pattern",
+			Input:    []string{"api-[0-9.example.com:80=b"},
+			Error:    `value "api-[0-9.example.com:80=b" has invalid src pattern "api-[0-9.example.com"`,
+		},
+		{
+			CaseName: "No src host",
+			Input:    []string{":80=b"},
+			Error:    `value ":80=b" has no src host ":80"`,
+		},
+		{
+			CaseName: "Src port out of range",
+			Input:    []string{"a:65536=b"},
+			Error:    `value "a:65536=b" has invalid src port "65536"`,
+		},
+		{
+			CaseName: "Bare IPv6 dst",
+			Input:    []string{"a:80=::1"},
+			Error:    `value "a:80=::1" has invalid dst "::1"; write an IPv6 address in brackets`,
+		},
 		// Multple
 		{
 			CaseName: "Multiple 1",
@@ -164,7 +225,7 @@ func Test_parseHostMappings(t *testing.T) {
 				"example.com:80=example.ca",
 				"example.com:80=example.ca:1080",
 			},
-			Output: []hostMapping{
+			Output: []httpassert.HostMapping{
 				{Src: "a:11", Dst: "bbb:2222"},
 				{Src: "a:11", Dst: "bbb"},
 				{Src: "a:11", Dst: "bbb:333"},
@@ -197,71 +258,27 @@ func Test_parseHostMappings(t *testing.T) {
 	}
 }
 
-func TestHostMapping_Matches(t *testing.T) {
+func Test_urlencodePart(t *testing.T) {
 	t.Parallel()
 
-	testCases := []struct {
-		SrcHost string
-		Input   string
-		Output  bool
-	}{
-		{"", "", false},
-		{"src", "", false},
-		{"src", "example.com", false},
-		{"", "example.com", false},
-		{"src", "src", true},
-		{"example.com", "example.com", true},
-		{"example.com:80", "example.com", false},
-		{"example.com:80", "example.com:99", false},
-		{"example.com", "example.com:99", false},
-		{"example.com:99", "example.com:99", true},
-		{"*", "", true},
-		{"*", "example.com", true},
-		{"*", "example.com:99", true},
-		{"*:12", "", false},
-		{"*:12", "example.com", false},
-		{"*:12", "example.com:99", false},
-		{"*:12", "example.com:12", true},
-	}
-
-	for _, tc := range testCases {
-		t.Run(fmt.Sprintf("%q matches %q", tc.SrcHost, tc.Input), func(t *testing.T) {
-			m := hostMapping{Src: tc.SrcHost}
-			if got := m.Matches(tc.Input); got != tc.Output {
-				t.Errorf("hostMapping{Src: %q}.Matches(%q) = %v, want %v",
-					tc.SrcHost, tc.Input, got, tc.Output)
+	for in, want := range map[string]string{
+		"q=a b&c":    "q=a%20b%26c",
+		"=a=b":       "a%3Db",
+		"plain text": "plain%20text",
+		"q=1+1 ~x":   "q=1%2B1%20~x",
+		"q=":         "q=",
+		"to=a@b.c":   "to=a%40b.c",
+		"a@missing":  "",
+	} {
+		got, err := urlencodePart(in)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%q: got %q, want an error", in, got)
 			}
-		})
-	}
-}
-
-func TestHostMapping_DstHost(t *testing.T) {
-	t.Parallel()
-
-	testCases := []struct {
-		SrcHost string
-		DstHost string
-		Output  string
-	}{
-		{"", "", ""},
-		{"src", "", ""},
-		{"src", "example.com", "example.com"},
-		{"", "example.com", "example.com"},
-		{"src", "src", "src"},
-		{"example.com", "example.ca", "example.ca"},
-		{"example.com:80", "example.ca", "example.ca:80"},
-		{"example.com:80", "example.ca:99", "example.ca:99"},
-		{"example.com", "example.ca:99", "example.ca:99"},
-		{"example.com:99", "example.ca:99", "example.ca:99"},
-	}
-
-	for _, tc := range testCases {
-		t.Run(fmt.Sprintf("src=%q dst=%q", tc.SrcHost, tc.DstHost), func(t *testing.T) {
-			m := hostMapping{Src: tc.SrcHost, Dst: tc.DstHost}
-			if got := m.DstHost(); got != tc.Output {
-				t.Errorf("hostMapping{Src: %q, Dst: %q}.DstHost() = %q, want %q",
-					tc.SrcHost, tc.DstHost, got, tc.Output)
-			}
-		})
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("%q: got %q, %v; want %q", in, got, err, want)
+		}
 	}
 }
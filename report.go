@@ -0,0 +1,689 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/korya/http-assert/httpassert"
+	"github.com/spf13/cobra"
+)
+
+// reportFormats are the values --report accepts.
+var reportFormats = []string{"json", "tap"}
+
+// reportSink is one destination for a report: --report's, or --junit's.
+type reportSink struct {
+	flag   string
+	format string
+	f      *os.File
+}
+
+// mustOpenReports opens every report destination before the request is made.
+//
+// A path that cannot be written is a mistake in the invocation, and finding it
+// after the run would mean the run's evidence had nowhere to go -- so it exits
+// 71 with no request attempted, like every other invocation error.
+func mustOpenReports(cmd *cobra.Command) []reportSink {
+	var res []reportSink
+
+	if cmd.Flags().Changed("report") {
+		s := reportSink{flag: "--report", f: os.Stdout}
+		s.format, _ = cmd.Flags().GetString("report")
+		if path, _ := cmd.Flags().GetString("report-file"); path != "" && path != "-" {
+			s.f = mustCreateReport("--report-file", path)
+		}
+		res = append(res, s)
+	}
+
+	// JUnit is always a file: a CI system picks it up from a path, and
+	// nothing reads it off a pipe.
+	if cmd.Flags().Changed("junit") {
+		path, _ := cmd.Flags().GetString("junit")
+		res = append(res, reportSink{flag: "--junit", format: "junit", f: mustCreateReport("--junit", path)})
+	}
+
+	return res
+}
+
+func mustCreateReport(flag, path string) *os.File {
+	f, err := os.Create(path) // #nosec G304 - the caller named the file to write
+	if err != nil {
+		dief(exitBadInvocation, "Cannot open %s file: %s", flag, err)
+	}
+
+	return f
+}
+
+// writeReports renders the run into every destination and closes them. write
+// renders it in one format: a single run's, or a suite's.
+//
+// A failure here is announced rather than allowed to change the exit code. The
+// code describes the service, and the service did whatever it did regardless
+// of whether the disk had room for the report about it.
+func writeReports(sinks []reportSink, write func(w io.Writer, format string) error) {
+	for _, s := range sinks {
+		err := write(s.f, s.format)
+		if s.f != os.Stdout {
+			if cerr := s.f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot write the %s report: %s\n", s.flag, err)
+		}
+	}
+}
+
+// writeReport renders a run in the named format. The format has already been
+// checked by checkReportFlags, so an unknown one here is a programming error
+// and is reported rather than silently writing nothing.
+//
+// "junit" is not one of reportFormats: it is reachable through --junit only.
+func writeReport(w io.Writer, format string, r *httpassert.Result) error {
+	switch format {
+	case "json":
+		return writeJSONReport(w, r)
+	case "tap":
+		return writeTAPReport(w, r)
+	case "junit":
+		return writeJUnitReport(w, r)
+	}
+
+	return fmt.Errorf("no writer for report format %q", format)
+}
+
+// writeSuiteReport renders a suite run in the named format: the single-run
+// format once per request, inside one document, so a consumer of either reads
+// the same fields.
+func writeSuiteReport(w io.Writer, format string, s *suiteResult) error {
+	switch format {
+	case "json":
+		return writeJSONSuiteReport(w, s)
+	case "tap":
+		return writeTAPSuiteReport(w, s)
+	case "junit":
+		return writeJUnitSuiteReport(w, s)
+	}
+
+	return fmt.Errorf("no writer for report format %q", format)
+}
+
+// exitCodeOf is the exit code a run ends with: the CLI's own tag if it has one,
+// and otherwise the kind the library wrapped the failure in.
+//
+// An error nobody tagged stays in the transport bucket: wrong by at most one
+// category, and never reported as a usage mistake the caller did not make.
+func exitCodeOf(err error) int {
+	var e *exitError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &e):
+		return e.code
+	case errors.Is(err, httpassert.ErrAssertion):
+		return exitAssertFail
+	case errors.Is(err, httpassert.ErrNoAssertions):
+		return exitBadInvocation
+	}
+
+	return exitTransportFail
+}
+
+// exitCategory names an exit code for a reader that should not have to know
+// the numbers.
+func exitCategory(code int) string {
+	switch code {
+	case exitOK:
+		return "ok"
+	case exitBadInvocation:
+		return "invocation"
+	case exitAssertFail:
+		return "assertion"
+	}
+
+	return "transport"
+}
+
+// Verdicts an assertion can reach. "error" is the one #45 split out: the
+// assertion could not be evaluated, which is not the same news as a service
+// that answered wrongly. "not_run" means no response arrived to check.
+const (
+	verdictPassed = "passed"
+	verdictFailed = "failed"
+	verdictError  = "error"
+	verdictNotRun = "not_run"
+)
+
+// jsonReport is the document --report json writes: one per run.
+//
+// It is a schema rather than a rendering of the prose, which is the reason it
+// exists. The dump's wording is free to change; the field names here are not,
+// and a dashboard reading "verdict" must keep working when a message does not.
+type jsonReport struct {
+	Method       string `json:"method"`
+	URL          string `json:"url"`
+	Verdict      string `json:"verdict"`
+	ExitCode     int    `json:"exit_code"`
+	ExitCategory string `json:"exit_category"`
+	// Assertions are the verdicts of the last attempt, which is the one the
+	// exit code describes.
+	Assertions []jsonAssertion `json:"assertions"`
+	Attempts   []jsonAttempt   `json:"attempts"`
+}
+
+type jsonAttempt struct {
+	Attempt    int       `json:"attempt"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS float64   `json:"duration_ms"`
+	Status     int       `json:"status,omitempty"`
+	// Error is why no response arrived. It is empty whenever one did, however
+	// badly the assertions then went.
+	Error      string          `json:"error,omitempty"`
+	Assertions []jsonAssertion `json:"assertions"`
+}
+
+type jsonAssertion struct {
+	Kind    string `json:"kind"`
+	Target  string `json:"target,omitempty"`
+	Verdict string `json:"verdict"`
+	// Failure is set for a "failed" verdict and Error for an "error" one; the
+	// two are never both present.
+	Failure *jsonFailure `json:"failure,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+type jsonFailure struct {
+	Target   string `json:"target,omitempty"`
+	Expected any    `json:"expected,omitempty"`
+	Actual   any    `json:"actual,omitempty"`
+	Message  string `json:"message"`
+}
+
+func writeJSONReport(w io.Writer, r *httpassert.Result) error {
+	return writeJSON(w, newJSONReport(r))
+}
+
+func newJSONReport(r *httpassert.Result) jsonReport {
+	code := exitCodeOf(r.Err)
+	doc := jsonReport{
+		Verdict:      verdictPassed,
+		ExitCode:     code,
+		ExitCategory: exitCategory(code),
+		Assertions:   jsonAssertions(r.Assertions, nil),
+		Attempts:     []jsonAttempt{},
+	}
+	if code != exitOK {
+		doc.Verdict = verdictFailed
+	}
+	if r.Request != nil {
+		doc.Method, doc.URL = r.Request.Method, r.Request.URL.Redacted()
+	}
+
+	for i, a := range r.Attempts {
+		ja := jsonAttempt{
+			Attempt:    i + 1,
+			StartedAt:  a.StartedAt.UTC(),
+			DurationMS: float64(a.Duration.Microseconds()) / 1000,
+			Assertions: jsonAssertions(r.Assertions, a.Checks),
+		}
+		if a.Response != nil {
+			ja.Status = a.Response.StatusCode
+		}
+		if a.SendErr != nil {
+			ja.Error = a.SendErr.Error()
+		}
+		doc.Attempts = append(doc.Attempts, ja)
+		doc.Assertions = ja.Assertions
+	}
+
+	return doc
+}
+
+func writeJSON(w io.Writer, doc any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(doc)
+}
+
+// jsonAssertions pairs every assertion with its verdict. checks is empty when
+// the attempt produced no response, and every assertion is then not_run rather
+// than missing: a report that drops the assertions it could not check reads
+// like a run that asked for fewer.
+func jsonAssertions(as []httpassert.Assertion, checks []httpassert.CheckResult) []jsonAssertion {
+	res := make([]jsonAssertion, 0, len(as))
+	for i, a := range as {
+		ja := jsonAssertion{Kind: a.Kind(), Target: a.Target(), Verdict: verdictNotRun}
+		if i < len(checks) {
+			switch c := checks[i]; {
+			case c.Err != nil:
+				ja.Verdict, ja.Error = verdictError, c.Err.Error()
+			case c.Failure != nil:
+				ja.Verdict = verdictFailed
+				ja.Failure = &jsonFailure{
+					Target:   c.Failure.Target,
+					Expected: c.Failure.Expected,
+					Actual:   c.Failure.Actual,
+					Message:  c.Failure.Message,
+				}
+			default:
+				ja.Verdict = verdictPassed
+			}
+		}
+		res = append(res, ja)
+	}
+
+	return res
+}
+
+// assertionName is how a report names an assertion: the kind, with the target
+// in brackets when there is one. It is the prefix the failure messages already
+// use -- header[X-Id], jq[.status] -- so a test case and the line in the dump
+// that explains it read the same.
+func assertionName(a httpassert.Assertion) string {
+	if t := a.Target(); t != "" {
+		return a.Kind() + "[" + t + "]"
+	}
+
+	return a.Kind()
+}
+
+// JUnit XML, as Jenkins and GitLab read it. There is no formal schema both
+// agree on; this is the common subset -- testsuites, testsuite, testcase with
+// failure, error and skipped, properties and system-out.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitTestCase `xml:"testcase"`
+	SystemOut  *junitText      `xml:"system-out,omitempty"`
+}
+
+// junitText is free text, written as CDATA so that a dump full of line breaks
+// and quotes stays readable in the file rather than turning into entities.
+type junitText struct {
+	Text string `xml:",cdata"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitProblem `xml:"skipped,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",cdata"`
+}
+
+// writeJUnitReport maps the run to one testsuite, and each assertion to a test
+// case named the way the failure dump names it.
+//
+// One more test case leads the suite: "request", which passes when a response
+// arrived and errors when none did. Without it a transport failure would have
+// nowhere to go -- no assertion ran, so none of them failed -- and a CI page
+// would show a suite of skipped tests for a service that was down. Keeping it
+// in a passing run too means the test count does not change with the weather.
+//
+// JUnit's own split between a failure and an error is exactly the one
+// Assertion.Check draws, so an assertion that could not be evaluated is an
+// <error> and one that did not hold is a <failure>.
+func writeJUnitReport(w io.Writer, r *httpassert.Result) error {
+	return writeJUnit(w, []junitTestSuite{newJUnitSuite(r)})
+}
+
+func newJUnitSuite(r *httpassert.Result) junitTestSuite {
+	suite := junitTestSuite{
+		Name:       "http-assert",
+		Properties: []junitProperty{{"attempts", strconv.Itoa(len(r.Attempts))}},
+	}
+	if r.Request != nil {
+		suite.Name = r.Request.Method + " " + r.Request.URL.Redacted()
+	}
+
+	last := lastAttempt(r)
+	var total time.Duration
+	if len(r.Attempts) > 0 {
+		suite.Timestamp = r.Attempts[0].StartedAt.UTC().Format("2006-01-02T15:04:05")
+		for _, a := range r.Attempts {
+			total += a.Duration
+		}
+	}
+	suite.Time = junitSeconds(total)
+	suite.Properties = append(suite.Properties,
+		junitProperty{"exit_code", strconv.Itoa(exitCodeOf(r.Err))})
+
+	request := junitTestCase{Name: "request", Classname: "request"}
+	switch {
+	case last.Response == nil && last.SendErr != nil:
+		request.Error = &junitProblem{Message: last.SendErr.Error(), Type: "transport"}
+	case len(r.Attempts) == 0 && r.Err != nil:
+		// A suite request that needed a value an earlier one failed to
+		// capture.
+		request.Error = &junitProblem{Message: r.Err.Error(), Type: "not_sent"}
+	}
+	suite.Cases = append(suite.Cases, request)
+
+	for i, a := range r.Assertions {
+		tc := junitTestCase{Name: assertionName(a), Classname: a.Kind()}
+		switch {
+		case i >= len(last.Checks):
+			tc.Skipped = &junitProblem{Message: "no response to assert on"}
+		case last.Checks[i].Err != nil:
+			err := last.Checks[i].Err
+			tc.Error = &junitProblem{Message: err.Error(), Type: a.Kind(), Text: err.Error()}
+		case last.Checks[i].Failure != nil:
+			f := last.Checks[i].Failure
+			tc.Failure = &junitProblem{Message: f.Message, Type: a.Kind(), Text: f.Message}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	for _, tc := range suite.Cases {
+		suite.Tests++
+		switch {
+		case tc.Failure != nil:
+			suite.Failures++
+		case tc.Error != nil:
+			suite.Errors++
+		case tc.Skipped != nil:
+			suite.Skipped++
+		}
+	}
+	if last.Details != "" {
+		suite.SystemOut = &junitText{last.Details}
+	}
+
+	return suite
+}
+
+// writeJUnit writes the document around suites, with the totals a CI page
+// shows before anyone opens a suite.
+func writeJUnit(w io.Writer, suites []junitTestSuite) error {
+	doc := junitTestSuites{Suites: suites}
+	var total float64
+	for _, s := range suites {
+		doc.Tests += s.Tests
+		doc.Failures += s.Failures
+		doc.Errors += s.Errors
+		t, _ := strconv.ParseFloat(s.Time, 64)
+		total += t
+	}
+	doc.Time = strconv.FormatFloat(total, 'f', 3, 64)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// junitSeconds is the time attribute's format: seconds, as a decimal.
+func junitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// writeTAPReport renders the run as TAP version 14: a test point per assertion,
+// in the order the flags built them, each failure followed by a YAML
+// diagnostic block.
+//
+// TAP has no verdict for "could not be evaluated", and its only directives,
+// SKIP and TODO, both count as passing -- so an evaluation error is a not ok
+// like a failure, told apart by "severity: error" in its block and the
+// description suffix a person reading the stream sees first. A harness that
+// only counts not-oks still gets the right answer; one that reads the blocks
+// gets the #45 distinction as well.
+//
+// A transport failure is a "Bail out!": no response arrived, so no test point
+// could say anything true, and TAP's word for a run that could not continue is
+// exactly that. The plan is printed first so the line still parses as a bail
+// out of a run with a known size.
+func writeTAPReport(w io.Writer, r *httpassert.Result) error {
+	var b strings.Builder
+
+	b.WriteString("TAP version 14\n")
+	if !writeTAPPoints(&b, "", r) {
+		reason := "no response"
+		if err := lastAttempt(r).SendErr; err != nil {
+			reason = tapLine(err.Error())
+		}
+		fmt.Fprintf(&b, "Bail out! %s\n", reason)
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// writeTAPPoints writes the plan and a test point per assertion, each line
+// prefixed with indent. It writes no test points when no response arrived, and
+// says so by returning false: what that means is the caller's to decide.
+func writeTAPPoints(b *strings.Builder, indent string, r *httpassert.Result) bool {
+	if r.Request != nil {
+		fmt.Fprintf(b, "%s# %s %s\n", indent, r.Request.Method, r.Request.URL.Redacted())
+	}
+	if n := len(r.Attempts); n > 1 {
+		fmt.Fprintf(b, "%s# %d attempts; the verdicts are the last one's\n", indent, n)
+	}
+	fmt.Fprintf(b, "%s1..%d\n", indent, len(r.Assertions))
+
+	last := lastAttempt(r)
+	if last.Response == nil {
+		return false
+	}
+
+	for i, a := range r.Assertions {
+		var c httpassert.CheckResult
+		if i < len(last.Checks) {
+			c = last.Checks[i]
+		}
+
+		name := tapLine(assertionName(a))
+		switch {
+		case c.Err != nil:
+			fmt.Fprintf(b, "%snot ok %d - %s (evaluation error)\n", indent, i+1, name)
+			tapDiag(b, indent, []tapField{
+				{"severity", verdictError},
+				{"kind", a.Kind()},
+				{"message", c.Err.Error()},
+			})
+		case c.Failure != nil:
+			f := c.Failure
+			fmt.Fprintf(b, "%snot ok %d - %s\n", indent, i+1, name)
+			tapDiag(b, indent, []tapField{
+				{"severity", "fail"},
+				{"kind", a.Kind()},
+				{"target", f.Target},
+				{"expected", f.Expected},
+				{"actual", f.Actual},
+				{"message", f.Message},
+			})
+		default:
+			fmt.Fprintf(b, "%sok %d - %s\n", indent, i+1, name)
+		}
+	}
+
+	return true
+}
+
+// lastAttempt is the attempt whose verdicts a report shows: the one the exit
+// code describes. It is the zero value for a run that made none.
+func lastAttempt(r *httpassert.Result) httpassert.Attempt {
+	if n := len(r.Attempts); n > 0 {
+		return r.Attempts[n-1]
+	}
+
+	return httpassert.Attempt{}
+}
+
+// tapField is one key of a diagnostic block. The keys are a slice rather than
+// a map so that the block reads the same way on every run.
+type tapField struct {
+	Key   string
+	Value any
+}
+
+// tapDiag writes a YAML diagnostic block, indented under its test point.
+//
+// Every value is written as JSON, which YAML reads as a flow scalar or
+// collection. That keeps a body full of colons, quotes and line breaks from
+// needing a YAML emitter -- and from breaking out of the block. Empty values
+// are left out, as they are in the JSON report. The encoder's newline ends the
+// line.
+func tapDiag(b *strings.Builder, indent string, fields []tapField) {
+	fmt.Fprintf(b, "%s  ---\n", indent)
+	for _, f := range fields {
+		if f.Value == nil || f.Value == "" {
+			continue
+		}
+		var v bytes.Buffer
+		enc := json.NewEncoder(&v)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(f.Value); err != nil {
+			v.Reset()
+			_ = enc.Encode(fmt.Sprint(f.Value))
+		}
+		fmt.Fprintf(b, "%s  %s: %s", indent, f.Key, v.Bytes())
+	}
+	fmt.Fprintf(b, "%s  ...\n", indent)
+}
+
+// tapLine keeps a description on its line. A newline there would end the test
+// point and start a line TAP reads as something else, and a "#" would begin a
+// directive the assertion never asked for.
+func tapLine(s string) string {
+	return strings.NewReplacer("\n", " ", "\r", " ", "#", "\\#").Replace(s)
+}
+
+// jsonSuiteReport is the document --report json writes for a suite: the
+// suite's verdict, and every request's own report under its name.
+type jsonSuiteReport struct {
+	Verdict      string             `json:"verdict"`
+	ExitCode     int                `json:"exit_code"`
+	ExitCategory string             `json:"exit_category"`
+	Requests     []jsonSuiteRequest `json:"requests"`
+}
+
+type jsonSuiteRequest struct {
+	Name string `json:"name"`
+	jsonReport
+	// Error says why a request has no attempts: it needed a value that an
+	// earlier request failed to capture.
+	Error string `json:"error,omitempty"`
+}
+
+func writeJSONSuiteReport(w io.Writer, s *suiteResult) error {
+	code := s.exitCode()
+	doc := jsonSuiteReport{
+		Verdict:      verdictPassed,
+		ExitCode:     code,
+		ExitCategory: exitCategory(code),
+		Requests:     []jsonSuiteRequest{},
+	}
+	if code != exitOK {
+		doc.Verdict = verdictFailed
+	}
+	for _, c := range s.Cases {
+		req := jsonSuiteRequest{Name: c.Name, jsonReport: newJSONReport(c.Result)}
+		if len(c.Attempts) == 0 && c.Err != nil {
+			req.Error = c.Err.Error()
+		}
+		doc.Requests = append(doc.Requests, req)
+	}
+
+	return writeJSON(w, doc)
+}
+
+// writeTAPSuiteReport writes a test point per request, each with its
+// assertions as a subtest.
+//
+// A request that got no response cannot bail out the way a single run does --
+// "Bail out!" ends the whole stream, and the requests after it did run -- so
+// it is a not ok with the transport error in its block, and no subtest.
+func writeTAPSuiteReport(w io.Writer, s *suiteResult) error {
+	var b strings.Builder
+
+	b.WriteString("TAP version 14\n")
+	fmt.Fprintf(&b, "1..%d\n", len(s.Cases))
+	for i, c := range s.Cases {
+		name := tapLine(c.Name)
+
+		var sub strings.Builder
+		if writeTAPPoints(&sub, "    ", c.Result) {
+			fmt.Fprintf(&b, "# Subtest: %s\n%s", name, sub.String())
+		}
+
+		if c.Err == nil {
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, name)
+			continue
+		}
+		fmt.Fprintf(&b, "not ok %d - %s\n", i+1, name)
+		switch last := lastAttempt(c.Result); {
+		case last.Response == nil && last.SendErr != nil:
+			tapDiag(&b, "", []tapField{
+				{"severity", exitCategory(exitTransportFail)},
+				{"message", last.SendErr.Error()},
+			})
+		case len(c.Attempts) == 0:
+			tapDiag(&b, "", []tapField{
+				{"severity", exitCategory(exitCodeOf(c.Err))},
+				{"message", c.Err.Error()},
+			})
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// writeJUnitSuiteReport writes a testsuite per request, named as the suite
+// file names it; the request line it would otherwise be named by moves to a
+// property.
+func writeJUnitSuiteReport(w io.Writer, s *suiteResult) error {
+	var suites []junitTestSuite
+	for _, c := range s.Cases {
+		js := newJUnitSuite(c.Result)
+		js.Properties = append([]junitProperty{{"request", js.Name}}, js.Properties...)
+		js.Name = c.Name
+		suites = append(suites, js)
+	}
+
+	return writeJUnit(w, suites)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/korya/http-assert/httpassert"
+)
+
+// A saved response is one somebody else received: `curl -i` output, a line cut
+// from a proxy log, a fixture checked into a repository. --from-response reads
+// one in place of making a request, so the assertions, the failure dump and the
+// exit codes are the ones a live run gets -- and an assertion set can be tried
+// out with no server at all.
+
+// loadResponse reads a saved response from a file, or from stdin for "-".
+// Either failing is a mistake in the invocation: there is no service to blame.
+func loadResponse(path string) (*httpassert.Response, error) {
+	var raw []byte
+	var err error
+	if path == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(path) // #nosec G304 - the caller named the file to read
+	}
+	if err != nil {
+		return nil, invalidf("Cannot read --from-response file: %s", err)
+	}
+
+	res, err := httpassert.ReadResponse(raw)
+	if err != nil {
+		return nil, invalidf("Invalid --from-response file %s: %s", path, err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,620 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/korya/http-assert/httpassert"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"go.yaml.in/yaml/v3"
+)
+
+// A suite file declares many requests, each with its own assertions, in one
+// document. It exists so a smoke test is one file and one process rather than
+// a shell loop around the binary, with one exit code at the end.
+//
+// The vocabulary is the command line's: every key of a request is the long
+// name of a flag, and its value is what the flag would have been given. So a
+// request is parsed by the very code that parses the command line, and nothing
+// a suite can say means something different from the flag it is named after.
+
+// suiteFile is the document. JSON is valid YAML, so one parser reads both.
+type suiteFile struct {
+	Requests []map[string]any `yaml:"requests"`
+}
+
+// suiteCase is one request of a suite, built and validated before any request
+// is sent.
+type suiteCase struct {
+	Name       string
+	Client     httpassert.Client
+	Request    *http.Request
+	Assertions []httpassert.Assertion
+	// Captures are also among the Assertions; they are here for their values.
+	Captures []*httpassert.Capture
+	// Defines names what the request captures.
+	Defines []string
+
+	// A request that interpolates a captured value cannot be built until the
+	// value exists. Its keys are checked when the file is loaded, as far as
+	// they can be without the values, and it is built from Spec when its turn
+	// comes. Needs names the values; Spec is nil for every other request.
+	Needs []string
+	Spec  map[string]any
+}
+
+// suiteResult is what running a suite observed: every request's run, in the
+// order the file declares them.
+type suiteResult struct {
+	Cases []caseResult
+}
+
+type caseResult struct {
+	Name string
+	*httpassert.Result
+}
+
+// exitCode folds the requests' exit codes into the suite's.
+//
+// A transport failure wins over an assertion failure. 93 says a response
+// arrived and was wrong, and a suite that exited 93 while one of its requests
+// never got an answer would be read as "everything answered" -- the outage is
+// the bigger news.
+func (s *suiteResult) exitCode() int {
+	res := exitOK
+	for _, c := range s.Cases {
+		switch code := exitCodeOf(c.Err); {
+		case code == exitTransportFail:
+			return code
+		case code != exitOK:
+			res = code
+		}
+	}
+
+	return res
+}
+
+func newRunCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run <suite-file>",
+		Short: "Perform every request in a suite file and assert on each response",
+		Long: `Perform every request in a suite file and assert on each response.
+
+The file is YAML or JSON, with a list of requests. Each request has a name and
+a url, and every other key is the long name of a request or assertion flag,
+with the value the flag would take; a repeatable flag takes a list:
+
+  requests:
+    - name: health
+      url: https://api.example.com/health
+      assert-ok: true
+      assert-header-eq: ["Content-Type: application/json"]
+    - name: create
+      url: https://api.example.com/things
+      data: '{"name": "x"}'
+      header: ["Content-Type: application/json"]
+      assert-status: 201
+      retry: 3
+
+` + suiteKeysHelp() + `
+
+A request can capture values from its response for the requests after it:
+
+  capture         NAME=<jq expression>, which must yield one value
+  capture-header  NAME=<header name>
+  capture-body    NAME=<regexp>; its first group, or the whole match
+
+A later request uses one as {{NAME}} in its url, percent-encoded within a path
+segment or the query, or in the value of any key:
+
+    - name: login
+      url: https://api.example.com/login
+      data: '{"user": "ci", "password": "..."}'
+      capture: ["TOKEN=.access_token"]
+    - name: me
+      url: https://api.example.com/me
+      header: ["Authorization: Bearer {{TOKEN}}"]
+      assert-ok: true
+
+A capture that finds nothing fails its request as an assertion would. A request
+that needs a value its failed request did not capture is not sent, and fails
+with the exit code of the request it depended on.
+
+Every request is validated before the first one is sent, so a mistake anywhere
+in the file exits 71 with nothing sent. The requests then run in order, and a
+failed one does not stop the rest.
+
+Exit codes:
+  0    every request passed
+  71   the suite was rejected; no request was attempted
+  92   at least one request produced no usable response
+  93   every request got a response, and at least one assertion failed`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			mustSetPalette(cmd)
+			logLevel := mustParseLogLevel(cmd)
+
+			cases, err := loadSuite(cmd.Context(), args[0], cmd.Flags())
+			dieOn(err)
+			reports := mustOpenReports(cmd)
+
+			res := runSuite(cmd.Context(), cases, cmd.Flags(), logLevel)
+			saveCookieJars()
+			writeReports(reports, func(w io.Writer, format string) error {
+				return writeSuiteReport(w, format, res)
+			})
+
+			var failed []string
+			for _, c := range res.Cases {
+				if c.Err != nil {
+					failed = append(failed, fmt.Sprintf("%q", c.Name))
+				}
+			}
+			if len(failed) > 0 {
+				dief(res.exitCode(), "%d of %d requests failed: %s",
+					len(failed), len(res.Cases), strings.Join(failed, ", "))
+			}
+			logInfo(logLevel, "[+] PASSED all %d requests\n", len(res.Cases))
+		},
+	}
+	registerReportFlags(cmd.Flags())
+
+	return cmd
+}
+
+// runSuite performs every request in order. A failed one is reported as a
+// single run would report it, and the next one is sent regardless: a suite
+// that stopped at the first failure would say nothing about the rest.
+//
+// A request's captures become values for the ones after it only if it passed.
+// A failed request's response is not one to build on, even where the capture
+// itself found something, and so its names are forgotten rather than left with
+// what an earlier request captured under them.
+func runSuite(ctx context.Context, cases []suiteCase, root *pflag.FlagSet, logLevel httpassert.LogLevel) *suiteResult {
+	res := &suiteResult{}
+	vars := map[string]string{}
+	capturedBy := map[string]caseResult{}
+	for i, sc := range cases {
+		logInfo(logLevel, "[#] %s (%d/%d)\n", sc.Name, i+1, len(cases))
+
+		var r *httpassert.Result
+		if sc.Spec != nil {
+			sc, r = resolveSuiteCase(ctx, sc, root, vars, capturedBy)
+		}
+		if r == nil {
+			c := sc.Client
+			c.LogLevel, c.Log = logLevel, logWriter{errPalette}
+			c.Init()
+			r = c.Run(sc.Request, sc.Assertions...)
+		}
+
+		if err := r.Err; err != nil {
+			if exitCodeOf(err) == exitAssertFail || len(r.Attempts) == 0 {
+				errorf("Request %q: %s", sc.Name, err)
+			} else {
+				errorf("Request %q: cannot perform request: %s", sc.Name, err)
+			}
+		}
+		cr := caseResult{sc.Name, r}
+		for _, name := range sc.Defines {
+			delete(vars, name)
+			capturedBy[name] = cr
+		}
+		if r.Err == nil {
+			for _, c := range sc.Captures {
+				vars[c.Name()], _ = c.Value()
+			}
+		}
+		res.Cases = append(res.Cases, cr)
+	}
+
+	return res
+}
+
+// resolveSuiteCase builds a request that interpolates captured values, now that
+// they exist. When it cannot be built, the result says why and nothing is sent.
+//
+// A missing value fails the request with the code of the one that should have
+// captured it: that one is the cause, and a suite that exited 71 here would
+// claim nothing had been sent. A value that makes the request invalid -- a
+// captured "abc" interpolated into assert-status -- is the response's fault,
+// and so is 93.
+func resolveSuiteCase(ctx context.Context, sc suiteCase, root *pflag.FlagSet,
+	vars map[string]string, capturedBy map[string]caseResult,
+) (suiteCase, *httpassert.Result) {
+	for _, name := range sc.Needs {
+		if _, ok := vars[name]; !ok {
+			by := capturedBy[name]
+			return sc, &httpassert.Result{Err: &exitError{exitCodeOf(by.Err), fmt.Sprintf(
+				"not sent: it needs {{%s}}, which %q did not capture because it failed", name, by.Name)}}
+		}
+	}
+
+	built, err := buildSuiteCase(ctx, sc.Spec, root, vars)
+	if err != nil {
+		return sc, &httpassert.Result{Err: &exitError{exitAssertFail, fmt.Sprintf(
+			"not sent: the captured values make it invalid: %s",
+			strings.TrimPrefix(err.Error(), fmt.Sprintf("%q: ", sc.Name)))}}
+	}
+
+	return built, nil
+}
+
+// placeholderRE matches {{NAME}}, a captured value's place in a later request.
+var placeholderRE = regexp.MustCompile(`\{\{([A-Za-z_][A-Za-z0-9_]*)\}\}`) //nolint:forbidigo // a constant, not user input
+
+// deferPlaceholders is the resolver a suite is loaded with: a value that names
+// a captured one cannot be set yet.
+func deferPlaceholders(v string) (string, bool) {
+	return v, !placeholderRE.MatchString(v)
+}
+
+// interpolate is the resolver a request is built with at its turn. The
+// replacement is a single pass, so a captured value that happens to contain
+// {{...}} is sent as it is rather than expanded again.
+func interpolate(vars map[string]string) func(string) (string, bool) {
+	return func(v string) (string, bool) {
+		return placeholderRE.ReplaceAllStringFunc(v, func(m string) string {
+			return vars[m[2:len(m)-2]]
+		}), true
+	}
+}
+
+// interpolateURL is interpolate for the url. A value that lands in a path
+// segment or the query is percent-encoded, so that a captured "a&b" or "a b"
+// stays one value rather than becoming a second query parameter or a broken
+// request. One that makes up the scheme, the host or the start of the path,
+// and one that is an absolute URL itself -- a Location to follow -- is the
+// url, or its base, and goes in as it is.
+func interpolateURL(vars map[string]string) func(string) (string, bool) {
+	return func(v string) (string, bool) {
+		var b strings.Builder
+		last := 0
+		for _, m := range placeholderRE.FindAllStringIndex(v, -1) {
+			b.WriteString(v[last:m[0]])
+			value := vars[v[m[0]+2:m[1]-2]]
+			if inPathOrQuery(b.String()) && !isAbsoluteURL(value) {
+				value = percentEscape(value)
+			}
+			b.WriteString(value)
+			last = m[1]
+		}
+		b.WriteString(v[last:])
+
+		return b.String(), true
+	}
+}
+
+// inPathOrQuery reports whether what follows the start of a url, prefix, is
+// within a path segment or the query, rather than the scheme and host.
+func inPathOrQuery(prefix string) bool {
+	if strings.ContainsAny(prefix, "?#") {
+		return true
+	}
+	_, rest, ok := strings.Cut(prefix, "://")
+
+	return ok && strings.Contains(rest, "/")
+}
+
+func isAbsoluteURL(v string) bool {
+	u, err := url.Parse(v)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// placeholders lists the captured values a request names, each once.
+func placeholders(spec map[string]any) []string {
+	var res []string
+	add := func(v any) {
+		s, ok := v.(string)
+		if !ok {
+			return
+		}
+		for _, m := range placeholderRE.FindAllStringSubmatch(s, -1) {
+			if !slices.Contains(res, m[1]) {
+				res = append(res, m[1])
+			}
+		}
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(spec)) {
+		if k == "name" {
+			continue
+		}
+		if list, ok := spec[k].([]any); ok {
+			for _, v := range list {
+				add(v)
+			}
+			continue
+		}
+		add(spec[k])
+	}
+
+	return res
+}
+
+// loadSuite reads and validates a suite file; "-" reads stdin. root supplies
+// the command line's values for the options a request may override.
+func loadSuite(ctx context.Context, path string, root *pflag.FlagSet) ([]suiteCase, error) {
+	var raw []byte
+	var err error
+	if path == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(path) // #nosec G304 - the caller named the file to read
+	}
+	if err != nil {
+		return nil, invalidf("Cannot read suite file: %s", err)
+	}
+
+	var doc suiteFile
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&doc); err != nil {
+		return nil, invalidf("Invalid suite file %s: %s", path, err)
+	}
+	if len(doc.Requests) == 0 {
+		return nil, invalidf("Invalid suite file %s: no requests; list them under requests:", path)
+	}
+
+	var res []suiteCase
+	seen := map[string]bool{}
+	captured := map[string]bool{}
+	for i, spec := range doc.Requests {
+		sc, err := newSuiteCase(ctx, spec, root)
+		if err != nil {
+			return nil, invalidf("Invalid suite file %s: request %d: %s", path, i+1, err)
+		}
+		if seen[sc.Name] {
+			return nil, invalidf("Invalid suite file %s: request %d: name %q is already taken; "+
+				"the reports tell requests apart by name", path, i+1, sc.Name)
+		}
+		for _, name := range sc.Needs {
+			if !captured[name] {
+				return nil, invalidf("Invalid suite file %s: request %d: %q: {{%s}} is not "+
+					"captured by an earlier request", path, i+1, sc.Name, name)
+			}
+		}
+		seen[sc.Name] = true
+		for _, name := range sc.Defines {
+			captured[name] = true
+		}
+		res = append(res, sc)
+	}
+
+	return res, nil
+}
+
+// newSuiteCase builds one request by setting its keys as flags on a flag set of
+// its own, then running the checks the command line gets. A request that
+// interpolates a captured value is checked but not built; see suiteCase.
+func newSuiteCase(ctx context.Context, spec map[string]any, root *pflag.FlagSet) (suiteCase, error) {
+	return buildSuiteCase(ctx, spec, root, nil)
+}
+
+// buildSuiteCase is newSuiteCase with the captured values to interpolate, or
+// nil while there are none yet. A value that names one it does not have is
+// left unset, and the request is returned unbuilt.
+func buildSuiteCase(ctx context.Context, spec map[string]any, root *pflag.FlagSet,
+	vars map[string]string,
+) (suiteCase, error) {
+	resolve, resolveURL := deferPlaceholders, deferPlaceholders
+	if vars != nil {
+		resolve, resolveURL = interpolate(vars), interpolateURL(vars)
+	}
+
+	name, _ := spec["name"].(string)
+	if name == "" {
+		return suiteCase{}, invalidf("name is missing; every request needs one")
+	}
+	rawURL, _ := spec["url"].(string)
+	if rawURL == "" {
+		return suiteCase{}, invalidf("%q: url is missing", name)
+	}
+	rawURL, resolved := resolveURL(rawURL)
+
+	fs := suiteFlagSet(root)
+	// A key whose value is not set yet may still be an assertion.
+	pendingAssertion := false
+	// Sorted, so that a file with two mistakes reports the same one each run.
+	for _, k := range slices.Sorted(maps.Keys(spec)) {
+		if k == "name" || k == "url" {
+			continue
+		}
+		pending, err := setSuiteKey(fs, k, spec[k], resolve)
+		if err != nil {
+			return suiteCase{}, invalidf("%q: %s", name, err)
+		}
+		if pending {
+			resolved = false
+			pendingAssertion = pendingAssertion ||
+				strings.HasPrefix(k, "assert-") || strings.HasPrefix(k, "capture")
+		}
+	}
+
+	sc := suiteCase{Name: name}
+	var err error
+	for _, check := range []func(*pflag.FlagSet) error{checkRedirectFlags, checkRetryFlags} {
+		if err = check(fs); err != nil {
+			return suiteCase{}, invalidf("%q: %s", name, err)
+		}
+	}
+	if sc.Assertions, err = parseAssertionFlags(fs); err != nil {
+		return suiteCase{}, invalidf("%q: %s", name, err)
+	}
+	if sc.Captures, err = parseCaptureFlags(fs); err != nil {
+		return suiteCase{}, invalidf("%q: %s", name, err)
+	}
+	if sc.Defines, err = captureNames(spec); err != nil {
+		return suiteCase{}, invalidf("%q: %s", name, err)
+	}
+	if len(sc.Assertions) == 0 && len(sc.Captures) == 0 && !pendingAssertion {
+		return suiteCase{}, invalidf("%q: no assertions; give at least one assert-* or "+
+			"capture key (e.g. assert-ok: true)", name)
+	}
+	if !resolved {
+		sc.Needs, sc.Spec = placeholders(spec), spec
+		return sc, nil
+	}
+
+	// After the assertions, so the reports list them in the order of the
+	// command line's dump.
+	for _, c := range sc.Captures {
+		sc.Assertions = append(sc.Assertions, c)
+	}
+	if sc.Client, err = newClient(fs); err != nil {
+		return suiteCase{}, invalidf("%q: %s", name, err)
+	}
+	if sc.Request, err = newRequest(ctx, fs, rawURL); err != nil {
+		return suiteCase{}, invalidf("%q: %s", name, err)
+	}
+
+	return sc, nil
+}
+
+// captureNames lists the names a request captures. It reads the keys rather
+// than the captures built from them, because a capture whose expression
+// interpolates a value is not built until the request is.
+func captureNames(spec map[string]any) ([]string, error) {
+	var res []string
+	for _, k := range []string{"capture", "capture-header", "capture-body"} {
+		list, isList := spec[k].([]any)
+		if !isList && spec[k] != nil {
+			list = []any{spec[k]}
+		}
+		for _, v := range list {
+			s, _ := v.(string)
+			name, _, err := parseCaptureSpec(s)
+			if err != nil {
+				return nil, invalidf("Invalid value for --%s flag: %s", k, err)
+			}
+			res = append(res, name)
+		}
+	}
+
+	return res, nil
+}
+
+// suiteFlagSet declares the keys a request may set. insecure, max-time,
+// maphost, unix-socket, the proxy, resolution and credential options, the TLS
+// options and the protocol switches are persistent flags on the command line, so a request inherits
+// their value there and may override it.
+func suiteFlagSet(root *pflag.FlagSet) *pflag.FlagSet {
+	fs := pflag.NewFlagSet("request", pflag.ContinueOnError)
+	registerRequestFlags(fs)
+	registerAssertionFlags(fs)
+	registerCaptureFlags(fs)
+	rejectRepeats(fs)
+
+	for _, name := range requestSettings {
+		switch root.Lookup(name).Value.Type() {
+		case "bool":
+			v, _ := root.GetBool(name)
+			fs.Bool(name, v, "")
+		case "int":
+			v, _ := root.GetInt(name)
+			fs.Int(name, v, "")
+		case "stringArray":
+			v, _ := root.GetStringArray(name)
+			fs.StringArray(name, v, "")
+		default:
+			v, _ := root.GetString(name)
+			fs.String(name, v, "")
+		}
+	}
+
+	return fs
+}
+
+// suiteKeysHelp is the paragraph of the run help that names the keys a
+// request can set, from the lists suiteFlagSet registers them from.
+func suiteKeysHelp() string {
+	keys := append(requestFlagNames(), requestSettings...)
+	text := "A request can set " + strings.Join(keys, ", ") + " and any --assert-* flag. " +
+		requestSettings[0] + " and the keys after it default to the command line's value."
+
+	var b strings.Builder
+	line := 0
+	for i, word := range strings.Fields(text) {
+		switch {
+		case i == 0:
+		case line+1+len(word) > 79:
+			b.WriteByte('\n')
+			line = 0
+		default:
+			b.WriteByte(' ')
+			line++
+		}
+		b.WriteString(word)
+		line += len(word)
+	}
+
+	return b.String()
+}
+
+// setSuiteKey sets one key of a request as its flag, passing each value through
+// resolve first. A list sets a repeatable flag once per entry; anywhere else it
+// is refused, for the reason checkRepeats refuses a repeated flag. pending says
+// a value was left unset because resolve could not resolve it yet.
+func setSuiteKey(fs *pflag.FlagSet, key string, value any,
+	resolve func(string) (string, bool),
+) (pending bool, err error) {
+	f := fs.Lookup(key)
+	if f == nil {
+		return false, fmt.Errorf("unknown key %q; a request takes name, url, and the long "+
+			"name of a request or assertion flag", key)
+	}
+
+	var vals []string
+	switch v := value.(type) {
+	case []any:
+		if !collects(f.Value.Type()) {
+			return false, fmt.Errorf("%s takes a single value, got a list", key)
+		}
+		for _, e := range v {
+			if !isScalar(e) {
+				return false, fmt.Errorf("%s takes a list of values, got %v", key, e)
+			}
+			vals = append(vals, fmt.Sprint(e))
+		}
+	default:
+		if !isScalar(v) {
+			return false, fmt.Errorf("%s takes a value, got %v", key, v)
+		}
+		vals = []string{fmt.Sprint(v)}
+	}
+
+	for _, v := range vals {
+		v, ok := resolve(v)
+		if !ok {
+			pending = true
+			continue
+		}
+		if err := fs.Set(key, v); err != nil {
+			return false, fmt.Errorf("invalid value for %s: %s", key, err)
+		}
+	}
+
+	return pending, nil
+}
+
+// isScalar reports whether YAML decoded v from a plain value. A missing value
+// is not one: `assert-ok:` with nothing after it asks for nothing, and reading
+// it as an empty string would make it ask for something.
+func isScalar(v any) bool {
+	switch v.(type) {
+	case string, bool, int, int64, uint64, float64:
+		return true
+	}
+
+	return false
+}
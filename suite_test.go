@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/korya/http-assert/httpassert"
+	"github.com/spf13/pflag"
+)
+
+func Test_suiteResult_exitCode(t *testing.T) {
+	t.Parallel()
+
+	ok := (*exitError)(nil)
+	tests := []struct {
+		Name  string
+		Codes []*exitError
+		Want  int
+	}{
+		{"all passed", []*exitError{ok, ok}, exitOK},
+		{"an assertion failed", []*exitError{ok, {exitAssertFail, ""}}, exitAssertFail},
+		{"transport wins, wherever it is", []*exitError{{exitTransportFail, ""}, {exitAssertFail, ""}}, exitTransportFail},
+		{"transport wins, last", []*exitError{{exitAssertFail, ""}, ok, {exitTransportFail, ""}}, exitTransportFail},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			s := &suiteResult{}
+			for _, e := range tc.Codes {
+				r := &httpassert.Result{}
+				if e != nil {
+					r.Err = e
+				}
+				s.Cases = append(s.Cases, caseResult{"x", r})
+			}
+			if got := s.exitCode(); got != tc.Want {
+				t.Errorf("exitCode() = %d, want %d", got, tc.Want)
+			}
+		})
+	}
+}
+
+// rootFlags stands in for the command line a suite runs under.
+func rootFlags(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+
+	fs := pflag.NewFlagSet("root", pflag.ContinueOnError)
+	registerRootFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+func Test_newSuiteCase(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keys are the flags they are named after", func(t *testing.T) {
+		sc, err := newSuiteCase(context.Background(), map[string]any{
+			"name":           "create",
+			"url":            "http://example.com/things",
+			"data":           `{"a": 1}`,
+			"header":         []any{"X-A: 1", "X-B: 2"},
+			"retry":          3,
+			"retry-delay":    "250ms",
+			"assert-status":  201,
+			"assert-ok":      true,
+			"assert-jq":      []any{".a == 1"},
+			"assert-body-eq": `{"a": 1}`,
+		}, rootFlags(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sc.Request.Method != http.MethodPost {
+			t.Errorf("method = %s, want the POST data implies", sc.Request.Method)
+		}
+		if got := sc.Request.Header.Get("X-B"); got != "2" {
+			t.Errorf("X-B = %q, want 2", got)
+		}
+		if sc.Client.Retries != 3 || sc.Client.RetryDelay != 250*time.Millisecond {
+			t.Errorf("retries = %d every %s, want 3 every 250ms", sc.Client.Retries, sc.Client.RetryDelay)
+		}
+
+		var kinds []string
+		for _, a := range sc.Assertions {
+			kinds = append(kinds, a.Kind())
+		}
+		if got, want := strings.Join(kinds, " "), "ok status body jq"; got != want {
+			t.Errorf("assertions = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("a false boolean asks for the opposite assertion", func(t *testing.T) {
+		sc, err := newSuiteCase(context.Background(), map[string]any{
+			"name": "down", "url": "http://example.com/", "assert-ok": false,
+		}, rootFlags(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sc.Assertions[0].Kind(); got != "nok" {
+			t.Errorf("kind = %s, want nok", got)
+		}
+	})
+
+	t.Run("captures are assertions, after the others", func(t *testing.T) {
+		sc, err := newSuiteCase(context.Background(), map[string]any{
+			"name": "login", "url": "http://example.com/login", "assert-ok": true,
+			"capture": "TOKEN=.token", "capture-header": []any{"LOC=Location"},
+		}, rootFlags(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, a := range sc.Assertions {
+			names = append(names, assertionName(a))
+		}
+		if got, want := strings.Join(names, " "), "ok capture[TOKEN] capture[LOC]"; got != want {
+			t.Errorf("assertions = %s, want %s", got, want)
+		}
+		if got := strings.Join(sc.Defines, " "); got != "TOKEN LOC" || len(sc.Captures) != 2 {
+			t.Errorf("defines = %s with %d captures, want TOKEN LOC", got, len(sc.Captures))
+		}
+	})
+
+	t.Run("a request that uses a captured value waits for it", func(t *testing.T) {
+		spec := map[string]any{
+			"name": "me", "url": "http://example.com/users/{{ID}}",
+			"header":        []any{"Authorization: Bearer {{TOKEN}}"},
+			"assert-status": "{{CODE}}",
+		}
+		sc, err := newSuiteCase(context.Background(), spec, rootFlags(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sc.Request != nil || sc.Spec == nil {
+			t.Fatalf("the request was built before its values existed")
+		}
+		if got := strings.Join(sc.Needs, " "); got != "CODE TOKEN ID" {
+			t.Errorf("needs = %s, want CODE TOKEN ID", got)
+		}
+
+		built, err := buildSuiteCase(context.Background(), spec, rootFlags(t), map[string]string{
+			"ID": "7", "TOKEN": "abc", "CODE": "204",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := built.Request.URL.String(); got != "http://example.com/users/7" {
+			t.Errorf("url = %s", got)
+		}
+		if got := built.Request.Header.Get("Authorization"); got != "Bearer abc" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := assertionName(built.Assertions[0]); got != "status" {
+			t.Errorf("assertion = %s, want status", got)
+		}
+	})
+
+	t.Run("the command line is the default", func(t *testing.T) {
+		root := rootFlags(t, "--insecure", "--max-time", "5", "--maphost", "a.example:80=127.0.0.1:1")
+
+		inherit, err := newSuiteCase(context.Background(), map[string]any{
+			"name": "inherit", "url": "http://a.example/", "assert-ok": true,
+		}, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !inherit.Client.SkipSslChecks || inherit.Client.Timeout != 5*time.Second ||
+			len(inherit.Client.HostMappings) != 1 {
+			t.Errorf("client = %+v, want the command line's insecure, max-time and maphost", inherit.Client)
+		}
+
+		override, err := newSuiteCase(context.Background(), map[string]any{
+			"name": "override", "url": "http://b.example/", "assert-ok": true,
+			"insecure": false, "max-time": 1, "maphost": []any{"b.example:80=127.0.0.1:2"},
+		}, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if override.Client.SkipSslChecks || override.Client.Timeout != time.Second ||
+			len(override.Client.HostMappings) != 1 || override.Client.HostMappings[0].Src != "b.example:80" {
+			t.Errorf("client = %+v, want the request's own values", override.Client)
+		}
+	})
+
+	for _, tc := range []struct {
+		Name string
+		Spec map[string]any
+		Want string
+	}{
+		{"no value", map[string]any{"assert-ok": nil}, "assert-ok takes a value"},
+		{"a mapping", map[string]any{"assert-status": map[string]any{"a": 1}}, "assert-status takes a value"},
+		{"a nested list", map[string]any{"assert-jq": []any{[]any{"."}}}, "assert-jq takes a list of values"},
+		{"not a boolean", map[string]any{"assert-ok": "yes"}, "invalid value for assert-ok"},
+		{"a report option", map[string]any{"assert-ok": true, "report": "json"}, `unknown key "report"`},
+		{"a short name", map[string]any{"assert-ok": true, "X": "POST"}, `unknown key "X"`},
+		{"a capture without a name", map[string]any{"capture": []any{".token"}}, "expected NAME=..."},
+		{"a name a shell would refuse", map[string]any{"capture-header": []any{"X-ID=X-Id"}}, `"X-ID" is not a valid name`},
+		{"a name captured twice", map[string]any{"capture": []any{"A=.a"}, "capture-header": []any{"A=X-A"}},
+			"A is already captured"},
+		{"a deferred request still checks what it can", map[string]any{
+			"assert-jq": []any{".[", ".id == {{ID}}"},
+		}, "Invalid value for --assert-jq flag"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			spec := map[string]any{"name": "n", "url": "http://example.com/"}
+			for k, v := range tc.Spec {
+				spec[k] = v
+			}
+
+			_, err := newSuiteCase(context.Background(), spec, rootFlags(t))
+			if err == nil || !strings.Contains(err.Error(), tc.Want) {
+				t.Errorf("err = %v, want it to contain %q", err, tc.Want)
+			}
+			if code := exitCodeOf(err); code != exitBadInvocation {
+				t.Errorf("exit code = %d, want %d", code, exitBadInvocation)
+			}
+		})
+	}
+}
+
+// requestSettings is the one list of them, so each has to be a root flag for
+// a request to take its default from.
+func Test_suiteFlagSet(t *testing.T) {
+	t.Parallel()
+
+	root := rootFlags(t, "--http2", "--resolve", "a.example:80:127.0.0.1")
+	for _, name := range requestSettings {
+		if root.Lookup(name) == nil {
+			t.Errorf("%s is not a root flag", name)
+		}
+	}
+	fs := suiteFlagSet(root)
+	for _, name := range fromResponseExcludes() {
+		if fs.Lookup(name) == nil && name != "all-addresses" {
+			t.Errorf("a request cannot set %s", name)
+		}
+	}
+	if v, _ := fs.GetBool("http2"); !v {
+		t.Error("http2 does not default to the command line's value")
+	}
+	if v, _ := fs.GetStringArray("resolve"); len(v) != 1 {
+		t.Errorf("resolve defaults to %q, not the command line's value", v)
+	}
+	if help := suiteKeysHelp(); !strings.Contains(help, "cookie-jar and any --assert-* flag.") {
+		t.Errorf("the help does not name every key:\n%s", help)
+	}
+}
+
+func Test_interpolate(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]string{
+		"A": "1", "B": "{{A}}", "Q": "a b&c=d?e#f/g",
+		"LOC": "http://example.com/things/42?v=a%20b", "BASE": "https://api.example.com", "PATH": "/things/42",
+	}
+	resolve := interpolate(vars)
+	for in, want := range map[string]string{
+		"{{A}}/{{A}}":  "1/1",
+		"{{B}}":        "{{A}}",
+		"{{ A }}":      "{{ A }}",
+		"{x: {{A}}}":   "{x: 1}",
+		"{{Q}}":        "a b&c=d?e#f/g",
+		"no variables": "no variables",
+	} {
+		if got, _ := resolve(in); got != want {
+			t.Errorf("interpolate(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	// In a path segment or the query a value stays one value, whatever it
+	// holds; the url itself, its base or a Location goes in as it is.
+	resolveURL := interpolateURL(vars)
+	for in, want := range map[string]string{
+		"http://example.com/{{A}}?q={{Q}}&b={{B}}": "http://example.com/1?q=a%20b%26c%3Dd%3Fe%23f%2Fg&b=%7B%7BA%7D%7D",
+		"http://example.com/users/{{Q}}":           "http://example.com/users/a%20b%26c%3Dd%3Fe%23f%2Fg",
+		"{{LOC}}":                                  "http://example.com/things/42?v=a%20b",
+		"{{BASE}}/users/{{Q}}":                     "https://api.example.com/users/a%20b%26c%3Dd%3Fe%23f%2Fg",
+		"{{BASE}}{{PATH}}":                         "https://api.example.com/things/42",
+		"https://api.example.com{{PATH}}":          "https://api.example.com/things/42",
+	} {
+		if got, _ := resolveURL(in); got != want {
+			t.Errorf("interpolateURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+	got, _ := resolveURL("http://example.com/{{A}}?q={{Q}}")
+	u, err := url.Parse(got)
+	if err != nil || u.Query().Get("q") != vars["Q"] || u.Path != "/1" {
+		t.Errorf("%s parses to path %q and q %q: %v", got, u.Path, u.Query().Get("q"), err)
+	}
+}